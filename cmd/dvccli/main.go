@@ -0,0 +1,687 @@
+package main
+
+import (
+	"bytes"
+	"cmp"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+type RPCResponse struct {
+	Result json.RawMessage `json:"result"`
+	Error  interface{}     `json:"error"`
+	ID     interface{}     `json:"id"`
+}
+
+type RESTResponse struct {
+	OK    bool            `json:"ok"`
+	Data  json.RawMessage `json:"data"`
+	Error string          `json:"error"`
+}
+
+type client struct {
+	base  string
+	http  *http.Client
+	token string // admin token, sent as a bearer token
+	idem  string // Idempotency-Key for POST requests
+}
+
+const usage = `Usage: dvccli [flags] <command> [args]
+
+Chain:
+  getinfo                          Network and chain summary
+  getblockcount                    Number of blocks
+  getbestblockhash                 Hash of the tip
+  getmininginfo                    Mining summary
+  getblocktemplateproposal         Pending transactions the next block would
+                                   include and their fees
+  getdeploymentinfo                Soft fork deployments and their activation state
+  getblock <hash|height>           Full block
+  getgovernance                    Governable parameters and current votes
+  getvotesnapshot                  Stake each vote of the current period counts with
+  getsupply                        Minted, burned and circulating supply
+  getstakinginfo [amount] [address]
+                                   Stake and PoS reward figures, the yield of a stake
+                                   of amount (0 for none) and an address's earnings
+  getvalidatorstats <address>      Blocks produced, staking rewards and recent
+                                   participation of an address
+  getfinality [height]             Finalized checkpoint and the latest one, or the
+                                   checkpoint at height with its attestations
+  getevidence                      Double-sign evidence waiting to be slashed
+  submitevidence <json>            Submit {"first": ..., "second": ...} attestations
+                                   of one address for two blocks at one height
+  getdifficulty [intervals]        Difficulty per retarget interval and floor schedule
+  getcharts [days]                 Daily transactions, volume, fees and new addresses
+  decoderawtransaction <hex>       Decode a canonical transaction encoding
+  decodeblock <hex>                Decode a canonical block encoding
+  gettxoutsetinfo                  Number and total value of unspent outputs
+  gettxout <txid> <vout>           An unspent output (utxo networks)
+
+History (archive nodes):
+  getaddresshistory <address> [from-height]
+                                   Confirmed transactions touching an address
+  getbalancehistory <address> [from-height]
+                                   Balance after each block that changed it
+  getbalanceat <address> <height>  Balance after the block at height
+  getrichlist [n]                  Largest balances (default 100)
+  getblockfilter <height>          Address filter of a block
+  getindexinfo                     Build progress of the optional indexes
+
+Event journal (nodes started with -journal):
+  getjournal [from] [limit]        Recorded block and mempool events from seq
+
+Wallet:
+  listwallets                      Wallets on the node with balances
+  createwallet                     Create a new wallet
+  getdepositaddress <reference>    New address tagged with an external reference
+  getbalance <address>             Balance, staked, pending and available amounts
+  validateaddress <address>        Check an address's format and checksum
+  upgradeaddress <address>         Move a legacy wallet to its checksummed address
+  listtransactions <address>       Transactions touching an address
+  getreceivedbyaddress <address> [minconf]
+                                   Total ever received (default minconf 1)
+  getnonce <address>               Last confirmed and next transaction nonce
+  gettransaction <txid>            Transaction with block hash and confirmations
+  getbroadcaststatus <txid>        Peers a pending transaction was sent to
+  rebroadcast <txid>               Announce a pending transaction again
+  listunspent [address]            Spendable outputs of an address, or of all node
+                                   wallets (utxo networks)
+  sendtoaddress <from> <to> <amt> [txid:vout,...|-] [change_address]
+                                   Send coins, optionally spending the given outputs
+                                   ("-" selects automatically) and paying change elsewhere
+  stake <address> <amount>         Stake coins
+  unstake <address> <amount>       Unstake coins
+  delegate <address> <validator> <amount>
+                                   Stake coins behind a validator; the address's
+                                   whole stake moves to it
+  getunbonding <address>           Unstaked coins waiting for release
+  getrestake <address>             Auto-restake policy of a node wallet
+  setrestake <address> <on|off> [threshold]
+                                   Stake released unbonds and PoS rewards again once
+                                   they reach threshold
+  getrewards <address> [from-height] [since] [until]
+                                   Staking rewards paid to an address; dates are
+                                   YYYY-MM-DD or unix times
+  vote <address> <param> <value>   Vote on a governable parameter
+  burn <address> <amount>          Destroy coins
+
+Peers:
+  getpeerinfo                      Connected peers
+  getnetworkinfo                   This node's ID and announced address
+  getnodeaddresses                 Signed peer announcements learned by gossip
+  getsyncstatus                    Block download progress
+  addnode <host:port>              Connect to a peer
+  disconnectnode <host:port>       Disconnect a peer
+
+Admin (needs -admintoken):
+  listbanned                       Banned peer IPs
+  ban <ip>                         Refuse and drop a peer IP until restart
+  unban <ip>                       Lift a ban made with ban
+  getloglevel                      Current log level
+  setloglevel <level>              Change the log level until the settings are reloaded
+  backupdb                         Copy the chain database to <datadir>/backups
+  reindex <received|charts|rewards|archive>
+                                   Rebuild an optional index
+  listapikeys                      Wallet API keys
+  createapikey <addr,...> <scope,...> [send_limit] [name]
+                                   Issue a key for read, send, stake and/or deposit
+                                   on the given wallets; prints the token once
+  revokeapikey <id>                Delete an API key
+
+Raw:
+  rpc <method> [json-params]       Call any JSON-RPC method
+
+Flags:
+`
+
+func main() {
+	rpcAddr := flag.String("rpcaddr", "127.0.0.1:9334", "Node RPC address (host:port)")
+	asJSON := flag.Bool("json", false, "Print raw JSON instead of a table")
+	timeout := flag.Duration("timeout", 30*time.Second, "HTTP request timeout")
+	adminToken := flag.String("admintoken", os.Getenv("DVC_ADMIN_TOKEN"), "Node admin token (default $DVC_ADMIN_TOKEN)")
+	idemKey := flag.String("idempotencykey", "", "Idempotency-Key for sends: repeating the command with the same key sends once")
+	apiKey := flag.String("apikey", os.Getenv("DVC_API_KEY"), "Wallet API key, used instead of -admintoken (default $DVC_API_KEY)")
+	flag.Usage = func() {
+		fmt.Fprint(os.Stderr, usage)
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	args := flag.Args()
+	if len(args) == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	c := &client{
+		base:  "http://" + *rpcAddr,
+		http:  &http.Client{Timeout: *timeout},
+		token: cmp.Or(*apiKey, *adminToken),
+		idem:  *idemKey,
+	}
+
+	result, err := run(c, args[0], args[1:])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		os.Exit(1)
+	}
+	if *asJSON {
+		printJSON(result)
+	} else {
+		printTable(result)
+	}
+}
+
+func run(c *client, cmd string, args []string) (json.RawMessage, error) {
+	switch cmd {
+	case "getinfo":
+		return c.get("/api/chain/info", nil)
+	case "getblockcount", "getbestblockhash", "getmininginfo", "getpeerinfo",
+		"getnetworkinfo", "getnodeaddresses", "getsyncstatus", "gettxoutsetinfo",
+		"getindexinfo", "getblocktemplateproposal", "getdeploymentinfo", "getevidence":
+		return c.call(cmd, nil)
+	case "gettxout":
+		if err := need(args, 2, "gettxout <txid> <vout>"); err != nil {
+			return nil, err
+		}
+		vout, err := strconv.ParseUint(args[1], 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("invalid vout: %s", args[1])
+		}
+		return c.call(cmd, map[string]interface{}{"txid": args[0], "vout": vout})
+	case "listunspent":
+		q := url.Values{}
+		if len(args) > 0 {
+			q.Set("address", args[0])
+		}
+		return c.get("/api/wallet/listunspent", q)
+	case "decoderawtransaction", "decodeblock":
+		if err := need(args, 1, cmd+" <hex>"); err != nil {
+			return nil, err
+		}
+		return c.call(cmd, map[string]string{"hex": args[0]})
+	case "getblock":
+		if err := need(args, 1, "getblock <hash|height>"); err != nil {
+			return nil, err
+		}
+		q := url.Values{}
+		if _, err := strconv.ParseUint(args[0], 10, 64); err == nil {
+			q.Set("height", args[0])
+		} else {
+			q.Set("hash", args[0])
+		}
+		return c.get("/api/chain/block", q)
+	case "getgovernance":
+		return c.get("/api/chain/governance", nil)
+	case "getvotesnapshot":
+		return c.get("/api/chain/governance/snapshot", nil)
+	case "getsupply":
+		return c.get("/api/chain/supply", nil)
+	case "getstakinginfo":
+		q := url.Values{}
+		if len(args) > 0 && args[0] != "0" {
+			q.Set("amount", args[0])
+		}
+		if len(args) > 1 {
+			q.Set("address", args[1])
+		}
+		return c.get("/api/staking/info", q)
+	case "getvalidatorstats":
+		if err := need(args, 1, "getvalidatorstats <address>"); err != nil {
+			return nil, err
+		}
+		return c.get("/api/validators/"+url.PathEscape(args[0])+"/stats", nil)
+	case "getfinality":
+		q := url.Values{}
+		if len(args) > 0 {
+			q.Set("height", args[0])
+		}
+		return c.get("/api/chain/finality", q)
+	case "submitevidence":
+		if err := need(args, 1, "submitevidence <json>"); err != nil {
+			return nil, err
+		}
+		if !json.Valid([]byte(args[0])) {
+			return nil, fmt.Errorf("evidence must be JSON")
+		}
+		return c.call(cmd, json.RawMessage(args[0]))
+	case "getdifficulty":
+		q := url.Values{}
+		if len(args) > 0 {
+			q.Set("intervals", args[0])
+		}
+		return c.get("/api/chain/difficulty", q)
+	case "getaddresshistory", "getbalancehistory":
+		if err := need(args, 1, cmd+" <address> [from-height]"); err != nil {
+			return nil, err
+		}
+		q := url.Values{"address": {args[0]}}
+		if len(args) > 1 {
+			q.Set("from", args[1])
+		}
+		if cmd == "getaddresshistory" {
+			return c.get("/api/address/history", q)
+		}
+		return c.get("/api/address/balances", q)
+	case "getbalanceat":
+		if err := need(args, 2, "getbalanceat <address> <height>"); err != nil {
+			return nil, err
+		}
+		return c.get("/api/address/balanceat", url.Values{"address": {args[0]}, "height": {args[1]}})
+	case "getrichlist":
+		q := url.Values{}
+		if len(args) > 0 {
+			q.Set("limit", args[0])
+		}
+		return c.get("/api/chain/richlist", q)
+	case "getblockfilter":
+		if err := need(args, 1, "getblockfilter <height>"); err != nil {
+			return nil, err
+		}
+		return c.get("/api/chain/filter", url.Values{"height": {args[0]}})
+	case "getjournal":
+		q := url.Values{}
+		if len(args) > 0 {
+			q.Set("from", args[0])
+		}
+		if len(args) > 1 {
+			q.Set("limit", args[1])
+		}
+		return c.get("/api/journal", q)
+	case "getcharts":
+		q := url.Values{}
+		if len(args) > 0 {
+			q.Set("days", args[0])
+		}
+		return c.get("/api/chain/charts", q)
+	case "listwallets":
+		return c.get("/api/wallet/list", nil)
+	case "createwallet":
+		return c.post("/api/wallet/create", nil)
+	case "getdepositaddress":
+		if err := need(args, 1, "getdepositaddress <reference>"); err != nil {
+			return nil, err
+		}
+		return c.post("/api/wallet/deposit-address", map[string]string{"reference": args[0]})
+	case "getbalance":
+		if err := need(args, 1, "getbalance <address>"); err != nil {
+			return nil, err
+		}
+		return c.get("/api/wallet/balance", url.Values{"address": {args[0]}})
+	case "getunbonding":
+		if err := need(args, 1, "getunbonding <address>"); err != nil {
+			return nil, err
+		}
+		return c.get("/api/wallet/unbonding", url.Values{"address": {args[0]}})
+	case "getrestake":
+		if err := need(args, 1, "getrestake <address>"); err != nil {
+			return nil, err
+		}
+		return c.get("/api/wallet/restake", url.Values{"address": {args[0]}})
+	case "setrestake":
+		if err := need(args, 2, "setrestake <address> <on|off> [threshold]"); err != nil {
+			return nil, err
+		}
+		if args[1] != "on" && args[1] != "off" {
+			return nil, fmt.Errorf("want on or off, got %q", args[1])
+		}
+		threshold := 0.0
+		if len(args) > 2 {
+			t, err := strconv.ParseFloat(args[2], 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid threshold: %s", args[2])
+			}
+			threshold = t
+		}
+		return c.post("/api/wallet/restake", map[string]interface{}{
+			"address": args[0], "enabled": args[1] == "on", "threshold": threshold,
+		})
+	case "getrewards":
+		if err := need(args, 1, "getrewards <address> [from-height] [since] [until]"); err != nil {
+			return nil, err
+		}
+		q := url.Values{"address": {args[0]}}
+		for i, name := range []string{"from", "since", "until"} {
+			if len(args) > i+1 {
+				q.Set(name, args[i+1])
+			}
+		}
+		return c.get("/api/wallet/rewards", q)
+	case "validateaddress":
+		if err := need(args, 1, "validateaddress <address>"); err != nil {
+			return nil, err
+		}
+		return c.call(cmd, map[string]string{"address": args[0]})
+	case "upgradeaddress":
+		if err := need(args, 1, "upgradeaddress <address>"); err != nil {
+			return nil, err
+		}
+		return c.post("/api/wallet/upgrade", map[string]string{"address": args[0]})
+	case "getreceivedbyaddress":
+		if err := need(args, 1, "getreceivedbyaddress <address> [minconf]"); err != nil {
+			return nil, err
+		}
+		params := map[string]interface{}{"address": args[0]}
+		if len(args) > 1 {
+			minconf, err := strconv.Atoi(args[1])
+			if err != nil {
+				return nil, fmt.Errorf("invalid minconf: %s", args[1])
+			}
+			params["minconf"] = minconf
+		}
+		return c.call(cmd, params)
+	case "getnonce":
+		if err := need(args, 1, "getnonce <address>"); err != nil {
+			return nil, err
+		}
+		return c.call(cmd, map[string]string{"address": args[0]})
+	case "listtransactions":
+		if err := need(args, 1, "listtransactions <address>"); err != nil {
+			return nil, err
+		}
+		return c.get("/api/wallet/transactions", url.Values{"address": {args[0]}})
+	case "gettransaction":
+		if err := need(args, 1, "gettransaction <txid>"); err != nil {
+			return nil, err
+		}
+		return c.get("/api/wallet/tx", url.Values{"txid": {args[0]}})
+	case "getbroadcaststatus":
+		if err := need(args, 1, "getbroadcaststatus <txid>"); err != nil {
+			return nil, err
+		}
+		return c.get("/api/tx/"+url.PathEscape(args[0])+"/broadcast", nil)
+	case "rebroadcast":
+		if err := need(args, 1, "rebroadcast <txid>"); err != nil {
+			return nil, err
+		}
+		return c.post("/api/tx/"+url.PathEscape(args[0])+"/rebroadcast", nil)
+	case "sendtoaddress":
+		if err := need(args, 3, "sendtoaddress <from> <to> <amount> [txid:vout,...|-] [change_address]"); err != nil {
+			return nil, err
+		}
+		amount, err := strconv.ParseFloat(args[2], 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid amount: %s", args[2])
+		}
+		body := map[string]interface{}{"from": args[0], "to": args[1], "amount": amount}
+		if len(args) > 3 && args[3] != "-" {
+			var inputs []map[string]interface{}
+			for _, op := range strings.Split(args[3], ",") {
+				txid, v, _ := strings.Cut(op, ":")
+				vout, err := strconv.ParseUint(v, 10, 32)
+				if err != nil || txid == "" {
+					return nil, fmt.Errorf("invalid input %q (want txid:vout)", op)
+				}
+				inputs = append(inputs, map[string]interface{}{"txid": txid, "vout": vout})
+			}
+			body["inputs"] = inputs
+		}
+		if len(args) > 4 {
+			body["change_address"] = args[4]
+		}
+		return c.post("/api/wallet/send", body)
+	case "stake", "unstake", "burn":
+		if err := need(args, 2, cmd+" <address> <amount>"); err != nil {
+			return nil, err
+		}
+		amount, err := strconv.ParseFloat(args[1], 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid amount: %s", args[1])
+		}
+		return c.post("/api/wallet/"+cmd, map[string]interface{}{
+			"address": args[0], "amount": amount,
+		})
+	case "delegate":
+		if err := need(args, 3, "delegate <address> <validator> <amount>"); err != nil {
+			return nil, err
+		}
+		amount, err := strconv.ParseFloat(args[2], 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid amount: %s", args[2])
+		}
+		return c.post("/api/wallet/delegate", map[string]interface{}{
+			"address": args[0], "validator": args[1], "amount": amount,
+		})
+	case "vote":
+		if err := need(args, 3, "vote <address> <param> <value>"); err != nil {
+			return nil, err
+		}
+		value, err := strconv.ParseFloat(args[2], 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid value: %s", args[2])
+		}
+		return c.post("/api/wallet/vote", map[string]interface{}{
+			"address": args[0], "param": args[1], "value": value,
+		})
+	case "addnode", "disconnectnode":
+		if err := need(args, 1, cmd+" <host:port>"); err != nil {
+			return nil, err
+		}
+		return c.call(cmd, map[string]string{"address": args[0]})
+	case "listbanned":
+		return c.get("/api/admin/banlist", nil)
+	case "ban", "unban":
+		if err := need(args, 1, cmd+" <ip>"); err != nil {
+			return nil, err
+		}
+		return c.post("/api/admin/"+cmd, map[string]string{"ip": args[0]})
+	case "getloglevel":
+		return c.get("/api/admin/loglevel", nil)
+	case "setloglevel":
+		if err := need(args, 1, "setloglevel <debug|info|warn|error>"); err != nil {
+			return nil, err
+		}
+		return c.post("/api/admin/loglevel", map[string]string{"level": args[0]})
+	case "backupdb":
+		return c.post("/api/admin/backup", nil)
+	case "reindex":
+		if err := need(args, 1, "reindex <received|charts|rewards|archive>"); err != nil {
+			return nil, err
+		}
+		return c.post("/api/admin/reindex", map[string]string{"index": args[0]})
+	case "listapikeys":
+		return c.get("/api/admin/apikeys", nil)
+	case "createapikey":
+		if err := need(args, 2, "createapikey <addr,...> <scope,...> [send_limit] [name]"); err != nil {
+			return nil, err
+		}
+		req := map[string]interface{}{
+			"addresses": strings.Split(args[0], ","),
+			"scopes":    strings.Split(args[1], ","),
+		}
+		if len(args) > 2 {
+			limit, err := strconv.ParseFloat(args[2], 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid send_limit %q", args[2])
+			}
+			req["send_limit"] = limit
+		}
+		if len(args) > 3 {
+			req["name"] = args[3]
+		}
+		return c.post("/api/admin/apikeys", req)
+	case "revokeapikey":
+		if err := need(args, 1, "revokeapikey <id>"); err != nil {
+			return nil, err
+		}
+		resp, err := c.send("DELETE", "/api/admin/apikeys/"+url.PathEscape(args[0]), nil)
+		if err != nil {
+			return nil, err
+		}
+		return decodeREST(resp)
+	case "rpc":
+		if err := need(args, 1, "rpc <method> [json-params]"); err != nil {
+			return nil, err
+		}
+		var params interface{}
+		if len(args) > 1 {
+			params = json.RawMessage(args[1])
+		}
+		return c.call(args[0], params)
+	default:
+		return nil, fmt.Errorf("unknown command %q (run dvccli -h)", cmd)
+	}
+}
+
+func need(args []string, n int, usage string) error {
+	if len(args) < n {
+		return fmt.Errorf("usage: dvccli %s", usage)
+	}
+	return nil
+}
+
+func (c *client) call(method string, params interface{}) (json.RawMessage, error) {
+	reqBody, _ := json.Marshal(map[string]interface{}{
+		"method": method, "params": params, "id": 1,
+	})
+	resp, err := c.send("POST", "/rpc", reqBody)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	var rr RPCResponse
+	if err := json.Unmarshal(body, &rr); err != nil {
+		return nil, fmt.Errorf("bad response: %s", strings.TrimSpace(string(body)))
+	}
+	if rr.Error != nil {
+		return nil, fmt.Errorf("%v", rr.Error)
+	}
+	return rr.Result, nil
+}
+
+func (c *client) get(path string, q url.Values) (json.RawMessage, error) {
+	if len(q) > 0 {
+		path += "?" + q.Encode()
+	}
+	resp, err := c.send("GET", path, nil)
+	if err != nil {
+		return nil, err
+	}
+	return decodeREST(resp)
+}
+
+func (c *client) post(path string, body interface{}) (json.RawMessage, error) {
+	data, _ := json.Marshal(body)
+	resp, err := c.send("POST", path, data)
+	if err != nil {
+		return nil, err
+	}
+	return decodeREST(resp)
+}
+
+func (c *client) send(method, path string, body []byte) (*http.Response, error) {
+	req, err := http.NewRequest(method, c.base+path, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+	if c.idem != "" && method == "POST" && path != "/rpc" {
+		req.Header.Set("Idempotency-Key", c.idem)
+	}
+	return c.http.Do(req)
+}
+
+func decodeREST(resp *http.Response) (json.RawMessage, error) {
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	var rr RESTResponse
+	if err := json.Unmarshal(body, &rr); err != nil {
+		return nil, fmt.Errorf("bad response (%d): %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	if !rr.OK {
+		return nil, fmt.Errorf("%s", rr.Error)
+	}
+	return rr.Data, nil
+}
+
+func printJSON(raw json.RawMessage) {
+	var buf bytes.Buffer
+	if json.Indent(&buf, raw, "", "  ") != nil {
+		fmt.Println(string(raw))
+		return
+	}
+	fmt.Println(buf.String())
+}
+
+// printTable renders objects as key/value rows and arrays of objects as a
+// table with one column per key. Anything else falls back to JSON.
+func printTable(raw json.RawMessage) {
+	var v interface{}
+	if err := json.Unmarshal(raw, &v); err != nil {
+		fmt.Println(string(raw))
+		return
+	}
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	defer tw.Flush()
+
+	switch val := v.(type) {
+	case map[string]interface{}:
+		for _, k := range sortedKeys(val) {
+			fmt.Fprintf(tw, "%s\t%s\n", k, cell(val[k]))
+		}
+	case []interface{}:
+		if len(val) == 0 {
+			return
+		}
+		first, ok := val[0].(map[string]interface{})
+		if !ok {
+			for _, item := range val {
+				fmt.Fprintln(tw, cell(item))
+			}
+			return
+		}
+		keys := sortedKeys(first)
+		fmt.Fprintln(tw, strings.ToUpper(strings.Join(keys, "\t")))
+		for _, item := range val {
+			row, _ := item.(map[string]interface{})
+			cols := make([]string, len(keys))
+			for i, k := range keys {
+				cols[i] = cell(row[k])
+			}
+			fmt.Fprintln(tw, strings.Join(cols, "\t"))
+		}
+	default:
+		fmt.Fprintln(tw, cell(val))
+	}
+}
+
+func cell(v interface{}) string {
+	switch val := v.(type) {
+	case nil:
+		return "-"
+	case string:
+		return val
+	case float64:
+		return strconv.FormatFloat(val, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(val)
+	default:
+		b, _ := json.Marshal(val)
+		return string(b)
+	}
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
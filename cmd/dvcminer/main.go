@@ -1,92 +1,87 @@
 package main
 
 import (
-	"bytes"
 	"crypto/sha256"
+	"devinsidercoin/internal/logging"
+	"devinsidercoin/pkg/client"
 	"encoding/hex"
-	"encoding/json"
 	"flag"
 	"fmt"
-	"io"
-	"log"
 	"math/big"
-	"net/http"
+	"os"
 	"time"
 )
 
-type BlockHeader struct {
-	Version    uint32 `json:"version"`
-	PrevHash   string `json:"prev_hash"`
-	MerkleRoot string `json:"merkle_root"`
-	Timestamp  int64  `json:"timestamp"`
-	Bits       uint32 `json:"bits"`
-	Nonce      uint64 `json:"nonce"`
-	Height     uint64 `json:"height"`
-}
-
-type Block struct {
-	Header       BlockHeader     `json:"header"`
-	Transactions json.RawMessage `json:"transactions"`
-	Hash         string          `json:"hash"`
-}
+// logger tags the miner's log lines like the node's components.
+var logger = logging.For("MINER")
 
-type RPCResponse struct {
-	Result json.RawMessage `json:"result"`
-	Error  interface{}     `json:"error"`
-	ID     interface{}     `json:"id"`
-}
+// hashRateInterval is how often the miner logs its hashrate while working
+// on one block.
+const hashRateInterval = 10 * time.Second
 
 func main() {
 	rpcAddr := flag.String("rpcaddr", "127.0.0.1:9334", "Node RPC address (host:port)")
 	minerAddr := flag.String("address", "", "Mining reward address")
+	worker := flag.String("worker", "", "Pool worker name: mine shares at the difficulty the node sets (node started with -poolconfig)")
+	logLevel := flag.String("loglevel", "info", "Log level: debug, info, warn, error")
+	logJSON := flag.Bool("logjson", false, "Emit logs as JSON lines")
+	logFile := flag.String("logfile", "", "Write logs to this file instead of stderr")
 	flag.Parse()
 
-	if *minerAddr == "" {
-		log.Fatal("Mining address required. Use -address <your_dvc_address>")
+	logCloser, err := logging.Setup(logging.Options{Level: *logLevel, JSON: *logJSON, File: *logFile})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to set up logging: %v\n", err)
+		os.Exit(1)
 	}
+	defer logCloser.Close()
 
-	log.Printf("=== DevInsiderCoin Miner ===")
-	log.Printf("  RPC:     %s", *rpcAddr)
-	log.Printf("  Address: %s", *minerAddr)
+	if *minerAddr == "" {
+		logging.Fatal(logger, "mining address required; use -address <your_dvc_address>")
+	}
+	logger.Info("DevInsiderCoin miner starting", "rpc", *rpcAddr, "address", *minerAddr, "worker", *worker)
 
-	rpcURL := fmt.Sprintf("http://%s/rpc", *rpcAddr)
+	node := client.New(*rpcAddr)
+	if *worker != "" {
+		mineShares(node, *worker, *minerAddr)
+		return
+	}
 	totalMined := 0
+	var floor floorWatch
 
 	for {
-		tmpl, err := getBlockTemplate(rpcURL, *minerAddr)
+		tmpl, err := node.GetBlockTemplate(*minerAddr)
 		if err != nil {
-			log.Printf("[MINER] Error getting template: %v (retrying in 5s)", err)
+			logger.Warn("cannot get block template, retrying in 5s", "err", err)
 			time.Sleep(5 * time.Second)
 			continue
 		}
+		floor.check(node, tmpl.Header.Height)
 
-		log.Printf("[MINER] Mining block #%d (bits: 0x%08x)...",
-			tmpl.Header.Height, tmpl.Header.Bits)
+		logger.Info("mining block", "height", tmpl.Header.Height, "bits", fmt.Sprintf("0x%08x", tmpl.Header.Bits))
 
 		startTime := time.Now()
+		lastReport := startTime
 
 		for nonce := uint64(0); nonce < ^uint64(0); nonce++ {
 			tmpl.Header.Nonce = nonce
 			hash := computeHash(&tmpl.Header)
 
-			if nonce%500000 == 0 && nonce > 0 {
-				elapsed := time.Since(startTime).Seconds()
-				rate := uint64(float64(nonce) / elapsed)
-				fmt.Printf("\r  Hashrate: %d H/s | Nonce: %d", rate, nonce)
+			if nonce%500000 == 0 && nonce > 0 && time.Since(lastReport) >= hashRateInterval {
+				lastReport = time.Now()
+				logger.Info("hashrate", "hps", uint64(float64(nonce)/time.Since(startTime).Seconds()), "nonce", nonce)
 			}
 
 			if checkPoW(hash, tmpl.Header.Bits) {
 				tmpl.Hash = hash
 				elapsed := time.Since(startTime)
-				fmt.Println()
-				log.Printf("[MINER] ✓ Block #%d found! Hash: %s (%.2fs, nonce: %d)",
-					tmpl.Header.Height, hash[:16]+"...", elapsed.Seconds(), nonce)
+				logger.Info("block found", "height", tmpl.Header.Height, "hash", hash,
+					"elapsed", elapsed.Round(time.Millisecond), "nonce", nonce)
 
-				if err := submitBlock(rpcURL, tmpl); err != nil {
-					log.Printf("[MINER] Submit error: %v", err)
+				if _, err := node.SubmitBlock(tmpl); err != nil {
+					logger.Error("block rejected", "height", tmpl.Header.Height, "err", err)
 				} else {
 					totalMined++
-					log.Printf("[MINER] Block accepted! Total mined: %d", totalMined)
+					logger.Info("block accepted", "height", tmpl.Header.Height, "total_mined", totalMined)
 				}
 				break
 			}
@@ -94,7 +89,98 @@ func main() {
 	}
 }
 
-func computeHash(h *BlockHeader) string {
+// shareJobSeconds is how long a pool worker mines one job before fetching
+// a fresh one with the latest transactions.
+const shareJobSeconds = 30
+
+// mineShares mines against a pool mode node: each job is worked to the
+// worker's share difficulty, and every share found is submitted, until
+// the job is stale or has been mined for shareJobSeconds.
+func mineShares(node *client.Client, worker, address string) {
+	shares, blocks := 0, 0
+	for {
+		job, err := node.GetShareJob(worker, address)
+		if err != nil {
+			logger.Warn("cannot get share job, retrying in 5s", "err", err)
+			time.Sleep(5 * time.Second)
+			continue
+		}
+		tmpl, shareBits := job.Block, job.ShareBits
+		logger.Info("mining block", "height", tmpl.Header.Height, "bits", fmt.Sprintf("0x%08x", tmpl.Header.Bits),
+			"share_bits", fmt.Sprintf("0x%08x", shareBits))
+
+		deadline := time.Now().Add(shareJobSeconds * time.Second)
+		for nonce := uint64(0); nonce < ^uint64(0); nonce++ {
+			if nonce%100000 == 0 && time.Now().After(deadline) {
+				break
+			}
+			tmpl.Header.Nonce = nonce
+			hash := computeHash(&tmpl.Header)
+			if !checkPoW(hash, shareBits) {
+				continue
+			}
+			tmpl.Hash = hash
+			res, err := node.SubmitShare(worker, tmpl)
+			if err != nil {
+				logger.Warn("share rejected", "err", err)
+				break
+			}
+			shares++
+			if res.Retargeted {
+				logger.Info("share difficulty retargeted", "from", fmt.Sprintf("0x%08x", shareBits),
+					"to", fmt.Sprintf("0x%08x", res.ShareBits))
+			}
+			shareBits = res.ShareBits
+			if res.Block {
+				blocks++
+				logger.Info("block found", "height", res.Height, "hash", hash, "shares", shares, "blocks", blocks)
+				break
+			}
+			if res.BlockError != "" {
+				logger.Error("block rejected", "height", tmpl.Header.Height, "err", res.BlockError)
+				break
+			}
+		}
+	}
+}
+
+// floorWarnBlocks is how far ahead the miner warns about a floor tightening.
+const floorWarnBlocks = 1000
+
+// floorWatch logs the progressive difficulty floor at startup, when it is
+// about to tighten and when it has.
+type floorWatch struct {
+	info   *client.MiningInfo
+	warned bool
+}
+
+func (f *floorWatch) check(node *client.Client, height uint64) {
+	if f.info != nil && (f.info.NextFloorHeight == 0 || height < f.info.NextFloorHeight) {
+		if left := f.info.NextFloorHeight - height; f.info.NextFloorHeight > 0 && left <= floorWarnBlocks && !f.warned {
+			logger.Warn("difficulty floor tightens soon; minimum difficulty doubles", "blocks_left", left,
+				"height", f.info.NextFloorHeight, "floor_bits", fmt.Sprintf("0x%08x", f.info.FloorBits),
+				"next_floor_bits", fmt.Sprintf("0x%08x", f.info.NextFloorBits))
+			f.warned = true
+		}
+		return
+	}
+	info, err := node.GetMiningInfo()
+	if err != nil {
+		return
+	}
+	if f.info != nil {
+		logger.Info("difficulty floor tightened", "epoch", info.FloorEpoch, "floor_bits", fmt.Sprintf("0x%08x", info.FloorBits))
+	} else {
+		logger.Info("difficulty floor", "epoch", info.FloorEpoch, "floor_bits", fmt.Sprintf("0x%08x", info.FloorBits))
+	}
+	if info.NextFloorHeight > 0 {
+		logger.Info("next floor tightening", "height", info.NextFloorHeight, "blocks_left", info.NextFloorHeight-height,
+			"next_floor_bits", fmt.Sprintf("0x%08x", info.NextFloorBits))
+	}
+	f.info, f.warned = info, false
+}
+
+func computeHash(h *client.BlockHeader) string {
 	buf := make([]byte, 0, 128)
 	buf = appendU32(buf, h.Version)
 	buf = append(buf, decodeHexPad(h.PrevHash, 32)...)
@@ -146,43 +232,3 @@ func appendU64(buf []byte, v uint64) []byte {
 	return append(buf, byte(v), byte(v>>8), byte(v>>16), byte(v>>24),
 		byte(v>>32), byte(v>>40), byte(v>>48), byte(v>>56))
 }
-
-func getBlockTemplate(rpcURL, addr string) (*Block, error) {
-	params, _ := json.Marshal(map[string]string{"miner_address": addr})
-	reqBody, _ := json.Marshal(map[string]interface{}{
-		"method": "getblocktemplate", "params": json.RawMessage(params), "id": 1,
-	})
-	resp, err := http.Post(rpcURL, "application/json", bytes.NewReader(reqBody))
-	if err != nil {
-		return nil, err
-	}
-	defer resp.Body.Close()
-	body, _ := io.ReadAll(resp.Body)
-	var rr RPCResponse
-	json.Unmarshal(body, &rr)
-	if rr.Error != nil {
-		return nil, fmt.Errorf("%v", rr.Error)
-	}
-	var block Block
-	json.Unmarshal(rr.Result, &block)
-	return &block, nil
-}
-
-func submitBlock(rpcURL string, block *Block) error {
-	blockJSON, _ := json.Marshal(block)
-	reqBody, _ := json.Marshal(map[string]interface{}{
-		"method": "submitblock", "params": json.RawMessage(blockJSON), "id": 2,
-	})
-	resp, err := http.Post(rpcURL, "application/json", bytes.NewReader(reqBody))
-	if err != nil {
-		return err
-	}
-	defer resp.Body.Close()
-	body, _ := io.ReadAll(resp.Body)
-	var rr RPCResponse
-	json.Unmarshal(body, &rr)
-	if rr.Error != nil {
-		return fmt.Errorf("%v", rr.Error)
-	}
-	return nil
-}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"devinsidercoin/internal/blockchain"
+	"devinsidercoin/internal/logging"
+	"devinsidercoin/internal/network"
+	"devinsidercoin/internal/wallet"
+)
+
+// startAttester signs every finality checkpoint with each local wallet
+// that has stake in it, and gossips the attestations.
+func startAttester(chain *blockchain.Blockchain, node *network.Node, wallets *wallet.WalletManager) {
+	if chain.Config.FinalityInterval == 0 {
+		return
+	}
+	logger := logging.For("FINALITY")
+	chain.Events.Subscribe(func(ev blockchain.Event) {
+		height := ev.Block.Header.Height
+		if height == 0 || height%chain.Config.FinalityInterval != 0 {
+			return
+		}
+		go func() {
+			cp := chain.GetCheckpoint(height)
+			if cp == nil || cp.Hash != ev.Block.Hash {
+				return
+			}
+			for addr := range cp.Weights {
+				if _, ok := wallets.GetWallet(addr); !ok {
+					continue
+				}
+				a := blockchain.Attestation{Height: cp.Height, Hash: cp.Hash, Address: addr}
+				if err := wallets.SignAttestation(&a, chain.Config.NetworkID); err != nil {
+					logger.Error("failed to sign checkpoint", "height", height, "address", addr, "err", err)
+					continue
+				}
+				added, err := chain.AddAttestation(a)
+				if err != nil {
+					logger.Error("own attestation rejected", "height", height, "address", addr, "err", err)
+					continue
+				}
+				if added {
+					node.BroadcastAttestation(&a)
+					logger.Info("signed checkpoint", "height", height, "address", addr)
+				}
+			}
+		}()
+	}, blockchain.EventBlockConnected)
+}
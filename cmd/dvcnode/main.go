@@ -1,49 +1,109 @@
 package main
 
 import (
+	"devinsidercoin/internal/address"
 	"devinsidercoin/internal/blockchain"
 	"devinsidercoin/internal/config"
+	"devinsidercoin/internal/logging"
 	"devinsidercoin/internal/network"
+	"devinsidercoin/internal/pool"
 	"devinsidercoin/internal/rpc"
 	"devinsidercoin/internal/wallet"
+	"devinsidercoin/internal/webhook"
 	"flag"
 	"fmt"
-	"log"
+	"net"
 	"os"
 	"os/signal"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"syscall"
+	"time"
 )
 
+// version is set at build time with -ldflags "-X main.version=1.2.0" and
+// announced to peers in the user agent.
+var version = "dev"
+
 func main() {
-	networkName := flag.String("network", "mainnet", "Network: mainnet or testnet")
+	networkName := flag.String("network", "mainnet", "Network: mainnet, testnet or regtest")
 	dataDir := flag.String("datadir", "", "Data directory (default: ./data/<network>)")
 	p2pPort := flag.Int("port", 0, "P2P port (default from config)")
 	rpcPort := flag.Int("rpcport", 0, "RPC/HTTP port (default from config)")
+	rpcBind := flag.String("rpcbind", "", "Interface the RPC/HTTP server listens on, e.g. 127.0.0.1 (default all)")
+	miningAddr := flag.String("miningaddr", "", "Also serve the mining JSON-RPC (/rpc), and nothing else, on this host:port")
+	poolConfig := flag.String("poolconfig", "", "Pool settings TOML; enables getsharejob and submitshare with per-worker share difficulty")
 	addPeers := flag.String("addpeer", "", "Comma-separated peer addresses (host:port)")
-	configPath := flag.String("config", "", "Path to network config JSON")
+	externalAddr := flag.String("externaladdr", "", "Address (host:port) announced to peers for discovery")
+	tlsCert := flag.String("p2ptlscert", "", "Certificate for mutual TLS between peers (private networks; needs -p2ptlskey and p2p_tls_pins)")
+	tlsKey := flag.String("p2ptlskey", "", "Private key for -p2ptlscert")
+	archive := flag.Bool("archive", false, "Keep every optional index (address and balance history, rich list, block filters) and advertise the archive service")
+	journal := flag.Bool("journal", false, "Record block and mempool events to <datadir>/journal.log for external indexers")
+	checkBlocks := flag.Int("checkblocks", blockchain.DefaultCheckBlocks, "Re-verify this many of the latest blocks at startup (0 to skip)")
+	checkLevel := flag.Int("checklevel", blockchain.DefaultCheckLevel, "How thoroughly -checkblocks verifies (0-3): 0 links and indexes, 1 headers and proof of work, 2 transactions, 3 balances")
+	minChainWork := flag.String("minchainwork", "", "Answer wallet APIs with \"node syncing\" until the chain has this much work, in hex (default from config)")
+	minChainHeight := flag.Uint64("minchainheight", 0, "Answer wallet APIs with \"node syncing\" until the chain reaches this height (default from config)")
+	checkpoints := flag.String("checkpoints", "", "Comma-separated height:hash block checkpoints, added to the network's")
+	overrideConfig := flag.Bool("overrideconfig", false, "Start even if consensus parameters differ from the ones the chain was created with")
+	settingsPath := flag.String("settings", "", "Reloadable node settings JSON (default: <datadir>/node.json if present)")
+	configPath := flag.String("config", "", "Path to custom network config JSON (overrides -network)")
+	logLevel := flag.String("loglevel", "info", "Log level: debug, info, warn, error")
+	logJSON := flag.Bool("logjson", false, "Emit logs as JSON lines")
+	logFile := flag.String("logfile", "", "Write logs to this file instead of stderr")
+	logMaxSize := flag.Int("logmaxsize", 100, "Rotate the log file after this many MB")
+	logBackups := flag.Int("logbackups", 5, "Number of rotated log files to keep")
 	flag.Parse()
 
-	// Find config file
-	cfgPath := *configPath
-	if cfgPath == "" {
-		exe, _ := os.Executable()
-		baseDir := filepath.Dir(exe)
-		cfgPath = filepath.Join(baseDir, "networks", *networkName+".json")
-		if _, err := os.Stat(cfgPath); err != nil {
-			cfgPath = filepath.Join("networks", *networkName+".json")
-		}
+	logCloser, err := logging.Setup(logging.Options{
+		Level:      *logLevel,
+		JSON:       *logJSON,
+		File:       *logFile,
+		MaxSizeMB:  *logMaxSize,
+		MaxBackups: *logBackups,
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to set up logging: %v\n", err)
+		os.Exit(1)
 	}
+	defer logCloser.Close()
+	logger := logging.For("NODE")
+	p2pLog := logging.For("P2P")
 
-	cfg, err := config.LoadConfig(cfgPath)
+	// Built-in networks are embedded; -config is only needed for custom ones.
+	var cfg *config.NetworkConfig
+	if *configPath != "" {
+		cfg, err = config.LoadConfig(*configPath)
+	} else {
+		cfg, err = config.LoadNetwork(*networkName)
+	}
 	if err != nil {
-		log.Fatalf("Failed to load config %s: %v", cfgPath, err)
+		logging.Fatal(logger, "failed to load config", "err", err)
+	}
+	if *minChainWork != "" {
+		if _, err := config.ParseChainWork(*minChainWork); err != nil {
+			logging.Fatal(logger, "invalid -minchainwork", "err", err)
+		}
+		cfg.MinChainWork = *minChainWork
+	}
+	if *minChainHeight > 0 {
+		cfg.MinChainHeight = *minChainHeight
+	}
+	if *checkpoints != "" {
+		extra, err := config.ParseCheckpoints(*checkpoints)
+		if err != nil {
+			logging.Fatal(logger, "invalid -checkpoints", "err", err)
+		}
+		if cfg.Checkpoints == nil {
+			cfg.Checkpoints = make(map[uint64]string)
+		}
+		for height, hash := range extra {
+			cfg.Checkpoints[height] = hash
+		}
 	}
 
-	log.Printf("=== DevInsiderCoin Node ===")
-	log.Printf("Network: %s (%s)", cfg.Name, cfg.Ticker)
-	log.Printf("Consensus: %s | Algorithm: %s", cfg.ConsensusType, cfg.Algorithm)
+	logger.Info("DevInsiderCoin node starting", "version", version, "network", cfg.Name, "ticker", cfg.Ticker,
+		"consensus", cfg.ConsensusType, "algorithm", cfg.Algorithm)
 
 	// Data directory
 	ddir := *dataDir
@@ -53,61 +113,196 @@ func main() {
 	os.MkdirAll(ddir, 0755)
 
 	// Initialize blockchain
-	chain := blockchain.NewBlockchain(cfg, ddir)
+	chain, err := blockchain.NewBlockchain(cfg, ddir, blockchain.Options{
+		OverrideConfig: *overrideConfig,
+		Archive:        *archive,
+		Journal:        *journal,
+		CheckBlocks:    *checkBlocks,
+		CheckLevel:     *checkLevel,
+	})
+	if err != nil {
+		logging.Fatal(logger, "failed to load blockchain", "err", err)
+	}
 
 	// Initialize wallet manager
 	wallets := wallet.NewWalletManager(filepath.Join(ddir, "wallets"), cfg.AddressPrefix)
 
 	// Initialize P2P node
 	node := network.NewNode(cfg, chain)
+	node.Identity, err = network.LoadIdentity(filepath.Join(ddir, "node_key"))
+	if err != nil {
+		logging.Fatal(logger, "failed to load node key", "err", err)
+	}
+	node.ExternalAddr = *externalAddr
+	node.UserAgent = "/dvcnode:" + version + "/"
+	if *archive {
+		node.Services |= network.ServiceArchive
+	}
+	if *tlsCert != "" || *tlsKey != "" {
+		pin, err := node.EnableTLS(*tlsCert, *tlsKey)
+		if err != nil {
+			logging.Fatal(logger, "failed to load P2P TLS certificate", "err", err)
+		}
+		p2pLog.Info("mutual TLS enabled; peers must list this pin in p2p_tls_pins", "pin", pin)
+	}
 	port := cfg.P2PPort
 	if *p2pPort > 0 {
 		port = *p2pPort
 	}
 	if err := node.Start(port); err != nil {
-		log.Fatalf("Failed to start P2P: %v", err)
+		logging.Fatal(logger, "failed to start P2P", "err", err)
 	}
 
 	// Connect to peers
-	if *addPeers != "" {
-		for _, addr := range strings.Split(*addPeers, ",") {
+	connectPeers := func(addrs []string) {
+		for _, addr := range addrs {
 			addr = strings.TrimSpace(addr)
-			if addr == "" {
+			if addr == "" || node.IsConnected(addr) {
 				continue
 			}
-			log.Printf("[P2P] Connecting to peer: %s", addr)
+			p2pLog.Info("connecting to peer", "peer", addr)
 			if err := node.ConnectPeer(addr); err != nil {
-				log.Printf("[P2P] Failed to connect to %s: %v", addr, err)
+				p2pLog.Warn("failed to connect", "peer", addr, "err", err)
 			}
 		}
 	}
-
 	// Start RPC/HTTP server
 	rPort := cfg.RPCPort
 	if *rpcPort > 0 {
 		rPort = *rpcPort
 	}
+	hooks := webhook.New()
+	var shares *pool.VarDiff
+	if *poolConfig != "" {
+		pc, err := pool.LoadConfig(*poolConfig, cfg.MinDifficultyBits)
+		if err != nil {
+			logging.Fatal(logger, "failed to load pool config", "err", err)
+		}
+		shares = pool.NewVarDiff(pc.VarDiff)
+		logger.Info("pool mode", "vardiff", pc.Enabled, "start_bits", fmt.Sprintf("0x%08x", pc.VarDiff.StartBits),
+			"target_shares_per_minute", pc.VarDiff.TargetSharesPerMinute)
+	}
 	srv := &rpc.Server{
 		Chain:   chain,
 		Node:    node,
 		Wallets: wallets,
-		Addr:    fmt.Sprintf(":%d", rPort),
+		Keys:    wallet.NewKeyStore(filepath.Join(ddir, "wallets")),
+		Hooks:   hooks,
+		Pool:    shares,
+		Addr:    net.JoinHostPort(*rpcBind, strconv.Itoa(rPort)),
+
+		MiningAddr: *miningAddr,
 	}
+
+	// Webhook endpoints, the hot/cold sweep and the event queue follow the
+	// settings file.
+	sweep := newSweeper(chain, node, wallets, hooks)
+	go sweep.run()
+	queue := newEventQueue(chain)
+	startAttester(chain, node, wallets)
+	startRestaker(chain, node, wallets)
+
+	// Operational settings, reloaded on SIGHUP
+	sPath := *settingsPath
+	if sPath == "" {
+		sPath = filepath.Join(ddir, "node.json")
+		if _, err := os.Stat(sPath); err != nil {
+			sPath = ""
+		}
+	}
+	applySettings := func() {
+		if sPath == "" {
+			return
+		}
+		st, err := config.LoadSettings(sPath)
+		if err != nil {
+			logger.Error("failed to load settings", "path", sPath, "err", err)
+			return
+		}
+		if sw := st.Sweep; sw != nil {
+			if err := address.Validate(cfg.AddressPrefix, sw.ColdAddress); err != nil {
+				logger.Error("failed to load settings", "path", sPath, "err", "sweep cold_address: "+err.Error())
+				return
+			}
+		}
+		if st.LogLevel != "" {
+			if err := logging.SetLevel(st.LogLevel); err != nil {
+				logger.Warn("invalid log level in settings", "err", err)
+			}
+		}
+		node.SetBanlist(st.Banlist)
+		node.SetPeerKeys(st.PeerKeys)
+		node.SetTLSPins(st.P2PTLSPins)
+		srv.SetRateLimit(st.RPCRateLimit, st.RPCRateBurst)
+		srv.SetAdminToken(st.AdminToken)
+		srv.SetRequireAPIKey(st.RequireKey)
+		connectPeers(st.Peers)
+		hooks.Configure(st.Webhooks, st.WebhookSecret)
+		sweep.setPolicy(st.Sweep)
+		if err := queue.configure(st.EventQueue); err != nil {
+			logger.Error("event queue not configured", "err", err)
+		}
+		chain.SetRelayPolicy(cfg.RelayPolicy().Override(st.Relay))
+		logger.Info("settings applied", "path", sPath, "log_level", logging.Level(),
+			"banned", len(st.Banlist), "rpc_rate_limit", st.RPCRateLimit,
+			"webhooks", len(st.Webhooks), "sweep", st.Sweep != nil, "relay_policy", st.Relay != nil,
+			"event_queue", st.EventQueue != nil, "admin_api", st.AdminToken != "", "require_api_key", st.RequireKey)
+	}
+	applySettings()
+	if *addPeers != "" {
+		connectPeers(strings.Split(*addPeers, ","))
+	}
+
 	go func() {
 		if err := srv.Start(); err != nil {
-			log.Fatalf("RPC server error: %v", err)
+			logging.Fatal(logger, "RPC server error", "err", err)
 		}
 	}()
 
-	log.Printf("=== Node running ===")
-	log.Printf("  P2P:  :%d", port)
-	log.Printf("  RPC:  http://localhost:%d/rpc", rPort)
-	log.Printf("  API:  http://localhost:%d/api/", rPort)
-	log.Printf("  Data: %s", ddir)
+	// Expire stale mempool entries and rebroadcast our own wallets'
+	// unconfirmed transactions so they aren't lost when peers drop them.
+	chain.Events.Subscribe(func(ev blockchain.Event) {
+		logger.Info("mempool tx expired", "txid", ev.Tx.TxID, "from", ev.Tx.From)
+	}, blockchain.EventTxExpired)
+	chain.Events.Subscribe(func(ev blockchain.Event) {
+		if _, ok := wallets.GetWallet(ev.Tx.From); ok {
+			logger.Warn("double spend attempt from local wallet", "txid", ev.Tx.TxID,
+				"from", ev.Tx.From, "conflicts", ev.Conflicts)
+		}
+	}, blockchain.EventDoubleSpend)
+	go func() {
+		expireTicker := time.NewTicker(time.Minute)
+		rebroadcastTicker := time.NewTicker(10 * time.Minute)
+		for {
+			select {
+			case now := <-expireTicker.C:
+				chain.ExpireMempool(now)
+			case now := <-rebroadcastTicker.C:
+				for _, tx := range chain.PendingSince(now.Add(-10 * time.Minute)) {
+					if _, ok := wallets.GetWallet(tx.From); ok {
+						node.BroadcastTx(&tx)
+					}
+				}
+			}
+		}
+	}()
+
+	logger.Info("node running", "p2p", fmt.Sprintf(":%d", port),
+		"rpc", fmt.Sprintf("http://localhost:%d/rpc", rPort),
+		"api", fmt.Sprintf("http://localhost:%d/api/", rPort),
+		"datadir", ddir)
 
-	// Wait for shutdown signal
+	// Wait for shutdown signal; SIGHUP reloads settings
 	sigCh := make(chan os.Signal, 1)
-	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
-	<-sigCh
-	log.Println("Shutting down...")
+	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP)
+	for sig := range sigCh {
+		if sig == syscall.SIGHUP {
+			logger.Info("SIGHUP received, reloading settings")
+			applySettings()
+			continue
+		}
+		break
+	}
+	logger.Info("shutting down")
+	node.Close("shutting down")
 }
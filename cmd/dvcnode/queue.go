@@ -0,0 +1,72 @@
+package main
+
+import (
+	"devinsidercoin/internal/blockchain"
+	"devinsidercoin/internal/config"
+	"devinsidercoin/internal/mq"
+	"devinsidercoin/internal/webhook"
+	"encoding/json"
+	"sync"
+	"time"
+)
+
+// eventQueue forwards block and mempool events to the broker named in the
+// settings' event_queue. The broker can be changed or removed on SIGHUP.
+type eventQueue struct {
+	pub *mq.Publisher
+
+	mu     sync.Mutex
+	prefix string // empty while no broker is configured
+}
+
+// queueEvent is the data of a published message, like a journal entry.
+type queueEvent struct {
+	Block  *blockchain.Block       `json:"block,omitempty"`
+	Tx     *blockchain.Transaction `json:"tx,omitempty"`
+	Reason string                  `json:"reason,omitempty"` // for tx_removed: conflict or expired
+}
+
+func newEventQueue(chain *blockchain.Blockchain) *eventQueue {
+	q := &eventQueue{pub: mq.New()}
+	chain.Events.Subscribe(q.publish, blockchain.EventBlockConnected, blockchain.EventTxAdded,
+		blockchain.EventTxExpired, blockchain.EventTxRemoved)
+	return q
+}
+
+// configure switches to the broker in c; nil stops publishing.
+func (q *eventQueue) configure(c *config.EventQueue) error {
+	kind, servers, prefix := "", []string(nil), ""
+	if c != nil {
+		kind, servers, prefix = c.Kind, c.Servers, c.TopicPrefix
+	}
+	if err := q.pub.Configure(kind, servers); err != nil {
+		return err
+	}
+	q.mu.Lock()
+	q.prefix = prefix
+	q.mu.Unlock()
+	return nil
+}
+
+func (q *eventQueue) publish(ev blockchain.Event) {
+	q.mu.Lock()
+	prefix := q.prefix
+	q.mu.Unlock()
+	if prefix == "" {
+		return
+	}
+	typ, data, key := ev.Type, queueEvent{Block: ev.Block, Tx: ev.Tx, Reason: ev.Reason}, ""
+	if ev.Type == blockchain.EventTxExpired {
+		typ, data.Reason = blockchain.EventTxRemoved, blockchain.RemovedExpired
+	}
+	if ev.Block != nil {
+		key = ev.Block.Hash
+	} else if ev.Tx != nil {
+		key = ev.Tx.TxID
+	}
+	msg, err := json.Marshal(webhook.Payload{Event: string(typ), Timestamp: time.Now().Unix(), Data: data})
+	if err != nil {
+		return
+	}
+	q.pub.Publish(prefix+"."+string(typ), key, msg)
+}
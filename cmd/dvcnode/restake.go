@@ -0,0 +1,94 @@
+package main
+
+import (
+	"devinsidercoin/internal/blockchain"
+	"devinsidercoin/internal/logging"
+	"devinsidercoin/internal/network"
+	"devinsidercoin/internal/wallet"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// restaker stakes the released unbonds and PoS rewards of wallets with an
+// auto-restake policy, once enough has come in since the last restake.
+type restaker struct {
+	chain   *blockchain.Blockchain
+	node    *network.Node
+	wallets *wallet.WalletManager
+	logger  *slog.Logger
+	mu      sync.Mutex // one block at a time
+}
+
+func startRestaker(chain *blockchain.Blockchain, node *network.Node, wallets *wallet.WalletManager) {
+	r := &restaker{chain: chain, node: node, wallets: wallets, logger: logging.For("RESTAKE")}
+	chain.Events.Subscribe(func(ev blockchain.Event) {
+		go r.blockConnected(ev.Block, ev.Released)
+	}, blockchain.EventBlockConnected)
+}
+
+// blockConnected credits each policy with what block paid its wallet and
+// restakes those that reached their threshold.
+func (r *restaker) blockConnected(block *blockchain.Block, released []blockchain.Unbond) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for addr := range r.wallets.Restaking() {
+		var credit int64
+		for _, u := range released {
+			if u.Address == addr {
+				credit += blockchain.ToUnits(u.Amount)
+			}
+		}
+		for _, tx := range block.Transactions {
+			if tx.Type != "pos_reward" {
+				continue
+			}
+			for _, out := range tx.Outputs {
+				if out.Address == addr {
+					credit += blockchain.ToUnits(out.Amount)
+				}
+			}
+		}
+		if credit == 0 {
+			continue
+		}
+		p, ok := r.wallets.CreditRestake(addr, blockchain.FromUnits(credit))
+		if !ok || p.Pending < p.Threshold || p.Pending < r.chain.Config.MinStakeAmount {
+			continue
+		}
+		amount, err := r.restake(addr, p.Pending)
+		if err != nil {
+			r.logger.Warn("restake failed, retrying after the next credit",
+				"address", addr, "amount", p.Pending, "err", err)
+			continue
+		}
+		r.wallets.DebitRestake(addr, amount)
+	}
+}
+
+// restake stakes amount from addr, or its spendable balance if that is
+// less, and returns what it staked.
+func (r *restaker) restake(addr string, amount float64) (float64, error) {
+	amount = min(amount, r.chain.GetSpendable(addr))
+	tx := blockchain.Transaction{
+		Version:   blockchain.TxVersionCanonical,
+		Type:      "stake",
+		From:      addr,
+		Amount:    amount,
+		Timestamp: time.Now().Unix(),
+	}
+	tx.TxID = tx.ComputeTxID()
+	err := r.chain.FundTransaction(&tx, nil, "")
+	if err == nil {
+		err = r.wallets.SignTx(&tx, r.chain.Config.NetworkID)
+	}
+	if err == nil {
+		err = r.chain.AddToMempool(tx)
+	}
+	if err != nil {
+		return 0, err
+	}
+	r.node.BroadcastTx(&tx)
+	r.logger.Info("restaked", "txid", tx.TxID, "address", addr, "amount", amount)
+	return amount, nil
+}
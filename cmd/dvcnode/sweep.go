@@ -0,0 +1,111 @@
+package main
+
+import (
+	"devinsidercoin/internal/blockchain"
+	"devinsidercoin/internal/config"
+	"devinsidercoin/internal/logging"
+	"devinsidercoin/internal/network"
+	"devinsidercoin/internal/wallet"
+	"devinsidercoin/internal/webhook"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// sweepFee is the fee paid by each sweep transfer, matching wallet sends.
+const sweepFee = 0.001
+
+// sweeper periodically moves funds above a threshold from hot deposit
+// wallets to a cold address. The policy comes from node settings and can
+// be replaced on SIGHUP.
+type sweeper struct {
+	chain   *blockchain.Blockchain
+	node    *network.Node
+	wallets *wallet.WalletManager
+	hooks   *webhook.Notifier
+	logger  *slog.Logger
+
+	mu     sync.Mutex
+	policy *config.SweepPolicy
+	reset  chan struct{}
+}
+
+func newSweeper(chain *blockchain.Blockchain, node *network.Node,
+	wallets *wallet.WalletManager, hooks *webhook.Notifier) *sweeper {
+	return &sweeper{
+		chain:   chain,
+		node:    node,
+		wallets: wallets,
+		hooks:   hooks,
+		logger:  logging.For("SWEEP"),
+		reset:   make(chan struct{}, 1),
+	}
+}
+
+// setPolicy installs p (nil disables sweeping) and restarts the schedule.
+func (s *sweeper) setPolicy(p *config.SweepPolicy) {
+	s.mu.Lock()
+	s.policy = p
+	s.mu.Unlock()
+	select {
+	case s.reset <- struct{}{}:
+	default:
+	}
+}
+
+func (s *sweeper) run() {
+	for {
+		s.mu.Lock()
+		p := s.policy
+		s.mu.Unlock()
+
+		var tick <-chan time.Time
+		var timer *time.Timer
+		if p != nil {
+			timer = time.NewTimer(time.Duration(p.IntervalMinutes) * time.Minute)
+			tick = timer.C
+		}
+		select {
+		case <-tick:
+			s.sweep(p)
+		case <-s.reset:
+			if timer != nil {
+				timer.Stop()
+			}
+		}
+	}
+}
+
+func (s *sweeper) sweep(p *config.SweepPolicy) {
+	for _, hot := range p.HotAddresses {
+		if _, ok := s.wallets.GetWallet(hot); !ok {
+			s.logger.Warn("hot address has no local wallet, skipping", "address", hot)
+			continue
+		}
+		amount := s.chain.GetSpendable(hot) - p.Threshold - sweepFee
+		if amount < s.chain.RelayPolicy().MinOutputAmount {
+			continue
+		}
+		tx := blockchain.NewTransferTransaction(hot, p.ColdAddress, amount, sweepFee, "")
+		err := s.chain.FundTransaction(&tx, nil, "")
+		if err == nil {
+			err = s.wallets.SignTx(&tx, s.chain.Config.NetworkID)
+		}
+		if err == nil {
+			err = s.chain.AddToMempool(tx)
+		}
+		if err != nil {
+			s.logger.Error("sweep failed", "from", hot, "amount", amount, "err", err)
+			s.hooks.Notify("sweep_failed", map[string]interface{}{
+				"from": hot, "to": p.ColdAddress, "amount": amount, "error": err.Error(),
+			})
+			continue
+		}
+		s.node.BroadcastTx(&tx)
+		s.logger.Info("swept hot wallet", "txid", tx.TxID, "from", hot,
+			"to", p.ColdAddress, "amount", amount)
+		s.hooks.Notify("sweep", map[string]interface{}{
+			"txid": tx.TxID, "from": hot, "to": p.ColdAddress, "amount": amount, "fee": tx.Fee,
+		})
+	}
+}
@@ -0,0 +1,202 @@
+package main
+
+import (
+	"devinsidercoin/internal/blockchain"
+	"devinsidercoin/internal/config"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// allocList collects repeated -premine address=amount flags.
+type allocList []config.GenesisAllocation
+
+func (a *allocList) String() string {
+	parts := make([]string, len(*a))
+	for i, al := range *a {
+		parts[i] = fmt.Sprintf("%s=%g", al.Address, al.Amount)
+	}
+	return strings.Join(parts, ",")
+}
+
+func (a *allocList) Set(v string) error {
+	addr, amt, ok := strings.Cut(v, "=")
+	if !ok || addr == "" {
+		return fmt.Errorf("expected address=amount, got %q", v)
+	}
+	amount, err := strconv.ParseFloat(amt, 64)
+	if err != nil || amount <= 0 {
+		return fmt.Errorf("invalid premine amount %q", amt)
+	}
+	*a = append(*a, config.GenesisAllocation{Address: addr, Amount: amount})
+	return nil
+}
+
+// cmdGenesis builds a network manifest from a base network plus overrides
+// and prints the resulting genesis block hash.
+func cmdGenesis(args []string) error {
+	fs := flag.NewFlagSet("genesis", flag.ExitOnError)
+	base := fs.String("base", "testnet", "Built-in network to start from, or path to a JSON manifest")
+	name := fs.String("name", "", "Network name (required)")
+	ticker := fs.String("ticker", "", "Ticker symbol")
+	prefix := fs.String("prefix", "", "Address prefix (defaults to ticker)")
+	networkID := fs.Uint("network-id", 0, "Network ID")
+	supply := fs.Float64("supply", 0, "Max supply")
+	reward := fs.Float64("reward", 0, "Initial block reward")
+	powShare := fs.Float64("pow-share", -1, "PoW share of the block reward (0..1)")
+	consensus := fs.String("consensus", "", "Consensus type: pow, pos or pow+pos")
+	feePolicy := fs.String("fee-policy", "", "Transaction fees: miner or burn")
+	txModel := fs.String("tx-model", "", "Transaction model: account or utxo (active from genesis)")
+	halving := fs.Uint64("halving", 0, "Halving interval in blocks")
+	floorCurve := fs.String("floor-curve", "", "Progressive difficulty floor: geometric, linear or none")
+	floorRatio := fs.Float64("floor-ratio", 0, "Floor step per epoch: target divisor (geometric) or added difficulty (linear)")
+	blockTime := fs.Int("block-time", 0, "Target block time in seconds")
+	p2pPort := fs.Int("p2p-port", 0, "Default P2P port")
+	rpcPort := fs.Int("rpc-port", 0, "Default RPC port")
+	timestamp := fs.String("timestamp", "", "Genesis timestamp, RFC3339 (default: now, truncated to the hour)")
+	message := fs.String("message", "", "Genesis message")
+	out := fs.String("out", "", "Output file (default: networks/<slug>.json)")
+	var premine allocList
+	fs.Var(&premine, "premine", "Genesis allocation address=amount (repeatable)")
+	fs.Parse(args)
+
+	if *name == "" {
+		return fmt.Errorf("-name is required")
+	}
+
+	var cfg *config.NetworkConfig
+	var err error
+	if strings.HasSuffix(*base, ".json") {
+		cfg, err = config.LoadConfig(*base)
+	} else {
+		cfg, err = config.LoadNetwork(*base)
+	}
+	if err != nil {
+		return err
+	}
+
+	cfg.Name = *name
+	if *ticker != "" {
+		cfg.Ticker = *ticker
+		cfg.AddressPrefix = *ticker
+	}
+	if *prefix != "" {
+		cfg.AddressPrefix = *prefix
+	}
+	if *networkID != 0 {
+		cfg.NetworkID = uint32(*networkID)
+	}
+	if *supply > 0 {
+		cfg.MaxSupply = *supply
+	}
+	if *reward > 0 {
+		cfg.InitialReward = *reward
+	}
+	if *powShare >= 0 {
+		if *powShare > 1 {
+			return fmt.Errorf("-pow-share must be between 0 and 1")
+		}
+		cfg.POWRewardShare = *powShare
+		cfg.POSRewardShare = 1 - *powShare
+	}
+	if *consensus != "" {
+		switch *consensus {
+		case blockchain.ConsensusPoW, blockchain.ConsensusPoS, blockchain.ConsensusHybrid:
+			cfg.ConsensusType = *consensus
+		default:
+			return fmt.Errorf("invalid -consensus %q", *consensus)
+		}
+	}
+	if *feePolicy != "" {
+		if *feePolicy != blockchain.FeePolicyMiner && *feePolicy != blockchain.FeePolicyBurn {
+			return fmt.Errorf("invalid -fee-policy %q", *feePolicy)
+		}
+		cfg.FeePolicy = *feePolicy
+	}
+	if *txModel != "" {
+		if *txModel != config.TxModelAccount && *txModel != config.TxModelUTXO {
+			return fmt.Errorf("invalid -tx-model %q", *txModel)
+		}
+		cfg.TxModel = *txModel
+		cfg.UTXOActivationHeight = 0
+	}
+	if *halving > 0 {
+		cfg.HalvingInterval = *halving
+	}
+	if *floorCurve != "" {
+		switch *floorCurve {
+		case blockchain.FloorGeometric, blockchain.FloorLinear, blockchain.FloorNone:
+			cfg.DifficultyFloorCurve = *floorCurve
+		default:
+			return fmt.Errorf("invalid -floor-curve %q", *floorCurve)
+		}
+	}
+	if *floorRatio > 0 {
+		cfg.DifficultyFloorRatio = *floorRatio
+	}
+	if cfg.DifficultyFloorRatio != 0 && cfg.DifficultyFloorCurve != blockchain.FloorLinear && cfg.DifficultyFloorRatio <= 1 {
+		return fmt.Errorf("-floor-ratio must be above 1 for a geometric curve")
+	}
+	if *blockTime > 0 {
+		cfg.BlockTimeSeconds = *blockTime
+	}
+	if *p2pPort > 0 {
+		cfg.P2PPort = *p2pPort
+	}
+	if *rpcPort > 0 {
+		cfg.RPCPort = *rpcPort
+	}
+	if *timestamp != "" {
+		if _, err := time.Parse(time.RFC3339, *timestamp); err != nil {
+			return fmt.Errorf("invalid -timestamp: %w", err)
+		}
+		cfg.GenesisTimestamp = *timestamp
+	} else {
+		cfg.GenesisTimestamp = time.Now().UTC().Truncate(time.Hour).Format(time.RFC3339)
+	}
+	if *message != "" {
+		cfg.GenesisMessage = *message
+	}
+	cfg.GenesisAllocations = premine
+	cfg.TimestampRulesHeight = 1
+
+	var premined float64
+	for _, a := range premine {
+		premined += a.Amount
+	}
+	if premined > cfg.MaxSupply {
+		return fmt.Errorf("premine %.8f exceeds max supply %.8f", premined, cfg.MaxSupply)
+	}
+
+	genesis, err := blockchain.CreateGenesisBlock(cfg)
+	if err != nil {
+		return err
+	}
+	cfg.GenesisHash = genesis.Hash
+
+	path := *out
+	if path == "" {
+		slug := strings.ToLower(strings.Join(strings.Fields(cfg.Name), "-"))
+		path = filepath.Join("networks", slug+".json")
+	}
+	data, err := json.MarshalIndent(cfg, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0644); err != nil {
+		return err
+	}
+
+	fmt.Printf("network:      %s (%s)\n", cfg.Name, cfg.Ticker)
+	fmt.Printf("manifest:     %s\n", path)
+	fmt.Printf("timestamp:    %s\n", cfg.GenesisTimestamp)
+	fmt.Printf("premine:      %.8f in %d allocation(s)\n", premined, len(premine))
+	fmt.Printf("merkle root:  %s\n", genesis.Header.MerkleRoot)
+	fmt.Printf("genesis hash: %s\n", genesis.Hash)
+	return nil
+}
@@ -0,0 +1,242 @@
+package main
+
+import (
+	"bytes"
+	"devinsidercoin/internal/blockchain"
+	"devinsidercoin/internal/storage"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"text/tabwriter"
+)
+
+const usage = `Usage: dvctool [flags] <command> [args]
+
+Offline database and network utility. Stop the node before using the
+database commands.
+
+Commands:
+  stats                         Bucket key counts and sizes
+  dump <height> [to-height]     Print one block or a range as JSON
+  verify                        Cross-check block, hash and tx indexes
+  extract <from> <to> <file>    Write a block range to a JSON file
+  compact <file>                Write a compacted copy of the database
+  utxo-snapshot [file]          Preview the outputs activating the utxo model
+                                would create from current balances
+  genesis [flags]               Generate a network manifest and genesis hash
+                                (dvctool genesis -h for its flags)
+  p2p-cert <dir> [name]         Write a self-signed peer TLS key pair to dir
+                                and print its pin for p2p_tls_pins
+
+Flags:
+`
+
+func main() {
+	dataDir := flag.String("datadir", "data/mainnet", "Node data directory containing blockchain.db")
+	flag.Usage = func() {
+		fmt.Fprint(os.Stderr, usage)
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	args := flag.Args()
+	if len(args) == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	if err := run(*dataDir, args[0], args[1:]); err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(dataDir, cmd string, args []string) error {
+	switch cmd {
+	case "genesis":
+		return cmdGenesis(args)
+	case "p2p-cert":
+		if len(args) < 1 {
+			return fmt.Errorf("usage: dvctool p2p-cert <dir> [name]")
+		}
+		name := "dvcnode"
+		if len(args) > 1 {
+			name = args[1]
+		}
+		return cmdP2PCert(args[0], name)
+	}
+
+	store, err := storage.OpenReadOnly(filepath.Join(dataDir, "blockchain.db"))
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	switch cmd {
+	case "stats":
+		return cmdStats(store)
+	case "dump":
+		if len(args) < 1 {
+			return fmt.Errorf("usage: dvctool dump <height> [to-height]")
+		}
+		from, to, err := parseRange(args[0], args...)
+		if err != nil {
+			return err
+		}
+		return cmdDump(store, from, to)
+	case "verify":
+		return cmdVerify(store)
+	case "extract":
+		if len(args) < 3 {
+			return fmt.Errorf("usage: dvctool extract <from> <to> <file>")
+		}
+		from, to, err := parseRange(args[0], args[:2]...)
+		if err != nil {
+			return err
+		}
+		return cmdExtract(store, from, to, args[2])
+	case "compact":
+		if len(args) < 1 {
+			return fmt.Errorf("usage: dvctool compact <file>")
+		}
+		return cmdCompact(store, args[0])
+	case "utxo-snapshot":
+		out := ""
+		if len(args) > 0 {
+			out = args[0]
+		}
+		return cmdUTXOSnapshot(store, out)
+	default:
+		return fmt.Errorf("unknown command %q (run dvctool -h)", cmd)
+	}
+}
+
+func parseRange(first string, args ...string) (uint64, uint64, error) {
+	from, err := strconv.ParseUint(first, 10, 64)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid height: %s", first)
+	}
+	to := from
+	if len(args) > 1 {
+		to, err = strconv.ParseUint(args[1], 10, 64)
+		if err != nil {
+			return 0, 0, fmt.Errorf("invalid height: %s", args[1])
+		}
+	}
+	if to < from {
+		return 0, 0, fmt.Errorf("range end %d is below start %d", to, from)
+	}
+	return from, to, nil
+}
+
+func cmdStats(store *storage.Store) error {
+	stats, err := store.BucketStats()
+	if err != nil {
+		return err
+	}
+	info, err := os.Stat(store.Path)
+	if err != nil {
+		return err
+	}
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "BUCKET\tKEYS\tLEAF BYTES\tDEPTH")
+	for _, st := range stats {
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%d\n", st.Name, st.Keys, st.LeafBytes, st.Depth)
+	}
+	tw.Flush()
+	fmt.Printf("\nfile: %s (%d bytes)\nbest height: %d\n", store.Path, info.Size(), store.GetBestHeight())
+	return nil
+}
+
+func cmdDump(store *storage.Store, from, to uint64) error {
+	for h := from; h <= to; h++ {
+		data, err := store.GetBlockByHeight(h)
+		if err != nil {
+			return err
+		}
+		var buf bytes.Buffer
+		json.Indent(&buf, data, "", "  ")
+		fmt.Println(buf.String())
+	}
+	return nil
+}
+
+func cmdVerify(store *storage.Store) error {
+	problems, err := store.VerifyIndexes()
+	if err != nil {
+		return err
+	}
+	for _, p := range problems {
+		fmt.Println(p)
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("%d problem(s) found", len(problems))
+	}
+	fmt.Printf("OK: %d blocks, indexes consistent\n", store.GetBlockCount())
+	return nil
+}
+
+func cmdExtract(store *storage.Store, from, to uint64, out string) error {
+	blocks := make([]json.RawMessage, 0, to-from+1)
+	for h := from; h <= to; h++ {
+		data, err := store.GetBlockByHeight(h)
+		if err != nil {
+			return err
+		}
+		blocks = append(blocks, data)
+	}
+	data, err := json.MarshalIndent(blocks, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(out, data, 0644); err != nil {
+		return err
+	}
+	fmt.Printf("wrote %d blocks to %s\n", len(blocks), out)
+	return nil
+}
+
+func cmdCompact(store *storage.Store, out string) error {
+	if _, err := os.Stat(out); err == nil {
+		return fmt.Errorf("%s already exists", out)
+	}
+	if err := store.CompactTo(out); err != nil {
+		return err
+	}
+	before, _ := os.Stat(store.Path)
+	after, _ := os.Stat(out)
+	fmt.Printf("compacted %s (%d bytes) -> %s (%d bytes)\n", store.Path, before.Size(), out, after.Size())
+	fmt.Println("replace blockchain.db with the new file while the node is stopped")
+	return nil
+}
+
+// cmdUTXOSnapshot shows what switching an account chain to the utxo model
+// at the next block would create: one output per funded address. Nodes
+// build the same set themselves at utxo_activation_height; the snapshot
+// lets operators check totals before and after the switch.
+func cmdUTXOSnapshot(store *storage.Store, out string) error {
+	next := store.GetBlockCount()
+	utxos := blockchain.MigrationUTXOs(store.GetAllBalances(), next)
+	var total float64
+	for _, u := range utxos {
+		total += u.Amount
+	}
+	fmt.Printf("tip height: %d\noutputs: %d\ntotal: %.8f\n", store.GetBestHeight(), len(utxos), total)
+	fmt.Printf("\nto migrate, set in the network manifest of every node:\n"+
+		"  \"tx_model\": \"utxo\", \"utxo_activation_height\": <height above %d>\n", next-1)
+	if out == "" {
+		return nil
+	}
+	data, err := json.MarshalIndent(utxos, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(out, data, 0644); err != nil {
+		return err
+	}
+	fmt.Printf("\nwrote %d outputs to %s\n", len(utxos), out)
+	return nil
+}
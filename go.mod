@@ -2,9 +2,9 @@ module devinsidercoin
 
 go 1.23
 
-require github.com/mr-tron/base58 v1.2.0
-
 require (
-	go.etcd.io/bbolt v1.4.3 // indirect
-	golang.org/x/sys v0.29.0 // indirect
+	github.com/mr-tron/base58 v1.2.0
+	go.etcd.io/bbolt v1.4.3
 )
+
+require golang.org/x/sys v0.29.0 // indirect
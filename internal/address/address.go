@@ -0,0 +1,117 @@
+// Package address encodes and validates DevInsiderCoin addresses.
+//
+// An address is the network prefix followed by base58 of a version byte,
+// the 20-byte key hash and a 4-byte checksum: the first bytes of SHA-256d
+// over prefix, version and hash. A mistyped character fails the checksum,
+// and so does an address from another network.
+//
+// Legacy addresses, created before checksums, are the prefix followed by
+// base58 of the bare hash. They stay valid, but nothing can detect typos
+// in them; wallets should move their funds to the checksummed form.
+package address
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/mr-tron/base58"
+)
+
+// Address encoding versions.
+const (
+	VersionLegacy  = 0 // prefix + base58(hash)
+	VersionChecked = 1 // prefix + base58(version + hash + checksum)
+)
+
+const (
+	hashLen     = 20
+	checksumLen = 4
+)
+
+// ErrChecksum means the address is well formed but mistyped, or belongs
+// to another network.
+var ErrChecksum = errors.New("address checksum mismatch")
+
+// Hash is the part of a public key's SHA-256 an address encodes.
+type Hash [hashLen]byte
+
+// HashPublicKey returns the address hash of pub.
+func HashPublicKey(pub []byte) Hash {
+	sum := sha256.Sum256(pub)
+	var h Hash
+	copy(h[:], sum[:hashLen])
+	return h
+}
+
+func checksum(prefix string, version byte, h Hash) []byte {
+	first := sha256.Sum256(append(append([]byte(prefix), version), h[:]...))
+	second := sha256.Sum256(first[:])
+	return second[:checksumLen]
+}
+
+// Encode returns the checksummed address of h.
+func Encode(prefix string, h Hash) string {
+	payload := append([]byte{VersionChecked}, h[:]...)
+	payload = append(payload, checksum(prefix, VersionChecked, h)...)
+	return prefix + base58.Encode(payload)
+}
+
+// EncodeLegacy returns the legacy, unchecked address of h.
+func EncodeLegacy(prefix string, h Hash) string {
+	return prefix + base58.Encode(h[:])
+}
+
+// FromPublicKey returns the checksummed address of a public key.
+func FromPublicKey(prefix string, pub []byte) string {
+	return Encode(prefix, HashPublicKey(pub))
+}
+
+// Decode returns the hash an address encodes and its version.
+func Decode(prefix, addr string) (Hash, int, error) {
+	var h Hash
+	body, ok := strings.CutPrefix(addr, prefix)
+	if !ok || body == "" {
+		return h, 0, fmt.Errorf("address must start with %s", prefix)
+	}
+	raw, err := base58.Decode(body)
+	if err != nil {
+		return h, 0, fmt.Errorf("address is not base58: %w", err)
+	}
+	switch len(raw) {
+	case hashLen:
+		copy(h[:], raw)
+		return h, VersionLegacy, nil
+	case 1 + hashLen + checksumLen:
+		if raw[0] != VersionChecked {
+			return h, 0, fmt.Errorf("unknown address version %d", raw[0])
+		}
+		copy(h[:], raw[1:1+hashLen])
+		if !bytes.Equal(raw[1+hashLen:], checksum(prefix, raw[0], h)) {
+			return h, 0, ErrChecksum
+		}
+		return h, VersionChecked, nil
+	}
+	return h, 0, fmt.Errorf("address has the wrong length")
+}
+
+// Validate reports why addr is not a valid address for prefix, or nil.
+func Validate(prefix, addr string) error {
+	_, _, err := Decode(prefix, addr)
+	return err
+}
+
+// Upgrade returns the checksummed form of a legacy address. Both forms
+// belong to the same key but are distinct accounts on chain.
+func Upgrade(prefix, legacy string) (string, error) {
+	h, version, err := Decode(prefix, legacy)
+	if err != nil {
+		return "", err
+	}
+	if version != VersionLegacy {
+		return "", fmt.Errorf("%s is already checksummed", legacy)
+	}
+	return Encode(prefix, h), nil
+}
@@ -0,0 +1,38 @@
+package blockchain
+
+import (
+	"devinsidercoin/internal/address"
+	"fmt"
+)
+
+// checkAddresses refuses transactions naming malformed addresses, ones
+// with a bad checksum, or ones for another network. Legacy addresses,
+// which have no checksum, pass, and so does the burn address.
+//
+// The relay policy always applies it; once AddressRulesActive it is also
+// consensus, for mempool admission and for every transaction in a block.
+func (bc *Blockchain) checkAddresses(tx *Transaction) error {
+	prefix := bc.Config.AddressPrefix
+	burn := BurnAddress(prefix)
+	check := func(role, addr string) error {
+		if addr == "" || addr == burn {
+			return nil
+		}
+		if err := address.Validate(prefix, addr); err != nil {
+			return fmt.Errorf("invalid %s address %s: %w", role, addr, err)
+		}
+		return nil
+	}
+	if err := check("sender", tx.From); err != nil {
+		return err
+	}
+	if err := check("recipient", tx.To); err != nil {
+		return err
+	}
+	for _, out := range tx.Outputs {
+		if err := check("output", out.Address); err != nil {
+			return err
+		}
+	}
+	return nil
+}
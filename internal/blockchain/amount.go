@@ -0,0 +1,33 @@
+package blockchain
+
+import (
+	"math"
+	"math/big"
+)
+
+// UnitsPerCoin is the number of indivisible base units in one coin.
+// Consensus-critical splits are done in base units so that they sum
+// exactly; float amounts are only the external representation.
+const UnitsPerCoin = 100_000_000
+
+// ToUnits converts a coin amount to base units, rounding to the nearest
+// unit. It is meant for per-block amounts such as rewards and fees, which
+// fit comfortably in an int64.
+func ToUnits(amount float64) int64 {
+	return int64(math.Round(amount * UnitsPerCoin))
+}
+
+// FromUnits converts base units back to a coin amount.
+func FromUnits(units int64) float64 {
+	return float64(units) / UnitsPerCoin
+}
+
+// bigUnits converts an amount of any size (e.g. a large stake) to base
+// units without overflowing.
+func bigUnits(amount float64) *big.Int {
+	f := new(big.Float).SetFloat64(amount)
+	f.Mul(f, big.NewFloat(UnitsPerCoin))
+	f.Add(f, big.NewFloat(0.5))
+	units, _ := f.Int(nil)
+	return units
+}
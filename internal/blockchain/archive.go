@@ -0,0 +1,347 @@
+package blockchain
+
+import (
+	"devinsidercoin/internal/storage"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math"
+)
+
+// ErrNotArchive is returned by historical queries on a node that doesn't
+// keep the archive indexes.
+var ErrNotArchive = errors.New("historical indexes need a node running with -archive")
+
+// MaxHistoryResults caps the entries one historical query returns.
+const MaxHistoryResults = 1000
+
+// Block address filters are Bloom filters over every address a block's
+// transactions touch, keyed by the block hash.
+const (
+	filterBitsPerAddress = 20
+	filterHashes         = 6
+	minFilterBytes       = 8
+)
+
+// AddressTx is one transaction in an address's history.
+type AddressTx struct {
+	Height uint64 `json:"height"`
+	Index  uint32 `json:"index"` // position in the block
+	TxID   string `json:"txid"`
+}
+
+// BalancePoint is an address's balance after a block that changed it.
+type BalancePoint struct {
+	Height  uint64  `json:"height"`
+	Balance float64 `json:"balance"`
+}
+
+// HistoricalBalance is an address's balance after a past block.
+type HistoricalBalance struct {
+	Address   string  `json:"address"`
+	Height    uint64  `json:"height"`
+	Balance   float64 `json:"balance"`
+	ChangedAt uint64  `json:"changed_at"` // last block at or below Height that changed it
+}
+
+// RichEntry is one address in the rich list.
+type RichEntry struct {
+	Rank    int     `json:"rank"`
+	Address string  `json:"address"`
+	Balance float64 `json:"balance"`
+}
+
+// BlockFilter is a block's address filter. An address may be touched by
+// the block only if FilterMatches reports a match.
+type BlockFilter struct {
+	Height uint64 `json:"height"`
+	Hash   string `json:"hash"`
+	Hashes int    `json:"hashes"`
+	Filter string `json:"filter"` // hex bitset
+}
+
+// blockAddresses returns the addresses each transaction of block touches,
+// with the positions of the transactions touching them.
+func blockAddresses(block *Block) map[string][]uint32 {
+	touched := make(map[string][]uint32)
+	for i := range block.Transactions {
+		tx := &block.Transactions[i]
+		addrs := []string{tx.From, tx.To}
+		for _, out := range tx.Outputs {
+			addrs = append(addrs, out.Address)
+		}
+		for _, a := range addrs {
+			if a == "" {
+				continue
+			}
+			if pos := touched[a]; len(pos) == 0 || pos[len(pos)-1] != uint32(i) {
+				touched[a] = append(pos, uint32(i))
+			}
+		}
+	}
+	return touched
+}
+
+// filterBits returns the bit positions address sets in a filter of m bits
+// for the block with blockHash.
+func filterBits(blockHash, address string, m uint64) [filterHashes]uint64 {
+	sum := SHA256d([]byte(blockHash + ":" + address))
+	var bits [filterHashes]uint64
+	for i := range bits {
+		bits[i] = uint64(binary.BigEndian.Uint32(sum[i*4:])) % m
+	}
+	return bits
+}
+
+// BuildAddressFilter returns the filter of addrs for the block with
+// blockHash.
+func BuildAddressFilter(blockHash string, addrs []string) []byte {
+	f := make([]byte, max(minFilterBytes, (len(addrs)*filterBitsPerAddress+7)/8))
+	m := uint64(len(f)) * 8
+	for _, a := range addrs {
+		for _, b := range filterBits(blockHash, a, m) {
+			f[b/8] |= 1 << (b % 8)
+		}
+	}
+	return f
+}
+
+// FilterMatches reports whether address may be in filter. False positives
+// are possible; false negatives are not.
+func FilterMatches(filter []byte, blockHash, address string) bool {
+	if len(filter) == 0 {
+		return false
+	}
+	m := uint64(len(filter)) * 8
+	for _, b := range filterBits(blockHash, address, m) {
+		if filter[b/8]&(1<<(b%8)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// archiveCommit returns block's archive index entries.
+func archiveCommit(block *Block) *storage.ArchiveCommit {
+	touched := blockAddresses(block)
+	addrs := make([]string, 0, len(touched))
+	for a := range touched {
+		addrs = append(addrs, a)
+	}
+	return &storage.ArchiveCommit{History: touched, Filter: BuildAddressFilter(block.Hash, addrs)}
+}
+
+// replayBalances applies block's balance changes to balances and returns
+// the new balances of the addresses it changed, plus the number of custom
+// transactions it could not apply.
+func (bc *Blockchain) replayBalances(block *Block, balances map[string]float64, outputs map[string]TxOutput) (map[string]float64, int) {
+	height := block.Header.Height
+	changed := make(map[string]float64)
+	var skipped int
+
+	if bc.Config.UTXOActive(height) {
+		if height > 0 && height == bc.Config.UTXOActivationHeight {
+			for _, u := range MigrationUTXOs(balances, height) {
+				outputs[outpoint(u.TxID, u.Vout)] = TxOutput{Address: u.Address, Amount: u.Amount}
+			}
+		}
+		deltas := make(map[string]float64)
+		for _, tx := range block.Transactions {
+			for _, in := range tx.Inputs {
+				op := outpoint(in.TxID, in.Vout)
+				if out, ok := outputs[op]; ok {
+					deltas[out.Address] -= out.Amount
+					delete(outputs, op)
+				}
+			}
+			for i, out := range tx.Outputs {
+				outputs[outpoint(tx.TxID, uint32(i))] = out
+				deltas[out.Address] += out.Amount
+			}
+		}
+		for addr, d := range deltas {
+			balances[addr] += d
+			changed[addr] = balances[addr]
+		}
+		return changed, 0
+	}
+
+	adjust := func(addr string, d float64) {
+		balances[addr] += d
+		changed[addr] = balances[addr]
+	}
+	for _, tx := range block.Transactions {
+		switch tx.Type {
+		case "coinbase", "pos_reward":
+			for _, out := range tx.Outputs {
+				adjust(out.Address, out.Amount)
+			}
+		case "transfer":
+			adjust(tx.From, -(tx.Amount + tx.Fee))
+			adjust(tx.To, tx.Amount)
+		case "stake", "delegate":
+			adjust(tx.From, -tx.Amount)
+		case "unstake":
+			adjust(tx.From, tx.Amount)
+		case "vote":
+			adjust(tx.From, -tx.Fee)
+		case "burn":
+			adjust(tx.From, -(tx.Amount + tx.Fee))
+		case "slash":
+			// burns stake, not balance
+		default:
+			skipped++
+		}
+	}
+	return changed, skipped
+}
+
+// IsArchive reports whether the node keeps the archive indexes.
+func (bc *Blockchain) IsArchive() bool {
+	return bc.archive != nil
+}
+
+// archiveUsable returns why the archive indexes can't answer queries, or
+// nil. The caller holds bc.mu.
+func (bc *Blockchain) archiveUsable() error {
+	switch {
+	case bc.archive == nil:
+		return ErrNotArchive
+	case !bc.archive.ready:
+		return ErrIndexing
+	}
+	return nil
+}
+
+func historyLimit(limit int) int {
+	if limit <= 0 || limit > MaxHistoryResults {
+		return MaxHistoryResults
+	}
+	return limit
+}
+
+// historyHeight refuses to answer a historical query as of a height the
+// chain hasn't reached. The caller holds bc.mu.
+func (bc *Blockchain) historyHeight(height uint64) error {
+	if best := bc.lastBlock.Header.Height; height > best {
+		return fmt.Errorf("height %d is above the best block %d", height, best)
+	}
+	return nil
+}
+
+// AddressHistory returns about limit confirmed transactions touching
+// address from fromHeight to toHeight, oldest first. The last block is
+// never cut short, so the next page starts at the last height plus one;
+// pinning toHeight keeps blocks connected meanwhile out of later pages.
+func (bc *Blockchain) AddressHistory(address string, fromHeight, toHeight uint64, limit int) ([]AddressTx, error) {
+	bc.mu.RLock()
+	defer bc.mu.RUnlock()
+	if err := bc.archiveUsable(); err != nil {
+		return nil, err
+	}
+	if err := bc.historyHeight(toHeight); err != nil {
+		return nil, err
+	}
+	result := []AddressTx{}
+	for _, e := range bc.Store.AddressHistory(address, fromHeight, toHeight, historyLimit(limit)) {
+		result = append(result, AddressTx{Height: e.Height, Index: e.Index, TxID: e.TxID})
+	}
+	return result, nil
+}
+
+// BalanceHistory returns up to limit balances of address after each block
+// from fromHeight to toHeight that changed it, oldest first.
+func (bc *Blockchain) BalanceHistory(address string, fromHeight, toHeight uint64, limit int) ([]BalancePoint, error) {
+	bc.mu.RLock()
+	defer bc.mu.RUnlock()
+	if err := bc.archiveUsable(); err != nil {
+		return nil, err
+	}
+	if err := bc.historyHeight(toHeight); err != nil {
+		return nil, err
+	}
+	result := []BalancePoint{}
+	for _, p := range bc.Store.BalanceHistory(address, fromHeight, toHeight, historyLimit(limit)) {
+		result = append(result, BalancePoint{Height: p.Height, Balance: p.Balance})
+	}
+	return result, nil
+}
+
+// BalanceAt returns what address held after the block at height, from
+// the balance history index.
+func (bc *Blockchain) BalanceAt(address string, height uint64) (*HistoricalBalance, error) {
+	bc.mu.RLock()
+	defer bc.mu.RUnlock()
+	if err := bc.archiveUsable(); err != nil {
+		return nil, err
+	}
+	if err := bc.historyHeight(height); err != nil {
+		return nil, err
+	}
+	result := &HistoricalBalance{Address: address, Height: height}
+	if p, ok := bc.Store.BalanceAt(address, height); ok {
+		result.Balance, result.ChangedAt = p.Balance, p.Height
+	}
+	return result, nil
+}
+
+// RichList returns n addresses of the rich list after the block at
+// height, from rank offset+1 on. Lists below the best block are built from
+// the balance history, so a client paging through it sees one consistent
+// ranking while blocks keep arriving.
+func (bc *Blockchain) RichList(height uint64, offset, n int) ([]RichEntry, error) {
+	bc.mu.RLock()
+	defer bc.mu.RUnlock()
+	if err := bc.archiveUsable(); err != nil {
+		return nil, err
+	}
+	if err := bc.historyHeight(height); err != nil {
+		return nil, err
+	}
+	n = historyLimit(n)
+	var entries []storage.RichEntry
+	if height == bc.lastBlock.Header.Height {
+		entries = bc.Store.RichList(offset, n)
+	} else if list := bc.archive.richListAt(bc.Store, height); offset < len(list) {
+		entries = list[offset:min(offset+n, len(list))]
+	}
+	result := []RichEntry{}
+	for i, e := range entries {
+		result = append(result, RichEntry{Rank: offset + i + 1, Address: e.Address, Balance: e.Balance})
+	}
+	return result, nil
+}
+
+// GetBlockFilter returns the address filter of the block at height.
+func (bc *Blockchain) GetBlockFilter(height uint64) (*BlockFilter, error) {
+	bc.mu.RLock()
+	defer bc.mu.RUnlock()
+	if err := bc.archiveUsable(); err != nil {
+		return nil, err
+	}
+	f := bc.Store.GetBlockFilter(height)
+	block := bc.loadBlock(height)
+	if f == nil || block == nil {
+		return nil, fmt.Errorf("block %d not found", height)
+	}
+	return &BlockFilter{Height: height, Hash: block.Hash, Hashes: filterHashes, Filter: hex.EncodeToString(f)}, nil
+}
+
+// archivedTransactions returns the confirmed transactions touching address
+// from the address history index. The caller holds bc.mu.
+func (bc *Blockchain) archivedTransactions(address string) []TxRecord {
+	var result []TxRecord
+	var block *Block
+	for _, e := range bc.Store.AddressHistory(address, 0, math.MaxUint64, 0) {
+		if block == nil || block.Header.Height != e.Height {
+			if block = bc.loadBlock(e.Height); block == nil {
+				continue
+			}
+		}
+		if int(e.Index) < len(block.Transactions) {
+			result = append(result, *bc.confirmedRecord(block.Transactions[e.Index], block))
+		}
+	}
+	return result
+}
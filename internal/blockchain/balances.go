@@ -0,0 +1,83 @@
+package blockchain
+
+import (
+	"container/list"
+	"devinsidercoin/internal/storage"
+	"sync"
+)
+
+const defaultBalanceCacheSize = 65536
+
+// balanceCache serves balances from BoltDB through a small LRU so that only
+// hot addresses are kept in memory.
+type balanceCache struct {
+	store *storage.Store
+	size  int
+	items map[string]*list.Element
+	order *list.List // front = most recently used
+	mu    sync.Mutex
+}
+
+type balanceItem struct {
+	address string
+	amount  float64
+}
+
+func newBalanceCache(store *storage.Store, size int) *balanceCache {
+	return &balanceCache{
+		store: store,
+		size:  size,
+		items: make(map[string]*list.Element),
+		order: list.New(),
+	}
+}
+
+// get returns the committed balance for address.
+func (c *balanceCache) get(address string) float64 {
+	c.mu.Lock()
+	if el, ok := c.items[address]; ok {
+		c.order.MoveToFront(el)
+		amount := el.Value.(*balanceItem).amount
+		c.mu.Unlock()
+		return amount
+	}
+	c.mu.Unlock()
+
+	amount := c.store.GetBalance(address)
+
+	c.mu.Lock()
+	c.put(address, amount)
+	c.mu.Unlock()
+	return amount
+}
+
+// update stores balances that were just committed to the database.
+func (c *balanceCache) update(changed map[string]float64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for addr, amount := range changed {
+		c.put(addr, amount)
+	}
+}
+
+// reset drops every cached entry.
+func (c *balanceCache) reset() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.items = make(map[string]*list.Element)
+	c.order.Init()
+}
+
+func (c *balanceCache) put(address string, amount float64) {
+	if el, ok := c.items[address]; ok {
+		el.Value.(*balanceItem).amount = amount
+		c.order.MoveToFront(el)
+		return
+	}
+	c.items[address] = c.order.PushFront(&balanceItem{address: address, amount: amount})
+	for c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.items, oldest.Value.(*balanceItem).address)
+	}
+}
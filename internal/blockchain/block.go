@@ -26,17 +26,28 @@ type TxOutput struct {
 	Amount  float64 `json:"amount"`
 }
 
+// TxInput references an output of an earlier transaction, spent in full by
+// a transaction on a utxo network.
+type TxInput struct {
+	TxID string `json:"txid"`
+	Vout uint32 `json:"vout"`
+}
+
 // Transaction represents a blockchain transaction.
 type Transaction struct {
 	TxID      string     `json:"txid"`
-	Type      string     `json:"type"` // coinbase, transfer, stake, unstake, pos_reward
+	Version   uint32     `json:"version,omitempty"`
+	Type      string     `json:"type"` // coinbase, transfer, stake, unstake, pos_reward, vote, burn, slash, delegate
 	From      string     `json:"from,omitempty"`
 	To        string     `json:"to,omitempty"`
 	Amount    float64    `json:"amount"`
 	Fee       float64    `json:"fee"`
 	Timestamp int64      `json:"timestamp"`
 	Signature string     `json:"signature,omitempty"`
+	Inputs    []TxInput  `json:"inputs,omitempty"` // utxo networks only
 	Outputs   []TxOutput `json:"outputs,omitempty"`
+	Data      string     `json:"data,omitempty"`  // type-specific payload, e.g. a vote
+	Nonce     uint64     `json:"nonce,omitempty"` // sender's sequence number; see NonceRulesActive
 }
 
 // Block represents a full block.
@@ -99,8 +110,14 @@ func (h *BlockHeader) ComputeHash() string {
 	return hex.EncodeToString(hash[:])
 }
 
-// ComputeTxID computes a deterministic transaction ID.
+// ComputeTxID computes a deterministic transaction ID. Canonical (version 1+)
+// transactions hash their binary encoding without the signature; legacy
+// transactions hash a JSON subset.
 func (tx *Transaction) ComputeTxID() string {
+	if tx.Version >= TxVersionCanonical {
+		hash := SHA256d(tx.SigningBytes())
+		return hex.EncodeToString(hash[:])
+	}
 	data, _ := json.Marshal(struct {
 		Type      string  `json:"type"`
 		From      string  `json:"from"`
@@ -119,9 +136,8 @@ func ComputeMerkleRoot(txs []Transaction) string {
 	}
 
 	hashes := make([][32]byte, len(txs))
-	for i, tx := range txs {
-		txData, _ := json.Marshal(tx)
-		hashes[i] = SHA256d(txData)
+	for i := range txs {
+		hashes[i] = merkleLeaf(&txs[i])
 	}
 
 	for len(hashes) > 1 {
@@ -142,9 +158,20 @@ func ComputeMerkleRoot(txs []Transaction) string {
 	return hex.EncodeToString(hashes[0][:])
 }
 
+// merkleLeaf hashes a transaction for the merkle tree. Canonical
+// transactions commit to their full encoding including the signature.
+func merkleLeaf(tx *Transaction) [32]byte {
+	if tx.Version >= TxVersionCanonical {
+		return SHA256d(tx.Serialize())
+	}
+	txData, _ := json.Marshal(tx)
+	return SHA256d(txData)
+}
+
 // NewCoinbaseTransaction creates a mining reward transaction.
 func NewCoinbaseTransaction(minerAddress string, reward float64, height uint64) Transaction {
 	tx := Transaction{
+		Version:   TxVersionCanonical,
 		Type:      "coinbase",
 		To:        minerAddress,
 		Amount:    reward,
@@ -158,6 +185,7 @@ func NewCoinbaseTransaction(minerAddress string, reward float64, height uint64)
 // NewTransferTransaction creates a transfer transaction.
 func NewTransferTransaction(from, to string, amount, fee float64, sig string) Transaction {
 	tx := Transaction{
+		Version:   TxVersionCanonical,
 		Type:      "transfer",
 		From:      from,
 		To:        to,
@@ -0,0 +1,92 @@
+package blockchain
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// metaTotalBurned is the meta key holding cumulative burned supply.
+const metaTotalBurned = "total_burned"
+
+// BurnAddress returns the sink named by burn transactions. '0' is not in
+// the base58 alphabet, so no key can ever hash to this address and coins
+// sent to it are provably unspendable.
+func BurnAddress(prefix string) string {
+	return prefix + "0burn000000000000000000000000"
+}
+
+// NewBurnTransaction creates a transaction destroying amount coins from
+// the sender.
+func NewBurnTransaction(from, sink string, amount, fee float64) Transaction {
+	tx := Transaction{
+		Version:   TxVersionCanonical,
+		Type:      "burn",
+		From:      from,
+		To:        sink,
+		Amount:    amount,
+		Fee:       fee,
+		Timestamp: time.Now().Unix(),
+	}
+	tx.TxID = tx.ComputeTxID()
+	return tx
+}
+
+// checkBurn validates the shape of a burn transaction.
+func (bc *Blockchain) checkBurn(tx *Transaction) error {
+	if tx.Version < TxVersionCanonical {
+		return fmt.Errorf("burn must use transaction version %d", TxVersionCanonical)
+	}
+	if tx.From == "" || tx.Amount <= 0 {
+		return fmt.Errorf("burn needs a sender and a positive amount")
+	}
+	if tx.To != BurnAddress(bc.Config.AddressPrefix) {
+		return fmt.Errorf("burn must pay the burn address %s", BurnAddress(bc.Config.AddressPrefix))
+	}
+	return nil
+}
+
+// blockBurned returns the coins block destroys: burn transaction amounts,
+// slashed stake and, under the burn fee policy, the fees nobody collects.
+func (bc *Blockchain) blockBurned(block *Block) float64 {
+	burned := blockFees(block.Transactions) - bc.collectedFees(block.Transactions)
+	for _, tx := range block.Transactions {
+		if tx.Type == "burn" || tx.Type == "slash" {
+			burned += tx.Amount
+		}
+	}
+	return burned
+}
+
+func (bc *Blockchain) loadTotalBurned() float64 {
+	v, _ := strconv.ParseFloat(string(bc.Store.GetMeta(metaTotalBurned)), 64)
+	return v
+}
+
+// Supply summarises minted, burned and circulating coins.
+type Supply struct {
+	Height      uint64  `json:"height"`
+	MaxSupply   float64 `json:"max_supply"`
+	TotalMinted float64 `json:"total_minted"`
+	TotalBurned float64 `json:"total_burned"`
+	Circulating float64 `json:"circulating"`
+	Staked      float64 `json:"staked"`
+	Unbonding   float64 `json:"unbonding"` // unstaked, waiting for release
+	BurnAddress string  `json:"burn_address"`
+}
+
+// GetSupply returns supply figures as of the tip.
+func (bc *Blockchain) GetSupply() Supply {
+	bc.mu.RLock()
+	defer bc.mu.RUnlock()
+	return Supply{
+		Height:      uint64(max(bc.Store.GetBestHeight(), 0)),
+		MaxSupply:   bc.Config.MaxSupply,
+		TotalMinted: bc.TotalMinted,
+		TotalBurned: bc.TotalBurned,
+		Circulating: bc.TotalMinted - bc.TotalBurned,
+		Staked:      bc.Stakes.GetTotalStaked(),
+		Unbonding:   bc.unbondingTotal(),
+		BurnAddress: BurnAddress(bc.Config.AddressPrefix),
+	}
+}
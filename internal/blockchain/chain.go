@@ -2,79 +2,270 @@ package blockchain
 
 import (
 	"devinsidercoin/internal/config"
+	"devinsidercoin/internal/logging"
 	"devinsidercoin/internal/storage"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"log"
+	"math/big"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
 )
 
+var logger = logging.For("CHAIN")
+
 // Blockchain manages the chain state.
 type Blockchain struct {
 	Config      *config.NetworkConfig
 	Store       *storage.Store
-	Balances    map[string]float64
 	Stakes      *StakeManager
-	Mempool     []Transaction
+	Mempool     *Mempool
 	TotalMinted float64
+	TotalBurned float64
 	DataDir     string
+	Events      *EventBus
+	Hooks       *Hooks
+	Engine      Consensus
 	mu          sync.RWMutex
 	lastBlock   *Block
+	balances    *balanceCache
+	gov         *govState
+	unbonding   []*Unbond // queued unstakes in release order
+	finalized   uint64    // height of the latest finalized checkpoint, 0 if none
+	chainWork   *big.Int  // cumulative work of the best chain
+	relay       config.RelayPolicy
+	archive     *archiveIndexer // nil unless in archive mode
+	journal     *journal        // nil unless the event journal is on
+	reindexMu   sync.Mutex      // serializes archive reindexes
+	maturing    maturingRewards // rewards of the blocks that may still be immature
+	versionBits versionBits     // deployment states by signaling window
+	evidence    evidencePool    // double-sign evidence waiting for a block
+}
+
+// Options controls optional startup behaviour of NewBlockchain.
+type Options struct {
+	// OverrideConfig accepts a network config whose consensus parameters
+	// differ from the ones the chain was created with.
+	OverrideConfig bool
+	// Archive keeps every optional index: address and balance history, the
+	// rich list and block address filters. Missing entries are built in the
+	// background; see IndexInfo.
+	Archive bool
+	// Journal records block and mempool events to journal.log for
+	// external indexers; see ReadJournal.
+	Journal bool
+	// CheckBlocks re-verifies this many of the latest blocks at CheckLevel
+	// before the chain is returned; 0 skips the check.
+	CheckBlocks int
+	CheckLevel  int
 }
 
 // NewBlockchain creates or loads a blockchain.
-func NewBlockchain(cfg *config.NetworkConfig, dataDir string) *Blockchain {
+func NewBlockchain(cfg *config.NetworkConfig, dataDir string, opts Options) (*Blockchain, error) {
 	store, err := storage.NewStore(dataDir)
 	if err != nil {
-		log.Fatalf("[CHAIN] Failed to open database: %v", err)
+		return nil, fmt.Errorf("open database: %w", err)
 	}
 
 	bc := &Blockchain{
 		Config:   cfg,
 		Store:    store,
-		Balances: make(map[string]float64),
 		Stakes:   NewStakeManager(),
-		Mempool:  make([]Transaction, 0),
+		Mempool:  NewMempool(),
 		DataDir:  dataDir,
+		Events:   NewEventBus(),
+		balances: newBalanceCache(store, defaultBalanceCacheSize),
+		Hooks:    newHooks(),
+		gov:      newGovState(),
+		relay:    cfg.RelayPolicy(),
+	}
+	engine, err := newConsensus(bc)
+	if err != nil {
+		store.Close()
+		return nil, err
+	}
+	bc.Engine = engine
+
+	genesis, err := CreateGenesisBlock(cfg)
+	if err != nil {
+		store.Close()
+		return nil, err
+	}
+	if cfg.GenesisHash != "" && genesis.Hash != cfg.GenesisHash {
+		store.Close()
+		return nil, fmt.Errorf("genesis block %s built from the network config does not match "+
+			"its genesis_hash %s", genesis.Hash, cfg.GenesisHash)
 	}
 
 	if !store.HasData() {
 		if bc.migrateFromJSON() {
-			log.Printf("[CHAIN] Migrated from blockchain.json to BoltDB")
+			logger.Info("migrated from blockchain.json to BoltDB")
 		} else {
-			genesis := CreateGenesisBlock(cfg)
+			balances := make(map[string]float64)
+			for _, out := range genesis.Transactions[0].Outputs {
+				if out.Amount > 0 {
+					balances[out.Address] += out.Amount
+					bc.TotalMinted += out.Amount
+				}
+			}
 			blockJSON, _ := json.Marshal(genesis)
 			commit := &storage.BlockCommit{
 				Height:      0,
 				Hash:        genesis.Hash,
 				BlockJSON:   blockJSON,
-				Balances:    bc.Balances,
+				Balances:    balances,
 				TxIDs:       collectTxIDs(genesis),
-				TotalMinted: 0,
+				TotalMinted: bc.TotalMinted,
+			}
+			if cfg.UTXOActive(0) {
+				view, err := bc.connectUTXOs(genesis)
+				if err != nil {
+					store.Close()
+					return nil, fmt.Errorf("genesis utxos: %w", err)
+				}
+				commit.UTXOs = view.changes()
+				commit.Meta = map[string][]byte{metaUTXOActivated: []byte("0")}
 			}
 			if err := store.CommitBlock(commit); err != nil {
-				log.Fatalf("[CHAIN] Failed to write genesis: %v", err)
+				store.Close()
+				return nil, fmt.Errorf("write genesis: %w", err)
 			}
 			bc.lastBlock = genesis
-			log.Printf("[CHAIN] Created genesis block: %s", genesis.Hash)
+			logger.Info("created genesis block", "hash", genesis.Hash)
 		}
 	} else {
-		bc.Balances = store.GetAllBalances()
 		bc.TotalMinted = store.GetTotalMinted()
+		bc.TotalBurned = bc.loadTotalBurned()
 		bc.loadStakesFromDB()
+		bc.loadUnbonding()
+		bc.gov = bc.loadGovState()
+		bc.loadFinalized()
 		bc.lastBlock = bc.loadBlock(uint64(store.GetBestHeight()))
-		log.Printf("[CHAIN] Loaded %d blocks from BoltDB (minted: %.2f / %.2f)",
-			store.GetBlockCount(), bc.TotalMinted, cfg.MaxSupply)
+		logger.Info("loaded chain from BoltDB", "blocks", store.GetBlockCount(),
+			"minted", bc.TotalMinted, "max_supply", cfg.MaxSupply)
+	}
+
+	if err := bc.checkGenesis(); err != nil {
+		store.Close()
+		return nil, err
+	}
+	if err := bc.checkCheckpoints(); err != nil {
+		store.Close()
+		return nil, err
+	}
+	if err := bc.checkConfigHash(opts.OverrideConfig); err != nil {
+		store.Close()
+		return nil, err
+	}
+	if err := bc.checkUTXOActivation(); err != nil {
+		store.Close()
+		return nil, err
+	}
+	if !store.ReceivedIndexed() {
+		if err := bc.rebuildReceived(); err != nil {
+			store.Close()
+			return nil, fmt.Errorf("build received index: %w", err)
+		}
+	}
+	if !store.ChartsIndexed() {
+		if err := bc.rebuildCharts(); err != nil {
+			store.Close()
+			return nil, fmt.Errorf("build chart stats: %w", err)
+		}
+	}
+	if !store.RewardsIndexed() {
+		if err := bc.rebuildRewards(); err != nil {
+			store.Close()
+			return nil, fmt.Errorf("build reward index: %w", err)
+		}
+	}
+	if !store.ChainWorkIndexed() {
+		if err := bc.rebuildChainWork(); err != nil {
+			store.Close()
+			return nil, fmt.Errorf("build chain work index: %w", err)
+		}
+	}
+	bc.loadChainWork()
+	if err := bc.loadMaturing(); err != nil {
+		store.Close()
+		return nil, fmt.Errorf("load maturing rewards: %w", err)
+	}
+	if err := bc.verifyChain(opts.CheckBlocks, opts.CheckLevel); err != nil {
+		store.Close()
+		return nil, fmt.Errorf("verify chain: %w", err)
+	}
+	if opts.Journal {
+		if err := bc.startJournal(); err != nil {
+			store.Close()
+			return nil, err
+		}
+	}
+	if opts.Archive {
+		bc.archive = newArchiveIndexer()
+		go bc.runArchiveIndexer(bc.archive)
 	}
+	return bc, nil
+}
 
-	return bc
+// checkConfigHash compares the consensus parameters the chain was built
+// with against the loaded config, so edited manifests can't silently apply
+// new rules to an existing chain. Rules scheduled above the tip, as a
+// release activating one does, are not a change, nor is moving one that is
+// still above it.
+func (bc *Blockchain) checkConfigHash(override bool) error {
+	current := bc.Config.ConsensusHash()
+	stored := bc.Store.GetConfigHash()
+	recorded := bc.Store.GetConfig()
+	switch {
+	case stored == current && recorded != nil:
+		return nil
+	case stored == current:
+		logger.Info("recording consensus config", "hash", current)
+	case stored == "":
+		logger.Info("recording consensus config hash", "hash", current)
+	case bc.sameRulesToTip(stored, recorded):
+		logger.Info("consensus config schedules rules above the tip", "stored", stored[:16], "current", current[:16])
+	case override:
+		logger.Warn("consensus config changed, continuing because of override",
+			"stored", stored, "current", current)
+	default:
+		return fmt.Errorf("consensus parameters in the network config differ from the ones "+
+			"this chain was created with (stored %s, current %s); restore the original "+
+			"config or restart with -overrideconfig", stored[:16], current[:16])
+	}
+	cfg, err := json.Marshal(bc.Config)
+	if err != nil {
+		return err
+	}
+	return bc.Store.SetConfigHash(current, cfg)
+}
+
+// sameRulesToTip reports whether the loaded config applies the same rules
+// to every block up to the tip as the recorded config, or, for databases
+// that only kept its hash, as the config stored hashes.
+func (bc *Blockchain) sameRulesToTip(stored string, recorded []byte) bool {
+	tip := bc.Store.GetBlockCount()
+	want := bc.Config.ScheduledHash(tip)
+	var prev config.NetworkConfig
+	if recorded != nil && json.Unmarshal(recorded, &prev) == nil {
+		return prev.ScheduledHash(tip) == want
+	}
+	return stored == want
 }
 
 func (bc *Blockchain) Close() {
+	bc.reindexMu.Lock()
+	defer bc.reindexMu.Unlock()
+	if bc.archive != nil {
+		bc.archive.close()
+	}
+	if bc.journal != nil {
+		bc.journal.close()
+	}
 	if bc.Store != nil {
 		bc.Store.Close()
 	}
@@ -101,13 +292,22 @@ func (bc *Blockchain) loadBlock(height uint64) *Block {
 }
 
 func (bc *Blockchain) loadStakesFromDB() {
-	raw := bc.Store.GetAllStakesRaw()
-	for addr, data := range raw {
+	bc.Stakes = bc.stakeSnapshot()
+}
+
+// stakeSnapshot returns the stake set committed with the current tip. PoS
+// rewards and producer eligibility for the next block are computed from
+// it rather than from the live StakeManager, so every node derives the
+// same outputs regardless of what is in flight.
+func (bc *Blockchain) stakeSnapshot() *StakeManager {
+	sm := NewStakeManager()
+	for addr, data := range bc.Store.GetAllStakesRaw() {
 		var s Stake
 		if json.Unmarshal(data, &s) == nil {
-			bc.Stakes.Stakes[addr] = &s
+			sm.Stakes[addr] = &s
 		}
 	}
+	return sm
 }
 
 // --- Migration from old JSON format ---
@@ -133,7 +333,7 @@ func (bc *Blockchain) migrateFromJSON() bool {
 		return false
 	}
 
-	log.Printf("[CHAIN] Migrating %d blocks from JSON to BoltDB...", len(data.Blocks))
+	logger.Info("migrating blocks from JSON to BoltDB", "blocks", len(data.Blocks))
 
 	for i, block := range data.Blocks {
 		blockJSON, _ := json.Marshal(block)
@@ -157,14 +357,11 @@ func (bc *Blockchain) migrateFromJSON() bool {
 			}
 		}
 		if err := bc.Store.CommitBlock(commit); err != nil {
-			log.Printf("[CHAIN] Migration error at block %d: %v", block.Header.Height, err)
+			logger.Error("migration failed", "height", block.Header.Height, "err", err)
 			return false
 		}
 	}
 
-	if data.Balances != nil {
-		bc.Balances = data.Balances
-	}
 	if data.Stakes != nil {
 		for addr, s := range data.Stakes {
 			bc.Stakes.Stakes[addr] = s
@@ -175,7 +372,7 @@ func (bc *Blockchain) migrateFromJSON() bool {
 
 	backupPath := jsonPath + ".migrated"
 	os.Rename(jsonPath, backupPath)
-	log.Printf("[CHAIN] Old blockchain.json renamed to %s", backupPath)
+	logger.Info("old blockchain.json renamed", "path", backupPath)
 	return true
 }
 
@@ -218,33 +415,16 @@ func (bc *Blockchain) GetBlockByHash(hash string) *Block {
 func (bc *Blockchain) GetBalance(address string) float64 {
 	bc.mu.RLock()
 	defer bc.mu.RUnlock()
-	return bc.Balances[address]
+	return bc.balances.get(address)
 }
 
-func (bc *Blockchain) GetTransactions(address string) []Transaction {
+// GetSpendable returns the committed balance of address minus its stake
+// and the spends it already has pending.
+func (bc *Blockchain) GetSpendable(address string) float64 {
 	bc.mu.RLock()
 	defer bc.mu.RUnlock()
-	var result []Transaction
-	count := bc.Store.GetBlockCount()
-	for h := uint64(0); h < count; h++ {
-		block := bc.loadBlock(h)
-		if block == nil {
-			continue
-		}
-		for _, tx := range block.Transactions {
-			if tx.From == address || tx.To == address {
-				result = append(result, tx)
-				continue
-			}
-			for _, out := range tx.Outputs {
-				if out.Address == address {
-					result = append(result, tx)
-					break
-				}
-			}
-		}
-	}
-	return result
+	return bc.balances.get(address) - bc.Stakes.GetStake(address) - bc.Mempool.PendingSpend(address) -
+		FromUnits(bc.immatureUnits(address, bc.Store.GetBlockCount()))
 }
 
 func (bc *Blockchain) GetBlockCount() uint64 {
@@ -259,58 +439,216 @@ func (bc *Blockchain) GetTotalMinted() float64 {
 	return bc.TotalMinted
 }
 
-func (bc *Blockchain) CalcBlockReward(height uint64) float64 {
-	if bc.TotalMinted >= bc.Config.MaxSupply {
-		return 0
-	}
-	halvings := height / bc.Config.HalvingInterval
-	reward := bc.Config.InitialReward
-	for i := uint64(0); i < halvings; i++ {
-		reward /= 2
-	}
-	if reward < 0.00000001 {
-		return 0
-	}
-	remaining := bc.Config.MaxSupply - bc.TotalMinted
-	if reward > remaining {
-		reward = remaining
+// AddToMempool validates tx for admission and publishes EventTxAdded.
+func (bc *Blockchain) AddToMempool(tx Transaction) error {
+	if err := bc.addToMempool(tx); err != nil {
+		var ds *DoubleSpendError
+		if errors.As(err, &ds) {
+			bc.Events.Publish(Event{Type: EventDoubleSpend, Tx: &tx, Conflicts: ds.Conflicts})
+		}
+		return err
 	}
-	return reward
+	bc.Events.Publish(Event{Type: EventTxAdded, Tx: &tx})
+	return nil
 }
 
-func (bc *Blockchain) AddToMempool(tx Transaction) error {
+func (bc *Blockchain) addToMempool(tx Transaction) error {
+	switch {
+	case tx.Type == "slash":
+		return fmt.Errorf("slash transactions are made from evidence; submit it with submitevidence")
+	case producerOnly(tx.Type):
+		return fmt.Errorf("%s transactions are only made by block producers", tx.Type)
+	}
 	bc.mu.Lock()
 	defer bc.mu.Unlock()
-	if tx.Type == "transfer" {
-		if bc.Balances[tx.From] < tx.Amount+tx.Fee {
+	if bc.Mempool.Has(tx.TxID) {
+		return rejectf(RejectDuplicate, "transaction %s already in mempool", tx.TxID)
+	}
+	if bc.Config.AddressRulesActive(bc.Store.GetBlockCount()) {
+		if err := bc.checkAddresses(&tx); err != nil {
+			return err
+		}
+	}
+	if err := bc.checkMempoolSignature(&tx, bc.Store.GetBlockCount()); err != nil {
+		return err
+	}
+	if err := bc.checkMempoolNonce(&tx, bc.Store.GetBlockCount()); err != nil {
+		return err
+	}
+	if err := bc.checkTxLimits(&tx, bc.Store.GetBlockCount()); err != nil {
+		return err
+	}
+	if err := bc.checkRelayPolicy(tx); err != nil {
+		return &RejectError{Code: RejectPolicy, Err: err}
+	}
+	height := bc.Store.GetBlockCount()
+	if bc.Config.UTXOActive(height) {
+		if err := bc.checkMempoolInputs(&tx, height); err != nil {
+			return err
+		}
+	} else if len(tx.Inputs) > 0 {
+		return fmt.Errorf("transaction inputs require the utxo model")
+	}
+	if tx.Type == "transfer" || tx.Type == "burn" {
+		if balance := bc.balances.get(tx.From); balance < tx.Amount+tx.Fee {
 			return fmt.Errorf("insufficient balance: have %.8f, need %.8f",
-				bc.Balances[tx.From], tx.Amount+tx.Fee)
+				balance, tx.Amount+tx.Fee)
 		}
 	}
-	if tx.Type == "stake" {
-		available := bc.Balances[tx.From] - bc.Stakes.GetStake(tx.From)
+	if addsStake(&tx) {
+		available := bc.balances.get(tx.From) - bc.Stakes.GetStake(tx.From)
 		if available < tx.Amount {
 			return fmt.Errorf("insufficient available balance for staking")
 		}
+	}
+	if tx.Type == "stake" {
 		if tx.Amount < bc.Config.MinStakeAmount {
 			return fmt.Errorf("minimum stake is %.2f %s", bc.Config.MinStakeAmount, bc.Config.Ticker)
 		}
 		totalStake := bc.Stakes.GetStake(tx.From) + tx.Amount
-		if totalStake < bc.Config.POSMinThreshold {
+		threshold := bc.govParam(ParamPOSMinThreshold, bc.Store.GetBlockCount())
+		if totalStake < threshold && bc.Stakes.delegatedTo(tx.From) == "" {
 			return fmt.Errorf("total stake must be at least %.2f %s to participate in PoS",
-				bc.Config.POSMinThreshold, bc.Config.Ticker)
+				threshold, bc.Config.Ticker)
+		}
+	}
+	if tx.Type == "unstake" {
+		staked := bc.Stakes.GetStake(tx.From) - bc.Mempool.pendingUnstake(tx.From)
+		if tx.Amount > staked+0.00000001 {
+			return fmt.Errorf("insufficient stake: have %.8f not already unstaking, want %.8f", staked, tx.Amount)
+		}
+	}
+	if bc.Config.StakeLockActive(height) {
+		if err := bc.checkMempoolStakeLock(&tx, height); err != nil {
+			return err
+		}
+	}
+	if tx.Type == "vote" {
+		if err := bc.checkVote(&tx, bc.voteWeights(bc.Store.GetBlockCount())); err != nil {
+			return err
+		}
+	}
+	if tx.Type == "burn" {
+		if err := bc.checkBurn(&tx); err != nil {
+			return err
+		}
+	}
+	if tx.Type == "delegate" {
+		if err := bc.checkDelegate(&tx, bc.Store.GetBlockCount()); err != nil {
+			return err
+		}
+		if err := bc.checkMempoolDelegate(&tx); err != nil {
+			return err
+		}
+	}
+	if !bc.isKnownTxType(tx.Type) {
+		return fmt.Errorf("unknown transaction type %q", tx.Type)
+	}
+	state := &lockedState{bc: bc, height: height}
+	if err := bc.Hooks.validate(&tx, state); err != nil {
+		return err
+	}
+	if err := bc.checkMatureSpend(&tx, height); err != nil {
+		return err
+	}
+	if err := bc.checkConflicts(tx); err != nil {
+		return err
+	}
+	if err := bc.Mempool.checkLimits(tx); err != nil {
+		return &RejectError{Code: RejectPolicy, Err: err}
+	}
+	bc.Mempool.add(tx, state.height)
+	return nil
+}
+
+// checkOverspends rejects a block on an account network that leaves one
+// of its senders with a negative balance once all its transactions are
+// staged, in order, on top of the unbonds it releases. The caller holds
+// bc.mu.
+func (bc *Blockchain) checkOverspends(block *Block) error {
+	height := block.Header.Height
+	ledger := &lockedState{bc: bc, height: height, changed: make(map[string]float64), stakes: newStakeChanges(bc.Stakes)}
+	for _, u := range bc.dueUnbonds(height) {
+		ledger.adjust(u.Address, u.Amount)
+	}
+	for i := range block.Transactions {
+		if _, _, err := bc.stageTx(&block.Transactions[i], ledger, ledger.adjust); err != nil {
+			return err
+		}
+	}
+	for i := range block.Transactions {
+		tx := &block.Transactions[i]
+		if tx.From == "" || producerOnly(tx.Type) {
+			continue
+		}
+		if bal := ledger.Balance(tx.From); ToUnits(bal) < 0 {
+			return fmt.Errorf("tx %s: %s overspends its balance, left with %.8f", tx.TxID, tx.From, bal)
 		}
 	}
-	bc.Mempool = append(bc.Mempool, tx)
 	return nil
 }
 
+// producerOnly reports whether transactions of txType are only put in a
+// block by its producer and never come from the mempool.
+func producerOnly(txType string) bool {
+	switch txType {
+	case "coinbase", "pos_reward", "slash":
+		return true
+	}
+	return false
+}
+
+func (bc *Blockchain) isKnownTxType(txType string) bool {
+	switch txType {
+	case "coinbase", "pos_reward", "transfer", "stake", "unstake", "vote", "burn", "slash", "delegate":
+		return true
+	}
+	_, ok := bc.Hooks.applier(txType)
+	return ok
+}
+
 func (bc *Blockchain) GetMempool() []Transaction {
 	bc.mu.RLock()
 	defer bc.mu.RUnlock()
-	cp := make([]Transaction, len(bc.Mempool))
-	copy(cp, bc.Mempool)
-	return cp
+	return bc.Mempool.Transactions()
+}
+
+// GetPendingTx returns a transaction from the mempool.
+func (bc *Blockchain) GetPendingTx(txid string) (Transaction, bool) {
+	bc.mu.RLock()
+	defer bc.mu.RUnlock()
+	return bc.Mempool.Get(txid)
+}
+
+// GetMempoolSize returns the number of pending transactions.
+func (bc *Blockchain) GetMempoolSize() int {
+	bc.mu.RLock()
+	defer bc.mu.RUnlock()
+	return bc.Mempool.Len()
+}
+
+// selectTemplate returns the pending transactions a template for height
+// includes, in block order, after making room for the reward transactions
+// paying subsidy to producer. The caller holds bc.mu.
+func (bc *Blockchain) selectTemplate(height uint64, producer string, subsidy float64) []Transaction {
+	// Reward transactions have the same count and size whatever the fees,
+	// so build them once without fees to size the budget for the rest.
+	rewards := bc.Engine.Rewards(height, producer, subsidy, 0)
+	budget := templateBudget{
+		txs:   int(bc.Config.MaxBlockTransactions) - len(rewards),
+		bytes: int(bc.Config.MaxBlockSize) - blockOverhead(int(bc.Config.MaxBlockTransactions)),
+	}
+	for i := range rewards {
+		budget.bytes -= txSlot(rewards[i].Size())
+	}
+	if budget.txs <= 0 || budget.bytes <= 0 {
+		return nil
+	}
+	prioritySlots := 0
+	if bc.Config.PriorityBlockPercent > 0 {
+		prioritySlots = budget.txs * bc.Config.PriorityBlockPercent / 100
+	}
+	return bc.Mempool.selectPackages(budget, prioritySlots, height)
 }
 
 func (bc *Blockchain) CreateBlockTemplate(minerAddress string) *Block {
@@ -319,51 +657,23 @@ func (bc *Blockchain) CreateBlockTemplate(minerAddress string) *Block {
 
 	height := bc.Store.GetBlockCount()
 	prevHash := strings.Repeat("0", 64)
-	prevBits := bc.Config.MinDifficultyBits
-
 	if bc.lastBlock != nil && height > 0 {
 		prevHash = bc.lastBlock.Hash
-		prevBits = bc.lastBlock.Header.Bits
 	}
 
-	totalReward := bc.CalcBlockReward(height)
-	powReward := totalReward * bc.Config.POWRewardShare
-	posReward := totalReward * bc.Config.POSRewardShare
-
-	var txs []Transaction
-	posOutputs := bc.Stakes.CalcPOSRewards(posReward, bc.Config.POSMinThreshold)
-	if len(posOutputs) > 0 {
-		txs = append(txs, NewCoinbaseTransaction(minerAddress, powReward, height))
-		posTx := Transaction{
-			Type:      "pos_reward",
-			Amount:    posReward,
-			Timestamp: time.Now().Unix(),
-			Outputs:   posOutputs,
-		}
-		posTx.TxID = posTx.ComputeTxID()
-		txs = append(txs, posTx)
-	} else {
-		txs = append(txs, NewCoinbaseTransaction(minerAddress, totalReward, height))
-	}
-
-	maxTxs := int(bc.Config.MaxBlockTransactions) - len(txs)
-	if maxTxs > len(bc.Mempool) {
-		maxTxs = len(bc.Mempool)
-	}
-	if maxTxs > 0 {
-		txs = append(txs, bc.Mempool[:maxTxs]...)
+	subsidy := bc.CalcBlockReward(height)
+	pending := bc.selectTemplate(height, minerAddress, subsidy)
+	txs := bc.Engine.Rewards(height, minerAddress, subsidy, bc.collectedFees(pending))
+	if bc.Config.UTXOActive(height) {
+		stampRewards(txs, height)
 	}
+	txs = append(txs, pending...)
+	txs = append(txs, bc.slashTransactions(height, txs, int(bc.Config.MaxBlockTransactions)-len(txs))...)
 
-	bits := prevBits
-	if height > 0 && height%bc.Config.DifficultyAdjustInterval == 0 {
-		bits = bc.calcNextBitsFromDB()
-	}
-	bits = ApplyProgressiveDifficulty(bits, height,
-		bc.Config.DifficultyEpochBlocks, bc.Config.MinDifficultyBits)
-
+	bits := bc.Engine.NextBits(height)
 	merkle := ComputeMerkleRoot(txs)
 	header := BlockHeader{
-		Version:    2,
+		Version:    bc.blockVersion(height),
 		PrevHash:   prevHash,
 		MerkleRoot: merkle,
 		Timestamp:  time.Now().Unix(),
@@ -371,79 +681,138 @@ func (bc *Blockchain) CreateBlockTemplate(minerAddress string) *Block {
 		Nonce:      0,
 		Height:     height,
 	}
+	if bc.Config.TimestampRulesActive(height) {
+		header.Timestamp = max(header.Timestamp, bc.medianTimePast()+1)
+	}
 	return &Block{Header: header, Transactions: txs}
 }
 
-func (bc *Blockchain) calcNextBitsFromDB() uint32 {
+func (bc *Blockchain) calcNextBitsFromDB(height uint64) uint32 {
 	interval := bc.Config.DifficultyAdjustInterval
-	rawBlocks, err := bc.Store.GetRecentBlocks(interval)
-	if err != nil || uint64(len(rawBlocks)) < interval {
+	clip := bc.Config.TimestampRulesActive(height)
+	count := interval
+	if clip {
+		count++
+	}
+	blocks := bc.recentBlocks(count)
+	if uint64(len(blocks)) < interval {
 		if bc.lastBlock != nil {
 			return bc.lastBlock.Header.Bits
 		}
 		return bc.Config.MinDifficultyBits
 	}
+	return CalcNextBits(blocks, interval,
+		bc.Config.BlockTimeSeconds, bc.Config.MinDifficultyBits, clip)
+}
+
+// recentBlocks returns up to count blocks ending at the tip, oldest first.
+// The caller holds bc.mu.
+func (bc *Blockchain) recentBlocks(count uint64) []*Block {
+	rawBlocks, err := bc.Store.GetRecentBlocks(count)
+	if err != nil {
+		return nil
+	}
 	blocks := make([]*Block, len(rawBlocks))
 	for i, raw := range rawBlocks {
 		var b Block
 		json.Unmarshal(raw, &b)
 		blocks[i] = &b
 	}
-	return CalcNextBits(blocks, interval,
-		bc.Config.BlockTimeSeconds, bc.Config.MinDifficultyBits)
+	return blocks
+}
+
+// medianTimePast returns the median timestamp of the blocks ending at the
+// tip. The caller holds bc.mu.
+func (bc *Blockchain) medianTimePast() int64 {
+	return MedianTimePast(bc.recentBlocks(MedianTimeSpan))
+}
+
+// MedianTimePast returns the median timestamp of the last MedianTimeSpan
+// blocks; a new block's timestamp must exceed it once timestamp rules are
+// active.
+func (bc *Blockchain) MedianTimePast() int64 {
+	bc.mu.RLock()
+	defer bc.mu.RUnlock()
+	return bc.medianTimePast()
 }
 
+// AddBlock validates and connects block to the tip and publishes
+// EventBlockConnected, then EventTxRemoved for pending transactions the
+// block made unspendable.
 func (bc *Blockchain) AddBlock(block *Block) error {
+	dropped, released, err := bc.addBlock(block)
+	if err != nil {
+		return err
+	}
+	bc.Events.Publish(Event{Type: EventBlockConnected, Block: block, Released: released})
+	for i := range dropped {
+		bc.Events.Publish(Event{Type: EventTxRemoved, Tx: &dropped[i], Reason: RemovedConflict})
+	}
+	return nil
+}
+
+// addBlock connects block and returns the pending transactions it pushed
+// out of the mempool, other than its own, and the unbonds it released.
+func (bc *Blockchain) addBlock(block *Block) ([]Transaction, []Unbond, error) {
 	bc.mu.Lock()
 	defer bc.mu.Unlock()
 
 	if err := bc.validateBlock(block); err != nil {
-		return fmt.Errorf("validation failed: %w", err)
+		return nil, nil, fmt.Errorf("validation failed: %w", err)
 	}
 
+	// Everything is staged and only applied to the runtime state once the
+	// block is committed, so a failed commit leaves memory matching disk.
 	changedBalances := make(map[string]float64)
-	changedStakes := make(map[string][]byte)
+	stakes := newStakeChanges(bc.Stakes)
+	ledger := &lockedState{bc: bc, height: block.Header.Height, changed: changedBalances, stakes: stakes}
 	var blockMinted float64
+	released := bc.dueUnbonds(block.Header.Height)
+	var queued []*Unbond
 
-	for _, tx := range block.Transactions {
-		switch tx.Type {
-		case "coinbase":
-			for _, out := range tx.Outputs {
-				bc.Balances[out.Address] += out.Amount
-				changedBalances[out.Address] = bc.Balances[out.Address]
-				blockMinted += out.Amount
-			}
-		case "pos_reward":
-			for _, out := range tx.Outputs {
-				bc.Balances[out.Address] += out.Amount
-				changedBalances[out.Address] = bc.Balances[out.Address]
-				blockMinted += out.Amount
-			}
-		case "transfer":
-			bc.Balances[tx.From] -= tx.Amount + tx.Fee
-			bc.Balances[tx.To] += tx.Amount
-			changedBalances[tx.From] = bc.Balances[tx.From]
-			changedBalances[tx.To] = bc.Balances[tx.To]
-		case "stake":
-			bc.Balances[tx.From] -= tx.Amount
-			changedBalances[tx.From] = bc.Balances[tx.From]
-			bc.Stakes.AddStake(tx.From, tx.Amount, block.Header.Height)
-			sJSON, _ := json.Marshal(bc.Stakes.Stakes[tx.From])
-			changedStakes[tx.From] = sJSON
-		case "unstake":
-			bc.Stakes.RemoveStake(tx.From, tx.Amount)
-			bc.Balances[tx.From] += tx.Amount
-			changedBalances[tx.From] = bc.Balances[tx.From]
-			if s, ok := bc.Stakes.Stakes[tx.From]; ok {
-				sJSON, _ := json.Marshal(s)
-				changedStakes[tx.From] = sJSON
-			} else {
-				changedStakes[tx.From] = nil
-			}
+	// On utxo networks balances follow the outputs created and spent.
+	adjust := ledger.adjust
+	var utxos *utxoView
+	if bc.Config.UTXOActive(block.Header.Height) {
+		var err error
+		if utxos, err = bc.connectUTXOs(block); err != nil {
+			return nil, nil, fmt.Errorf("validation failed: %w", err)
+		}
+		for _, u := range released {
+			utxos.release(u, block.Header.Height)
 		}
+		for addr, delta := range utxos.deltas {
+			ledger.adjust(addr, delta)
+		}
+		adjust = func(string, float64) {}
+	}
+	for _, u := range released {
+		adjust(u.Address, u.Amount)
 	}
 
-	bc.TotalMinted += blockMinted
+	for i := range block.Transactions {
+		minted, unbond, err := bc.stageTx(&block.Transactions[i], ledger, adjust)
+		if err != nil {
+			return nil, nil, err
+		}
+		blockMinted += minted
+		if unbond != nil {
+			queued = append(queued, unbond)
+		}
+	}
+
+	paid := rewardOutputs(block)
+	if err := bc.checkMatureBalances(block, ledger, paid); err != nil {
+		return nil, nil, fmt.Errorf("validation failed: %w", err)
+	}
+
+	totalMinted := bc.TotalMinted + blockMinted
+	burned := bc.blockBurned(block)
+	changedReceived := make(map[string]float64)
+	bc.stageReceived(block, changedReceived)
+	gov := bc.nextGovState(block)
+	nextUnbonding, changedUnbonding := bc.unbondingChanges(len(released), queued)
+	nonces, _ := bc.blockNonces(block) // checked by validateBlock
 
 	blockJSON, _ := json.Marshal(block)
 	commit := &storage.BlockCommit{
@@ -451,73 +820,300 @@ func (bc *Blockchain) AddBlock(block *Block) error {
 		Hash:        block.Hash,
 		BlockJSON:   blockJSON,
 		Balances:    changedBalances,
-		Stakes:      changedStakes,
+		Received:    changedReceived,
+		Stakes:      stakes.records(),
+		Unbonding:   changedUnbonding,
 		TxIDs:       collectTxIDs(block),
-		TotalMinted: bc.TotalMinted,
+		TotalMinted: totalMinted,
+		Stats:       blockDayStats(block),
+		Rewards:     blockRewards(block),
+		Produced:    blockProducer(block),
+		Checkpoint:  bc.openCheckpoint(block, stakes),
+		Nonces:      nonces,
+		Timestamp:   block.Header.Timestamp,
+	}
+	chainWork := new(big.Int).Add(bc.chainWork, BlockWork(block.Header.Bits))
+	commit.ChainWork = chainWork.Bytes()
+	commit.Meta = make(map[string][]byte)
+	if burned > 0 {
+		commit.Meta[metaTotalBurned] = []byte(strconv.FormatFloat(bc.TotalBurned+burned, 'g', -1, 64))
+	}
+	if gov != nil {
+		govJSON, _ := json.Marshal(gov)
+		commit.Meta[metaGovState] = govJSON
+	}
+	slashes := blockSlashes(block)
+	for key, txid := range slashes {
+		commit.Meta[key] = txid
+	}
+	if bc.archive != nil && bc.archive.ready {
+		commit.Archive = archiveCommit(block)
+	}
+	if utxos != nil {
+		commit.UTXOs = utxos.changes()
+		if block.Header.Height == bc.Config.UTXOActivationHeight {
+			commit.Meta[metaUTXOActivated] = []byte(strconv.FormatUint(block.Header.Height, 10))
+		}
 	}
 	if err := bc.Store.CommitBlock(commit); err != nil {
-		return fmt.Errorf("db commit failed: %w", err)
+		return nil, nil, fmt.Errorf("db commit failed: %w", err)
+	}
+	bc.balances.update(changedBalances)
+	stakes.commit()
+	bc.TotalMinted = totalMinted
+	bc.TotalBurned += burned
+	bc.unbonding = nextUnbonding
+	bc.chainWork = chainWork
+	bc.recordMaturing(block, paid)
+	if gov != nil {
+		bc.gov = gov
 	}
 
-	processed := make(map[string]bool)
 	for _, tx := range block.Transactions {
-		processed[tx.TxID] = true
+		bc.Mempool.remove(tx.TxID)
 	}
-	var remaining []Transaction
-	for _, tx := range bc.Mempool {
-		if !processed[tx.TxID] {
-			remaining = append(remaining, tx)
-		}
+	for key := range slashes {
+		delete(bc.evidence, strings.TrimPrefix(key, metaSlashedPrefix))
+	}
+	var dropped []Transaction
+	if utxos != nil {
+		dropped = bc.Mempool.removeSpenders(utxos.spent)
+	} else if bc.Config.UTXOActive(block.Header.Height + 1) {
+		dropped = bc.Mempool.removeUnfunded()
+	}
+	if bc.Config.SignatureRulesHeight == block.Header.Height+1 {
+		dropped = append(dropped, bc.Mempool.removeUnsigned()...)
+	}
+	if nonces != nil {
+		dropped = append(dropped, bc.Mempool.removeStaleNonces(nonces)...)
+	}
+	if bc.Config.NonceRulesHeight == block.Header.Height+1 {
+		dropped = append(dropped, bc.Mempool.removeNonceless()...)
+	}
+	if bc.Config.MaturityRulesHeight == block.Header.Height+1 {
+		dropped = append(dropped, bc.removeImmatureSpends(block.Header.Height+1)...)
+	}
+	if bc.Config.TxLimitsHeight == block.Header.Height+1 {
+		dropped = append(dropped, bc.removeOverLimit(block.Header.Height+1)...)
+	}
+	if bc.Config.StakeLockActive(block.Header.Height + 1) {
+		dropped = append(dropped, bc.removeLockedUnstakes(block)...)
 	}
-	bc.Mempool = remaining
 	bc.lastBlock = block
 
-	log.Printf("[CHAIN] Block #%d added: %s (txs: %d, minted: %.2f, total: %.2f/%.2f)",
-		block.Header.Height, block.Hash[:16]+"...", len(block.Transactions),
-		blockMinted, bc.TotalMinted, bc.Config.MaxSupply)
-	return nil
+	logger.Info("block added", "height", block.Header.Height, "hash", block.Hash[:16]+"...",
+		"txs", len(block.Transactions), "minted", blockMinted,
+		"total", bc.TotalMinted, "max_supply", bc.Config.MaxSupply)
+	releasedUnbonds := make([]Unbond, len(released))
+	for i, u := range released {
+		releasedUnbonds[i] = *u
+	}
+	return dropped, releasedUnbonds, nil
+}
+
+// stageTx applies tx's balance and stake changes to ledger, moving coins
+// through adjust, and returns what tx mints and the unbond it queues, if
+// any. The caller holds bc.mu.
+func (bc *Blockchain) stageTx(tx *Transaction, ledger *lockedState, adjust func(string, float64)) (float64, *Unbond, error) {
+	height, stakes := ledger.height, ledger.stakes
+	var minted float64
+	switch tx.Type {
+	case "coinbase", "pos_reward":
+		for _, out := range tx.Outputs {
+			adjust(out.Address, out.Amount)
+			minted += out.Amount
+		}
+	case "transfer":
+		adjust(tx.From, -(tx.Amount + tx.Fee))
+		adjust(tx.To, tx.Amount)
+	case "stake", "delegate":
+		adjust(tx.From, -tx.Amount)
+		stakes.add(tx.From, tx.Amount, height)
+		if bc.Config.StakeLockActive(height) {
+			stakes.relock(tx.From, height)
+		}
+		if tx.Type == "delegate" {
+			stakes.delegate(tx.From, tx.To)
+		}
+	case "unstake":
+		stakes.remove(tx.From, tx.Amount)
+		if bc.Config.UnbondingActive(height) {
+			return 0, &Unbond{TxID: tx.TxID, Address: tx.From, Amount: tx.Amount,
+				Height: height, ReleaseHeight: bc.UnbondRelease(height)}, nil
+		}
+		adjust(tx.From, tx.Amount)
+	case "vote":
+		adjust(tx.From, -tx.Fee)
+	case "burn":
+		adjust(tx.From, -(tx.Amount + tx.Fee))
+	case "slash":
+		stakes.remove(tx.To, tx.Amount)
+	default:
+		apply, _ := bc.Hooks.applier(tx.Type)
+		if err := apply(tx, ledger); err != nil {
+			return 0, nil, fmt.Errorf("apply %s tx %s: %w", tx.Type, tx.TxID, err)
+		}
+	}
+	return minted, nil, nil
 }
 
 func (bc *Blockchain) validateBlock(block *Block) error {
 	expectedHeight := bc.Store.GetBlockCount()
 	if block.Header.Height != expectedHeight {
-		return fmt.Errorf("bad height: expected %d, got %d", expectedHeight, block.Header.Height)
+		code := RejectUnconnected
+		if data, _ := bc.Store.GetBlockByHash(block.Hash); data != nil {
+			code = RejectDuplicate
+		} else if block.Header.Height <= bc.finalized {
+			return rejectf(RejectInvalid, "block %d conflicts with finalized checkpoint %d",
+				block.Header.Height, bc.finalized)
+		} else if cp := bc.passedCheckpoint(); cp > 0 && block.Header.Height <= cp {
+			return rejectf(RejectInvalid, "block %d forks below checkpoint %d", block.Header.Height, cp)
+		}
+		return rejectf(code, "bad height: expected %d, got %d", expectedHeight, block.Header.Height)
 	}
 	if expectedHeight > 0 && bc.lastBlock != nil {
 		if block.Header.PrevHash != bc.lastBlock.Hash {
-			return fmt.Errorf("bad prev hash")
+			return rejectf(RejectUnconnected, "bad prev hash")
 		}
 	}
 	computed := block.Header.ComputeHash()
 	if block.Hash != computed {
 		return fmt.Errorf("bad hash: computed %s, got %s", computed, block.Hash)
 	}
-	if !CheckProofOfWork(block.Hash, block.Header.Bits) {
-		return fmt.Errorf("insufficient proof of work")
+	if err := bc.checkBlockCheckpoint(block); err != nil {
+		return err
+	}
+	if err := checkTxCommitments(block); err != nil {
+		return err
+	}
+	if limit := time.Now().Unix() + MaxFutureBlockTime; block.Header.Timestamp > limit {
+		return rejectf(RejectPolicy, "timestamp %d is more than %ds in the future",
+			block.Header.Timestamp, MaxFutureBlockTime)
+	}
+	if bc.Config.TimestampRulesActive(expectedHeight) {
+		if err := bc.checkTimestampRules(block); err != nil {
+			return err
+		}
+	}
+	if err := bc.Engine.VerifySeal(block); err != nil {
+		return err
+	}
+	state := &lockedState{bc: bc, height: block.Header.Height}
+	addressRules := bc.Config.AddressRulesActive(block.Header.Height)
+	assumeSigned := bc.belowCheckpoint(block.Header.Height)
+	var weights map[string]float64
+	for i := range block.Transactions {
+		tx := &block.Transactions[i]
+		if !bc.isKnownTxType(tx.Type) {
+			return fmt.Errorf("unknown transaction type %q in tx %s", tx.Type, tx.TxID)
+		}
+		if addressRules {
+			if err := bc.checkAddresses(tx); err != nil {
+				return fmt.Errorf("tx %s: %w", tx.TxID, err)
+			}
+		}
+		if !assumeSigned {
+			if err := bc.checkSignature(tx, block.Header.Height); err != nil {
+				return fmt.Errorf("tx %s: %w", tx.TxID, err)
+			}
+		}
+		if err := bc.checkTxLimits(tx, block.Header.Height); err != nil {
+			return fmt.Errorf("tx %s: %w", tx.TxID, err)
+		}
+		if len(tx.Inputs) > 0 && !bc.Config.UTXOActive(block.Header.Height) {
+			return fmt.Errorf("tx %s has inputs before the utxo model is active", tx.TxID)
+		}
+		if tx.Type == "burn" {
+			if err := bc.checkBurn(tx); err != nil {
+				return fmt.Errorf("tx %s: %w", tx.TxID, err)
+			}
+		}
+		if tx.Type == "delegate" {
+			if err := bc.checkDelegate(tx, block.Header.Height); err != nil {
+				return fmt.Errorf("tx %s: %w", tx.TxID, err)
+			}
+		}
+		if tx.Type == "vote" {
+			if weights == nil {
+				weights = bc.voteWeights(block.Header.Height)
+			}
+			if err := bc.checkVote(tx, weights); err != nil {
+				return fmt.Errorf("tx %s: %w", tx.TxID, err)
+			}
+		}
+		if err := bc.Hooks.validate(tx, state); err != nil {
+			return fmt.Errorf("tx %s: %w", tx.TxID, err)
+		}
+	}
+	if bc.Config.UnbondingActive(block.Header.Height) || bc.Config.BalanceRulesActive(block.Header.Height) {
+		if err := bc.checkUnstakes(block); err != nil {
+			return err
+		}
+	}
+	if bc.Config.BalanceRulesActive(block.Header.Height) && !bc.Config.UTXOActive(block.Header.Height) {
+		if err := bc.checkOverspends(block); err != nil {
+			return err
+		}
+	}
+	if bc.Config.StakeLockActive(block.Header.Height) {
+		if err := bc.checkStakeLocks(block); err != nil {
+			return err
+		}
+	}
+	if err := bc.checkSlashes(block); err != nil {
+		return err
+	}
+	if _, err := bc.blockNonces(block); err != nil {
+		return err
+	}
+	if err := bc.checkBlockReward(block); err != nil {
+		return err
 	}
 	if uint64(len(block.Transactions)) > bc.Config.MaxBlockTransactions {
 		return fmt.Errorf("too many transactions: %d > %d",
 			len(block.Transactions), bc.Config.MaxBlockTransactions)
 	}
-	blockData, _ := json.Marshal(block)
-	if uint64(len(blockData)) > bc.Config.MaxBlockSize {
-		return fmt.Errorf("block too large: %d bytes > %d",
-			len(blockData), bc.Config.MaxBlockSize)
+	if size := block.Size(); uint64(size) > bc.Config.MaxBlockSize {
+		return fmt.Errorf("block too large: %d bytes > %d", size, bc.Config.MaxBlockSize)
+	}
+	return nil
+}
+
+// checkTimestampRules rejects a block whose timestamp is not after
+// median-time-past, or whose bits differ from the retarget result. Blocks
+// too far in the future are refused by validateBlock at any height.
+func (bc *Blockchain) checkTimestampRules(block *Block) error {
+	if mtp := bc.medianTimePast(); block.Header.Timestamp <= mtp {
+		return fmt.Errorf("timestamp %d is not after median time past %d", block.Header.Timestamp, mtp)
+	}
+	if want := bc.Engine.NextBits(block.Header.Height); block.Header.Bits != want {
+		return fmt.Errorf("bad bits: expected %08x, got %08x", want, block.Header.Bits)
 	}
-	floorBits := ProgressiveDifficultyFloor(block.Header.Height,
-		bc.Config.DifficultyEpochBlocks, bc.Config.MinDifficultyBits)
-	blockTarget := BitsToTarget(block.Header.Bits)
-	floorTarget := BitsToTarget(floorBits)
-	if blockTarget.Cmp(floorTarget) > 0 {
-		return fmt.Errorf("difficulty below progressive floor at height %d", block.Header.Height)
+	return nil
+}
+
+// checkTxCommitments rejects a block whose transactions are not the ones
+// its header commits to. The header hash covers only the merkle root, so
+// transactions swapped or altered after mining must fail here; txids are
+// checked too, since indexes, nonces and outpoints are keyed on them.
+func checkTxCommitments(block *Block) error {
+	if root := ComputeMerkleRoot(block.Transactions); root != block.Header.MerkleRoot {
+		return fmt.Errorf("bad merkle root: computed %s, header has %s", root, block.Header.MerkleRoot)
+	}
+	for i := range block.Transactions {
+		tx := &block.Transactions[i]
+		if id := tx.ComputeTxID(); id != tx.TxID {
+			return fmt.Errorf("tx %s: computed txid %s", tx.TxID, id)
+		}
 	}
 	return nil
 }
 
-func (bc *Blockchain) GetBlocks(startHeight uint64) []*Block {
+// GetBlocks returns up to limit blocks from startHeight (0 for no limit).
+func (bc *Blockchain) GetBlocks(startHeight uint64, limit int) []*Block {
 	bc.mu.RLock()
 	defer bc.mu.RUnlock()
-	rawBlocks, err := bc.Store.GetBlocksFrom(startHeight)
+	rawBlocks, err := bc.Store.GetBlocksFrom(startHeight, limit)
 	if err != nil {
 		return nil
 	}
@@ -0,0 +1,167 @@
+package blockchain
+
+import (
+	"crypto/ed25519"
+	"devinsidercoin/internal/address"
+	"devinsidercoin/internal/config"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testMiner is a valid regtest address.
+const testMiner = "rDVCVuguUhmWMt3PqtXMmT6bwBFGKGX1hMLpS"
+
+func newRegtestChain(t *testing.T) *Blockchain {
+	t.Helper()
+	cfg, err := config.LoadNetwork("regtest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	bc, err := NewBlockchain(cfg, t.TempDir(), Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(bc.Close)
+	return bc
+}
+
+// mineAt adds a block to bc with the given timestamp.
+func mineAt(t *testing.T, bc *Blockchain, timestamp int64) {
+	t.Helper()
+	block := bc.CreateBlockTemplate(testMiner)
+	block.Header.Timestamp = timestamp
+	if !SolveBlock(block, 1<<32) {
+		t.Fatal("failed to solve block")
+	}
+	if err := bc.AddBlock(block); err != nil {
+		t.Fatalf("add block at %d: %v", timestamp, err)
+	}
+}
+
+func TestCheckTimestampRules(t *testing.T) {
+	bc := newRegtestChain(t)
+	now := time.Now().Unix()
+	for _, ts := range []int64{now - 300, now - 100, now - 200, now - 50} {
+		mineAt(t, bc, ts)
+	}
+	mtp := bc.MedianTimePast()
+	if mtp != now-200 {
+		t.Fatalf("median time past %d, want %d", mtp, now-200)
+	}
+	template := bc.CreateBlockTemplate(testMiner)
+
+	tests := []struct {
+		name      string
+		timestamp int64
+		bits      uint32
+		ok        bool
+	}{
+		{"template", template.Header.Timestamp, template.Header.Bits, true},
+		{"just after median time past", mtp + 1, template.Header.Bits, true},
+		{"before the previous block", now - 150, template.Header.Bits, true},
+		{"at median time past", mtp, template.Header.Bits, false},
+		{"before median time past", mtp - 1, template.Header.Bits, false},
+		{"two hours ahead", now + MaxFutureBlockTime - 60, template.Header.Bits, true},
+		{"other bits", now, template.Header.Bits - 1, false},
+	}
+	for _, tt := range tests {
+		block := *template
+		block.Header.Timestamp, block.Header.Bits = tt.timestamp, tt.bits
+		if err := bc.checkTimestampRules(&block); (err == nil) != tt.ok {
+			t.Errorf("%s: checkTimestampRules = %v, want ok %v", tt.name, err, tt.ok)
+		}
+	}
+}
+
+func TestFarFutureBlockIsRefusedAsPolicy(t *testing.T) {
+	bc := newRegtestChain(t)
+	bc.Config.TimestampRulesHeight = 0
+	block := bc.CreateBlockTemplate(testMiner)
+	block.Header.Timestamp = time.Now().Unix() + MaxFutureBlockTime + 60
+	if !SolveBlock(block, 1<<32) {
+		t.Fatal("failed to solve block")
+	}
+	err := bc.AddBlock(block)
+	if err == nil || RejectCode(err) != RejectPolicy {
+		t.Fatalf("AddBlock = %v (%s), want a policy reject", err, RejectCode(err))
+	}
+}
+
+func TestCheckConfigHashAllowsMovingScheduledRules(t *testing.T) {
+	dir := t.TempDir()
+	open := func(rewardRules uint64) error {
+		cfg, err := config.LoadNetwork("regtest")
+		if err != nil {
+			t.Fatal(err)
+		}
+		cfg.RewardRulesHeight = rewardRules
+		bc, err := NewBlockchain(cfg, dir, Options{})
+		if err != nil {
+			return err
+		}
+		defer bc.Close()
+		if bc.GetBlockCount() < 3 {
+			mineAt(t, bc, time.Now().Unix())
+			mineAt(t, bc, time.Now().Unix()+1)
+		}
+		return nil
+	}
+	tests := []struct {
+		name        string
+		rewardRules uint64
+		ok          bool
+	}{
+		{"scheduled", 100, true},
+		{"postponed", 200, true},
+		{"brought forward above the tip", 50, true},
+		{"brought below the tip", 2, false},
+		{"dropped", 0, true},
+		{"back to the recorded schedule", 50, true},
+	}
+	for _, tt := range tests {
+		if err := open(tt.rewardRules); (err == nil) != tt.ok {
+			t.Errorf("%s: NewBlockchain = %v, want ok %v", tt.name, err, tt.ok)
+		}
+	}
+}
+
+func TestBalanceRules(t *testing.T) {
+	pub, _, _ := ed25519.GenerateKey(nil)
+	tests := []struct {
+		name string
+		tx   Transaction
+		want string
+	}{
+		{"overspend", Transaction{Type: "transfer", To: testMiner, Amount: 5}, "overspends its balance"},
+		{"unstake more than staked", Transaction{Type: "unstake", Amount: 5}, "exceeds the 0.00000000 staked"},
+	}
+	for _, tt := range tests {
+		for _, active := range []bool{false, true} {
+			bc := newRegtestChain(t)
+			cfg := bc.Config
+			cfg.SignatureRulesHeight, cfg.NonceRulesHeight, cfg.MaturityRulesHeight = 0, 0, 0
+			cfg.UnbondingHeight, cfg.StakeLockHeight = 0, 0
+			cfg.BalanceRulesHeight = 0
+			if active {
+				cfg.BalanceRulesHeight = 1
+			}
+			tx := tt.tx
+			tx.Version, tx.From = TxVersionCanonical, address.FromPublicKey(cfg.AddressPrefix, pub)
+			tx.TxID = tx.ComputeTxID()
+			block := bc.CreateBlockTemplate(testMiner)
+			block.Transactions = append(block.Transactions, tx)
+			block.Header.MerkleRoot = ComputeMerkleRoot(block.Transactions)
+			if !SolveBlock(block, 1<<32) {
+				t.Fatal("failed to solve block")
+			}
+			err := bc.AddBlock(block)
+			if active && (err == nil || !strings.Contains(err.Error(), tt.want)) {
+				t.Errorf("%s: AddBlock = %v, want %q", tt.name, err, tt.want)
+			}
+			if !active && err != nil {
+				t.Errorf("%s before the rules: AddBlock = %v", tt.name, err)
+			}
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package blockchain
+
+import (
+	"devinsidercoin/internal/config"
+	"errors"
+	"fmt"
+	"math/big"
+)
+
+// ErrSyncing means the best chain is still below the network's minimum
+// chain work or height, so balances read from it can't be trusted yet.
+var ErrSyncing = errors.New("node syncing")
+
+// FormatChainWork renders work as 64 hex digits, the form used by the API
+// and the P2P version message.
+func FormatChainWork(work *big.Int) string {
+	return fmt.Sprintf("%064x", work)
+}
+
+// GetChainWork returns the cumulative work of the best chain. Sync peers
+// are ranked by it rather than by height, since a long chain of
+// low-difficulty blocks is cheap to make. It plays no part in choosing
+// between forks: the chain only ever extends its tip.
+func (bc *Blockchain) GetChainWork() *big.Int {
+	bc.mu.RLock()
+	defer bc.mu.RUnlock()
+	return new(big.Int).Set(bc.chainWork)
+}
+
+// CheckSynced returns ErrSyncing until the best chain has at least the
+// configured min_chain_work and min_chain_height.
+func (bc *Blockchain) CheckSynced() error {
+	bc.mu.RLock()
+	defer bc.mu.RUnlock()
+	var height uint64
+	if bc.lastBlock != nil {
+		height = bc.lastBlock.Header.Height
+	}
+	if height < bc.Config.MinChainHeight {
+		return fmt.Errorf("%w: height %d, need %d", ErrSyncing, height, bc.Config.MinChainHeight)
+	}
+	need, _ := config.ParseChainWork(bc.Config.MinChainWork) // checked by ParseConfig
+	if bc.chainWork.Cmp(need) < 0 {
+		return fmt.Errorf("%w: chain work %s, need %s", ErrSyncing, FormatChainWork(bc.chainWork), FormatChainWork(need))
+	}
+	return nil
+}
+
+// ChainWorkAt returns the cumulative work of the chain up to height, or
+// nil if there is no block at height.
+func (bc *Blockchain) ChainWorkAt(height uint64) *big.Int {
+	data := bc.Store.GetChainWork(height)
+	if data == nil {
+		return nil
+	}
+	return new(big.Int).SetBytes(data)
+}
+
+// loadChainWork reads the work of the stored best block.
+func (bc *Blockchain) loadChainWork() {
+	bc.chainWork = new(big.Int).SetBytes(bc.Store.GetChainWork(bc.lastBlock.Header.Height))
+}
+
+// rebuildChainWork sums the work of every stored block, for databases
+// created before chain work was tracked.
+func (bc *Blockchain) rebuildChainWork() error {
+	work := make(map[uint64][]byte)
+	total := new(big.Int)
+	var count uint64
+	for {
+		blocks, err := bc.decodeBlocksFrom(count, indexBatch)
+		if err != nil {
+			return err
+		}
+		if len(blocks) == 0 {
+			break
+		}
+		for i := range blocks {
+			total.Add(total, BlockWork(blocks[i].Header.Bits))
+			work[blocks[i].Header.Height] = total.Bytes()
+		}
+		count += uint64(len(blocks))
+	}
+	logger.Info("built chain work index", "blocks", count, "chainwork", FormatChainWork(total))
+	return bc.Store.RebuildChainWork(work)
+}
@@ -0,0 +1,76 @@
+package blockchain
+
+import "devinsidercoin/internal/storage"
+
+// MaxChartDays caps the days Charts returns.
+const MaxChartDays = 3650
+
+// blockDayStats returns block's contribution to its day's stats. New
+// addresses are counted by the store as the block is committed.
+func blockDayStats(block *Block) *storage.DayStats {
+	st := &storage.DayStats{Blocks: 1}
+	for _, tx := range block.Transactions {
+		switch tx.Type {
+		case "coinbase", "pos_reward":
+			for _, out := range tx.Outputs {
+				st.Minted += out.Amount
+			}
+			continue
+		case "transfer":
+			st.Volume += tx.Amount
+		}
+		st.Transactions++
+		st.Fees += tx.Fee
+	}
+	return st
+}
+
+// rebuildCharts recomputes the daily stats from every stored block, for
+// databases created before they existed.
+func (bc *Blockchain) rebuildCharts() error {
+	days := make(map[uint64]*storage.DayStats)
+	balances := make(map[string]float64)
+	outputs := make(map[string]TxOutput)
+	seen := make(map[string]bool)
+	var count uint64
+	for {
+		blocks, err := bc.decodeBlocksFrom(count, indexBatch)
+		if err != nil {
+			return err
+		}
+		if len(blocks) == 0 {
+			break
+		}
+		for i := range blocks {
+			b := &blocks[i]
+			st := blockDayStats(b)
+			changed, _ := bc.replayBalances(b, balances, outputs)
+			for addr := range changed {
+				if !seen[addr] {
+					seen[addr] = true
+					st.NewAddresses++
+				}
+			}
+			day := storage.Day(b.Header.Timestamp)
+			if days[day] == nil {
+				days[day] = &storage.DayStats{}
+			}
+			days[day].Add(st)
+		}
+		count += uint64(len(blocks))
+	}
+	logger.Info("built daily chart stats", "blocks", count, "days", len(days))
+	return bc.Store.RebuildDailyStats(days)
+}
+
+// Charts returns the daily stats of the last days days that have blocks,
+// oldest first.
+func (bc *Blockchain) Charts(days int) []storage.DayStats {
+	bc.mu.RLock()
+	defer bc.mu.RUnlock()
+	result := bc.Store.DailyStats(min(max(days, 1), MaxChartDays))
+	if result == nil {
+		result = []storage.DayStats{}
+	}
+	return result
+}
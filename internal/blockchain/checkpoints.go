@@ -0,0 +1,57 @@
+package blockchain
+
+import "fmt"
+
+// checkBlockCheckpoint refuses a block at a checkpoint height whose hash
+// is not the checkpoint's: it belongs to a chain that conflicts with one
+// the network config pins.
+func (bc *Blockchain) checkBlockCheckpoint(block *Block) error {
+	want, ok := bc.Config.Checkpoints[block.Header.Height]
+	if ok && block.Hash != want {
+		return rejectf(RejectInvalid, "block %d %s conflicts with checkpoint %s",
+			block.Header.Height, block.Hash, want)
+	}
+	return nil
+}
+
+// passedCheckpoint returns the highest checkpoint height the chain has
+// reached, or 0. Blocks at or below it can only fork from a checkpointed
+// chain. The caller holds bc.mu.
+func (bc *Blockchain) passedCheckpoint() uint64 {
+	best := bc.Store.GetBlockCount()
+	var passed uint64
+	for height := range bc.Config.Checkpoints {
+		if height < best {
+			passed = max(passed, height)
+		}
+	}
+	return passed
+}
+
+// belowCheckpoint reports whether a block at height lies below the highest
+// checkpoint. The checkpoint hash commits to every block beneath it and a
+// chain that doesn't reach it is refused there, so validation skips
+// checking their signatures.
+func (bc *Blockchain) belowCheckpoint(height uint64) bool {
+	return height < bc.Config.LastCheckpoint()
+}
+
+// checkCheckpoints refuses a stored chain that conflicts with a
+// checkpoint, for example one synced before the checkpoint was added.
+func (bc *Blockchain) checkCheckpoints() error {
+	best := bc.Store.GetBlockCount()
+	for height, want := range bc.Config.Checkpoints {
+		if height >= best {
+			continue
+		}
+		b, err := bc.readBlock(height)
+		if err != nil {
+			return err
+		}
+		if b.Hash != want {
+			return fmt.Errorf("block %d %s conflicts with checkpoint %s in the network config; "+
+				"the data directory must be resynced", height, b.Hash, want)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,167 @@
+package blockchain
+
+import (
+	"fmt"
+	"time"
+)
+
+// Consensus types accepted in the network config's consensus_type field.
+const (
+	ConsensusPoW    = "pow"
+	ConsensusPoS    = "pos"
+	ConsensusHybrid = "pow+pos"
+)
+
+// Consensus decides who may produce a block and how the block reward is
+// paid. Engines are called with the chain lock held and must not call
+// locking Blockchain methods.
+type Consensus interface {
+	Name() string
+	// Rewards returns the reward transactions that open a block at height.
+	// Collected fees go to the producer's coinbase.
+	Rewards(height uint64, producer string, reward, fees float64) []Transaction
+	// StakerShare returns the part of reward paid to stakers through the
+	// pos_reward transaction.
+	StakerShare(reward float64) float64
+	// NextBits returns the difficulty bits for a block at height on top of
+	// the current tip.
+	NextBits(height uint64) uint32
+	// VerifySeal checks that block was legitimately produced.
+	VerifySeal(block *Block) error
+}
+
+// newConsensus selects the engine named by the network config. An empty
+// consensus_type means hybrid, matching networks created before the field
+// was read.
+func newConsensus(bc *Blockchain) (Consensus, error) {
+	switch bc.Config.ConsensusType {
+	case ConsensusHybrid, "":
+		return &hybridConsensus{pow: powConsensus{bc}, pos: posConsensus{bc}}, nil
+	case ConsensusPoW:
+		return &powConsensus{bc}, nil
+	case ConsensusPoS:
+		return &posConsensus{bc}, nil
+	}
+	return nil, fmt.Errorf("unknown consensus type %q", bc.Config.ConsensusType)
+}
+
+// powConsensus pays the whole reward to the miner and requires proof of
+// work at a retargeted difficulty.
+type powConsensus struct {
+	bc *Blockchain
+}
+
+func (c powConsensus) Name() string { return ConsensusPoW }
+
+func (c powConsensus) Rewards(height uint64, producer string, reward, fees float64) []Transaction {
+	return []Transaction{NewCoinbaseTransaction(producer, reward+fees, height)}
+}
+
+func (c powConsensus) StakerShare(reward float64) float64 { return 0 }
+
+func (c powConsensus) NextBits(height uint64) uint32 {
+	bc := c.bc
+	bits := bc.Config.MinDifficultyBits
+	if bc.lastBlock != nil && height > 0 {
+		bits = bc.lastBlock.Header.Bits
+	}
+	if height > 0 && height%bc.Config.DifficultyAdjustInterval == 0 && !bc.Config.PowNoRetargeting {
+		bits = bc.calcNextBitsFromDB(height)
+	}
+	return ApplyProgressiveDifficulty(bits, height, FloorCurveFor(bc.Config))
+}
+
+func (c powConsensus) VerifySeal(block *Block) error {
+	if !CheckProofOfWork(block.Hash, block.Header.Bits) {
+		return fmt.Errorf("insufficient proof of work")
+	}
+	floorBits := ProgressiveDifficultyFloor(block.Header.Height, FloorCurveFor(c.bc.Config))
+	if BitsToTarget(block.Header.Bits).Cmp(BitsToTarget(floorBits)) > 0 {
+		return fmt.Errorf("difficulty below progressive floor at height %d", block.Header.Height)
+	}
+	return nil
+}
+
+// posConsensus pays the whole reward to stakers. The producer named in the
+// coinbase must hold an eligible stake. Producer signatures are not yet
+// part of the block format, so pure PoS networks are only suitable for
+// permissioned or test deployments.
+type posConsensus struct {
+	bc *Blockchain
+}
+
+func (c posConsensus) Name() string { return ConsensusPoS }
+
+func (c posConsensus) Rewards(height uint64, producer string, reward, fees float64) []Transaction {
+	txs := []Transaction{NewCoinbaseTransaction(producer, fees, height)}
+	if posTx, ok := c.stakerRewards(height, reward); ok {
+		txs = append(txs, posTx)
+	}
+	return txs
+}
+
+// stakerRewards builds the pos_reward transaction for a block at height,
+// splitting reward among stakers eligible as of the tip.
+func (c posConsensus) stakerRewards(height uint64, reward float64) (Transaction, bool) {
+	threshold := c.bc.govParam(ParamPOSMinThreshold, height)
+	outputs := c.bc.stakeSnapshot().CalcPOSRewards(reward, threshold, c.bc.Config.DelegationCommission)
+	if len(outputs) == 0 {
+		return Transaction{}, false
+	}
+	tx := Transaction{
+		Version:   TxVersionCanonical,
+		Type:      "pos_reward",
+		Amount:    reward,
+		Timestamp: time.Now().Unix(),
+		Outputs:   outputs,
+	}
+	tx.TxID = tx.ComputeTxID()
+	return tx, true
+}
+
+func (c posConsensus) StakerShare(reward float64) float64 { return reward }
+
+func (c posConsensus) NextBits(height uint64) uint32 {
+	return c.bc.Config.MinDifficultyBits
+}
+
+func (c posConsensus) VerifySeal(block *Block) error {
+	if block.Header.Bits != c.bc.Config.MinDifficultyBits {
+		return fmt.Errorf("bad bits for pos block: %08x", block.Header.Bits)
+	}
+	if len(block.Transactions) == 0 || block.Transactions[0].Type != "coinbase" {
+		return fmt.Errorf("pos block has no coinbase naming its producer")
+	}
+	producer := block.Transactions[0].To
+	threshold := c.bc.govParam(ParamPOSMinThreshold, block.Header.Height)
+	if c.bc.stakeSnapshot().GetStake(producer) < threshold {
+		return fmt.Errorf("producer %s has no eligible stake", producer)
+	}
+	return nil
+}
+
+// hybridConsensus is the original DevInsiderCoin scheme: blocks are mined
+// with proof of work, the miner receives pow_reward_share and stakers the
+// rest. With no eligible stakers the miner receives the whole reward.
+type hybridConsensus struct {
+	pow powConsensus
+	pos posConsensus
+}
+
+func (c *hybridConsensus) Name() string { return ConsensusHybrid }
+
+func (c *hybridConsensus) Rewards(height uint64, producer string, reward, fees float64) []Transaction {
+	cfg := c.pow.bc.Config
+	if posTx, ok := c.pos.stakerRewards(height, c.StakerShare(reward)); ok {
+		return []Transaction{NewCoinbaseTransaction(producer, reward*cfg.POWRewardShare+fees, height), posTx}
+	}
+	return c.pow.Rewards(height, producer, reward, fees)
+}
+
+func (c *hybridConsensus) StakerShare(reward float64) float64 {
+	return reward * c.pow.bc.Config.POSRewardShare
+}
+
+func (c *hybridConsensus) NextBits(height uint64) uint32 { return c.pow.NextBits(height) }
+
+func (c *hybridConsensus) VerifySeal(block *Block) error { return c.pow.VerifySeal(block) }
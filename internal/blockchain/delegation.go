@@ -0,0 +1,137 @@
+package blockchain
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+)
+
+// From delegation_height, a delegate transaction stakes its amount like a
+// stake does and puts the sender's whole stake behind the validator in
+// To, another staker with a stake of its own. A validator's group, its
+// own stake plus those delegated to it, must reach the PoS threshold as a
+// whole, so holders too small to stake alone still earn. Delegators keep
+// their share less delegation_commission percent, which goes to the
+// validator. Delegating to one's own address stakes on one's own account
+// again. Governance votes and finality attestations still weigh only the
+// staker's own stake.
+
+// ErrDelegationDisabled means the network has no delegation yet.
+var ErrDelegationDisabled = errors.New("delegation is not active on this network")
+
+// commissionScale is the reward weight of one base unit staked without
+// commission: the commission is applied in basis points.
+const commissionScale = 10000
+
+// addsStake reports whether tx moves coins from its sender's balance into
+// its stake: a stake or a delegate transaction.
+func addsStake(tx *Transaction) bool {
+	return tx.Type == "stake" || tx.Type == "delegate"
+}
+
+// validatorGroups returns the stake of each validator whose group is at
+// or above minThreshold, counting the stakes delegated to it. A validator
+// stakes on its own account and has a positive stake; stakes delegated to
+// an address that isn't one count for no one.
+func validatorGroups(stakes map[string]*Stake, minThreshold float64) map[string]float64 {
+	delegated := make(map[string]int64)
+	for _, s := range stakes {
+		if s.Validator != "" {
+			delegated[s.Validator] += ToUnits(s.Amount)
+		}
+	}
+	groups := make(map[string]float64)
+	for addr, s := range stakes {
+		if s.Validator != "" || s.Amount <= 0 {
+			continue
+		}
+		total := s.Amount
+		if d := delegated[addr]; d > 0 {
+			total = FromUnits(ToUnits(s.Amount) + d)
+		}
+		if total >= minThreshold {
+			groups[addr] = total
+		}
+	}
+	return groups
+}
+
+// rewardWeights returns what each staker's PoS reward is proportional
+// to, in base units times commissionScale: a validator's own stake in
+// full plus the commission on its delegators' stakes, and each delegator's
+// stake less the commission. Only eligible groups are weighed.
+func rewardWeights(stakes map[string]*Stake, minThreshold, commission float64) map[string]*big.Int {
+	groups := validatorGroups(stakes, minThreshold)
+	full := big.NewInt(commissionScale)
+	cut := big.NewInt(int64(math.Round(commission * commissionScale / 100)))
+	keep := new(big.Int).Sub(full, cut)
+	weights := make(map[string]*big.Int)
+	add := func(addr string, w *big.Int) {
+		if cur, ok := weights[addr]; ok {
+			cur.Add(cur, w)
+		} else {
+			weights[addr] = w
+		}
+	}
+	for addr, s := range stakes {
+		st := bigUnits(s.Amount)
+		if st.Sign() <= 0 {
+			continue
+		}
+		validator := addr
+		if s.Validator != "" {
+			validator = s.Validator
+		}
+		if _, ok := groups[validator]; !ok {
+			continue
+		}
+		if validator == addr {
+			add(addr, new(big.Int).Mul(st, full))
+			continue
+		}
+		add(addr, new(big.Int).Mul(st, keep))
+		add(validator, new(big.Int).Mul(st, cut))
+	}
+	return weights
+}
+
+// delegations returns the total stake delegated to validator and the
+// number of stakers delegating it.
+func (sm *StakeManager) delegations(validator string) (float64, int) {
+	sm.mu.RLock()
+	defer sm.mu.RUnlock()
+	var units int64
+	n := 0
+	for _, s := range sm.Stakes {
+		if s.Validator == validator {
+			units += ToUnits(s.Amount)
+			n++
+		}
+	}
+	return FromUnits(units), n
+}
+
+// checkDelegate checks the shape of delegate transaction tx in a block at
+// height.
+func (bc *Blockchain) checkDelegate(tx *Transaction, height uint64) error {
+	if !bc.Config.DelegationActive(height) {
+		return ErrDelegationDisabled
+	}
+	if tx.From == "" || tx.To == "" || tx.Amount <= 0 {
+		return errors.New("delegate needs a sender, a validator and a positive amount")
+	}
+	return nil
+}
+
+// checkMempoolDelegate refuses a delegation to an address that is not a
+// validator as of the tip. The caller holds bc.mu.
+func (bc *Blockchain) checkMempoolDelegate(tx *Transaction) error {
+	if tx.To == tx.From {
+		return nil
+	}
+	if bc.Stakes.GetStake(tx.To) <= 0 || bc.Stakes.delegatedTo(tx.To) != "" {
+		return fmt.Errorf("%s is not a validator: it has no stake of its own", tx.To)
+	}
+	return nil
+}
@@ -0,0 +1,122 @@
+package blockchain
+
+import (
+	"math/big"
+	"reflect"
+	"testing"
+)
+
+func TestRewardWeights(t *testing.T) {
+	stakes := map[string]*Stake{
+		"v":  {Amount: 100},
+		"d1": {Amount: 50, Validator: "v"},
+		"d2": {Amount: 30, Validator: "x"}, // x is not a validator
+		"s":  {Amount: 5},                  // below the threshold
+		"w":  {Amount: 8},
+		"d3": {Amount: 4, Validator: "w"}, // lifts w's group over it
+	}
+	units := func(coins int64) *big.Int { return big.NewInt(coins * UnitsPerCoin) }
+	weight := func(terms ...*big.Int) *big.Int {
+		sum := new(big.Int)
+		for i := 0; i < len(terms); i += 2 {
+			sum.Add(sum, new(big.Int).Mul(terms[i], terms[i+1]))
+		}
+		return sum
+	}
+	full, cut, keep := big.NewInt(10000), big.NewInt(1000), big.NewInt(9000)
+
+	tests := []struct {
+		name       string
+		commission float64
+		want       map[string]*big.Int
+	}{
+		{"no commission", 0, map[string]*big.Int{
+			"v":  weight(units(100), full),
+			"d1": weight(units(50), full),
+			"w":  weight(units(8), full),
+			"d3": weight(units(4), full),
+		}},
+		{"ten percent", 10, map[string]*big.Int{
+			"v":  weight(units(100), full, units(50), cut),
+			"d1": weight(units(50), keep),
+			"w":  weight(units(8), full, units(4), cut),
+			"d3": weight(units(4), keep),
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := rewardWeights(stakes, 10, tt.commission)
+			if len(got) != len(tt.want) {
+				t.Fatalf("weights for %d stakers, want %d: %v", len(got), len(tt.want), got)
+			}
+			for addr, w := range tt.want {
+				if got[addr] == nil || got[addr].Cmp(w) != 0 {
+					t.Errorf("weight of %s = %v, want %v", addr, got[addr], w)
+				}
+			}
+		})
+	}
+}
+
+func TestCalcPOSRewardsCommission(t *testing.T) {
+	tests := []struct {
+		name       string
+		stakes     map[string]float64
+		delegates  map[string]string
+		reward     float64
+		commission float64
+		want       []TxOutput
+	}{
+		{
+			name:       "delegator pays commission",
+			stakes:     map[string]float64{"d": 100, "v": 100},
+			delegates:  map[string]string{"d": "v"},
+			reward:     100,
+			commission: 10,
+			want:       []TxOutput{{Address: "d", Amount: 45}, {Address: "v", Amount: 55}},
+		},
+		{
+			name:       "no commission",
+			stakes:     map[string]float64{"d": 100, "v": 300},
+			delegates:  map[string]string{"d": "v"},
+			reward:     100,
+			commission: 0,
+			want:       []TxOutput{{Address: "d", Amount: 25}, {Address: "v", Amount: 75}},
+		},
+		{
+			name:       "group below the threshold",
+			stakes:     map[string]float64{"d": 4, "v": 5, "w": 20},
+			delegates:  map[string]string{"d": "v"},
+			reward:     100,
+			commission: 10,
+			want:       []TxOutput{{Address: "w", Amount: 100}},
+		},
+		{
+			name:       "leftover units by remainder then address",
+			stakes:     map[string]float64{"a": 20, "b": 20, "c": 20},
+			reward:     0.00000002,
+			commission: 10,
+			want:       []TxOutput{{Address: "a", Amount: 0.00000001}, {Address: "b", Amount: 0.00000001}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sm := NewStakeManager()
+			for addr, amount := range tt.stakes {
+				sm.AddStake(addr, amount, 1)
+				sm.Stakes[addr].Validator = tt.delegates[addr]
+			}
+			got := sm.CalcPOSRewards(tt.reward, 10, tt.commission)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("CalcPOSRewards = %v, want %v", got, tt.want)
+			}
+			paid := int64(0)
+			for _, out := range got {
+				paid += ToUnits(out.Amount)
+			}
+			if paid != ToUnits(tt.reward) {
+				t.Errorf("outputs pay %d units, want %d", paid, ToUnits(tt.reward))
+			}
+		})
+	}
+}
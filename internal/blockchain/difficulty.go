@@ -0,0 +1,152 @@
+package blockchain
+
+import "time"
+
+// MaxDifficultyIntervals caps the retarget intervals DifficultyHistory
+// reads.
+const MaxDifficultyIntervals = 1000
+
+// RetargetPoint is the difficulty of one retarget interval.
+type RetargetPoint struct {
+	Height       uint64  `json:"height"` // first block of the interval
+	Bits         uint32  `json:"bits"`
+	Difficulty   float64 `json:"difficulty"`
+	AtFloor      bool    `json:"at_floor"`       // bits equal the progressive floor
+	AvgBlockTime float64 `json:"avg_block_time"` // seconds, up to the next interval or the tip
+}
+
+// FloorEpoch is one step of the progressive difficulty floor.
+type FloorEpoch struct {
+	Epoch           uint64  `json:"epoch"`
+	StartHeight     uint64  `json:"start_height"`
+	FloorBits       uint32  `json:"floor_bits"`
+	FloorDifficulty float64 `json:"floor_difficulty"`
+	BlocksUntil     uint64  `json:"blocks_until"` // 0 once reached
+	ETA             int64   `json:"eta"`          // unix time at the target block time; 0 once reached
+}
+
+// FloorStatus is the progressive difficulty floor for the next block and
+// when it next tightens.
+type FloorStatus struct {
+	Epoch           uint64 `json:"floor_epoch"`
+	FloorBits       uint32 `json:"floor_bits"`
+	NextFloorHeight uint64 `json:"next_floor_height"` // 0 once the floor stops tightening
+	NextFloorBits   uint32 `json:"next_floor_bits"`
+}
+
+// FloorStatusAt returns the floor that applies to a block at height.
+func FloorStatusAt(height uint64, curve FloorCurve) FloorStatus {
+	st := FloorStatus{FloorBits: ProgressiveDifficultyFloor(height, curve)}
+	if !curve.Tightens() {
+		return st
+	}
+	st.Epoch = min(height/curve.EpochBlocks, maxFloorEpoch)
+	if st.Epoch < maxFloorEpoch {
+		st.NextFloorHeight = (height/curve.EpochBlocks + 1) * curve.EpochBlocks
+		st.NextFloorBits = ProgressiveDifficultyFloor(st.NextFloorHeight, curve)
+	}
+	return st
+}
+
+// FloorStatus returns the floor for the block after the tip.
+func (bc *Blockchain) FloorStatus() FloorStatus {
+	return FloorStatusAt(bc.GetBlockCount(), FloorCurveFor(bc.Config))
+}
+
+// DifficultyHistory reports past retargets and the upcoming floor schedule.
+type DifficultyHistory struct {
+	Height             uint64          `json:"height"`
+	Bits               uint32          `json:"bits"`
+	Difficulty         float64         `json:"difficulty"`
+	NextBits           uint32          `json:"next_bits"`
+	NextDifficulty     float64         `json:"next_difficulty"`
+	AdjustInterval     uint64          `json:"adjust_interval"`
+	NextRetargetHeight uint64          `json:"next_retarget_height"`
+	Retargets          []RetargetPoint `json:"retargets"`      // oldest first
+	FloorSchedule      []FloorEpoch    `json:"floor_schedule"` // current epoch, then upcoming ones
+}
+
+// DifficultyHistory returns the last intervals retarget intervals and the
+// current plus the next epochs floor epochs.
+func (bc *Blockchain) DifficultyHistory(intervals, epochs int) DifficultyHistory {
+	bc.mu.RLock()
+	defer bc.mu.RUnlock()
+	cfg := bc.Config
+	curve := FloorCurveFor(cfg)
+	intervals = min(max(intervals, 1), MaxDifficultyIntervals)
+	epochs = min(max(epochs, 0), maxFloorEpoch)
+
+	best := bc.Store.GetBestHeight()
+	if best < 0 || bc.lastBlock == nil {
+		return DifficultyHistory{}
+	}
+	tip := bc.lastBlock
+	height := uint64(best)
+	interval := max(cfg.DifficultyAdjustInterval, 1)
+	next := bc.Engine.NextBits(height + 1)
+	h := DifficultyHistory{
+		Height:             height,
+		Bits:               tip.Header.Bits,
+		Difficulty:         Difficulty(tip.Header.Bits, cfg.MinDifficultyBits),
+		NextBits:           next,
+		NextDifficulty:     Difficulty(next, cfg.MinDifficultyBits),
+		AdjustInterval:     interval,
+		NextRetargetHeight: (height/interval + 1) * interval,
+	}
+
+	start := height / interval * interval
+	if n := uint64(intervals - 1); start > n*interval {
+		start -= n * interval
+	} else {
+		start = 0
+	}
+	var prev *Block
+	for at := start; at <= height; at += interval {
+		b := bc.loadBlock(at)
+		if b == nil {
+			continue
+		}
+		if prev != nil {
+			closeRetarget(&h.Retargets[len(h.Retargets)-1], prev, b)
+		}
+		floor := ProgressiveDifficultyFloor(at, curve)
+		h.Retargets = append(h.Retargets, RetargetPoint{
+			Height:     at,
+			Bits:       b.Header.Bits,
+			Difficulty: Difficulty(b.Header.Bits, cfg.MinDifficultyBits),
+			AtFloor:    b.Header.Bits == floor,
+		})
+		prev = b
+	}
+	if prev != nil {
+		closeRetarget(&h.Retargets[len(h.Retargets)-1], prev, tip)
+	}
+
+	if curve.Tightens() {
+		current := min((height+1)/curve.EpochBlocks, maxFloorEpoch)
+		for e := current; e <= min(current+uint64(epochs), maxFloorEpoch); e++ {
+			at := e * curve.EpochBlocks
+			bits := ProgressiveDifficultyFloor(at, curve)
+			fe := FloorEpoch{
+				Epoch:           e,
+				StartHeight:     at,
+				FloorBits:       bits,
+				FloorDifficulty: Difficulty(bits, cfg.MinDifficultyBits),
+			}
+			if at > height {
+				fe.BlocksUntil = at - height
+				fe.ETA = time.Now().Unix() + int64(fe.BlocksUntil)*int64(cfg.BlockTimeSeconds)
+			}
+			h.FloorSchedule = append(h.FloorSchedule, fe)
+		}
+	}
+	return h
+}
+
+// closeRetarget sets p's average block time from the interval's first
+// block to end.
+func closeRetarget(p *RetargetPoint, first, end *Block) {
+	if n := end.Header.Height - first.Header.Height; n > 0 {
+		p.AvgBlockTime = float64(end.Header.Timestamp-first.Header.Timestamp) / float64(n)
+	}
+}
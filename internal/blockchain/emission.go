@@ -0,0 +1,230 @@
+package blockchain
+
+import (
+	"devinsidercoin/internal/config"
+	"fmt"
+	"math"
+)
+
+// rewardTolerance absorbs float rounding when reward outputs are split
+// among stakers.
+const rewardTolerance = 0.000001
+
+// scheduledSubsidy returns the uncapped block subsidy at height. The genesis
+// block pays no subsidy; premine allocations are accounted separately.
+func scheduledSubsidy(cfg *config.NetworkConfig, height uint64) float64 {
+	if height == 0 {
+		return 0
+	}
+	halvings := height / cfg.HalvingInterval
+	if halvings >= 64 {
+		return 0
+	}
+	reward := cfg.InitialReward
+	for i := uint64(0); i < halvings; i++ {
+		reward /= 2
+	}
+	if reward < 0.00000001 {
+		return 0
+	}
+	return reward
+}
+
+// premineTotal returns the amount allocated in the genesis block.
+func premineTotal(cfg *config.NetworkConfig) float64 {
+	total := 0.0
+	for _, a := range cfg.GenesisAllocations {
+		if a.Amount > 0 {
+			total += a.Amount
+		}
+	}
+	return total
+}
+
+// scheduledEmission returns the coins emitted by the schedule before height:
+// the premine plus the uncapped subsidies of blocks 1..height-1. It depends
+// only on the config, so every node computes the same value.
+func scheduledEmission(cfg *config.NetworkConfig, height uint64) float64 {
+	total := premineTotal(cfg)
+	if height <= 1 {
+		return total
+	}
+	reward := cfg.InitialReward
+	for era := uint64(0); era < 64 && reward >= 0.00000001; era++ {
+		start := era * cfg.HalvingInterval
+		if start < 1 {
+			start = 1
+		}
+		end := (era + 1) * cfg.HalvingInterval
+		if end > height {
+			end = height
+		}
+		if end > start {
+			total += float64(end-start) * reward
+		}
+		if end == height {
+			break
+		}
+		reward /= 2
+	}
+	return total
+}
+
+// CalcBlockReward returns the subsidy for the block at height, capped so
+// that cumulative emission never exceeds max supply.
+func (bc *Blockchain) CalcBlockReward(height uint64) float64 {
+	reward := scheduledSubsidy(bc.Config, height)
+	remaining := bc.Config.MaxSupply - scheduledEmission(bc.Config, height)
+	if remaining <= 0 {
+		return 0
+	}
+	if reward > remaining {
+		reward = remaining
+	}
+	return reward
+}
+
+// checkStakerRewards requires a canonical pos_reward to pay out exactly
+// the engine's staker share in base units. Legacy pos_reward transactions
+// were split in floats and are only held to the block-level tolerance.
+func (bc *Blockchain) checkStakerRewards(tx *Transaction, height uint64) error {
+	want := ToUnits(bc.Engine.StakerShare(bc.CalcBlockReward(height)))
+	if got := ToUnits(tx.Amount); got != want {
+		return fmt.Errorf("pos_reward amount %.8f, staker share is %.8f", tx.Amount, FromUnits(want))
+	}
+	paid := int64(0)
+	for _, out := range tx.Outputs {
+		paid += ToUnits(out.Amount)
+	}
+	if paid != want {
+		return fmt.Errorf("pos_reward outputs sum to %.8f, want %.8f", FromUnits(paid), FromUnits(want))
+	}
+	return nil
+}
+
+// blockFees returns the total fee paid by the non-reward transactions.
+func blockFees(txs []Transaction) float64 {
+	fees := 0.0
+	for _, tx := range txs {
+		if tx.Type != "coinbase" && tx.Type != "pos_reward" {
+			fees += tx.Fee
+		}
+	}
+	return fees
+}
+
+// Fee policies for the network config's fee_policy field.
+const (
+	FeePolicyBurn  = "burn"  // fees are destroyed (default, legacy behaviour)
+	FeePolicyMiner = "miner" // fees are paid to the block producer's coinbase
+)
+
+// collectedFees returns the fees the block producer may claim for txs
+// under the network's fee policy.
+func (bc *Blockchain) collectedFees(txs []Transaction) float64 {
+	if bc.Config.FeePolicy != FeePolicyMiner {
+		return 0
+	}
+	return blockFees(txs)
+}
+
+// checkBlockReward enforces the reward structure of a block: the coinbase
+// comes first, an optional pos_reward second, and together they mint
+// exactly the scheduled subsidy plus the fees collected under the fee
+// policy.
+func (bc *Blockchain) checkBlockReward(block *Block) error {
+	txs := block.Transactions
+	if len(txs) == 0 || txs[0].Type != "coinbase" {
+		return fmt.Errorf("first transaction must be the coinbase")
+	}
+	minted := 0.0
+	for i, tx := range txs {
+		switch tx.Type {
+		case "coinbase", "pos_reward":
+			if tx.Type == "coinbase" && i != 0 {
+				return fmt.Errorf("extra coinbase at position %d", i)
+			}
+			if tx.Type == "pos_reward" && i != 1 {
+				return fmt.Errorf("pos_reward at position %d, must follow the coinbase", i)
+			}
+			if tx.From != "" {
+				return fmt.Errorf("%s tx %s must not have a sender", tx.Type, tx.TxID)
+			}
+			for _, out := range tx.Outputs {
+				if out.Amount < 0 {
+					return fmt.Errorf("negative reward output in tx %s", tx.TxID)
+				}
+				minted += out.Amount
+			}
+			if tx.Type == "pos_reward" && tx.Version >= TxVersionCanonical {
+				if err := bc.checkStakerRewards(&tx, block.Header.Height); err != nil {
+					return err
+				}
+			}
+		default:
+			if tx.Fee < 0 || tx.Amount < 0 {
+				return fmt.Errorf("negative amount or fee in tx %s", tx.TxID)
+			}
+		}
+	}
+	subsidy := bc.CalcBlockReward(block.Header.Height)
+	fees := bc.collectedFees(txs)
+	if math.Abs(minted-(subsidy+fees)) > rewardTolerance {
+		return fmt.Errorf("block mints %.8f, expected %.8f (subsidy %.8f + fees %.8f)",
+			minted, subsidy+fees, subsidy, fees)
+	}
+	if bc.Config.RewardRulesActive(block.Header.Height) {
+		return bc.checkRewardSplit(block, subsidy)
+	}
+	return nil
+}
+
+// checkRewardSplit enforces who a block's rewards go to, from
+// reward_rules_height: each reward transaction's amount must be what its
+// outputs pay, and the stakers' share must be paid exactly as
+// CalcPOSRewards splits it over the stakes as of the parent block, in a
+// canonical pos_reward that is present if and only if someone is
+// eligible. With the block total checked, that leaves the coinbase its
+// own share and nothing more.
+func (bc *Blockchain) checkRewardSplit(block *Block, subsidy float64) error {
+	height := block.Header.Height
+	threshold := bc.govParam(ParamPOSMinThreshold, height)
+	want := bc.stakeSnapshot().CalcPOSRewards(bc.Engine.StakerShare(subsidy), threshold,
+		bc.Config.DelegationCommission)
+	var posTx *Transaction
+	for i := range block.Transactions {
+		tx := &block.Transactions[i]
+		if tx.Type != "coinbase" && tx.Type != "pos_reward" {
+			continue
+		}
+		paid := int64(0)
+		for _, out := range tx.Outputs {
+			paid += ToUnits(out.Amount)
+		}
+		if ToUnits(tx.Amount) != paid {
+			return fmt.Errorf("%s tx %s has amount %.8f but pays %.8f", tx.Type, tx.TxID, tx.Amount, FromUnits(paid))
+		}
+		if tx.Type == "pos_reward" {
+			posTx = tx
+		}
+	}
+	switch {
+	case posTx == nil && len(want) > 0:
+		return fmt.Errorf("block pays no pos_reward to %d eligible stakers", len(want))
+	case posTx == nil:
+		return nil
+	case len(want) == 0:
+		return fmt.Errorf("pos_reward tx %s with no eligible stakers", posTx.TxID)
+	case posTx.Version < TxVersionCanonical:
+		return fmt.Errorf("pos_reward tx %s must be version %d", posTx.TxID, TxVersionCanonical)
+	case len(posTx.Outputs) != len(want):
+		return fmt.Errorf("pos_reward pays %d stakers, %d are eligible", len(posTx.Outputs), len(want))
+	}
+	for i, out := range posTx.Outputs {
+		if out.Address != want[i].Address || ToUnits(out.Amount) != ToUnits(want[i].Amount) {
+			return fmt.Errorf("pos_reward pays %s %.8f, expected %s %.8f",
+				out.Address, out.Amount, want[i].Address, want[i].Amount)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,84 @@
+package blockchain
+
+import (
+	"devinsidercoin/internal/config"
+	"testing"
+)
+
+func emissionConfig() *config.NetworkConfig {
+	return &config.NetworkConfig{
+		InitialReward:   50,
+		HalvingInterval: 10,
+		MaxSupply:       1485,
+		GenesisAllocations: []config.GenesisAllocation{
+			{Address: "a", Amount: 1000},
+			{Address: "b", Amount: -5}, // ignored
+		},
+	}
+}
+
+func TestScheduledSubsidy(t *testing.T) {
+	cfg := emissionConfig()
+	tests := []struct {
+		height uint64
+		want   float64
+	}{
+		{0, 0},
+		{1, 50},
+		{9, 50},
+		{10, 25},
+		{25, 12.5},
+		{320, 50.0 / (1 << 32)},
+		{330, 0}, // below one base unit
+		{640, 0},
+	}
+	for _, tt := range tests {
+		if got := scheduledSubsidy(cfg, tt.height); got != tt.want {
+			t.Errorf("scheduledSubsidy(%d) = %v, want %v", tt.height, got, tt.want)
+		}
+	}
+}
+
+func TestScheduledEmission(t *testing.T) {
+	cfg := emissionConfig()
+	tests := []struct {
+		height uint64
+		want   float64
+	}{
+		{0, 1000},
+		{1, 1000},
+		{2, 1050},
+		{10, 1450},
+		{11, 1475},
+		{21, 1712.5},
+	}
+	for _, tt := range tests {
+		if got := scheduledEmission(cfg, tt.height); got != tt.want {
+			t.Errorf("scheduledEmission(%d) = %v, want %v", tt.height, got, tt.want)
+		}
+	}
+	for h := uint64(1); h < 100; h++ {
+		step := scheduledEmission(cfg, h+1) - scheduledEmission(cfg, h)
+		if sub := scheduledSubsidy(cfg, h); step != sub {
+			t.Fatalf("emission grows by %v at height %d, subsidy is %v", step, h, sub)
+		}
+	}
+}
+
+func TestCalcBlockRewardCapsAtMaxSupply(t *testing.T) {
+	bc := &Blockchain{Config: emissionConfig()}
+	tests := []struct {
+		height uint64
+		want   float64
+	}{
+		{5, 50},
+		{10, 25},
+		{11, 10}, // 1475 emitted before it, 10 left
+		{12, 0},
+	}
+	for _, tt := range tests {
+		if got := bc.CalcBlockReward(tt.height); got != tt.want {
+			t.Errorf("CalcBlockReward(%d) = %v, want %v", tt.height, got, tt.want)
+		}
+	}
+}
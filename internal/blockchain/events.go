@@ -0,0 +1,94 @@
+package blockchain
+
+import "sync"
+
+// EventType identifies a chain or mempool notification.
+type EventType string
+
+const (
+	EventBlockConnected EventType = "block_connected"
+	EventTxAdded        EventType = "tx_added"
+	EventTxExpired      EventType = "tx_expired"
+	EventTxRemoved      EventType = "tx_removed"
+	EventDoubleSpend    EventType = "double_spend"
+)
+
+// Reasons a pending transaction leaves the mempool without being mined,
+// carried by EventTxRemoved.
+const (
+	RemovedConflict = "conflict" // a block spent what it spends, or it lost its funding
+	RemovedExpired  = "expired"
+)
+
+// Event is delivered to bus subscribers. Only the fields matching Type are
+// set; EventBlockConnected carries the unbonds the Block Released,
+// EventDoubleSpend the rejected Tx and the pending txids it conflicts
+// with, and EventTxRemoved the Reason the Tx left the mempool.
+type Event struct {
+	Type      EventType    `json:"type"`
+	Block     *Block       `json:"block,omitempty"`
+	Tx        *Transaction `json:"tx,omitempty"`
+	Conflicts []string     `json:"conflicts,omitempty"`
+	Released  []Unbond     `json:"released,omitempty"`
+	Reason    string       `json:"reason,omitempty"`
+}
+
+type subscriber struct {
+	fn    func(Event)
+	types map[EventType]bool // nil = all events
+}
+
+// EventBus is a small synchronous pub/sub hub for block and tx
+// notifications. Events are published after the chain lock is released, so
+// handlers may call back into the Blockchain, but they run on the
+// publisher's goroutine and must not block for long.
+type EventBus struct {
+	subs map[int]*subscriber
+	next int
+	mu   sync.RWMutex
+}
+
+// NewEventBus creates an empty event bus.
+func NewEventBus() *EventBus {
+	return &EventBus{subs: make(map[int]*subscriber)}
+}
+
+// Subscribe registers fn for the given event types (all types if none are
+// given) and returns a function that removes the subscription.
+func (eb *EventBus) Subscribe(fn func(Event), types ...EventType) (unsubscribe func()) {
+	sub := &subscriber{fn: fn}
+	if len(types) > 0 {
+		sub.types = make(map[EventType]bool, len(types))
+		for _, t := range types {
+			sub.types[t] = true
+		}
+	}
+
+	eb.mu.Lock()
+	id := eb.next
+	eb.next++
+	eb.subs[id] = sub
+	eb.mu.Unlock()
+
+	return func() {
+		eb.mu.Lock()
+		delete(eb.subs, id)
+		eb.mu.Unlock()
+	}
+}
+
+// Publish delivers ev to every matching subscriber.
+func (eb *EventBus) Publish(ev Event) {
+	eb.mu.RLock()
+	targets := make([]func(Event), 0, len(eb.subs))
+	for _, sub := range eb.subs {
+		if sub.types == nil || sub.types[ev.Type] {
+			targets = append(targets, sub.fn)
+		}
+	}
+	eb.mu.RUnlock()
+
+	for _, fn := range targets {
+		fn(ev)
+	}
+}
@@ -0,0 +1,247 @@
+package blockchain
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"devinsidercoin/internal/address"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Every finality_interval blocks the chain opens a checkpoint on the block
+// at that height. Stakers eligible for PoS rewards after the block sign its
+// hash, and once signers holding more than half of that stake have
+// attested, the checkpoint is final: the node refuses any block that would
+// replace it or anything below it.
+//
+// Attestations travel outside blocks, so a node that missed them leaves
+// the checkpoint open. Block validity never waits on finality.
+
+// finalityDomain starts every attestation envelope; see sigDomain.
+const finalityDomain = "DevInsiderCoin finality\x00"
+
+// Checkpoint is a block the stakers are asked to finalize. Weights are the
+// eligible stakes after the block, fixed when it is connected.
+type Checkpoint struct {
+	Height      uint64             `json:"height"`
+	Hash        string             `json:"hash"`
+	Weights     map[string]float64 `json:"weights"`
+	TotalStake  float64            `json:"total_stake"`
+	SignedStake float64            `json:"signed_stake"`
+	Signatures  map[string]string  `json:"signatures"` // address -> encoded signature
+	Finalized   bool               `json:"finalized"`
+	FinalizedAt int64              `json:"finalized_at,omitempty"` // unix time, as seen by this node
+}
+
+// Attestation is one staker's signature over a checkpoint.
+type Attestation struct {
+	Height    uint64 `json:"height"`
+	Hash      string `json:"hash"`
+	Address   string `json:"address"`
+	Signature string `json:"signature"` // see EncodeSignature
+}
+
+// FinalityInfo is the state of checkpoint finality at the tip.
+type FinalityInfo struct {
+	Interval        uint64      `json:"interval"`
+	FinalizedHeight uint64      `json:"finalized_height"` // 0 if nothing is final yet
+	FinalizedHash   string      `json:"finalized_hash,omitempty"`
+	Latest          *Checkpoint `json:"latest,omitempty"`
+}
+
+// ErrFinalityDisabled means the network has no finality_interval.
+var ErrFinalityDisabled = errors.New("finality checkpoints are disabled on this network")
+
+// FinalityMessage returns the envelope an attestation signs: the domain
+// tag, the scheme, the network ID, the height and the block hash.
+func FinalityMessage(networkID uint32, height uint64, hash string) []byte {
+	var buf bytes.Buffer
+	buf.WriteString(finalityDomain)
+	buf.WriteByte(SigSchemeEd25519)
+	binary.Write(&buf, binary.LittleEndian, networkID)
+	binary.Write(&buf, binary.LittleEndian, height)
+	raw, _ := hex.DecodeString(hash)
+	buf.Write(raw)
+	return buf.Bytes()
+}
+
+// SignAttestation signs a for networkID with priv and sets its Signature.
+func SignAttestation(a *Attestation, networkID uint32, priv ed25519.PrivateKey) {
+	sig := ed25519.Sign(priv, FinalityMessage(networkID, a.Height, a.Hash))
+	a.Signature = EncodeSignature(priv.Public().(ed25519.PublicKey), sig)
+}
+
+// isCheckpoint reports whether the block at height opens a checkpoint.
+func (bc *Blockchain) isCheckpoint(height uint64) bool {
+	interval := bc.Config.FinalityInterval
+	return interval > 0 && height > 0 && height%interval == 0
+}
+
+// openCheckpoint returns the JSON checkpoint for block if it opens one,
+// weighted by the stakes with the block's changes staged, or nil. The
+// caller holds bc.mu.
+func (bc *Blockchain) openCheckpoint(block *Block, stakes *stakeChanges) []byte {
+	if !bc.isCheckpoint(block.Header.Height) {
+		return nil
+	}
+	threshold := bc.govParam(ParamPOSMinThreshold, block.Header.Height+1)
+	cp := Checkpoint{
+		Height:     block.Header.Height,
+		Hash:       block.Hash,
+		Weights:    make(map[string]float64),
+		Signatures: make(map[string]string),
+	}
+	var units int64
+	for addr, s := range stakes.all() {
+		if s.Amount > 0 && s.Amount >= threshold {
+			cp.Weights[addr] = s.Amount
+			units += ToUnits(s.Amount)
+		}
+	}
+	if units == 0 {
+		return nil
+	}
+	cp.TotalStake = FromUnits(units)
+	data, _ := json.Marshal(cp)
+	return data
+}
+
+// loadCheckpoint decodes the checkpoint at height. The caller holds bc.mu.
+func (bc *Blockchain) loadCheckpoint(height uint64) *Checkpoint {
+	data := bc.Store.GetCheckpoint(height)
+	if data == nil {
+		return nil
+	}
+	var cp Checkpoint
+	if json.Unmarshal(data, &cp) != nil {
+		return nil
+	}
+	return &cp
+}
+
+// AddAttestation records a staker's signature over a checkpoint and
+// finalizes it once the signers hold a majority of its stake. It reports
+// whether the attestation was new and should be relayed; attestations for
+// a finalized checkpoint, or one older than it, are ignored. One for
+// another block is refused unless it makes double-sign evidence.
+func (bc *Blockchain) AddAttestation(a Attestation) (bool, error) {
+	bc.mu.Lock()
+	defer bc.mu.Unlock()
+	if bc.Config.FinalityInterval == 0 {
+		return false, ErrFinalityDisabled
+	}
+	cp := bc.loadCheckpoint(a.Height)
+	if cp == nil {
+		return false, fmt.Errorf("no checkpoint at height %d", a.Height)
+	}
+	if a.Hash != cp.Hash {
+		return bc.conflictingAttestation(cp, a)
+	}
+	if cp.Finalized || a.Height < bc.finalized {
+		return false, nil
+	}
+	if _, ok := cp.Signatures[a.Address]; ok {
+		return false, nil
+	}
+	weight, ok := cp.Weights[a.Address]
+	if !ok {
+		return false, fmt.Errorf("%s has no eligible stake at checkpoint %d", a.Address, a.Height)
+	}
+	if err := bc.verifyAttestation(&a); err != nil {
+		return false, err
+	}
+
+	cp.Signatures[a.Address] = a.Signature
+	cp.SignedStake = FromUnits(ToUnits(cp.SignedStake) + ToUnits(weight))
+	if 2*ToUnits(cp.SignedStake) > ToUnits(cp.TotalStake) {
+		cp.Finalized = true
+		cp.FinalizedAt = time.Now().Unix()
+	}
+	data, _ := json.Marshal(cp)
+	if err := bc.Store.PutCheckpoint(cp.Height, data, cp.Finalized); err != nil {
+		return false, fmt.Errorf("store checkpoint: %w", err)
+	}
+	if cp.Finalized {
+		bc.finalized = cp.Height
+		logger.Info("checkpoint finalized", "height", cp.Height, "hash", cp.Hash[:16]+"...",
+			"signed", cp.SignedStake, "total", cp.TotalStake, "signers", len(cp.Signatures))
+	}
+	return true, nil
+}
+
+// verifyAttestation checks that a is signed for this network by a key
+// belonging to its address.
+func (bc *Blockchain) verifyAttestation(a *Attestation) error {
+	raw, err := hex.DecodeString(a.Signature)
+	if err != nil || len(raw) != envelopeSigLen || raw[0] != SigSchemeEd25519 {
+		return errors.New("bad attestation signature encoding")
+	}
+	pub := ed25519.PublicKey(raw[1 : 1+ed25519.PublicKeySize])
+	owner, _, err := address.Decode(bc.Config.AddressPrefix, a.Address)
+	if err != nil || owner != address.HashPublicKey(pub) {
+		return fmt.Errorf("attestation key does not belong to %s", a.Address)
+	}
+	if !ed25519.Verify(pub, FinalityMessage(bc.Config.NetworkID, a.Height, a.Hash), raw[1+ed25519.PublicKeySize:]) {
+		return errors.New("bad attestation signature")
+	}
+	return nil
+}
+
+// loadFinalized restores the latest finalized height at startup.
+func (bc *Blockchain) loadFinalized() {
+	if h, ok := bc.Store.FinalizedHeight(); ok {
+		bc.finalized = h
+	}
+}
+
+// GetFinality returns the finalized height and the latest checkpoint.
+func (bc *Blockchain) GetFinality() FinalityInfo {
+	bc.mu.RLock()
+	defer bc.mu.RUnlock()
+	info := FinalityInfo{Interval: bc.Config.FinalityInterval, FinalizedHeight: bc.finalized}
+	if cp := bc.loadCheckpoint(bc.finalized); cp != nil && cp.Finalized {
+		info.FinalizedHash = cp.Hash
+	}
+	if height, data := bc.Store.LastCheckpoint(); data != nil {
+		info.Latest = bc.loadCheckpoint(height)
+	}
+	return info
+}
+
+// GetCheckpoint returns the checkpoint at height, or nil if the block
+// there opened none.
+func (bc *Blockchain) GetCheckpoint(height uint64) *Checkpoint {
+	bc.mu.RLock()
+	defer bc.mu.RUnlock()
+	return bc.loadCheckpoint(height)
+}
+
+// RecentAttestations returns the signatures collected for the latest
+// checkpoint and, if different, the finalized one, for peers that
+// connected after they were gossiped.
+func (bc *Blockchain) RecentAttestations() []Attestation {
+	bc.mu.RLock()
+	defer bc.mu.RUnlock()
+	heights := []uint64{}
+	if height, data := bc.Store.LastCheckpoint(); data != nil {
+		heights = append(heights, height)
+	}
+	if bc.finalized > 0 && (len(heights) == 0 || heights[0] != bc.finalized) {
+		heights = append(heights, bc.finalized)
+	}
+	var out []Attestation
+	for _, h := range heights {
+		cp := bc.loadCheckpoint(h)
+		if cp == nil {
+			continue
+		}
+		for addr, sig := range cp.Signatures {
+			out = append(out, Attestation{Height: cp.Height, Hash: cp.Hash, Address: addr, Signature: sig})
+		}
+	}
+	return out
+}
@@ -2,15 +2,18 @@ package blockchain
 
 import (
 	"devinsidercoin/internal/config"
+	"encoding/json"
+	"fmt"
 	"strings"
 	"time"
 )
 
 // CreateGenesisBlock creates the genesis (first) block for the network.
-func CreateGenesisBlock(cfg *config.NetworkConfig) *Block {
+// It depends only on the config, so every node derives the same block.
+func CreateGenesisBlock(cfg *config.NetworkConfig) (*Block, error) {
 	ts, err := time.Parse(time.RFC3339, cfg.GenesisTimestamp)
 	if err != nil {
-		ts = time.Now()
+		return nil, fmt.Errorf("invalid genesis_timestamp: %w", err)
 	}
 
 	coinbase := Transaction{
@@ -20,6 +23,13 @@ func CreateGenesisBlock(cfg *config.NetworkConfig) *Block {
 		Timestamp: ts.Unix(),
 		Outputs:   []TxOutput{{Address: "genesis", Amount: 0}},
 	}
+	if len(cfg.GenesisAllocations) > 0 {
+		coinbase.Outputs = make([]TxOutput, len(cfg.GenesisAllocations))
+		for i, a := range cfg.GenesisAllocations {
+			coinbase.Outputs[i] = TxOutput{Address: a.Address, Amount: a.Amount}
+			coinbase.Amount += a.Amount
+		}
+	}
 	coinbase.TxID = coinbase.ComputeTxID()
 
 	merkle := ComputeMerkleRoot([]Transaction{coinbase})
@@ -40,5 +50,28 @@ func CreateGenesisBlock(cfg *config.NetworkConfig) *Block {
 	}
 	block.Hash = header.ComputeHash()
 
-	return block
+	return block, nil
+}
+
+// checkGenesis refuses a chain whose genesis block is not the one pinned
+// by the config's genesis_hash: the data directory belongs to another
+// network, or the manifest was edited.
+func (bc *Blockchain) checkGenesis() error {
+	pinned := bc.Config.GenesisHash
+	if pinned == "" {
+		return nil
+	}
+	data, err := bc.Store.GetBlockByHeight(0)
+	if err != nil || data == nil {
+		return fmt.Errorf("read genesis block: %v", err)
+	}
+	var genesis Block
+	if err := json.Unmarshal(data, &genesis); err != nil {
+		return fmt.Errorf("decode genesis block: %w", err)
+	}
+	if genesis.Hash != pinned {
+		return fmt.Errorf("genesis block %s does not match genesis_hash %s in the network config; "+
+			"the data directory belongs to another network", genesis.Hash, pinned)
+	}
+	return nil
 }
@@ -0,0 +1,345 @@
+package blockchain
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+)
+
+// Governable parameters. Each starts at the network config's value and
+// changes only through stake-weighted vote transactions, so every node
+// applies the same value at the same height.
+const (
+	ParamMinRelayFee     = "min_relay_fee"
+	ParamMaxMempoolTxs   = "max_mempool_txs"
+	ParamPOSMinThreshold = "pos_min_threshold"
+)
+
+// governableParams bounds the values a vote may propose.
+var governableParams = map[string]struct{ min, max float64 }{
+	ParamMinRelayFee:     {0, 1000},
+	ParamMaxMempoolTxs:   {100, 1000000},
+	ParamPOSMinThreshold: {0, 1e12},
+}
+
+// metaGovState is the meta key holding the encoded govState.
+const metaGovState = "gov_state"
+
+// Vote is the Data payload of a vote transaction.
+type Vote struct {
+	Param string  `json:"param"`
+	Value float64 `json:"value"`
+}
+
+// ParamChange is a value adopted by vote, effective from ActivationHeight.
+type ParamChange struct {
+	Value            float64 `json:"value"`
+	ActivationHeight uint64  `json:"activation_height"`
+}
+
+// govState is the governance state committed with each block: the votes
+// cast in the current period, the stake they are weighed by and every
+// change adopted so far.
+//
+// Votes are tallied at heights that are multiples of governance_period.
+// Each vote counts with the voter's stake when the period started, so
+// staking, unstaking or moving coins mid-vote doesn't change the outcome.
+// A value backed by more than half of that stake takes effect one period
+// later, giving operators time to notice.
+type govState struct {
+	Votes   map[string]map[string]float64 `json:"votes"`   // param -> voter -> value
+	Changes map[string][]ParamChange      `json:"changes"` // param -> changes in activation order
+
+	// Weights is each staker's stake at SnapshotHeight, the last block
+	// before the current period. Nil in states saved before snapshots
+	// existed, in which case the stake at the tip is used.
+	SnapshotHeight uint64             `json:"snapshot_height"`
+	Weights        map[string]float64 `json:"weights"`
+}
+
+func newGovState() *govState {
+	return &govState{
+		Votes:   make(map[string]map[string]float64),
+		Changes: make(map[string][]ParamChange),
+	}
+}
+
+func (g *govState) clone() *govState {
+	c := newGovState()
+	for param, votes := range g.Votes {
+		c.Votes[param] = make(map[string]float64, len(votes))
+		for voter, v := range votes {
+			c.Votes[param][voter] = v
+		}
+	}
+	for param, changes := range g.Changes {
+		c.Changes[param] = append([]ParamChange(nil), changes...)
+	}
+	c.SnapshotHeight, c.Weights = g.SnapshotHeight, g.Weights // never modified in place
+	return c
+}
+
+// stakeWeights returns the stake of each staker in stakes.
+func stakeWeights(stakes *StakeManager) map[string]float64 {
+	w := make(map[string]float64)
+	for addr, s := range stakes.GetAllStakes() {
+		w[addr] = s.Amount
+	}
+	return w
+}
+
+// periodStart reports whether the block at height is the first of a
+// voting period.
+func periodStart(height, period uint64) bool {
+	return height > 0 && (height-1)%period == 0
+}
+
+// value returns the value of param in effect at height, or def if no
+// adopted change has activated yet.
+func (g *govState) value(param string, height uint64, def float64) float64 {
+	v := def
+	for _, ch := range g.Changes[param] {
+		if ch.ActivationHeight <= height {
+			v = ch.Value
+		}
+	}
+	return v
+}
+
+// tally adopts every proposal backed by a majority of weights and clears
+// the votes. Weights are summed in base units so the result doesn't depend
+// on map iteration order.
+func (g *govState) tally(height, period uint64, weights map[string]float64) {
+	var total int64
+	for _, w := range weights {
+		total += ToUnits(w)
+	}
+	params := make([]string, 0, len(g.Votes))
+	for param := range g.Votes {
+		params = append(params, param)
+	}
+	sort.Strings(params)
+	for _, param := range params {
+		backing := make(map[float64]int64)
+		for voter, v := range g.Votes[param] {
+			backing[v] += ToUnits(weights[voter])
+		}
+		for v, w := range backing {
+			if total > 0 && 2*w > total {
+				g.Changes[param] = append(g.Changes[param],
+					ParamChange{Value: v, ActivationHeight: height + period})
+				logger.Info("governance change adopted", "param", param, "value", v,
+					"activation_height", height+period)
+			}
+		}
+	}
+	g.Votes = make(map[string]map[string]float64)
+}
+
+func (bc *Blockchain) loadGovState() *govState {
+	g := newGovState()
+	if data := bc.Store.GetMeta(metaGovState); data != nil {
+		if err := json.Unmarshal(data, g); err != nil {
+			logger.Error("bad governance state, using config values", "err", err)
+			return newGovState()
+		}
+	}
+	if g.Votes == nil {
+		g.Votes = make(map[string]map[string]float64)
+	}
+	if g.Changes == nil {
+		g.Changes = make(map[string][]ParamChange)
+	}
+	return g
+}
+
+// nextGovState returns the governance state after block, or nil if block
+// leaves it unchanged. The caller must hold bc.mu.
+func (bc *Blockchain) nextGovState(block *Block) *govState {
+	period := bc.Config.GovernancePeriod
+	if period == 0 {
+		return nil
+	}
+	height := block.Header.Height
+	var next *govState
+	if periodStart(height, period) {
+		next = bc.gov.clone()
+		next.SnapshotHeight, next.Weights = height-1, stakeWeights(bc.stakeSnapshot())
+	}
+	for _, tx := range block.Transactions {
+		if tx.Type != "vote" {
+			continue
+		}
+		vote, _ := parseVote(&tx)
+		if next == nil {
+			next = bc.gov.clone()
+		}
+		if next.Votes[vote.Param] == nil {
+			next.Votes[vote.Param] = make(map[string]float64)
+		}
+		next.Votes[vote.Param][tx.From] = vote.Value
+	}
+	if height > 0 && height%period == 0 && (len(bc.gov.Votes) > 0 || next != nil) {
+		if next == nil {
+			next = bc.gov.clone()
+		}
+		next.tally(height, period, bc.voteWeights(height))
+	}
+	return next
+}
+
+// voteWeights returns the stakes that votes in the block at height count
+// with. The caller must hold bc.mu.
+func (bc *Blockchain) voteWeights(height uint64) map[string]float64 {
+	if bc.gov.Weights == nil || periodStart(height, bc.Config.GovernancePeriod) {
+		return stakeWeights(bc.stakeSnapshot())
+	}
+	return bc.gov.Weights
+}
+
+// govParam returns the value of a governable parameter in effect at
+// height. The caller must hold bc.mu.
+func (bc *Blockchain) govParam(param string, height uint64) float64 {
+	var def float64
+	switch param {
+	case ParamMinRelayFee:
+		def = bc.Config.MinRelayFee
+	case ParamMaxMempoolTxs:
+		def = float64(bc.Config.MaxMempoolTxs)
+	case ParamPOSMinThreshold:
+		def = bc.Config.POSMinThreshold
+	}
+	return bc.gov.value(param, height, def)
+}
+
+func parseVote(tx *Transaction) (Vote, error) {
+	var v Vote
+	if err := json.Unmarshal([]byte(tx.Data), &v); err != nil {
+		return v, fmt.Errorf("bad vote payload: %w", err)
+	}
+	return v, nil
+}
+
+// checkVote validates a vote transaction against the vote weights of its
+// period. Only addresses that were staking when the period started may
+// vote.
+func (bc *Blockchain) checkVote(tx *Transaction, weights map[string]float64) error {
+	if bc.Config.GovernancePeriod == 0 {
+		return fmt.Errorf("governance is disabled on this network")
+	}
+	if tx.Version < TxVersionCanonical {
+		return fmt.Errorf("vote must use transaction version %d", TxVersionCanonical)
+	}
+	vote, err := parseVote(tx)
+	if err != nil {
+		return err
+	}
+	bounds, ok := governableParams[vote.Param]
+	if !ok {
+		return fmt.Errorf("parameter %q is not governable", vote.Param)
+	}
+	if vote.Value < bounds.min || vote.Value > bounds.max {
+		return fmt.Errorf("%s must be between %g and %g", vote.Param, bounds.min, bounds.max)
+	}
+	if weights[tx.From] <= 0 {
+		return fmt.Errorf("voter %s had no stake at the start of the voting period", tx.From)
+	}
+	return nil
+}
+
+// GovernanceParam is the current and scheduled state of one parameter.
+type GovernanceParam struct {
+	Value     float64            `json:"value"`
+	Scheduled []ParamChange      `json:"scheduled,omitempty"`
+	Votes     map[string]float64 `json:"votes,omitempty"` // value -> vote weight behind it this period
+}
+
+// GovernanceInfo describes the governable parameters at the next height.
+type GovernanceInfo struct {
+	Period         uint64                     `json:"period"`
+	NextTally      uint64                     `json:"next_tally,omitempty"`
+	SnapshotHeight uint64                     `json:"snapshot_height"`
+	TotalStake     float64                    `json:"total_stake"` // at the snapshot height
+	Params         map[string]GovernanceParam `json:"params"`
+}
+
+// VoteSnapshot is the stake each vote of the current period counts with.
+type VoteSnapshot struct {
+	Height     uint64             `json:"height"`
+	NextTally  uint64             `json:"next_tally"`
+	TotalStake float64            `json:"total_stake"`
+	Weights    map[string]float64 `json:"weights"` // staker -> stake at Height
+}
+
+// snapshotHeight returns the height the vote weights of the block at
+// height were taken at. The caller must hold bc.mu.
+func (bc *Blockchain) snapshotHeight(height uint64) uint64 {
+	if bc.gov.Weights == nil || periodStart(height, bc.Config.GovernancePeriod) {
+		return height - 1
+	}
+	return bc.gov.SnapshotHeight
+}
+
+// nextTally returns the first tally height at or after height.
+func nextTally(height, period uint64) uint64 {
+	return max(period, (height+period-1)/period*period)
+}
+
+// GetGovernanceInfo returns the effective parameter values, pending
+// changes and the running tally of the current period.
+func (bc *Blockchain) GetGovernanceInfo() GovernanceInfo {
+	bc.mu.RLock()
+	defer bc.mu.RUnlock()
+	height := bc.Store.GetBlockCount()
+	info := GovernanceInfo{
+		Period: bc.Config.GovernancePeriod,
+		Params: make(map[string]GovernanceParam),
+	}
+	weights := stakeWeights(bc.stakeSnapshot())
+	if p := info.Period; p > 0 {
+		info.NextTally = nextTally(height, p)
+		info.SnapshotHeight = bc.snapshotHeight(height)
+		weights = bc.voteWeights(height)
+	}
+	for _, w := range weights {
+		info.TotalStake += w
+	}
+	for param := range governableParams {
+		gp := GovernanceParam{Value: bc.govParam(param, height)}
+		for _, ch := range bc.gov.Changes[param] {
+			if ch.ActivationHeight > height {
+				gp.Scheduled = append(gp.Scheduled, ch)
+			}
+		}
+		for voter, v := range bc.gov.Votes[param] {
+			if gp.Votes == nil {
+				gp.Votes = make(map[string]float64)
+			}
+			gp.Votes[fmt.Sprint(v)] += weights[voter]
+		}
+		info.Params[param] = gp
+	}
+	return info
+}
+
+// GetVoteSnapshot returns the vote weights of the current period, so
+// anyone can recompute a tally from the votes in its blocks.
+func (bc *Blockchain) GetVoteSnapshot() (*VoteSnapshot, error) {
+	bc.mu.RLock()
+	defer bc.mu.RUnlock()
+	period := bc.Config.GovernancePeriod
+	if period == 0 {
+		return nil, fmt.Errorf("governance is disabled on this network")
+	}
+	height := bc.Store.GetBlockCount()
+	snap := &VoteSnapshot{
+		Height:    bc.snapshotHeight(height),
+		NextTally: nextTally(height, period),
+		Weights:   make(map[string]float64),
+	}
+	for addr, w := range bc.voteWeights(height) {
+		snap.Weights[addr] = w
+		snap.TotalStake += w
+	}
+	return snap, nil
+}
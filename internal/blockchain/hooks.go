@@ -0,0 +1,129 @@
+package blockchain
+
+import (
+	"fmt"
+	"sync"
+)
+
+// StateView is the read-only chain state handed to extension hooks. Hooks
+// run while the chain lock is held and must not call Blockchain methods.
+type StateView interface {
+	Height() uint64
+	Balance(address string) float64
+	Stake(address string) float64
+}
+
+// Ledger lets a custom transaction type move funds when its block is
+// connected.
+type Ledger interface {
+	StateView
+	Credit(address string, amount float64)
+	Debit(address string, amount float64) error
+}
+
+// TxValidator inspects a transaction at mempool admission and again during
+// block validation. Returning an error rejects the transaction.
+type TxValidator func(tx *Transaction, state StateView) error
+
+// TxApplier applies a custom transaction type to the ledger.
+type TxApplier func(tx *Transaction, ledger Ledger) error
+
+// Hooks holds extension points registered by downstream code before the
+// node starts processing blocks.
+type Hooks struct {
+	validators []TxValidator
+	txTypes    map[string]TxApplier
+	mu         sync.RWMutex
+}
+
+func newHooks() *Hooks {
+	return &Hooks{txTypes: make(map[string]TxApplier)}
+}
+
+// RegisterTxValidator adds a validation hook run for every transaction.
+func (h *Hooks) RegisterTxValidator(fn TxValidator) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.validators = append(h.validators, fn)
+}
+
+// RegisterTxType adds a custom transaction type. Built-in types cannot be
+// overridden.
+func (h *Hooks) RegisterTxType(txType string, apply TxApplier) error {
+	switch txType {
+	case "coinbase", "pos_reward", "transfer", "stake", "unstake", "vote", "burn", "slash", "delegate":
+		return fmt.Errorf("cannot override built-in tx type %q", txType)
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if _, ok := h.txTypes[txType]; ok {
+		return fmt.Errorf("tx type %q already registered", txType)
+	}
+	h.txTypes[txType] = apply
+	return nil
+}
+
+func (h *Hooks) validate(tx *Transaction, state StateView) error {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	for _, fn := range h.validators {
+		if err := fn(tx, state); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (h *Hooks) applier(txType string) (TxApplier, bool) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	fn, ok := h.txTypes[txType]
+	return fn, ok
+}
+
+// OnBlock registers fn to run after each block is connected. It is a
+// convenience wrapper around the event bus.
+func (bc *Blockchain) OnBlock(fn func(*Block)) (unsubscribe func()) {
+	return bc.Events.Subscribe(func(ev Event) { fn(ev.Block) }, EventBlockConnected)
+}
+
+// lockedState implements Ledger over committed balances plus the changes
+// staged for the block being connected. The caller must hold bc.mu.
+type lockedState struct {
+	bc      *Blockchain
+	height  uint64
+	changed map[string]float64 // staged balances; nil for read-only views
+	stakes  *stakeChanges      // staged stakes; nil for read-only views
+}
+
+func (s *lockedState) Height() uint64 { return s.height }
+
+func (s *lockedState) Stake(address string) float64 {
+	if s.stakes != nil {
+		return s.stakes.GetStake(address)
+	}
+	return s.bc.Stakes.GetStake(address)
+}
+
+func (s *lockedState) Balance(address string) float64 {
+	if v, ok := s.changed[address]; ok {
+		return v
+	}
+	return s.bc.balances.get(address)
+}
+
+func (s *lockedState) Credit(address string, amount float64) {
+	s.adjust(address, amount)
+}
+
+func (s *lockedState) Debit(address string, amount float64) error {
+	if s.Balance(address) < amount {
+		return fmt.Errorf("insufficient balance for %s", address)
+	}
+	s.adjust(address, -amount)
+	return nil
+}
+
+func (s *lockedState) adjust(address string, delta float64) {
+	s.changed[address] = s.Balance(address) + delta
+}
@@ -0,0 +1,243 @@
+package blockchain
+
+import (
+	"devinsidercoin/internal/storage"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+)
+
+// ErrIndexing is returned by historical queries while the archive indexes
+// are still being built.
+var ErrIndexing = errors.New("archive indexes are still being built; see getindexinfo")
+
+// richSnapshots is how many past rich lists the archive keeps for clients
+// paging through one.
+const richSnapshots = 4
+
+// indexBatch is how many blocks the archive indexer reads and writes at a
+// time. Progress is persisted after every batch.
+const indexBatch = 1000
+
+// archiveIndexer builds the archive indexes in the background while the
+// node runs, resuming from the last persisted height after a restart.
+type archiveIndexer struct {
+	ready     bool // indexes cover the tip and connectBlock maintains them; guarded by bc.mu
+	startedAt time.Time
+	stop      chan struct{}
+	done      chan struct{}
+	stopOnce  sync.Once
+
+	mu   sync.Mutex
+	err  error
+	rich map[uint64][]storage.RichEntry // rich lists by height, at most richSnapshots
+}
+
+func newArchiveIndexer() *archiveIndexer {
+	return &archiveIndexer{
+		startedAt: time.Now(),
+		stop:      make(chan struct{}),
+		done:      make(chan struct{}),
+	}
+}
+
+// close stops the indexer and waits for it to finish its batch.
+func (ix *archiveIndexer) close() {
+	ix.stopOnce.Do(func() { close(ix.stop) })
+	<-ix.done
+}
+
+// richListAt returns the rich list after the block at height, built from
+// the balance history the first time it is asked for.
+func (ix *archiveIndexer) richListAt(store *storage.Store, height uint64) []storage.RichEntry {
+	ix.mu.Lock()
+	list, ok := ix.rich[height]
+	ix.mu.Unlock()
+	if ok {
+		return list
+	}
+	list = store.RichListAt(height)
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	if ix.rich == nil {
+		ix.rich = make(map[uint64][]storage.RichEntry)
+	}
+	if len(ix.rich) >= richSnapshots {
+		oldest := uint64(math.MaxUint64)
+		for h := range ix.rich {
+			oldest = min(oldest, h)
+		}
+		delete(ix.rich, oldest)
+	}
+	ix.rich[height] = list
+	return list
+}
+
+func (ix *archiveIndexer) failed() error {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	return ix.err
+}
+
+// IndexStatus reports how far an optional index has been built.
+type IndexStatus struct {
+	Synced      bool    `json:"synced"`
+	Height      int64   `json:"best_block_height"` // last block indexed, -1 if none
+	ChainHeight int64   `json:"chain_height"`
+	Progress    float64 `json:"progress"` // 0 to 1
+	StartedAt   int64   `json:"started_at,omitempty"`
+	Error       string  `json:"error,omitempty"`
+}
+
+// IndexInfo returns the status of each optional index the node keeps.
+func (bc *Blockchain) IndexInfo() map[string]IndexStatus {
+	bc.mu.RLock()
+	defer bc.mu.RUnlock()
+	best := bc.Store.GetBestHeight()
+	info := map[string]IndexStatus{
+		"received": {Synced: true, Height: best, ChainHeight: best, Progress: 1},
+	}
+	if ix := bc.archive; ix != nil {
+		st := IndexStatus{Synced: ix.ready, Height: -1, ChainHeight: best, StartedAt: ix.startedAt.Unix()}
+		if h, ok := bc.Store.ArchiveHeight(); ok {
+			st.Height = int64(h)
+		}
+		if best >= 0 {
+			st.Progress = float64(st.Height+1) / float64(best+1)
+		}
+		if err := ix.failed(); err != nil {
+			st.Error = err.Error()
+		}
+		info["archive"] = st
+	}
+	return info
+}
+
+// runArchiveIndexer builds the archive indexes until they cover the tip,
+// then hands them to connectBlock.
+func (bc *Blockchain) runArchiveIndexer(ix *archiveIndexer) {
+	defer close(ix.done)
+	if err := bc.buildArchive(ix); err != nil {
+		ix.mu.Lock()
+		ix.err = err
+		ix.mu.Unlock()
+		logger.Error("archive indexing failed", "err", err)
+	}
+}
+
+func (bc *Blockchain) buildArchive(ix *archiveIndexer) error {
+	next := uint64(0)
+	if h, ok := bc.Store.ArchiveHeight(); ok {
+		next = h + 1
+	} else if err := bc.Store.ResetArchive(); err != nil {
+		return err
+	}
+
+	// Balances are replayed from genesis; blocks already indexed are only
+	// read, not written again.
+	balances := make(map[string]float64)
+	outputs := make(map[string]TxOutput) // unspent outputs, once the utxo model is active
+	var skipped int
+	for at := uint64(0); at < next; {
+		blocks, err := bc.decodeBlocksFrom(at, min(indexBatch, int(next-at)))
+		if err != nil {
+			return err
+		}
+		if len(blocks) == 0 {
+			return fmt.Errorf("archive indexes cover height %d, above the chain", next-1)
+		}
+		for i := range blocks {
+			_, n := bc.replayBalances(&blocks[i], balances, outputs)
+			skipped += n
+		}
+		at += uint64(len(blocks))
+	}
+	logger.Info("building archive indexes", "from", next, "blocks", bc.Store.GetBlockCount())
+
+	lastLog := time.Now()
+	for {
+		select {
+		case <-ix.stop:
+			logger.Info("archive indexing paused", "next", next)
+			return nil
+		default:
+		}
+
+		blocks, err := bc.decodeBlocksFrom(next, indexBatch)
+		if err != nil {
+			return err
+		}
+		if len(blocks) == 0 {
+			done, err := bc.finishArchive(ix, next, balances, skipped)
+			if done || err != nil {
+				return err
+			}
+			continue
+		}
+		batch := make([]storage.ArchiveBlock, 0, len(blocks))
+		for i := range blocks {
+			b := &blocks[i]
+			changed, n := bc.replayBalances(b, balances, outputs)
+			skipped += n
+			batch = append(batch, storage.ArchiveBlock{
+				Height:        b.Header.Height,
+				TxIDs:         collectTxIDs(b),
+				Balances:      changed,
+				ArchiveCommit: *archiveCommit(b),
+			})
+		}
+		if err := bc.Store.WriteArchive(batch); err != nil {
+			return err
+		}
+		next += uint64(len(blocks))
+		if time.Since(lastLog) > 10*time.Second {
+			logger.Info("archive indexing", "height", next-1, "blocks", bc.Store.GetBlockCount())
+			lastLog = time.Now()
+		}
+	}
+}
+
+// finishArchive marks the indexes ready if no block arrived after next-1,
+// so that from the next block on connectBlock writes archive entries.
+func (bc *Blockchain) finishArchive(ix *archiveIndexer, next uint64, balances map[string]float64, skipped int) (bool, error) {
+	bc.mu.Lock()
+	defer bc.mu.Unlock()
+	if uint64(bc.Store.GetBestHeight()+1) != next {
+		return false, nil
+	}
+	if err := bc.Store.RebuildRichList(); err != nil {
+		return false, err
+	}
+	var mismatched int
+	for addr, bal := range bc.Store.GetAllBalances() {
+		if math.Abs(balances[addr]-bal) > 0.00000001 {
+			mismatched++
+		}
+	}
+	if skipped > 0 || mismatched > 0 {
+		logger.Warn("archive balance history may be incomplete",
+			"custom_txs_skipped", skipped, "balances_mismatched", mismatched)
+	}
+	ix.ready = true
+	logger.Info("archive indexes ready", "height", int64(next)-1,
+		"took", time.Since(ix.startedAt).Round(time.Millisecond))
+	return true, nil
+}
+
+// decodeBlocksFrom returns up to limit stored blocks starting at height.
+func (bc *Blockchain) decodeBlocksFrom(height uint64, limit int) ([]Block, error) {
+	raw, err := bc.Store.GetBlocksFrom(height, limit)
+	if err != nil {
+		return nil, err
+	}
+	blocks := make([]Block, len(raw))
+	for i, data := range raw {
+		if err := json.Unmarshal(data, &blocks[i]); err != nil {
+			return nil, fmt.Errorf("decode block: %w", err)
+		}
+	}
+	return blocks, nil
+}
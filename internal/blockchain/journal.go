@@ -0,0 +1,249 @@
+package blockchain
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// ErrNoJournal is returned when the node runs without the event journal.
+var ErrNoJournal = errors.New("event journal is not enabled on this node (start with -journal)")
+
+// MaxJournalEntries caps the entries returned by one ReadJournal call.
+const MaxJournalEntries = 1000
+
+// JournalEntry is one recorded event. Seq numbers start at 1 and have no
+// gaps, so a consumer resumes from the last seq it processed plus one.
+type JournalEntry struct {
+	Seq    uint64       `json:"seq"`
+	Time   int64        `json:"time"`
+	Type   EventType    `json:"type"` // block_connected, tx_added or tx_removed
+	Block  *Block       `json:"block,omitempty"`
+	Tx     *Transaction `json:"tx,omitempty"`
+	Reason string       `json:"reason,omitempty"` // for tx_removed
+}
+
+// journal appends chain and mempool events to journal.log in the data
+// directory, one JSON entry per line. Block events are complete and in
+// height order: blocks connected while the journal wasn't running (before
+// it was first enabled, or between a crash and restart) are written from
+// the store when it opens. Mempool events are best effort.
+type journal struct {
+	f       *os.File
+	offsets []int64 // byte offset of entry seq i+1
+	size    int64
+	tip     int64         // height of the last journaled block, -1 for none
+	changed chan struct{} // closed and replaced on every append
+	mu      sync.Mutex
+}
+
+// openJournal opens or creates the journal, dropping a torn final line
+// left by a crash.
+func openJournal(dir string) (*journal, error) {
+	f, err := os.OpenFile(filepath.Join(dir, "journal.log"), os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+	j := &journal{f: f, tip: -1, changed: make(chan struct{})}
+	r := bufio.NewReader(f)
+	for {
+		line, err := r.ReadBytes('\n')
+		if err == io.EOF {
+			break // a partial line without its newline is discarded below
+		}
+		if err != nil {
+			f.Close()
+			return nil, err
+		}
+		var e struct {
+			Type  EventType `json:"type"`
+			Block *struct {
+				Header struct {
+					Height uint64 `json:"height"`
+				} `json:"header"`
+			} `json:"block"`
+		}
+		if json.Unmarshal(line, &e) != nil {
+			break
+		}
+		j.offsets = append(j.offsets, j.size)
+		j.size += int64(len(line))
+		if e.Type == EventBlockConnected && e.Block != nil {
+			j.tip = int64(e.Block.Header.Height)
+		}
+	}
+	if err := f.Truncate(j.size); err != nil {
+		f.Close()
+		return nil, err
+	}
+	if _, err := f.Seek(j.size, io.SeekStart); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return j, nil
+}
+
+// append writes one entry. The caller holds j.mu.
+func (j *journal) append(typ EventType, block *Block, tx *Transaction, reason string) error {
+	e := JournalEntry{
+		Seq:    uint64(len(j.offsets)) + 1,
+		Time:   time.Now().Unix(),
+		Type:   typ,
+		Block:  block,
+		Tx:     tx,
+		Reason: reason,
+	}
+	line, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+	if _, err := j.f.Write(line); err != nil {
+		j.f.Truncate(j.size)
+		j.f.Seek(j.size, io.SeekStart)
+		return err
+	}
+	if block != nil {
+		j.f.Sync()
+	}
+	j.offsets = append(j.offsets, j.size)
+	j.size += int64(len(line))
+	close(j.changed)
+	j.changed = make(chan struct{})
+	return nil
+}
+
+// catchUp journals the stored blocks after the journal's tip up to and
+// including height. The caller holds j.mu.
+func (j *journal) catchUp(bc *Blockchain, height int64) error {
+	for h := j.tip + 1; h <= height; h++ {
+		block := bc.loadBlock(uint64(h))
+		if block == nil {
+			return fmt.Errorf("journal: block %d missing from store", h)
+		}
+		if err := j.append(EventBlockConnected, block, nil, ""); err != nil {
+			return err
+		}
+		j.tip = h
+	}
+	return nil
+}
+
+// record journals an event from the bus.
+func (j *journal) record(bc *Blockchain, ev Event) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	var err error
+	switch ev.Type {
+	case EventBlockConnected:
+		err = j.catchUp(bc, int64(ev.Block.Header.Height))
+	case EventTxAdded:
+		err = j.append(ev.Type, nil, ev.Tx, "")
+	case EventTxExpired:
+		err = j.append(EventTxRemoved, nil, ev.Tx, RemovedExpired)
+	case EventTxRemoved:
+		err = j.append(ev.Type, nil, ev.Tx, ev.Reason)
+	}
+	if err != nil {
+		logger.Error("writing event journal failed", "event", ev.Type, "err", err)
+	}
+}
+
+func (j *journal) close() {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.f.Close()
+}
+
+// startJournal opens the journal, brings its block events up to the tip
+// and subscribes it to the event bus.
+func (bc *Blockchain) startJournal() error {
+	j, err := openJournal(bc.DataDir)
+	if err != nil {
+		return fmt.Errorf("open event journal: %w", err)
+	}
+	j.mu.Lock()
+	from := j.tip + 1
+	err = j.catchUp(bc, bc.Store.GetBestHeight())
+	j.mu.Unlock()
+	if err != nil {
+		j.close()
+		return err
+	}
+	if to := bc.Store.GetBestHeight(); to >= from {
+		logger.Info("event journal caught up", "from", from, "to", to)
+	}
+	bc.journal = j
+	bc.Events.Subscribe(func(ev Event) { j.record(bc, ev) },
+		EventBlockConnected, EventTxAdded, EventTxExpired, EventTxRemoved)
+	return nil
+}
+
+// JournalPage is a run of journal entries.
+type JournalPage struct {
+	Entries []JournalEntry `json:"entries"`
+	Next    uint64         `json:"next"` // seq to request next
+	Last    uint64         `json:"last"` // seq of the newest entry, 0 if empty
+}
+
+// ReadJournal returns up to limit entries starting at seq from. If there
+// are none yet it waits up to wait for one, or until done is closed.
+func (bc *Blockchain) ReadJournal(from uint64, limit int, wait time.Duration, done <-chan struct{}) (*JournalPage, error) {
+	j := bc.journal
+	if j == nil {
+		return nil, ErrNoJournal
+	}
+	if from == 0 {
+		from = 1
+	}
+	if limit <= 0 || limit > MaxJournalEntries {
+		limit = MaxJournalEntries
+	}
+	j.mu.Lock()
+	if uint64(len(j.offsets)) < from && wait > 0 {
+		changed := j.changed
+		j.mu.Unlock()
+		timer := time.NewTimer(wait)
+		select {
+		case <-changed:
+		case <-timer.C:
+		case <-done:
+		}
+		timer.Stop()
+		j.mu.Lock()
+	}
+	defer j.mu.Unlock()
+
+	last := uint64(len(j.offsets))
+	page := &JournalPage{Entries: []JournalEntry{}, Next: from, Last: last}
+	if from > last {
+		return page, nil
+	}
+	end := min(last, from+uint64(limit)-1)
+	start := j.offsets[from-1]
+	stop := j.size
+	if end < last {
+		stop = j.offsets[end]
+	}
+	buf := make([]byte, stop-start)
+	if _, err := j.f.ReadAt(buf, start); err != nil {
+		return nil, fmt.Errorf("read event journal: %w", err)
+	}
+	dec := json.NewDecoder(bytes.NewReader(buf))
+	for dec.More() {
+		var e JournalEntry
+		if err := dec.Decode(&e); err != nil {
+			return nil, fmt.Errorf("read event journal: %w", err)
+		}
+		page.Entries = append(page.Entries, e)
+	}
+	page.Next = end + 1
+	return page, nil
+}
@@ -0,0 +1,187 @@
+package blockchain
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrImmature means a transaction spends coinbase or pos_reward coins
+// that have not yet matured.
+var ErrImmature = errors.New("spends immature rewards")
+
+// maturingRewards holds what the latest blocks paid each address in
+// rewards, in units, by height.
+type maturingRewards map[uint64]map[string]int64
+
+// rewardOutputs returns what the coinbase and pos_reward transactions of
+// block pay each address, in units. The genesis block can't be reorganized
+// away, so its allocations are spendable at once and it returns nil.
+func rewardOutputs(block *Block) map[string]int64 {
+	if block.Header.Height == 0 {
+		return nil
+	}
+	paid := make(map[string]int64)
+	for _, tx := range block.Transactions {
+		if tx.Type != "coinbase" && tx.Type != "pos_reward" {
+			continue
+		}
+		for _, out := range tx.Outputs {
+			paid[out.Address] += ToUnits(out.Amount)
+		}
+	}
+	return paid
+}
+
+// loadMaturing reads the rewards of the latest CoinbaseMaturity blocks,
+// the ones that may still be immature.
+func (bc *Blockchain) loadMaturing() error {
+	bc.maturing = make(maturingRewards)
+	if bc.Config.CoinbaseMaturity == 0 || bc.lastBlock == nil {
+		return nil
+	}
+	best := bc.lastBlock.Header.Height
+	from := uint64(1)
+	if best >= bc.Config.CoinbaseMaturity {
+		from = best + 1 - bc.Config.CoinbaseMaturity
+	}
+	for h := from; h <= best; h++ {
+		b, err := bc.readBlock(h)
+		if err != nil {
+			return err
+		}
+		bc.maturing[h] = rewardOutputs(b)
+	}
+	return nil
+}
+
+// recordMaturing adds the rewards of a connected block and forgets those
+// that are mature for the next block.
+func (bc *Blockchain) recordMaturing(block *Block, paid map[string]int64) {
+	if bc.Config.CoinbaseMaturity == 0 {
+		return
+	}
+	bc.maturing[block.Header.Height] = paid
+	for h := range bc.maturing {
+		if h+bc.Config.CoinbaseMaturity <= block.Header.Height+1 {
+			delete(bc.maturing, h)
+		}
+	}
+}
+
+// immatureUnits returns the rewards paid to address that a transaction in
+// a block at height may not spend yet, in units: those paid fewer than
+// CoinbaseMaturity blocks earlier. The caller holds bc.mu.
+func (bc *Blockchain) immatureUnits(address string, height uint64) int64 {
+	if !bc.Config.MaturityActive(height) {
+		return 0
+	}
+	var units int64
+	for h, paid := range bc.maturing {
+		if h+bc.Config.CoinbaseMaturity > height {
+			units += paid[address]
+		}
+	}
+	return units
+}
+
+// GetImmature returns the rewards paid to address that can't be spent in
+// the next block yet.
+func (bc *Blockchain) GetImmature(address string) float64 {
+	bc.mu.RLock()
+	defer bc.mu.RUnlock()
+	return FromUnits(bc.immatureUnits(address, bc.Store.GetBlockCount()))
+}
+
+// utxoMaturity returns the maturity a utxo view connecting a block at
+// height enforces, or 0.
+func (bc *Blockchain) utxoMaturity(height uint64) uint64 {
+	if !bc.Config.MaturityActive(height) {
+		return 0
+	}
+	return bc.Config.CoinbaseMaturity
+}
+
+// checkMatureSpend refuses an account-network transaction whose sender
+// can only cover it, together with its pending ones, with immature
+// rewards. On utxo networks each input is checked instead.
+func (bc *Blockchain) checkMatureSpend(tx *Transaction, height uint64) error {
+	need := spendOf(*tx)
+	if need == 0 || tx.From == "" || bc.Config.UTXOActive(height) {
+		return nil
+	}
+	immature := bc.immatureUnits(tx.From, height)
+	if immature == 0 {
+		return nil
+	}
+	available := bc.balances.get(tx.From) - bc.Mempool.PendingSpend(tx.From)
+	if addsStake(tx) {
+		available -= bc.Stakes.GetStake(tx.From)
+	}
+	if mature := available - FromUnits(immature); mature+0.00000001 < need && available+0.00000001 >= need {
+		return fmt.Errorf("%w: need %.8f, %.8f of %s's %.8f is not yet mature",
+			ErrImmature, need, FromUnits(immature), tx.From, available)
+	}
+	return nil
+}
+
+// checkMatureBalances checks, on account networks, that no sender in
+// block spent into rewards that are still immature: each one's balance
+// after the block must cover them, counting what the block itself pays.
+func (bc *Blockchain) checkMatureBalances(block *Block, ledger *lockedState, paid map[string]int64) error {
+	height := block.Header.Height
+	if !bc.Config.MaturityActive(height) || bc.Config.UTXOActive(height) {
+		return nil
+	}
+	checked := make(map[string]bool)
+	for i := range block.Transactions {
+		tx := &block.Transactions[i]
+		if !needsSignature(tx) || checked[tx.From] || spendOf(*tx) == 0 {
+			continue
+		}
+		checked[tx.From] = true
+		immature := bc.immatureUnits(tx.From, height) + paid[tx.From]
+		if bal := ToUnits(ledger.Balance(tx.From)); bal < immature {
+			return fmt.Errorf("tx %s: %w: %s holds %.8f, %.8f of it immature",
+				tx.TxID, ErrImmature, tx.From, FromUnits(bal), FromUnits(immature))
+		}
+	}
+	return nil
+}
+
+// removeImmatureSpends drops pending transactions that spend rewards
+// still immature at height when maturity rules activate, and returns them.
+// The caller holds bc.mu.
+func (bc *Blockchain) removeImmatureSpends(height uint64) []Transaction {
+	var removed []Transaction
+	maturity := bc.Config.CoinbaseMaturity
+	utxo := bc.Config.UTXOActive(height)
+	view := newUTXOView(bc.Store)
+	for _, e := range bc.Mempool.ordered() {
+		tx := e.Tx
+		if !needsSignature(&tx) || !bc.Mempool.Has(tx.TxID) {
+			continue
+		}
+		drop := false
+		if utxo {
+			for _, in := range tx.Inputs {
+				if u, ok := view.get(outpoint(in.TxID, in.Vout)); ok && u.immature(height, maturity) {
+					drop = true
+				}
+			}
+		} else if immature := bc.immatureUnits(tx.From, height); immature > 0 {
+			mature := bc.balances.get(tx.From) - FromUnits(immature)
+			drop = mature+0.00000001 < bc.Mempool.PendingSpend(tx.From)
+		}
+		if drop {
+			removed = append(removed, tx)
+			bc.Mempool.remove(tx.TxID)
+		}
+	}
+	return removed
+}
+
+// immature reports whether u is a reward a block at height may not spend
+// under maturity, where 0 disables the rule.
+func (u *UTXO) immature(height, maturity uint64) bool {
+	return u.Coinbase && maturity > 0 && u.Height > 0 && height < u.Height+maturity
+}
@@ -0,0 +1,656 @@
+package blockchain
+
+import (
+	"container/heap"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// Default in-pool chain limits. A transaction may have at most this many
+// unconfirmed ancestors (itself included), and no pending transaction may
+// gain more than this many unconfirmed descendants.
+const (
+	DefaultMaxAncestors   = 25
+	DefaultMaxDescendants = 25
+)
+
+// mempoolEntry is a pending transaction plus its in-pool dependency links.
+// A transaction depends on every pending transaction that credits its
+// sender, since it may be spending those funds, and on its sender's
+// lower-nonce transactions.
+type mempoolEntry struct {
+	Tx       Transaction
+	Seq      uint64
+	Height   uint64 // chain height when admitted, for coin-age priority
+	Size     int    // canonical encoding length, for fee rate
+	Added    time.Time
+	parents  map[string]bool
+	children map[string]bool
+	ancestry packageTotals // this transaction and its in-pool ancestors
+}
+
+// packageTotals sums the fees and sizes of a package of pending
+// transactions.
+type packageTotals struct {
+	fee  int64 // base units, so totals add and subtract exactly
+	size int
+}
+
+// own returns the totals of e's transaction alone.
+func (e *mempoolEntry) own() packageTotals {
+	return packageTotals{fee: ToUnits(e.Tx.Fee), size: e.Size}
+}
+
+func (t packageTotals) plus(o packageTotals) packageTotals {
+	return packageTotals{t.fee + o.fee, t.size + o.size}
+}
+
+func (t packageTotals) minus(o packageTotals) packageTotals {
+	return packageTotals{t.fee - o.fee, t.size - o.size}
+}
+
+// senderState aggregates the pending transactions of one address.
+type senderState struct {
+	Spend float64 // amount + fee of all pending txs from this address
+	TxIDs map[string]bool
+}
+
+// Mempool holds unconfirmed transactions keyed by txid, with per-sender
+// aggregates and parent/child links. It is guarded by the Blockchain lock.
+type Mempool struct {
+	entries     map[string]*mempoolEntry
+	bySender    map[string]*senderState
+	byRecipient map[string]map[string]bool // address -> txids crediting it
+	spentBy     map[string]string          // outpoint -> pending txid spending it
+	nextSeq     uint64
+
+	MaxAncestors   int
+	MaxDescendants int
+}
+
+// NewMempool creates an empty mempool.
+func NewMempool() *Mempool {
+	return &Mempool{
+		entries:     make(map[string]*mempoolEntry),
+		bySender:    make(map[string]*senderState),
+		byRecipient: make(map[string]map[string]bool),
+		spentBy:     make(map[string]string),
+
+		MaxAncestors:   DefaultMaxAncestors,
+		MaxDescendants: DefaultMaxDescendants,
+	}
+}
+
+// Len returns the number of pending transactions.
+func (mp *Mempool) Len() int {
+	return len(mp.entries)
+}
+
+// Has reports whether txid is pending.
+func (mp *Mempool) Has(txid string) bool {
+	_, ok := mp.entries[txid]
+	return ok
+}
+
+// Get returns a pending transaction.
+func (mp *Mempool) Get(txid string) (Transaction, bool) {
+	e, ok := mp.entries[txid]
+	if !ok {
+		return Transaction{}, false
+	}
+	return e.Tx, true
+}
+
+// PendingSpend returns the total amount plus fees of pending transactions
+// sent from address.
+func (mp *Mempool) PendingSpend(address string) float64 {
+	if st, ok := mp.bySender[address]; ok {
+		return st.Spend
+	}
+	return 0
+}
+
+// pendingUnstake returns the total amount of pending unstakes sent from
+// address.
+func (mp *Mempool) pendingUnstake(address string) float64 {
+	total := 0.0
+	if st, ok := mp.bySender[address]; ok {
+		for id := range st.TxIDs {
+			if e := mp.entries[id]; e.Tx.Type == "unstake" {
+				total += e.Tx.Amount
+			}
+		}
+	}
+	return total
+}
+
+// hasPending reports whether a transaction of txType sent from address is
+// pending.
+func (mp *Mempool) hasPending(address, txType string) bool {
+	if st, ok := mp.bySender[address]; ok {
+		for id := range st.TxIDs {
+			if mp.entries[id].Tx.Type == txType {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// PendingCount returns the number of pending transactions sent from address.
+func (mp *Mempool) PendingCount(address string) int {
+	if st, ok := mp.bySender[address]; ok {
+		return len(st.TxIDs)
+	}
+	return 0
+}
+
+// add inserts tx and links it to its in-pool parents. The caller checks
+// for duplicates.
+func (mp *Mempool) add(tx Transaction, height uint64) *mempoolEntry {
+	e := &mempoolEntry{
+		Tx:       tx,
+		Seq:      mp.nextSeq,
+		Height:   height,
+		Size:     tx.Size(),
+		Added:    time.Now(),
+		parents:  make(map[string]bool),
+		children: make(map[string]bool),
+	}
+	mp.nextSeq++
+	mp.entries[tx.TxID] = e
+
+	if tx.From != "" {
+		for pid := range mp.parentsOf(tx) {
+			e.parents[pid] = true
+			mp.entries[pid].children[tx.TxID] = true
+		}
+		st, ok := mp.bySender[tx.From]
+		if !ok {
+			st = &senderState{TxIDs: make(map[string]bool)}
+			mp.bySender[tx.From] = st
+		}
+		st.Spend += spendOf(tx)
+		st.TxIDs[tx.TxID] = true
+	}
+	for _, addr := range creditedAddresses(tx) {
+		set, ok := mp.byRecipient[addr]
+		if !ok {
+			set = make(map[string]bool)
+			mp.byRecipient[addr] = set
+		}
+		set[tx.TxID] = true
+	}
+	for _, in := range tx.Inputs {
+		mp.spentBy[outpoint(in.TxID, in.Vout)] = tx.TxID
+	}
+	e.ancestry = mp.ancestry(e)
+	return e
+}
+
+// ancestry returns the totals of e and its in-pool ancestors.
+func (mp *Mempool) ancestry(e *mempoolEntry) packageTotals {
+	t := e.own()
+	for id := range mp.ancestors(e.parents) {
+		if a, ok := mp.entries[id]; ok {
+			t = t.plus(a.own())
+		}
+	}
+	return t
+}
+
+// parentsOf returns the pending transactions tx depends on: those that
+// credit its sender and, if tx has a nonce, the sender's earlier ones,
+// which must confirm first.
+func (mp *Mempool) parentsOf(tx Transaction) map[string]bool {
+	if tx.Nonce == 0 {
+		return mp.byRecipient[tx.From]
+	}
+	parents := make(map[string]bool)
+	for id := range mp.byRecipient[tx.From] {
+		parents[id] = true
+	}
+	if st, ok := mp.bySender[tx.From]; ok {
+		for id := range st.TxIDs {
+			if mp.entries[id].Tx.Nonce != 0 {
+				parents[id] = true
+			}
+		}
+	}
+	return parents
+}
+
+// remove deletes txid and unlinks it from parents, children and aggregates.
+func (mp *Mempool) remove(txid string) {
+	e, ok := mp.entries[txid]
+	if !ok {
+		return
+	}
+	descendants := mp.descendants(txid)
+	delete(mp.entries, txid)
+
+	for pid := range e.parents {
+		if p, ok := mp.entries[pid]; ok {
+			delete(p.children, txid)
+		}
+	}
+	for cid := range e.children {
+		if c, ok := mp.entries[cid]; ok {
+			delete(c.parents, txid)
+		}
+	}
+
+	tx := e.Tx
+	if st, ok := mp.bySender[tx.From]; ok {
+		st.Spend -= spendOf(tx)
+		delete(st.TxIDs, txid)
+		if len(st.TxIDs) == 0 {
+			delete(mp.bySender, tx.From)
+		}
+	}
+	for _, addr := range creditedAddresses(tx) {
+		if set, ok := mp.byRecipient[addr]; ok {
+			delete(set, txid)
+			if len(set) == 0 {
+				delete(mp.byRecipient, addr)
+			}
+		}
+	}
+	for _, in := range tx.Inputs {
+		delete(mp.spentBy, outpoint(in.TxID, in.Vout))
+	}
+	// Descendants lose txid and whatever they only depended on through it.
+	for id := range descendants {
+		d := mp.entries[id]
+		d.ancestry = mp.ancestry(d)
+	}
+}
+
+// removeSpenders drops pending transactions that spend any of the given
+// outpoints, after a block spent them first, and returns them.
+func (mp *Mempool) removeSpenders(spent map[string]bool) []Transaction {
+	var removed []Transaction
+	for op := range spent {
+		if txid, ok := mp.spentBy[op]; ok {
+			removed = append(removed, mp.entries[txid].Tx)
+			mp.remove(txid)
+		}
+	}
+	return removed
+}
+
+// removeUnfunded drops account-model transactions, which have neither
+// inputs nor outputs, when the utxo model activates, and returns them.
+func (mp *Mempool) removeUnfunded() []Transaction {
+	var removed []Transaction
+	for txid, e := range mp.entries {
+		if e.Tx.From != "" && len(e.Tx.Inputs) == 0 && len(e.Tx.Outputs) == 0 {
+			removed = append(removed, e.Tx)
+			mp.remove(txid)
+		}
+	}
+	return removed
+}
+
+// removeUnsigned drops transactions without an envelope signature when
+// signature rules activate, and returns them.
+func (mp *Mempool) removeUnsigned() []Transaction {
+	var removed []Transaction
+	for txid, e := range mp.entries {
+		if needsSignature(&e.Tx) && len(e.Tx.Signature) != 2*envelopeSigLen {
+			removed = append(removed, e.Tx)
+			mp.remove(txid)
+		}
+	}
+	return removed
+}
+
+// removeNonceless drops transactions without a nonce when nonce rules
+// activate, and returns them.
+func (mp *Mempool) removeNonceless() []Transaction {
+	var removed []Transaction
+	for txid, e := range mp.entries {
+		if needsSignature(&e.Tx) && e.Tx.Nonce == 0 {
+			removed = append(removed, e.Tx)
+			mp.remove(txid)
+		}
+	}
+	return removed
+}
+
+// ancestors returns every pending transaction that the given parents
+// depend on, including the parents themselves.
+func (mp *Mempool) ancestors(parents map[string]bool) map[string]bool {
+	seen := make(map[string]bool)
+	stack := make([]string, 0, len(parents))
+	for id := range parents {
+		stack = append(stack, id)
+	}
+	for len(stack) > 0 {
+		id := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+		if e, ok := mp.entries[id]; ok {
+			for pid := range e.parents {
+				stack = append(stack, pid)
+			}
+		}
+	}
+	return seen
+}
+
+// descendants returns every pending transaction that depends on txid,
+// directly or indirectly.
+func (mp *Mempool) descendants(txid string) map[string]bool {
+	seen := make(map[string]bool)
+	stack := []string{txid}
+	for len(stack) > 0 {
+		id := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		for cid := range mp.entries[id].children {
+			if !seen[cid] {
+				seen[cid] = true
+				stack = append(stack, cid)
+			}
+		}
+	}
+	return seen
+}
+
+// checkLimits reports whether adding tx would exceed the ancestor or
+// descendant limits.
+func (mp *Mempool) checkLimits(tx Transaction) error {
+	if tx.From == "" {
+		return nil
+	}
+	anc := mp.ancestors(mp.parentsOf(tx))
+	if len(anc)+1 > mp.MaxAncestors {
+		return fmt.Errorf("too many unconfirmed ancestors (%d, limit %d)", len(anc)+1, mp.MaxAncestors)
+	}
+	for id := range anc {
+		if n := len(mp.descendants(id)) + 1; n > mp.MaxDescendants {
+			return fmt.Errorf("ancestor %s would have too many unconfirmed descendants (%d, limit %d)",
+				id, n, mp.MaxDescendants)
+		}
+	}
+	return nil
+}
+
+// templateBudget is the room left in a block template.
+type templateBudget struct {
+	txs   int // transaction count
+	bytes int // serialized bytes, see Block.Size
+}
+
+// selectPackages fills budget for a block template. The first
+// prioritySlots transactions are chosen by coin-age priority so that aged
+// low-fee transactions are not starved; the rest go by ancestor fee rate.
+// Each candidate is scored together with its unselected ancestors, so a
+// high-fee child can pull in a low-fee parent (CPFP). Parents always
+// precede their children in the result. Reward and slash transactions are
+// never selected; the producer adds its own.
+func (mp *Mempool) selectPackages(budget templateBudget, prioritySlots int, height uint64) []Transaction {
+	if prioritySlots > budget.txs {
+		prioritySlots = budget.txs
+	}
+	sel := &packageSelection{mp: mp, budget: budget, selected: make(map[string]bool),
+		unselected: make(map[*mempoolEntry]packageTotals)}
+	sel.fill(prioritySlots, true, func(e *mempoolEntry, _ packageTotals) float64 {
+		return packagePriority(sel.pending(e), height)
+	})
+	sel.fill(budget.txs, false, func(_ *mempoolEntry, t packageTotals) float64 { return float64(t.fee) })
+	return sel.out
+}
+
+// packageSelection is a block template being filled with packages.
+type packageSelection struct {
+	mp       *Mempool
+	budget   templateBudget
+	selected map[string]bool
+	out      []Transaction
+
+	// unselected holds the totals of pending transactions with selected
+	// ancestors, counting only the ancestors still unselected.
+	unselected map[*mempoolEntry]packageTotals
+}
+
+// totals returns the totals of e and its unselected ancestors.
+func (s *packageSelection) totals(e *mempoolEntry) packageTotals {
+	if t, ok := s.unselected[e]; ok {
+		return t
+	}
+	return e.ancestry
+}
+
+// fill appends packages to s.out, best score first, until it holds max
+// transactions or the byte budget runs out. Packages rank by score over
+// their size; score is given the package's last transaction and its
+// totals. With positiveOnly, packages scoring zero are left out.
+func (s *packageSelection) fill(max int, positiveOnly bool, score func(*mempoolEntry, packageTotals) float64) {
+	candidates := &packageHeap{}
+	push := func(e *mempoolEntry) {
+		t := s.totals(e)
+		heap.Push(candidates, packageCandidate{entry: e, totals: t, score: score(e, t)})
+	}
+	for _, e := range s.mp.entries {
+		if !s.selected[e.Tx.TxID] && !producerOnly(e.Tx.Type) {
+			push(e)
+		}
+	}
+	for len(s.out) < max && candidates.Len() > 0 {
+		c := heap.Pop(candidates).(packageCandidate)
+		if s.selected[c.entry.Tx.TxID] || c.totals != s.totals(c.entry) {
+			continue // already taken, or pushed again since with new totals
+		}
+		if positiveOnly && c.score <= 0 {
+			break
+		}
+		pkg := s.pending(c.entry)
+		pkgBytes := 0
+		for _, e := range pkg {
+			pkgBytes += txSlot(e.Size)
+		}
+		if len(s.out)+len(pkg) > max || pkgBytes > s.budget.bytes {
+			// The package doesn't fit; its tip can't be included without
+			// its ancestors, but smaller packages still may.
+			continue
+		}
+		s.budget.bytes -= pkgBytes
+		for _, e := range pkg {
+			s.selected[e.Tx.TxID] = true
+			s.out = append(s.out, e.Tx)
+		}
+		// Whatever descends from the package now scores without it.
+		for _, e := range pkg {
+			for id := range s.mp.descendants(e.Tx.TxID) {
+				d := s.mp.entries[id]
+				if s.selected[id] || producerOnly(d.Tx.Type) {
+					continue
+				}
+				s.unselected[d] = s.totals(d).minus(e.own())
+				push(d)
+			}
+		}
+	}
+}
+
+// pending returns e and its ancestors that aren't yet selected, in arrival
+// order.
+func (s *packageSelection) pending(e *mempoolEntry) []*mempoolEntry {
+	pkg := []*mempoolEntry{e}
+	for id := range s.mp.ancestors(e.parents) {
+		if a, ok := s.mp.entries[id]; ok && !s.selected[id] {
+			pkg = append(pkg, a)
+		}
+	}
+	sort.Slice(pkg, func(i, j int) bool { return pkg[i].Seq < pkg[j].Seq })
+	return pkg
+}
+
+// packageCandidate is a package, named by its last transaction, with the
+// totals it was scored on.
+type packageCandidate struct {
+	entry  *mempoolEntry
+	totals packageTotals
+	score  float64
+}
+
+// packageHeap orders candidates by score over size, best first, then by
+// arrival.
+type packageHeap []packageCandidate
+
+func (h packageHeap) Len() int { return len(h) }
+
+func (h packageHeap) Less(i, j int) bool {
+	// Compare ratios without dividing.
+	a, b := h[i].score*float64(h[j].totals.size), h[j].score*float64(h[i].totals.size)
+	if a != b {
+		return a > b
+	}
+	return h[i].entry.Seq < h[j].entry.Seq
+}
+
+func (h packageHeap) Swap(i, j int) { h[i], h[j] = h[j], h[i] }
+
+func (h *packageHeap) Push(x any) { *h = append(*h, x.(packageCandidate)) }
+
+func (h *packageHeap) Pop() any {
+	old := *h
+	c := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return c
+}
+
+// packagePriority returns the package's coin-age: amount times blocks
+// waited. Transactions admitted at the current height have no age yet.
+func packagePriority(pkg []*mempoolEntry, height uint64) float64 {
+	var age float64
+	for _, e := range pkg {
+		if height > e.Height {
+			age += e.Tx.Amount * float64(height-e.Height)
+		}
+	}
+	return age
+}
+
+// ordered returns pending transactions in arrival order, which always
+// places parents before their children.
+func (mp *Mempool) ordered() []*mempoolEntry {
+	list := make([]*mempoolEntry, 0, len(mp.entries))
+	for _, e := range mp.entries {
+		list = append(list, e)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Seq < list[j].Seq })
+	return list
+}
+
+// Transactions returns a copy of all pending transactions in arrival order.
+func (mp *Mempool) Transactions() []Transaction {
+	entries := mp.ordered()
+	txs := make([]Transaction, len(entries))
+	for i, e := range entries {
+		txs[i] = e.Tx
+	}
+	return txs
+}
+
+// DoubleSpendError rejects a transaction that spends funds already
+// committed by pending transactions from the same sender. The first-seen
+// transaction wins.
+type DoubleSpendError struct {
+	TxID      string
+	Conflicts []string
+	Need      float64
+	Available float64
+}
+
+func (e *DoubleSpendError) Error() string {
+	return fmt.Sprintf("double spend: tx %s needs %.8f but only %.8f is unspent after %d pending tx(s)",
+		e.TxID, e.Need, e.Available, len(e.Conflicts))
+}
+
+// checkConflicts rejects tx if the sender's committed balance cannot cover
+// it on top of everything the sender already has pending. It runs after
+// the plain balance check, so a failure here means the funds are spoken
+// for by another pending transaction.
+func (bc *Blockchain) checkConflicts(tx Transaction) error {
+	need := spendOf(tx)
+	if need == 0 || tx.From == "" {
+		return nil
+	}
+	available := bc.balances.get(tx.From) - bc.Mempool.PendingSpend(tx.From)
+	if addsStake(&tx) {
+		available -= bc.Stakes.GetStake(tx.From)
+	}
+	if available+0.00000001 >= need {
+		return nil
+	}
+	var conflicts []string
+	if st, ok := bc.Mempool.bySender[tx.From]; ok {
+		for id := range st.TxIDs {
+			conflicts = append(conflicts, id)
+		}
+		sort.Strings(conflicts)
+	}
+	return &DoubleSpendError{TxID: tx.TxID, Conflicts: conflicts, Need: need, Available: available}
+}
+
+// ExpireMempool drops transactions that have been pending longer than the
+// relay policy's expiry and publishes EventTxExpired for each.
+func (bc *Blockchain) ExpireMempool(now time.Time) []Transaction {
+	bc.mu.Lock()
+	cutoff := now.Add(-time.Duration(bc.relay.MempoolExpiryHours) * time.Hour)
+	var expired []Transaction
+	for _, e := range bc.Mempool.ordered() {
+		if e.Added.Before(cutoff) {
+			expired = append(expired, e.Tx)
+			bc.Mempool.remove(e.Tx.TxID)
+		}
+	}
+	bc.mu.Unlock()
+
+	for i := range expired {
+		bc.Events.Publish(Event{Type: EventTxExpired, Tx: &expired[i]})
+	}
+	return expired
+}
+
+// PendingSince returns pending transactions that entered the mempool
+// before t, in arrival order.
+func (bc *Blockchain) PendingSince(t time.Time) []Transaction {
+	bc.mu.RLock()
+	defer bc.mu.RUnlock()
+	var txs []Transaction
+	for _, e := range bc.Mempool.ordered() {
+		if e.Added.Before(t) {
+			txs = append(txs, e.Tx)
+		}
+	}
+	return txs
+}
+
+func spendOf(tx Transaction) float64 {
+	switch tx.Type {
+	case "transfer", "burn":
+		return tx.Amount + tx.Fee
+	case "stake", "delegate":
+		return tx.Amount
+	case "vote":
+		return tx.Fee
+	}
+	return 0
+}
+
+func creditedAddresses(tx Transaction) []string {
+	switch tx.Type {
+	case "transfer":
+		return []string{tx.To}
+	case "unstake":
+		return []string{tx.From}
+	}
+	return nil
+}
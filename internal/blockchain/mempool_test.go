@@ -0,0 +1,91 @@
+package blockchain
+
+import "testing"
+
+func TestMempoolRefusesProducerTransactions(t *testing.T) {
+	bc := newRegtestChain(t)
+	coinbase := NewCoinbaseTransaction(testMiner, 50, 1)
+	posReward := coinbase
+	posReward.Type = "pos_reward"
+	posReward.TxID = posReward.ComputeTxID()
+	slash := Transaction{Type: "slash", From: testMiner, To: testMiner, Amount: 1}
+	slash.TxID = slash.ComputeTxID()
+
+	for _, tx := range []Transaction{coinbase, posReward, slash} {
+		if err := bc.AddToMempool(tx); err == nil {
+			t.Errorf("%s accepted into the mempool", tx.Type)
+		}
+	}
+	if n := len(bc.GetMempool()); n != 0 {
+		t.Fatalf("mempool holds %d transactions, want 0", n)
+	}
+
+	// One that got in anyway must not end up in a template.
+	bc.Mempool.add(coinbase, 0)
+	block := bc.CreateBlockTemplate(testMiner)
+	for i, tx := range block.Transactions {
+		if tx.TxID == coinbase.TxID {
+			t.Fatalf("pooled coinbase selected at position %d", i)
+		}
+	}
+	if !SolveBlock(block, 1<<32) {
+		t.Fatal("failed to solve block")
+	}
+	if err := bc.AddBlock(block); err != nil {
+		t.Fatalf("template rejected: %v", err)
+	}
+}
+
+func TestSelectPackages(t *testing.T) {
+	// p pays a with a low fee, c spends from a with a high one, and m is
+	// unrelated, between the two.
+	newPool := func() *Mempool {
+		mp := NewMempool()
+		mp.add(NewTransferTransaction("x", "a", 100, 0.0001, ""), 1)
+		mp.add(NewTransferTransaction("a", "b", 1, 0.01, ""), 5)
+		mp.add(NewTransferTransaction("y", "z", 1, 0.001, ""), 5)
+		return mp
+	}
+	const p, c, m = 0, 1, 2
+	tests := []struct {
+		name     string
+		txs      int
+		slots    float64 // byte budget in transactions
+		priority int
+		want     []int
+	}{
+		{"child pays for parent", 10, 10, 0, []int{p, c, m}},
+		{"package over the count", 1, 10, 0, []int{m}},
+		{"package over the bytes", 10, 1.5, 0, []int{m}},
+		{"priority slot", 1, 10, 1, []int{p}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mp := newPool()
+			pool := mp.Transactions()
+			bytes := int(tt.slots * float64(txSlot(pool[m].Size())))
+			got := mp.selectPackages(templateBudget{txs: tt.txs, bytes: bytes}, tt.priority, 10)
+			if len(got) != len(tt.want) {
+				t.Fatalf("selected %d transactions, want %d", len(got), len(tt.want))
+			}
+			for i, w := range tt.want {
+				if got[i].TxID != pool[w].TxID {
+					t.Errorf("position %d holds %s, want %s", i, got[i].TxID, pool[w].TxID)
+				}
+			}
+		})
+	}
+}
+
+func TestMempoolAncestryTotals(t *testing.T) {
+	mp := NewMempool()
+	parent := mp.add(NewTransferTransaction("x", "a", 100, 0.0001, ""), 1)
+	child := mp.add(NewTransferTransaction("a", "b", 1, 0.01, ""), 1)
+	if want := parent.own().plus(child.own()); child.ancestry != want {
+		t.Fatalf("child ancestry %+v, want %+v", child.ancestry, want)
+	}
+	mp.remove(parent.Tx.TxID)
+	if child.ancestry != child.own() {
+		t.Fatalf("child ancestry %+v after its parent left, want %+v", child.ancestry, child.own())
+	}
+}
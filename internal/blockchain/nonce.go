@@ -0,0 +1,114 @@
+package blockchain
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrNonceUsed means a transaction's nonce is not above its sender's last
+// confirmed one: it was already confirmed, or is a replay of one that was.
+var ErrNonceUsed = errors.New("nonce already used")
+
+// checkNonce checks tx's nonce against last, the sender's highest nonce so
+// far. Nonces only bind through the canonical TxID, so a legacy
+// transaction can't carry one.
+func checkNonce(tx *Transaction, last uint64) error {
+	if tx.Version < TxVersionCanonical {
+		return fmt.Errorf("version %d transactions can't carry a nonce", tx.Version)
+	}
+	if tx.Nonce == 0 {
+		return fmt.Errorf("transaction has no nonce")
+	}
+	if tx.Nonce <= last {
+		return &RejectError{Code: RejectDuplicate,
+			Err: fmt.Errorf("%w: %d, %s is at %d", ErrNonceUsed, tx.Nonce, tx.From, last)}
+	}
+	return nil
+}
+
+// blockNonces returns the highest nonce each sender uses in block. From
+// nonce_rules_height on, every transaction with a sender must carry a
+// nonce above the sender's previous one, in the chain or earlier in the
+// block.
+func (bc *Blockchain) blockNonces(block *Block) (map[string]uint64, error) {
+	if !bc.Config.NonceRulesActive(block.Header.Height) {
+		return nil, nil
+	}
+	last := make(map[string]uint64)
+	for i := range block.Transactions {
+		tx := &block.Transactions[i]
+		if !needsSignature(tx) {
+			continue
+		}
+		n, ok := last[tx.From]
+		if !ok {
+			n = bc.Store.GetNonce(tx.From)
+		}
+		if err := checkNonce(tx, n); err != nil {
+			return nil, fmt.Errorf("tx %s: %w", tx.TxID, err)
+		}
+		last[tx.From] = tx.Nonce
+	}
+	return last, nil
+}
+
+// checkMempoolNonce admits tx only above its sender's confirmed and
+// pending nonces, so each sender's pending transactions confirm in the
+// order they were sent.
+func (bc *Blockchain) checkMempoolNonce(tx *Transaction, height uint64) error {
+	if !bc.Config.NonceRulesActive(height) || !needsSignature(tx) {
+		return nil
+	}
+	if err := checkNonce(tx, bc.Store.GetNonce(tx.From)); err != nil {
+		return err
+	}
+	if pending := bc.Mempool.pendingNonce(tx.From); tx.Nonce <= pending {
+		return rejectf(RejectPolicy, "nonce %d is not above %s's pending nonce %d", tx.Nonce, tx.From, pending)
+	}
+	return nil
+}
+
+// nextNonce returns the nonce for address's next transaction: one above
+// its confirmed and pending nonces. The caller holds bc.mu.
+func (bc *Blockchain) nextNonce(address string) uint64 {
+	return max(bc.Store.GetNonce(address), bc.Mempool.pendingNonce(address)) + 1
+}
+
+// GetNonce returns address's highest confirmed nonce and the nonce its
+// next transaction should carry.
+func (bc *Blockchain) GetNonce(address string) (confirmed, next uint64) {
+	bc.mu.RLock()
+	defer bc.mu.RUnlock()
+	return bc.Store.GetNonce(address), bc.nextNonce(address)
+}
+
+// pendingNonce returns the highest nonce among address's pending
+// transactions, or 0.
+func (mp *Mempool) pendingNonce(address string) uint64 {
+	var n uint64
+	if st, ok := mp.bySender[address]; ok {
+		for id := range st.TxIDs {
+			n = max(n, mp.entries[id].Tx.Nonce)
+		}
+	}
+	return n
+}
+
+// removeStaleNonces drops pending transactions whose nonce a block has
+// used up, and returns them.
+func (mp *Mempool) removeStaleNonces(nonces map[string]uint64) []Transaction {
+	var removed []Transaction
+	for addr, n := range nonces {
+		st, ok := mp.bySender[addr]
+		if !ok {
+			continue
+		}
+		for id := range st.TxIDs {
+			if e := mp.entries[id]; e.Tx.Nonce <= n {
+				removed = append(removed, e.Tx)
+				mp.remove(id)
+			}
+		}
+	}
+	return removed
+}
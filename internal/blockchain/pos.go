@@ -1,7 +1,10 @@
 package blockchain
 
 import (
+	"encoding/json"
 	"fmt"
+	"math/big"
+	"sort"
 	"sync"
 )
 
@@ -10,6 +13,7 @@ type Stake struct {
 	Address     string  `json:"address"`
 	Amount      float64 `json:"amount"`
 	BlockHeight uint64  `json:"block_height"`
+	Validator   string  `json:"validator,omitempty"` // delegated to; see delegation.go
 }
 
 // StakeManager tracks all active stakes.
@@ -73,33 +77,95 @@ func (sm *StakeManager) GetStake(address string) float64 {
 	return 0
 }
 
-// CalcPOSRewards distributes PoS reward proportionally among stakers
-// whose stake is at or above minThreshold. Stakers below the threshold
-// are excluded from rewards entirely.
-func (sm *StakeManager) CalcPOSRewards(totalReward float64, minThreshold float64) []TxOutput {
+// stakedAt returns the height address last started or, under the stake
+// lock, topped up its stake, and whether it has a stake.
+func (sm *StakeManager) stakedAt(address string) (uint64, bool) {
 	sm.mu.RLock()
 	defer sm.mu.RUnlock()
+	if s, ok := sm.Stakes[address]; ok {
+		return s.BlockHeight, true
+	}
+	return 0, false
+}
 
-	// Calculate total eligible stake (only stakers >= minThreshold).
-	eligibleStaked := 0.0
-	for _, s := range sm.Stakes {
-		if s.Amount >= minThreshold {
-			eligibleStaked += s.Amount
+// delegatedTo returns the validator address has delegated its stake to,
+// or "" if it stakes on its own account.
+func (sm *StakeManager) delegatedTo(address string) string {
+	sm.mu.RLock()
+	defer sm.mu.RUnlock()
+	if s, ok := sm.Stakes[address]; ok {
+		return s.Validator
+	}
+	return ""
+}
+
+// eligible returns the total and number of validators whose stake,
+// counting what is delegated to them, is at or above minThreshold.
+func (sm *StakeManager) eligible(minThreshold float64) (float64, int) {
+	sm.mu.RLock()
+	defer sm.mu.RUnlock()
+	total := 0.0
+	groups := validatorGroups(sm.Stakes, minThreshold)
+	for _, stake := range groups {
+		total += stake
+	}
+	return total, len(groups)
+}
+
+// CalcPOSRewards distributes PoS reward proportionally among validators
+// whose stake, counting what is delegated to them, is at or above
+// minThreshold, and the stakers delegating to them. Delegators pay
+// commission percent of their share to their validator. Stakers outside
+// an eligible validator's group are excluded from rewards entirely.
+//
+// The split is done in base units: each staker gets the floor of their
+// proportional share, and the leftover units go one each to the stakers
+// with the largest remainders (ties by address). Outputs are sorted by
+// address and always sum to exactly ToUnits(totalReward).
+func (sm *StakeManager) CalcPOSRewards(totalReward, minThreshold, commission float64) []TxOutput {
+	sm.mu.RLock()
+	defer sm.mu.RUnlock()
+
+	type share struct {
+		addr  string
+		stake *big.Int
+		units int64
+		rem   *big.Int
+	}
+	var shares []*share
+	eligible := new(big.Int)
+	for addr, w := range rewardWeights(sm.Stakes, minThreshold, commission) {
+		if w.Sign() <= 0 {
+			continue
 		}
+		shares = append(shares, &share{addr: addr, stake: w})
+		eligible.Add(eligible, w)
 	}
-	if eligibleStaked == 0 {
+	if eligible.Sign() == 0 {
 		return nil
 	}
+	sort.Slice(shares, func(i, j int) bool { return shares[i].addr < shares[j].addr })
+
+	total := ToUnits(totalReward)
+	totalBig := big.NewInt(total)
+	distributed := int64(0)
+	for _, sh := range shares {
+		q, r := new(big.Int).QuoRem(new(big.Int).Mul(totalBig, sh.stake), eligible, new(big.Int))
+		sh.units, sh.rem = q.Int64(), r
+		distributed += sh.units
+	}
+
+	byRem := make([]*share, len(shares))
+	copy(byRem, shares)
+	sort.SliceStable(byRem, func(i, j int) bool { return byRem[i].rem.Cmp(byRem[j].rem) > 0 })
+	for i := int64(0); i < total-distributed; i++ {
+		byRem[i].units++
+	}
 
 	var outputs []TxOutput
-	for addr, s := range sm.Stakes {
-		if s.Amount < minThreshold {
-			continue // below threshold — no rewards
-		}
-		share := s.Amount / eligibleStaked
-		reward := totalReward * share
-		if reward > 0.00000001 {
-			outputs = append(outputs, TxOutput{Address: addr, Amount: reward})
+	for _, sh := range shares {
+		if sh.units > 0 {
+			outputs = append(outputs, TxOutput{Address: sh.addr, Amount: FromUnits(sh.units)})
 		}
 	}
 	return outputs
@@ -116,3 +182,121 @@ func (sm *StakeManager) GetAllStakes() map[string]*Stake {
 	}
 	return cp
 }
+
+// stakeChanges stages the stake changes of a block being connected over
+// the live stakes, which stay untouched until commit applies them once
+// the block is on disk.
+type stakeChanges struct {
+	base    *StakeManager
+	changed map[string]*Stake // address -> new stake; nil once fully unstaked
+}
+
+func newStakeChanges(base *StakeManager) *stakeChanges {
+	return &stakeChanges{base: base, changed: make(map[string]*Stake)}
+}
+
+// get returns the staged stake of address, or nil if it has none.
+func (sc *stakeChanges) get(address string) *Stake {
+	if s, ok := sc.changed[address]; ok {
+		return s
+	}
+	sc.base.mu.RLock()
+	defer sc.base.mu.RUnlock()
+	if s, ok := sc.base.Stakes[address]; ok {
+		cp := *s
+		return &cp
+	}
+	return nil
+}
+
+// GetStake returns the staged stake amount of address.
+func (sc *stakeChanges) GetStake(address string) float64 {
+	if s := sc.get(address); s != nil {
+		return s.Amount
+	}
+	return 0
+}
+
+// add stages AddStake.
+func (sc *stakeChanges) add(address string, amount float64, height uint64) {
+	s := sc.get(address)
+	if s == nil {
+		s = &Stake{Address: address, BlockHeight: height}
+	}
+	s.Amount += amount
+	sc.changed[address] = s
+}
+
+// relock restarts the stake lock of address at height, for a top-up.
+func (sc *stakeChanges) relock(address string, height uint64) {
+	if s := sc.get(address); s != nil {
+		s.BlockHeight = height
+		sc.changed[address] = s
+	}
+}
+
+// delegate stages address delegating its stake to validator, or staking
+// on its own account again if validator is address itself.
+func (sc *stakeChanges) delegate(address, validator string) {
+	if s := sc.get(address); s != nil {
+		s.Validator = validator
+		if validator == address {
+			s.Validator = ""
+		}
+		sc.changed[address] = s
+	}
+}
+
+// remove stages RemoveStake. Blocks are validated first, so a missing or
+// short stake is left as it is, like RemoveStake does.
+func (sc *stakeChanges) remove(address string, amount float64) {
+	s := sc.get(address)
+	if s == nil || s.Amount < amount {
+		return
+	}
+	s.Amount -= amount
+	if s.Amount < 0.00000001 {
+		s = nil
+	}
+	sc.changed[address] = s
+}
+
+// all returns a copy of every stake with the staged changes applied.
+func (sc *stakeChanges) all() map[string]*Stake {
+	stakes := sc.base.GetAllStakes()
+	for addr, s := range sc.changed {
+		if s == nil {
+			delete(stakes, addr)
+		} else {
+			stakes[addr] = s
+		}
+	}
+	return stakes
+}
+
+// records returns the staged stakes as JSON for the block commit, nil for
+// removed ones.
+func (sc *stakeChanges) records() map[string][]byte {
+	records := make(map[string][]byte, len(sc.changed))
+	for addr, s := range sc.changed {
+		if s == nil {
+			records[addr] = nil
+		} else {
+			records[addr], _ = json.Marshal(s)
+		}
+	}
+	return records
+}
+
+// commit applies the staged changes to the live stakes.
+func (sc *stakeChanges) commit() {
+	sc.base.mu.Lock()
+	defer sc.base.mu.Unlock()
+	for addr, s := range sc.changed {
+		if s == nil {
+			delete(sc.base.Stakes, addr)
+		} else {
+			sc.base.Stakes[addr] = s
+		}
+	}
+}
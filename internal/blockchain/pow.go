@@ -1,8 +1,10 @@
 package blockchain
 
 import (
+	"devinsidercoin/internal/config"
 	"encoding/hex"
 	"math/big"
+	"sort"
 )
 
 // BitsToTarget converts compact "bits" representation to a 256-bit target.
@@ -53,10 +55,47 @@ func CheckProofOfWork(hashHex string, bits uint32) bool {
 	return hashInt.Cmp(target) <= 0
 }
 
+// Timestamp rules, enforced from timestamp_rules_height except for
+// MaxFutureBlockTime, which every node applies as policy.
+const (
+	// MedianTimeSpan is the number of blocks whose median timestamp a new
+	// block must exceed.
+	MedianTimeSpan = 11
+	// MaxFutureBlockTime is how far ahead of the local clock a block
+	// timestamp may be.
+	MaxFutureBlockTime = 2 * 60 * 60
+	// maxSolveTimeFactor caps a single block's solve time at this many
+	// target intervals when retargeting.
+	maxSolveTimeFactor = 6
+)
+
+// MedianTimePast returns the median timestamp of the last MedianTimeSpan
+// blocks (fewer near genesis), or 0 for no blocks.
+func MedianTimePast(blocks []*Block) int64 {
+	if len(blocks) > MedianTimeSpan {
+		blocks = blocks[len(blocks)-MedianTimeSpan:]
+	}
+	if len(blocks) == 0 {
+		return 0
+	}
+	times := make([]int64, len(blocks))
+	for i, b := range blocks {
+		times[i] = b.Header.Timestamp
+	}
+	sort.Slice(times, func(i, j int) bool { return times[i] < times[j] })
+	return times[len(times)/2]
+}
+
 // CalcNextBits performs standard difficulty retargeting with bounds, used every
 // DifficultyAdjustInterval blocks.  The result is then clamped by the
 // progressive difficulty floor for the current height.
-func CalcNextBits(blocks []*Block, adjustInterval uint64, targetSeconds int, minBits uint32) uint32 {
+//
+// With clipSolveTimes the timespan is the sum of per-block solve times over
+// the last adjustInterval+1 blocks instead of last minus first timestamp.
+// A timestamp not after its predecessor's counts as one second later, and
+// no solve time counts for more than maxSolveTimeFactor targets, so
+// alternating or extreme timestamps cannot drag difficulty down.
+func CalcNextBits(blocks []*Block, adjustInterval uint64, targetSeconds int, minBits uint32, clipSolveTimes bool) uint32 {
 	n := len(blocks)
 	if n == 0 {
 		return minBits
@@ -72,6 +111,14 @@ func CalcNextBits(blocks []*Block, adjustInterval uint64, targetSeconds int, min
 
 	actualTime := last.Header.Timestamp - first.Header.Timestamp
 	expectedTime := int64(adjustInterval) * int64(targetSeconds)
+	if clipSolveTimes {
+		window := blocks[max(0, n-int(adjustInterval)-1):]
+		if len(window) < 2 {
+			return last.Header.Bits
+		}
+		actualTime = clippedTimespan(window, int64(targetSeconds))
+		expectedTime = int64(len(window)-1) * int64(targetSeconds)
+	}
 
 	// Clamp adjustment to 4x range (no more than 4x easier or harder).
 	if actualTime < expectedTime/4 {
@@ -94,31 +141,100 @@ func CalcNextBits(blocks []*Block, adjustInterval uint64, targetSeconds int, min
 	return TargetToBits(newTarget)
 }
 
+// clippedTimespan sums the solve times of blocks[1:], treating an
+// out-of-order timestamp as one second after the latest seen and capping
+// each solve time.
+func clippedTimespan(blocks []*Block, targetSeconds int64) int64 {
+	var span int64
+	prev := blocks[0].Header.Timestamp
+	for _, b := range blocks[1:] {
+		ts := b.Header.Timestamp
+		if ts <= prev {
+			ts = prev + 1
+		}
+		span += min(ts-prev, maxSolveTimeFactor*targetSeconds)
+		prev = ts
+	}
+	return span
+}
+
+// Progressive floor curves accepted in difficulty_floor_curve.
+const (
+	FloorGeometric = "geometric"
+	FloorLinear    = "linear"
+	FloorNone      = "none"
+)
+
+// maxFloorEpoch is the last epoch in which the floor tightens.
+const maxFloorEpoch = 60
+
+// FloorCurve describes how the progressive difficulty floor tightens.
+type FloorCurve struct {
+	Kind        string  // geometric (default), linear or none
+	Ratio       float64 // geometric: target divisor per epoch (default 2); linear: difficulty added per epoch (default 1)
+	EpochBlocks uint64
+	MinBits     uint32
+}
+
+// FloorCurveFor returns the floor curve configured for a network.
+func FloorCurveFor(cfg *config.NetworkConfig) FloorCurve {
+	return FloorCurve{
+		Kind:        cfg.DifficultyFloorCurve,
+		Ratio:       cfg.DifficultyFloorRatio,
+		EpochBlocks: cfg.DifficultyEpochBlocks,
+		MinBits:     cfg.MinDifficultyBits,
+	}
+}
+
+// Tightens reports whether the floor changes between epochs at all.
+func (c FloorCurve) Tightens() bool {
+	return c.Kind != FloorNone && c.EpochBlocks > 0
+}
+
 // ProgressiveDifficultyFloor returns the minimum bits (maximum target) allowed
-// at a given height.  Every DifficultyEpochBlocks blocks the floor tightens by
-// halving the max target, making mining progressively harder over time.
+// at a given height.  Every EpochBlocks blocks the floor tightens:
+//
+// geometric: floor target = minTarget / ratio^epoch (ratio 2 halves it)
+// linear:    floor target = minTarget / (1 + ratio*epoch)
+// none:      floor target = minTarget
 //
-// Epoch 0 (blocks 0 – epoch-1):       floor = minBits (easiest)
-// Epoch 1 (blocks epoch – 2*epoch-1): floor target = minTarget / 2
-// Epoch 2:                             floor target = minTarget / 4
-// ...
+// Epoch 0 (blocks 0 – epoch-1) is always minBits (easiest), and the floor
+// stops tightening after maxFloorEpoch. The arithmetic is exact on the
+// ratio's binary fraction, so every node computes the same bits.
 //
 // This guarantees that even if hash rate drops, the network never becomes
 // trivially easy to mine at high block heights.
-func ProgressiveDifficultyFloor(height uint64, epochBlocks uint64, minBits uint32) uint32 {
-	if epochBlocks == 0 {
-		return minBits
+func ProgressiveDifficultyFloor(height uint64, curve FloorCurve) uint32 {
+	if !curve.Tightens() {
+		return curve.MinBits
 	}
-	epoch := height / epochBlocks
+	epoch := min(height/curve.EpochBlocks, maxFloorEpoch)
 	if epoch == 0 {
-		return minBits
+		return curve.MinBits
 	}
-	// Cap epoch shifts to prevent target from becoming zero.
-	if epoch > 60 {
-		epoch = 60
+	ratio := curve.Ratio
+	if ratio == 0 {
+		ratio = 2
+		if curve.Kind == FloorLinear {
+			ratio = 1
+		}
 	}
-	maxTarget := BitsToTarget(minBits)
-	floorTarget := new(big.Int).Rsh(maxTarget, uint(epoch)) // divide by 2^epoch
+	r := new(big.Rat).SetFloat64(ratio)
+	num, den := r.Num(), r.Denom()
+	floorTarget := BitsToTarget(curve.MinBits)
+	divisor := new(big.Int)
+	if curve.Kind == FloorLinear {
+		// minTarget * den / (den + num*epoch)
+		floorTarget.Mul(floorTarget, den)
+		divisor.Mul(num, new(big.Int).SetUint64(epoch))
+		divisor.Add(divisor, den)
+	} else {
+		// minTarget * den^epoch / num^epoch
+		e := new(big.Int).SetUint64(epoch)
+		floorTarget.Mul(floorTarget, new(big.Int).Exp(den, e, nil))
+		divisor.Exp(num, e, nil)
+	}
+	floorTarget.Div(floorTarget, divisor)
 	if floorTarget.Sign() == 0 {
 		floorTarget.SetInt64(1)
 	}
@@ -128,8 +244,8 @@ func ProgressiveDifficultyFloor(height uint64, epochBlocks uint64, minBits uint3
 // ApplyProgressiveDifficulty clamps bits so they never exceed the progressive
 // floor for the given height.  "Exceed" means the target is too large (mining
 // too easy).  Smaller bits = harder mining.
-func ApplyProgressiveDifficulty(bits uint32, height uint64, epochBlocks uint64, minBits uint32) uint32 {
-	floorBits := ProgressiveDifficultyFloor(height, epochBlocks, minBits)
+func ApplyProgressiveDifficulty(bits uint32, height uint64, curve FloorCurve) uint32 {
+	floorBits := ProgressiveDifficultyFloor(height, curve)
 	// A higher target value = easier mining.  We want target <= floor target.
 	target := BitsToTarget(bits)
 	floorTarget := BitsToTarget(floorBits)
@@ -138,3 +254,18 @@ func ApplyProgressiveDifficulty(bits uint32, height uint64, epochBlocks uint64,
 	}
 	return bits
 }
+
+// SolveBlock searches nonces until the block header satisfies its own
+// difficulty target, setting block.Hash on success. It is intended for
+// regtest and other trivial-difficulty networks.
+func SolveBlock(block *Block, maxTries uint64) bool {
+	for i := uint64(0); i < maxTries; i++ {
+		block.Header.Nonce = i
+		hash := block.Header.ComputeHash()
+		if CheckProofOfWork(hash, block.Header.Bits) {
+			block.Hash = hash
+			return true
+		}
+	}
+	return false
+}
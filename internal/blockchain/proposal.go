@@ -0,0 +1,63 @@
+package blockchain
+
+import "math"
+
+// ProposedTx is a pending transaction the next block template includes.
+type ProposedTx struct {
+	TxID    string  `json:"txid"`
+	Type    string  `json:"type"`
+	Fee     float64 `json:"fee"`
+	Size    int     `json:"size"`     // canonical bytes
+	FeeRate float64 `json:"fee_rate"` // fee per 1000 bytes
+}
+
+// BlockProposal previews the block a template would hold right now, for
+// fee estimators and pools. Transactions are in block order.
+type BlockProposal struct {
+	Height        uint64       `json:"height"`
+	Transactions  []ProposedTx `json:"transactions"`
+	Fees          float64      `json:"fees"`
+	CollectedFees float64      `json:"collected_fees"` // what the producer receives under the fee policy
+	Subsidy       float64      `json:"subsidy"`
+	MinFeeRate    float64      `json:"min_fee_rate"` // lowest fee rate included; 0 if none
+	Pending       int          `json:"pending"`      // mempool transactions
+	Excluded      int          `json:"excluded"`     // pending transactions left for later blocks
+}
+
+// ProposeBlock runs block assembly against the mempool without building a
+// block. The reward transactions are sized for a producer address of the
+// network's usual length.
+func (bc *Blockchain) ProposeBlock() *BlockProposal {
+	bc.mu.RLock()
+	defer bc.mu.RUnlock()
+	height := bc.Store.GetBlockCount()
+	subsidy := bc.CalcBlockReward(height)
+	pending := bc.selectTemplate(height, BurnAddress(bc.Config.AddressPrefix), subsidy)
+	p := &BlockProposal{
+		Height:        height,
+		Transactions:  make([]ProposedTx, 0, len(pending)),
+		Fees:          blockFees(pending),
+		CollectedFees: bc.collectedFees(pending),
+		Subsidy:       subsidy,
+		Pending:       bc.Mempool.Len(),
+		Excluded:      bc.Mempool.Len() - len(pending),
+	}
+	minRate := math.Inf(1)
+	for i := range pending {
+		tx := &pending[i]
+		size := tx.Size()
+		rate := tx.Fee * 1000 / float64(size)
+		p.Transactions = append(p.Transactions, ProposedTx{
+			TxID:    tx.TxID,
+			Type:    tx.Type,
+			Fee:     tx.Fee,
+			Size:    size,
+			FeeRate: rate,
+		})
+		minRate = math.Min(minRate, rate)
+	}
+	if len(pending) > 0 {
+		p.MinFeeRate = minRate
+	}
+	return p
+}
@@ -0,0 +1,96 @@
+package blockchain
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// MaxReceivedMinConf bounds the minconf argument of GetReceivedByAddress,
+// since deeper confirmation counts are answered by rescanning blocks.
+const MaxReceivedMinConf = 10000
+
+// receivedCredits returns the payments tx makes to its recipients. Unstakes
+// return the sender's own funds and don't count as received.
+func receivedCredits(tx *Transaction) []TxOutput {
+	switch tx.Type {
+	case "transfer":
+		return []TxOutput{{Address: tx.To, Amount: tx.Amount}}
+	case "coinbase", "pos_reward":
+		return tx.Outputs
+	}
+	return nil
+}
+
+// stageReceived adds block's credits to the running totals in changed,
+// reading committed totals for addresses not yet staged.
+func (bc *Blockchain) stageReceived(block *Block, changed map[string]float64) {
+	for i := range block.Transactions {
+		for _, out := range receivedCredits(&block.Transactions[i]) {
+			if _, ok := changed[out.Address]; !ok {
+				changed[out.Address] = bc.Store.GetReceived(out.Address)
+			}
+			changed[out.Address] += out.Amount
+		}
+	}
+}
+
+// rebuildReceived recomputes the received totals from every stored block,
+// for databases created before the index existed.
+func (bc *Blockchain) rebuildReceived() error {
+	raw, err := bc.Store.GetBlocksFrom(0, 0)
+	if err != nil {
+		return err
+	}
+	totals := make(map[string]float64)
+	for _, data := range raw {
+		var b Block
+		if err := json.Unmarshal(data, &b); err != nil {
+			return fmt.Errorf("decode block: %w", err)
+		}
+		for i := range b.Transactions {
+			for _, out := range receivedCredits(&b.Transactions[i]) {
+				totals[out.Address] += out.Amount
+			}
+		}
+	}
+	logger.Info("built received-amount index", "blocks", len(raw), "addresses", len(totals))
+	return bc.Store.RebuildReceived(totals)
+}
+
+// GetReceivedByAddress returns the total ever paid to address by
+// transactions with at least minconf confirmations. minconf 0 includes
+// pending transactions.
+func (bc *Blockchain) GetReceivedByAddress(address string, minconf int) (float64, error) {
+	if minconf < 0 || minconf > MaxReceivedMinConf {
+		return 0, fmt.Errorf("minconf must be between 0 and %d", MaxReceivedMinConf)
+	}
+	bc.mu.RLock()
+	defer bc.mu.RUnlock()
+
+	total := bc.Store.GetReceived(address)
+	if minconf == 0 {
+		for _, tx := range bc.Mempool.Transactions() {
+			for _, out := range receivedCredits(&tx) {
+				if out.Address == address {
+					total += out.Amount
+				}
+			}
+		}
+	}
+	// Blocks above tip-minconf+1 don't have enough confirmations yet.
+	tip := bc.Store.GetBestHeight()
+	for h := tip; h >= 0 && h > tip-int64(minconf)+1; h-- {
+		block := bc.loadBlock(uint64(h))
+		if block == nil {
+			continue
+		}
+		for i := range block.Transactions {
+			for _, out := range receivedCredits(&block.Transactions[i]) {
+				if out.Address == address {
+					total -= out.Amount
+				}
+			}
+		}
+	}
+	return total, nil
+}
@@ -0,0 +1,85 @@
+package blockchain
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Indexes that Reindex rebuilds.
+const (
+	IndexReceived = "received"
+	IndexCharts   = "charts"
+	IndexRewards  = "rewards"
+	IndexArchive  = "archive"
+)
+
+// ErrUnknownIndex is returned by Reindex for an index it doesn't know.
+var ErrUnknownIndex = errors.New("unknown index")
+
+// Reindex rebuilds an optional index from the stored blocks. The received,
+// chart and reward indexes are rebuilt before it returns, holding back new blocks
+// meanwhile; the archive indexes are dropped and rebuilt in the background
+// (see IndexInfo).
+func (bc *Blockchain) Reindex(index string) error {
+	switch index {
+	case IndexReceived:
+		bc.mu.Lock()
+		defer bc.mu.Unlock()
+		return bc.rebuildReceived()
+	case IndexCharts:
+		bc.mu.Lock()
+		defer bc.mu.Unlock()
+		return bc.rebuildCharts()
+	case IndexRewards:
+		bc.mu.Lock()
+		defer bc.mu.Unlock()
+		return bc.rebuildRewards()
+	case IndexArchive:
+		return bc.reindexArchive()
+	}
+	return fmt.Errorf("%w %q (%s, %s, %s or %s)", ErrUnknownIndex, index, IndexReceived, IndexCharts, IndexRewards, IndexArchive)
+}
+
+// reindexArchive stops the archive indexer, empties the indexes and starts
+// a new indexer from genesis.
+func (bc *Blockchain) reindexArchive() error {
+	bc.reindexMu.Lock()
+	defer bc.reindexMu.Unlock()
+	bc.mu.RLock()
+	old := bc.archive
+	bc.mu.RUnlock()
+	if old == nil {
+		return ErrNotArchive
+	}
+	old.close()
+
+	bc.mu.Lock()
+	defer bc.mu.Unlock()
+	if err := bc.Store.ResetArchive(); err != nil {
+		return err
+	}
+	bc.archive = newArchiveIndexer()
+	go bc.runArchiveIndexer(bc.archive)
+	logger.Info("archive reindex started")
+	return nil
+}
+
+// Backup writes a copy of the chain database to the backups directory
+// under the data directory and returns its path.
+func (bc *Blockchain) Backup() (string, error) {
+	dir := filepath.Join(bc.DataDir, "backups")
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", err
+	}
+	name := fmt.Sprintf("blockchain-%d-%s.db", bc.Store.GetBestHeight(), time.Now().UTC().Format("20060102T150405Z"))
+	path := filepath.Join(dir, name)
+	if err := bc.Store.BackupTo(path); err != nil {
+		os.Remove(path)
+		return "", err
+	}
+	logger.Info("database backed up", "path", path)
+	return path, nil
+}
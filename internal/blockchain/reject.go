@@ -0,0 +1,44 @@
+package blockchain
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Reject codes classify why a block or transaction was refused, so peers
+// can tell data that will never be accepted from data that may be later.
+const (
+	RejectMalformed   = "malformed"    // could not be decoded
+	RejectInvalid     = "invalid"      // breaks consensus rules
+	RejectDuplicate   = "duplicate"    // already known
+	RejectDoubleSpend = "double_spend" // conflicts with a pending transaction
+	RejectPolicy      = "policy"       // valid, but outside this node's relay policy
+	RejectUnconnected = "unconnected"  // block doesn't extend the tip
+)
+
+// RejectError is an error carrying a reject code.
+type RejectError struct {
+	Code string
+	Err  error
+}
+
+func (e *RejectError) Error() string { return e.Err.Error() }
+func (e *RejectError) Unwrap() error { return e.Err }
+
+func rejectf(code, format string, args ...interface{}) error {
+	return &RejectError{Code: code, Err: fmt.Errorf(format, args...)}
+}
+
+// RejectCode returns the reject code of an error from AddBlock or
+// AddToMempool. Unclassified errors are RejectInvalid.
+func RejectCode(err error) string {
+	var re *RejectError
+	var ds *DoubleSpendError
+	switch {
+	case errors.As(err, &re):
+		return re.Code
+	case errors.As(err, &ds):
+		return RejectDoubleSpend
+	}
+	return RejectInvalid
+}
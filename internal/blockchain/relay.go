@@ -0,0 +1,69 @@
+package blockchain
+
+import (
+	"devinsidercoin/internal/config"
+	"fmt"
+)
+
+// RelayPolicy returns the policy currently applied to mempool admission.
+func (bc *Blockchain) RelayPolicy() config.RelayPolicy {
+	bc.mu.RLock()
+	defer bc.mu.RUnlock()
+	return bc.relay
+}
+
+// SetRelayPolicy replaces the node's relay policy. Transactions already in
+// the mempool are kept.
+func (bc *Blockchain) SetRelayPolicy(p config.RelayPolicy) {
+	bc.mu.Lock()
+	bc.relay = p
+	bc.mu.Unlock()
+}
+
+// checkRelayPolicy applies the node's relay policy: address format, pool
+// size, fee, dust, size, data-carrier and per-sender limits. This is
+// policy, not consensus: validateBlock never calls it, so blocks containing
+// such transactions stay valid. The governed relay fee and pool size are
+// floors the node's policy can only tighten.
+func (bc *Blockchain) checkRelayPolicy(tx Transaction) error {
+	if err := bc.checkAddresses(&tx); err != nil {
+		return err
+	}
+	p := bc.relay
+	height := bc.Store.GetBlockCount()
+	limit := int(bc.govParam(ParamMaxMempoolTxs, height))
+	if p.MaxMempoolTxs > 0 && p.MaxMempoolTxs < limit {
+		limit = p.MaxMempoolTxs
+	}
+	if bc.Mempool.Len() >= limit {
+		return fmt.Errorf("mempool full (%d transactions)", limit)
+	}
+	minFee := bc.govParam(ParamMinRelayFee, height)
+	if p.MinRelayFee > minFee {
+		minFee = p.MinRelayFee
+	}
+	if tx.Fee < minFee {
+		return fmt.Errorf("fee %.8f below minimum relay fee %.8f", tx.Fee, minFee)
+	}
+	switch tx.Type {
+	case "transfer", "stake", "unstake", "burn", "delegate":
+		if tx.Amount < p.MinOutputAmount {
+			return fmt.Errorf("amount %.8f below minimum %.8f", tx.Amount, p.MinOutputAmount)
+		}
+	}
+	if tx.Type == "transfer" && tx.To == BurnAddress(bc.Config.AddressPrefix) {
+		return fmt.Errorf("use a burn transaction to destroy coins")
+	}
+	if len(tx.Data) > p.MaxDataCarrierSize {
+		return fmt.Errorf("data payload too large: %d bytes (limit %d)", len(tx.Data), p.MaxDataCarrierSize)
+	}
+	size := tx.Size()
+	if size > p.MaxTxSize || blockOverhead(1)+txSlot(size) > int(bc.Config.MaxBlockSize) {
+		return fmt.Errorf("transaction too large: %d bytes", size)
+	}
+	if tx.From != "" && bc.Mempool.PendingCount(tx.From) >= p.MaxPendingPerSender {
+		return fmt.Errorf("sender %s has too many pending transactions (limit %d)",
+			tx.From, p.MaxPendingPerSender)
+	}
+	return nil
+}
@@ -0,0 +1,104 @@
+package blockchain
+
+import "devinsidercoin/internal/storage"
+
+// RewardEntry is one staking reward paid to an address.
+type RewardEntry struct {
+	Height    uint64  `json:"height"`
+	TxID      string  `json:"txid"`
+	Amount    float64 `json:"amount"`
+	Timestamp int64   `json:"timestamp"`
+}
+
+// RewardPage is a page of an address's staking rewards. Total sums the
+// page; Next, when set, is the from height of the following page.
+type RewardPage struct {
+	Address string        `json:"address"`
+	Rewards []RewardEntry `json:"rewards"`
+	Total   float64       `json:"total"`
+	Next    uint64        `json:"next,omitempty"`
+}
+
+// blockRewards returns the pos_reward payments in block by address.
+func blockRewards(block *Block) map[string]storage.Reward {
+	var rewards map[string]storage.Reward
+	for _, tx := range block.Transactions {
+		if tx.Type != "pos_reward" {
+			continue
+		}
+		if rewards == nil {
+			rewards = make(map[string]storage.Reward)
+		}
+		for _, out := range tx.Outputs {
+			r := rewards[out.Address]
+			r.TxID, r.Timestamp = tx.TxID, block.Header.Timestamp
+			r.Amount = FromUnits(ToUnits(r.Amount) + ToUnits(out.Amount))
+			rewards[out.Address] = r
+		}
+	}
+	return rewards
+}
+
+// blockProducer returns the coinbase of block keyed by the producer it
+// names, or nil for the genesis block.
+func blockProducer(block *Block) map[string]storage.Reward {
+	if block.Header.Height == 0 || len(block.Transactions) == 0 {
+		return nil
+	}
+	cb := block.Transactions[0]
+	if cb.Type != "coinbase" || cb.To == "" {
+		return nil
+	}
+	return map[string]storage.Reward{cb.To: {TxID: cb.TxID, Amount: cb.Amount, Timestamp: block.Header.Timestamp}}
+}
+
+// rebuildRewards indexes the staking rewards and producers of every stored
+// block, for databases created before the indexes existed.
+func (bc *Blockchain) rebuildRewards() error {
+	rewards := make(map[uint64]map[string]storage.Reward)
+	produced := make(map[uint64]map[string]storage.Reward)
+	var count uint64
+	for {
+		blocks, err := bc.decodeBlocksFrom(count, indexBatch)
+		if err != nil {
+			return err
+		}
+		if len(blocks) == 0 {
+			break
+		}
+		for i := range blocks {
+			if r := blockRewards(&blocks[i]); r != nil {
+				rewards[blocks[i].Header.Height] = r
+			}
+			if p := blockProducer(&blocks[i]); p != nil {
+				produced[blocks[i].Header.Height] = p
+			}
+		}
+		count += uint64(len(blocks))
+	}
+	logger.Info("built reward indexes", "blocks", count, "staking", len(rewards), "produced", len(produced))
+	return bc.Store.RebuildRewards(rewards, produced)
+}
+
+// GetRewards returns up to limit staking rewards paid to address from
+// fromHeight on, oldest first. Non-zero since and until keep only rewards
+// from blocks timestamped in [since, until].
+func (bc *Blockchain) GetRewards(address string, fromHeight uint64, limit int, since, until int64) RewardPage {
+	bc.mu.RLock()
+	defer bc.mu.RUnlock()
+	limit = historyLimit(limit)
+	keep := func(r storage.Reward) bool {
+		return (since == 0 || r.Timestamp >= since) && (until == 0 || r.Timestamp <= until)
+	}
+	page := RewardPage{Address: address, Rewards: []RewardEntry{}}
+	var units int64
+	for _, r := range bc.Store.Rewards(address, fromHeight, limit, keep) {
+		page.Rewards = append(page.Rewards, RewardEntry{Height: r.Height, TxID: r.TxID, Amount: r.Amount, Timestamp: r.Timestamp})
+		units += ToUnits(r.Amount)
+	}
+	page.Total = FromUnits(units)
+	if n := len(page.Rewards); n == limit {
+		page.Next = page.Rewards[n-1].Height + 1
+	}
+	return page
+}
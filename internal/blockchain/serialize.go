@@ -0,0 +1,354 @@
+package blockchain
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+)
+
+// Transaction serialization versions.
+//
+// Version 0 (legacy) transactions derive their TxID from a JSON subset and
+// their merkle leaf from the full JSON encoding. They remain valid so that
+// existing chains keep verifying. Version 1 transactions use the canonical
+// binary encoding below for TxID, merkle leaf and signature payload.
+const (
+	TxVersionLegacy    uint32 = 0
+	TxVersionCanonical uint32 = 1
+)
+
+// Extension tags for optional trailing fields. A field is written only when
+// it is non-empty, so adding a tag never changes the encoding of
+// transactions that don't use it. Tags must be written in ascending order.
+const (
+	tagSignature = 1
+	tagData      = 2
+	tagInputs    = 3
+	tagNonce     = 4
+)
+
+// Canonical layout (little endian, strings and lists uvarint-length
+// prefixed):
+//
+//	version   uint32
+//	type      string
+//	from      string
+//	to        string
+//	amount    float64 (IEEE-754 bits)
+//	fee       float64
+//	timestamp int64
+//	outputs   uvarint count, then {address string, amount float64}
+//	extensions: {tag uvarint, value bytes} in ascending tag order; the
+//	           inputs value is a uvarint count, then {txid string, vout uint32};
+//	           the nonce value is a uvarint
+func (tx *Transaction) serialize(withSignature bool) []byte {
+	var buf bytes.Buffer
+	writeU32(&buf, tx.Version)
+	writeString(&buf, tx.Type)
+	writeString(&buf, tx.From)
+	writeString(&buf, tx.To)
+	writeF64(&buf, tx.Amount)
+	writeF64(&buf, tx.Fee)
+	writeU64(&buf, uint64(tx.Timestamp))
+	writeUvarint(&buf, uint64(len(tx.Outputs)))
+	for _, out := range tx.Outputs {
+		writeString(&buf, out.Address)
+		writeF64(&buf, out.Amount)
+	}
+	if withSignature && tx.Signature != "" {
+		writeUvarint(&buf, tagSignature)
+		writeString(&buf, tx.Signature)
+	}
+	if tx.Data != "" {
+		writeUvarint(&buf, tagData)
+		writeString(&buf, tx.Data)
+	}
+	if len(tx.Inputs) > 0 {
+		writeUvarint(&buf, tagInputs)
+		writeUvarint(&buf, uint64(len(tx.Inputs)))
+		for _, in := range tx.Inputs {
+			writeString(&buf, in.TxID)
+			writeU32(&buf, in.Vout)
+		}
+	}
+	if tx.Nonce != 0 {
+		writeUvarint(&buf, tagNonce)
+		writeUvarint(&buf, tx.Nonce)
+	}
+	return buf.Bytes()
+}
+
+// Serialize returns the full canonical encoding, including the signature.
+func (tx *Transaction) Serialize() []byte {
+	return tx.serialize(true)
+}
+
+// SigningBytes returns the canonical encoding without the signature. It is
+// the payload signed by wallets and the preimage of a version 1 TxID.
+func (tx *Transaction) SigningBytes() []byte {
+	return tx.serialize(false)
+}
+
+// DeserializeTransaction decodes a canonical transaction and recomputes
+// its TxID.
+func DeserializeTransaction(data []byte) (*Transaction, error) {
+	r := bytes.NewReader(data)
+	tx := &Transaction{}
+	var err error
+	read := func(fn func() error) {
+		if err == nil {
+			err = fn()
+		}
+	}
+	read(func() (e error) { tx.Version, e = readU32(r); return })
+	read(func() (e error) { tx.Type, e = readString(r); return })
+	read(func() (e error) { tx.From, e = readString(r); return })
+	read(func() (e error) { tx.To, e = readString(r); return })
+	read(func() (e error) { tx.Amount, e = readF64(r); return })
+	read(func() (e error) { tx.Fee, e = readF64(r); return })
+	read(func() error {
+		ts, e := readU64(r)
+		tx.Timestamp = int64(ts)
+		return e
+	})
+	var nOut uint64
+	read(func() (e error) { nOut, e = binary.ReadUvarint(r); return })
+	if err == nil && nOut > uint64(r.Len()) {
+		err = fmt.Errorf("output count %d exceeds payload", nOut)
+	}
+	for i := uint64(0); err == nil && i < nOut; i++ {
+		var out TxOutput
+		read(func() (e error) { out.Address, e = readString(r); return })
+		read(func() (e error) { out.Amount, e = readF64(r); return })
+		tx.Outputs = append(tx.Outputs, out)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("decode transaction: %w", err)
+	}
+
+	lastTag := uint64(0)
+	for r.Len() > 0 {
+		tag, err := binary.ReadUvarint(r)
+		if err != nil {
+			return nil, fmt.Errorf("decode transaction: %w", err)
+		}
+		if tag <= lastTag {
+			return nil, fmt.Errorf("decode transaction: extension tag %d out of order", tag)
+		}
+		lastTag = tag
+		switch tag {
+		case tagSignature:
+			if tx.Signature, err = readString(r); err != nil {
+				return nil, fmt.Errorf("decode transaction: %w", err)
+			}
+		case tagData:
+			if tx.Data, err = readString(r); err != nil {
+				return nil, fmt.Errorf("decode transaction: %w", err)
+			}
+		case tagInputs:
+			if tx.Inputs, err = readInputs(r); err != nil {
+				return nil, fmt.Errorf("decode transaction: %w", err)
+			}
+		case tagNonce:
+			if tx.Nonce, err = binary.ReadUvarint(r); err != nil {
+				return nil, fmt.Errorf("decode transaction: %w", err)
+			}
+		default:
+			return nil, fmt.Errorf("decode transaction: unknown extension tag %d", tag)
+		}
+	}
+
+	tx.TxID = tx.ComputeTxID()
+	return tx, nil
+}
+
+// Size returns the length of the transaction's canonical encoding. It is
+// the unit for fee rates and block size limits.
+func (tx *Transaction) Size() int {
+	return len(tx.Serialize())
+}
+
+// blockHeaderSize is the length of BlockHeader.Serialize output.
+const blockHeaderSize = 88
+
+// blockOverhead is the serialized size of a block without transactions:
+// the hashed header, the 8-byte height and a transaction count.
+func blockOverhead(txCount int) int {
+	return blockHeaderSize + 8 + uvarintLen(uint64(txCount))
+}
+
+// txSlot is the space a transaction occupies in a block: its length prefix
+// plus its encoding.
+func txSlot(size int) int {
+	return uvarintLen(uint64(size)) + size
+}
+
+// Size returns the serialized block size used for max_block_size: header,
+// height, transaction count and each length-prefixed canonical transaction.
+// Template building, validation and relay all use this definition.
+func (b *Block) Size() int {
+	size := blockOverhead(len(b.Transactions))
+	for i := range b.Transactions {
+		size += txSlot(b.Transactions[i].Size())
+	}
+	return size
+}
+
+// Serialize returns the canonical block encoding: the header as hashed,
+// the height as uint64, a uvarint transaction count, then each transaction
+// as a uvarint length and its full encoding. Its length is Size().
+func (b *Block) Serialize() []byte {
+	var buf bytes.Buffer
+	buf.Write(b.Header.Serialize())
+	writeU64(&buf, b.Header.Height)
+	writeUvarint(&buf, uint64(len(b.Transactions)))
+	for i := range b.Transactions {
+		raw := b.Transactions[i].Serialize()
+		writeUvarint(&buf, uint64(len(raw)))
+		buf.Write(raw)
+	}
+	return buf.Bytes()
+}
+
+// DeserializeBlock decodes a canonical block and recomputes its hash and
+// transaction IDs.
+func DeserializeBlock(data []byte) (*Block, error) {
+	if len(data) < blockHeaderSize+8 {
+		return nil, fmt.Errorf("decode block: %d bytes is shorter than a header", len(data))
+	}
+	h := BlockHeader{
+		Version:    binary.LittleEndian.Uint32(data[0:4]),
+		PrevHash:   hex.EncodeToString(data[4:36]),
+		MerkleRoot: hex.EncodeToString(data[36:68]),
+		Timestamp:  int64(binary.LittleEndian.Uint64(data[68:76])),
+		Bits:       binary.LittleEndian.Uint32(data[76:80]),
+		Nonce:      binary.LittleEndian.Uint64(data[80:88]),
+		Height:     binary.LittleEndian.Uint64(data[88:96]),
+	}
+	r := bytes.NewReader(data[blockHeaderSize+8:])
+	count, err := binary.ReadUvarint(r)
+	if err != nil {
+		return nil, fmt.Errorf("decode block: %w", err)
+	}
+	if count > uint64(r.Len()) {
+		return nil, fmt.Errorf("decode block: transaction count %d exceeds payload", count)
+	}
+	b := &Block{Header: h, Transactions: make([]Transaction, 0, count)}
+	for i := uint64(0); i < count; i++ {
+		n, err := binary.ReadUvarint(r)
+		if err != nil {
+			return nil, fmt.Errorf("decode block: transaction %d: %w", i, err)
+		}
+		if n > uint64(r.Len()) {
+			return nil, fmt.Errorf("decode block: transaction %d length %d exceeds payload", i, n)
+		}
+		raw := make([]byte, n)
+		io.ReadFull(r, raw)
+		tx, err := DeserializeTransaction(raw)
+		if err != nil {
+			return nil, fmt.Errorf("decode block: transaction %d: %w", i, err)
+		}
+		b.Transactions = append(b.Transactions, *tx)
+	}
+	if r.Len() > 0 {
+		return nil, fmt.Errorf("decode block: %d trailing bytes", r.Len())
+	}
+	b.Hash = h.ComputeHash()
+	return b, nil
+}
+
+// --- primitives ---
+
+func uvarintLen(v uint64) int {
+	var b [binary.MaxVarintLen64]byte
+	return binary.PutUvarint(b[:], v)
+}
+
+func writeU32(buf *bytes.Buffer, v uint32) {
+	var b [4]byte
+	binary.LittleEndian.PutUint32(b[:], v)
+	buf.Write(b[:])
+}
+
+func writeU64(buf *bytes.Buffer, v uint64) {
+	var b [8]byte
+	binary.LittleEndian.PutUint64(b[:], v)
+	buf.Write(b[:])
+}
+
+func writeF64(buf *bytes.Buffer, v float64) {
+	writeU64(buf, math.Float64bits(v))
+}
+
+func writeUvarint(buf *bytes.Buffer, v uint64) {
+	var b [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(b[:], v)
+	buf.Write(b[:n])
+}
+
+func writeString(buf *bytes.Buffer, s string) {
+	writeUvarint(buf, uint64(len(s)))
+	buf.WriteString(s)
+}
+
+var errShortString = errors.New("string length exceeds payload")
+
+func readU32(r *bytes.Reader) (uint32, error) {
+	var b [4]byte
+	if _, err := io.ReadFull(r, b[:]); err != nil {
+		return 0, err
+	}
+	return binary.LittleEndian.Uint32(b[:]), nil
+}
+
+func readU64(r *bytes.Reader) (uint64, error) {
+	var b [8]byte
+	if _, err := io.ReadFull(r, b[:]); err != nil {
+		return 0, err
+	}
+	return binary.LittleEndian.Uint64(b[:]), nil
+}
+
+func readF64(r *bytes.Reader) (float64, error) {
+	v, err := readU64(r)
+	return math.Float64frombits(v), err
+}
+
+func readString(r *bytes.Reader) (string, error) {
+	n, err := binary.ReadUvarint(r)
+	if err != nil {
+		return "", err
+	}
+	if n > uint64(r.Len()) {
+		return "", errShortString
+	}
+	b := make([]byte, n)
+	if _, err := io.ReadFull(r, b); err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+func readInputs(r *bytes.Reader) ([]TxInput, error) {
+	n, err := binary.ReadUvarint(r)
+	if err != nil {
+		return nil, err
+	}
+	if n == 0 || n > uint64(r.Len()) {
+		return nil, fmt.Errorf("bad input count %d", n)
+	}
+	inputs := make([]TxInput, n)
+	for i := range inputs {
+		if inputs[i].TxID, err = readString(r); err != nil {
+			return nil, err
+		}
+		if inputs[i].Vout, err = readU32(r); err != nil {
+			return nil, err
+		}
+	}
+	return inputs, nil
+}
@@ -0,0 +1,128 @@
+package blockchain
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"devinsidercoin/internal/address"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+)
+
+// Signature schemes, the first byte of an encoded signature.
+//
+// SigSchemeEd25519 signs the envelope returned by SigHash and carries the
+// signer's public key, so any node can check it against the sender's
+// address. Signatures made before envelopes existed are a bare 64-byte
+// ed25519 signature over SigningBytes with no key; they can't be checked
+// and are accepted as they are.
+const (
+	SigSchemeEd25519 byte = 1
+)
+
+// sigDomain starts every signed envelope, so a transaction signature can
+// never be mistaken for a signature over any other message a key signs.
+const sigDomain = "DevInsiderCoin tx signature\x00"
+
+// Encoded signature lengths in bytes.
+const (
+	legacySigLen   = ed25519.SignatureSize
+	envelopeSigLen = 1 + ed25519.PublicKeySize + ed25519.SignatureSize
+)
+
+// SigHash returns the envelope a transaction signature covers: the domain
+// tag, the scheme, the network ID and SHA-256d of SigningBytes. Binding
+// the network ID means a signature made for testnet is invalid on mainnet
+// and the other way round.
+func (tx *Transaction) SigHash(networkID uint32) []byte {
+	var buf bytes.Buffer
+	buf.WriteString(sigDomain)
+	buf.WriteByte(SigSchemeEd25519)
+	binary.Write(&buf, binary.LittleEndian, networkID)
+	digest := SHA256d(tx.SigningBytes())
+	buf.Write(digest[:])
+	return buf.Bytes()
+}
+
+// EncodeSignature returns the Signature field for an envelope signature
+// made with pub: hex of the scheme, the public key and the signature.
+func EncodeSignature(pub ed25519.PublicKey, sig []byte) string {
+	raw := make([]byte, 0, envelopeSigLen)
+	raw = append(raw, SigSchemeEd25519)
+	raw = append(raw, pub...)
+	raw = append(raw, sig...)
+	return hex.EncodeToString(raw)
+}
+
+// SignTx signs tx for networkID with priv and sets its Signature.
+func SignTx(tx *Transaction, networkID uint32, priv ed25519.PrivateKey) {
+	sig := ed25519.Sign(priv, tx.SigHash(networkID))
+	tx.Signature = EncodeSignature(priv.Public().(ed25519.PublicKey), sig)
+}
+
+// ErrBadSignature means a transaction's envelope signature doesn't verify.
+var ErrBadSignature = errors.New("bad transaction signature")
+
+// ErrUnsigned means a transaction that needs an envelope signature has
+// none, or only a legacy one.
+var ErrUnsigned = errors.New("transaction not signed")
+
+// needsSignature reports whether tx spends a sender's coins: everything
+// but the reward transactions a block producer creates.
+func needsSignature(tx *Transaction) bool {
+	return tx.From != "" && tx.Type != "coinbase" && tx.Type != "pos_reward"
+}
+
+// checkSignature verifies an envelope signature: its key must hash to the
+// sender's address and it must sign this network's envelope. From
+// signature_rules_height on, transactions with a sender must carry one;
+// before it, unsigned transactions and legacy signatures pass.
+func (bc *Blockchain) checkSignature(tx *Transaction, height uint64) error {
+	return bc.verifySignature(tx, bc.Config.SignatureRulesActive(height) && needsSignature(tx))
+}
+
+// checkMempoolSignature requires an envelope signature on every
+// transaction with a sender as relay policy, ahead of signature_rules_height:
+// nothing unsigned is relayed or mined by this node.
+func (bc *Blockchain) checkMempoolSignature(tx *Transaction, height uint64) error {
+	err := bc.verifySignature(tx, needsSignature(tx))
+	if errors.Is(err, ErrUnsigned) && !bc.Config.SignatureRulesActive(height) {
+		return &RejectError{Code: RejectPolicy, Err: err}
+	}
+	return err
+}
+
+// verifySignature checks tx's signature, failing with ErrUnsigned if
+// required and tx has no envelope signature.
+func (bc *Blockchain) verifySignature(tx *Transaction, required bool) error {
+	if tx.Signature == "" {
+		if required {
+			return ErrUnsigned
+		}
+		return nil
+	}
+	raw, err := hex.DecodeString(tx.Signature)
+	if err != nil {
+		return fmt.Errorf("%w: not hex", ErrBadSignature)
+	}
+	if len(raw) == legacySigLen {
+		if required {
+			return fmt.Errorf("%w: legacy signatures are no longer accepted", ErrUnsigned)
+		}
+		return nil
+	}
+	if len(raw) != envelopeSigLen || raw[0] != SigSchemeEd25519 {
+		return fmt.Errorf("%w: unknown signature scheme", ErrBadSignature)
+	}
+	pub := ed25519.PublicKey(raw[1 : 1+ed25519.PublicKeySize])
+	sig := raw[1+ed25519.PublicKeySize:]
+	owner, _, err := address.Decode(bc.Config.AddressPrefix, tx.From)
+	if err != nil || owner != address.HashPublicKey(pub) {
+		return fmt.Errorf("%w: key does not belong to %s", ErrBadSignature, tx.From)
+	}
+	if !ed25519.Verify(pub, tx.SigHash(bc.Config.NetworkID), sig) {
+		return ErrBadSignature
+	}
+	return nil
+}
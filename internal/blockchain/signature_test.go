@@ -0,0 +1,75 @@
+package blockchain
+
+import (
+	"crypto/ed25519"
+	"devinsidercoin/internal/address"
+	"devinsidercoin/internal/config"
+	"encoding/hex"
+	"errors"
+	"testing"
+)
+
+func TestCheckSignature(t *testing.T) {
+	cfg := &config.NetworkConfig{NetworkID: 1, AddressPrefix: "DVC", SignatureRulesHeight: 100}
+	bc := &Blockchain{Config: cfg}
+	pub, priv, _ := ed25519.GenerateKey(nil)
+	otherPub, otherPriv, _ := ed25519.GenerateKey(nil)
+	from := address.FromPublicKey(cfg.AddressPrefix, pub)
+	to := address.FromPublicKey(cfg.AddressPrefix, otherPub)
+
+	signed := func(edit func(tx *Transaction)) Transaction {
+		tx := NewTransferTransaction(from, to, 5, 0.001, "")
+		SignTx(&tx, cfg.NetworkID, priv)
+		if edit != nil {
+			edit(&tx)
+		}
+		return tx
+	}
+	base := signed(nil)
+	legacy := hex.EncodeToString(ed25519.Sign(priv, base.SigningBytes()))
+
+	tests := []struct {
+		name   string
+		tx     Transaction
+		height uint64
+		want   error
+	}{
+		{"valid", signed(nil), 100, nil},
+		{"valid before the rules", signed(nil), 1, nil},
+		{"unsigned before the rules", signed(func(tx *Transaction) { tx.Signature = "" }), 1, nil},
+		{"unsigned", signed(func(tx *Transaction) { tx.Signature = "" }), 100, ErrUnsigned},
+		{"legacy before the rules", signed(func(tx *Transaction) { tx.Signature = legacy }), 1, nil},
+		{"legacy", signed(func(tx *Transaction) { tx.Signature = legacy }), 100, ErrUnsigned},
+		{"not hex", signed(func(tx *Transaction) { tx.Signature = "zz" }), 1, ErrBadSignature},
+		{"unknown scheme", signed(func(tx *Transaction) { tx.Signature = "02" + tx.Signature[2:] }), 1, ErrBadSignature},
+		{"amount changed", signed(func(tx *Transaction) { tx.Amount = 50 }), 1, ErrBadSignature},
+		{"other network", signed(func(tx *Transaction) { SignTx(tx, 2, priv) }), 1, ErrBadSignature},
+		{"key of another address", signed(func(tx *Transaction) { SignTx(tx, cfg.NetworkID, otherPriv) }), 1, ErrBadSignature},
+		{"coinbase", NewCoinbaseTransaction(to, 50, 100), 100, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := bc.checkSignature(&tt.tx, tt.height)
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("checkSignature = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckMempoolSignatureIsPolicyBeforeTheRules(t *testing.T) {
+	cfg := &config.NetworkConfig{NetworkID: 1, AddressPrefix: "DVC", SignatureRulesHeight: 100}
+	bc := &Blockchain{Config: cfg}
+	pub, _, _ := ed25519.GenerateKey(nil)
+	from := address.FromPublicKey(cfg.AddressPrefix, pub)
+	tx := NewTransferTransaction(from, from, 5, 0.001, "")
+
+	err := bc.checkMempoolSignature(&tx, 1)
+	if !errors.Is(err, ErrUnsigned) || RejectCode(err) != RejectPolicy {
+		t.Fatalf("before the rules: got %v (%s), want unsigned policy reject", err, RejectCode(err))
+	}
+	err = bc.checkMempoolSignature(&tx, 100)
+	if !errors.Is(err, ErrUnsigned) || RejectCode(err) != RejectInvalid {
+		t.Fatalf("after the rules: got %v (%s), want unsigned invalid reject", err, RejectCode(err))
+	}
+}
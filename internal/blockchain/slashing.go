@@ -0,0 +1,274 @@
+package blockchain
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// A staker that attests two different blocks at one checkpoint height has
+// signed two conflicting histories. The pair of attestations is evidence
+// any node can check on its own. From slashing_height, blocks carry a
+// slash transaction for each piece of evidence that burns slash_percent
+// of the offender's stake. Coins already unbonding are not slashed, and
+// each address is slashed at most once per checkpoint height.
+
+// metaSlashedPrefix starts the meta keys recording slashes, one per
+// address and checkpoint height; see slashKey.
+const metaSlashedPrefix = "slashed:"
+
+// ErrSlashingDisabled means the network has no slashing yet.
+var ErrSlashingDisabled = errors.New("slashing is not active on this network")
+
+// Evidence is two attestations by one address for different blocks at the
+// same checkpoint height.
+type Evidence struct {
+	First  Attestation `json:"first"`
+	Second Attestation `json:"second"`
+}
+
+// evidencePool holds evidence not yet slashed in a block, by slashKey.
+type evidencePool map[string]*Evidence
+
+func slashKey(address string, height uint64) string {
+	return fmt.Sprintf("%d:%s", height, address)
+}
+
+// normalize orders the attestations by hash, so that both orders of a
+// pair make the same slash transaction.
+func (ev *Evidence) normalize() {
+	if ev.Second.Hash < ev.First.Hash {
+		ev.First, ev.Second = ev.Second, ev.First
+	}
+}
+
+// checkEvidence verifies ev: validly signed attestations by one address
+// for two blocks at the height of one of our checkpoints, where the
+// address had eligible stake. The caller holds bc.mu.
+func (bc *Blockchain) checkEvidence(ev *Evidence) error {
+	a, b := &ev.First, &ev.Second
+	if a.Address != b.Address || a.Height != b.Height {
+		return errors.New("evidence attestations must share address and height")
+	}
+	if a.Hash == b.Hash {
+		return errors.New("evidence attestations sign the same block")
+	}
+	cp := bc.loadCheckpoint(a.Height)
+	if cp == nil {
+		return fmt.Errorf("no checkpoint at height %d", a.Height)
+	}
+	if _, ok := cp.Weights[a.Address]; !ok {
+		return fmt.Errorf("%s has no eligible stake at checkpoint %d", a.Address, a.Height)
+	}
+	for _, att := range []*Attestation{a, b} {
+		if err := bc.verifyAttestation(att); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// slashed reports whether a block has already slashed address for the
+// checkpoint at height. The caller holds bc.mu.
+func (bc *Blockchain) slashed(address string, height uint64) bool {
+	return bc.Store.GetMeta(metaSlashedPrefix+slashKey(address, height)) != nil
+}
+
+// addEvidence pools ev once verified and reports whether it is new.
+// Evidence already pooled or slashed is ignored. The caller holds bc.mu.
+func (bc *Blockchain) addEvidence(ev Evidence) (bool, error) {
+	ev.normalize()
+	if err := bc.checkEvidence(&ev); err != nil {
+		return false, err
+	}
+	key := slashKey(ev.First.Address, ev.First.Height)
+	if _, ok := bc.evidence[key]; ok || bc.slashed(ev.First.Address, ev.First.Height) {
+		return false, nil
+	}
+	if bc.evidence == nil {
+		bc.evidence = make(evidencePool)
+	}
+	bc.evidence[key] = &ev
+	logger.Warn("double-sign evidence", "address", ev.First.Address, "height", ev.First.Height,
+		"hashes", []string{ev.First.Hash, ev.Second.Hash})
+	return true, nil
+}
+
+// conflictingAttestation handles an attestation for another block than
+// checkpoint cp's. If the address also attested cp's block, the pair is
+// evidence: it is pooled and the attestation reported as new, so that
+// peers holding the other half learn of it too. The caller holds bc.mu.
+func (bc *Blockchain) conflictingAttestation(cp *Checkpoint, a Attestation) (bool, error) {
+	logger.Warn("attestation for a conflicting block", "height", a.Height,
+		"hash", a.Hash, "ours", cp.Hash, "address", a.Address)
+	sig, ok := cp.Signatures[a.Address]
+	if !ok || !bc.Config.SlashingActive(bc.Store.GetBlockCount()) {
+		return false, fmt.Errorf("checkpoint %d is block %s, not %s", a.Height, cp.Hash, a.Hash)
+	}
+	ours := Attestation{Height: cp.Height, Hash: cp.Hash, Address: a.Address, Signature: sig}
+	return bc.addEvidence(Evidence{First: ours, Second: a})
+}
+
+// AddEvidence pools double-sign evidence for the next blocks to slash. It
+// reports whether the evidence was new.
+func (bc *Blockchain) AddEvidence(ev Evidence) (bool, error) {
+	bc.mu.Lock()
+	defer bc.mu.Unlock()
+	if !bc.Config.SlashingActive(bc.Store.GetBlockCount()) {
+		return false, ErrSlashingDisabled
+	}
+	return bc.addEvidence(ev)
+}
+
+// PendingEvidence returns the evidence waiting for a block, by height.
+func (bc *Blockchain) PendingEvidence() []Evidence {
+	bc.mu.RLock()
+	defer bc.mu.RUnlock()
+	out := make([]Evidence, 0, len(bc.evidence))
+	for _, key := range bc.evidence.keys() {
+		out = append(out, *bc.evidence[key])
+	}
+	return out
+}
+
+// keys returns the pool's keys by height, then address.
+func (p evidencePool) keys() []string {
+	keys := make([]string, 0, len(p))
+	for key := range p {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		a, b := p[keys[i]].First, p[keys[j]].First
+		if a.Height != b.Height {
+			return a.Height < b.Height
+		}
+		return a.Address < b.Address
+	})
+	return keys
+}
+
+// slashAmount returns what a slash burns of staked.
+func (bc *Blockchain) slashAmount(staked float64) float64 {
+	return FromUnits(int64(float64(ToUnits(staked)) * bc.Config.SlashPercent / 100))
+}
+
+// stageStake applies the stake change of tx to stakes, as addBlock does.
+func stageStake(stakes *stakeChanges, tx *Transaction) {
+	switch tx.Type {
+	case "stake", "delegate":
+		stakes.add(tx.From, tx.Amount, 0)
+	case "unstake":
+		stakes.remove(tx.From, tx.Amount)
+	case "slash":
+		stakes.remove(tx.To, tx.Amount)
+	}
+}
+
+// slashTransactions returns up to room slash transactions for a block at
+// height that carries txs: one per pooled piece of evidence whose
+// offender still has stake after txs. The caller holds bc.mu.
+func (bc *Blockchain) slashTransactions(height uint64, txs []Transaction, room int) []Transaction {
+	if !bc.Config.SlashingActive(height) || len(bc.evidence) == 0 {
+		return nil
+	}
+	stakes := newStakeChanges(bc.Stakes)
+	for i := range txs {
+		stageStake(stakes, &txs[i])
+	}
+	var out []Transaction
+	for _, key := range bc.evidence.keys() {
+		if len(out) >= room {
+			break
+		}
+		ev := bc.evidence[key]
+		offender := ev.First.Address
+		amount := bc.slashAmount(stakes.GetStake(offender))
+		if amount <= 0 || bc.slashed(offender, ev.First.Height) {
+			continue
+		}
+		data, _ := json.Marshal(ev)
+		tx := Transaction{
+			Version:   TxVersionCanonical,
+			Type:      "slash",
+			To:        offender,
+			Amount:    amount,
+			Timestamp: time.Now().Unix(),
+			Data:      string(data),
+		}
+		tx.TxID = tx.ComputeTxID()
+		stageStake(stakes, &tx)
+		out = append(out, tx)
+	}
+	return out
+}
+
+// checkSlashes rejects a block whose slash transactions don't each carry
+// new valid evidence against their recipient and burn exactly
+// slash_percent of its stake at that point in the block. The caller holds
+// bc.mu.
+func (bc *Blockchain) checkSlashes(block *Block) error {
+	height := block.Header.Height
+	stakes := newStakeChanges(bc.Stakes)
+	seen := make(map[string]bool)
+	for i := range block.Transactions {
+		tx := &block.Transactions[i]
+		if tx.Type == "slash" {
+			if !bc.Config.SlashingActive(height) {
+				return fmt.Errorf("tx %s: slash before slashing_height", tx.TxID)
+			}
+			ev, err := bc.slashEvidence(tx)
+			if err != nil {
+				return fmt.Errorf("tx %s: %w", tx.TxID, err)
+			}
+			key := slashKey(tx.To, ev.First.Height)
+			if seen[key] || bc.slashed(tx.To, ev.First.Height) {
+				return fmt.Errorf("tx %s: %s already slashed for checkpoint %d", tx.TxID, tx.To, ev.First.Height)
+			}
+			seen[key] = true
+			want := bc.slashAmount(stakes.GetStake(tx.To))
+			if want <= 0 || tx.Amount != want {
+				return fmt.Errorf("tx %s: slash of %.8f, expected %.8f", tx.TxID, tx.Amount, want)
+			}
+		}
+		stageStake(stakes, tx)
+	}
+	return nil
+}
+
+// slashEvidence checks the shape of slash transaction tx and returns its
+// verified evidence. The caller holds bc.mu.
+func (bc *Blockchain) slashEvidence(tx *Transaction) (*Evidence, error) {
+	if tx.From != "" || tx.Fee != 0 || len(tx.Inputs) > 0 || len(tx.Outputs) > 0 {
+		return nil, errors.New("slash must have no sender, fee, inputs or outputs")
+	}
+	var ev Evidence
+	if err := json.Unmarshal([]byte(tx.Data), &ev); err != nil {
+		return nil, fmt.Errorf("undecodable evidence: %w", err)
+	}
+	if err := bc.checkEvidence(&ev); err != nil {
+		return nil, err
+	}
+	if ev.First.Address != tx.To {
+		return nil, fmt.Errorf("evidence is against %s, not %s", ev.First.Address, tx.To)
+	}
+	return &ev, nil
+}
+
+// blockSlashes returns the meta entries recording the slashes in block.
+func blockSlashes(block *Block) map[string][]byte {
+	var meta map[string][]byte
+	for _, tx := range block.Transactions {
+		if tx.Type != "slash" {
+			continue
+		}
+		var ev Evidence
+		json.Unmarshal([]byte(tx.Data), &ev)
+		if meta == nil {
+			meta = make(map[string][]byte)
+		}
+		meta[metaSlashedPrefix+slashKey(tx.To, ev.First.Height)] = []byte(tx.TxID)
+	}
+	return meta
+}
@@ -0,0 +1,80 @@
+package blockchain
+
+import (
+	"devinsidercoin/internal/config"
+	"testing"
+)
+
+func TestSlashAmount(t *testing.T) {
+	tests := []struct {
+		percent float64
+		staked  float64
+		want    float64
+	}{
+		{10, 1000, 100},
+		{10, 0.00000015, 0.00000001}, // rounds down to whole base units
+		{33, 1, 0.33},
+		{100, 12.5, 12.5},
+		{0, 1000, 0},
+	}
+	for _, tt := range tests {
+		bc := &Blockchain{Config: &config.NetworkConfig{SlashPercent: tt.percent}}
+		if got := bc.slashAmount(tt.staked); got != tt.want {
+			t.Errorf("%v%% of %v: got %v, want %v", tt.percent, tt.staked, got, tt.want)
+		}
+	}
+}
+
+func TestEvidenceNormalize(t *testing.T) {
+	a := Attestation{Address: "v", Height: 10, Hash: "aa"}
+	b := Attestation{Address: "v", Height: 10, Hash: "bb"}
+	for _, ev := range []Evidence{{First: a, Second: b}, {First: b, Second: a}} {
+		ev.normalize()
+		if ev.First.Hash != "aa" || ev.Second.Hash != "bb" {
+			t.Errorf("normalize gave %s, %s; want aa, bb", ev.First.Hash, ev.Second.Hash)
+		}
+	}
+}
+
+func TestCheckEvidenceShape(t *testing.T) {
+	bc := &Blockchain{Config: &config.NetworkConfig{}}
+	tests := []struct {
+		name          string
+		first, second Attestation
+	}{
+		{"other address", Attestation{Address: "v", Height: 10, Hash: "aa"}, Attestation{Address: "w", Height: 10, Hash: "bb"}},
+		{"other height", Attestation{Address: "v", Height: 10, Hash: "aa"}, Attestation{Address: "v", Height: 20, Hash: "bb"}},
+		{"same block", Attestation{Address: "v", Height: 10, Hash: "aa"}, Attestation{Address: "v", Height: 10, Hash: "aa"}},
+	}
+	for _, tt := range tests {
+		ev := Evidence{First: tt.first, Second: tt.second}
+		if err := bc.checkEvidence(&ev); err == nil {
+			t.Errorf("%s: evidence accepted", tt.name)
+		}
+	}
+}
+
+func TestStageStake(t *testing.T) {
+	base := NewStakeManager()
+	base.AddStake("a", 100, 1)
+	base.AddStake("b", 50, 1)
+	stakes := newStakeChanges(base)
+	for _, tx := range []Transaction{
+		{Type: "stake", From: "a", Amount: 20},
+		{Type: "unstake", From: "b", Amount: 10},
+		{Type: "slash", To: "a", Amount: 12},
+		{Type: "delegate", From: "c", To: "a", Amount: 5},
+		{Type: "transfer", From: "a", To: "b", Amount: 1},
+	} {
+		stageStake(stakes, &tx)
+	}
+	want := map[string]float64{"a": 108, "b": 40, "c": 5}
+	for addr, amount := range want {
+		if got := stakes.GetStake(addr); got != amount {
+			t.Errorf("stake of %s = %v, want %v", addr, got, amount)
+		}
+	}
+	if got := base.GetStake("a"); got != 100 {
+		t.Errorf("staging changed the base stake of a to %v", got)
+	}
+}
@@ -0,0 +1,109 @@
+package blockchain
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrStakeLocked means an unstake comes before its sender's stake has been
+// locked for StakeLockBlocks blocks.
+var ErrStakeLocked = errors.New("stake is locked")
+
+// stakeUnlockHeight returns the first height whose blocks may include an
+// unstake from address: StakeLockBlocks after the block it last staked in,
+// or 0 if it has no stake. The caller holds bc.mu.
+func (bc *Blockchain) stakeUnlockHeight(address string) uint64 {
+	at, ok := bc.Stakes.stakedAt(address)
+	if !ok {
+		return 0
+	}
+	return at + bc.Config.StakeLockBlocks
+}
+
+// StakeUnlockHeight returns the first height at which address may unstake,
+// or 0 if it has no stake or the stake lock is not active.
+func (bc *Blockchain) StakeUnlockHeight(address string) uint64 {
+	bc.mu.RLock()
+	defer bc.mu.RUnlock()
+	if !bc.Config.StakeLockActive(bc.Store.GetBlockCount()) {
+		return 0
+	}
+	return bc.stakeUnlockHeight(address)
+}
+
+// checkStakeLocks rejects a block with an unstake whose sender's stake is
+// still locked. A stake earlier in the block restarts its sender's lock.
+// The caller holds bc.mu.
+func (bc *Blockchain) checkStakeLocks(block *Block) error {
+	height := block.Header.Height
+	unlock := make(map[string]uint64)
+	for i := range block.Transactions {
+		tx := &block.Transactions[i]
+		switch tx.Type {
+		case "stake", "delegate":
+			unlock[tx.From] = height + bc.Config.StakeLockBlocks
+		case "unstake":
+			at, ok := unlock[tx.From]
+			if !ok {
+				at = bc.stakeUnlockHeight(tx.From)
+			}
+			if height < at {
+				return fmt.Errorf("tx %s: %w: %s may unstake from height %d", tx.TxID, ErrStakeLocked, tx.From, at)
+			}
+		}
+	}
+	return nil
+}
+
+// checkMempoolStakeLock refuses an unstake that the next block, at height,
+// could not include under the stake lock. Stakes and unstakes from one
+// sender don't wait together, since a stake confirmed first would lock
+// the unstake out. The caller holds bc.mu.
+func (bc *Blockchain) checkMempoolStakeLock(tx *Transaction, height uint64) error {
+	switch tx.Type {
+	case "unstake":
+		if bc.Mempool.hasPending(tx.From, "stake") || bc.Mempool.hasPending(tx.From, "delegate") {
+			return fmt.Errorf("%w: a pending stake from %s restarts the lock", ErrStakeLocked, tx.From)
+		}
+		if at := bc.stakeUnlockHeight(tx.From); height < at {
+			return fmt.Errorf("%w: %s may unstake from height %d, %d blocks from now",
+				ErrStakeLocked, tx.From, at, at-height)
+		}
+	case "stake", "delegate":
+		if bc.Mempool.hasPending(tx.From, "unstake") {
+			return fmt.Errorf("%s has a pending unstake; stake again once it confirms", tx.From)
+		}
+	}
+	return nil
+}
+
+// removeLockedUnstakes drops the pending unstakes that the block after
+// block may no longer include and returns them: those of senders who
+// staked in block, or every locked one when the stake lock activates.
+// The caller holds bc.mu.
+func (bc *Blockchain) removeLockedUnstakes(block *Block) []Transaction {
+	height := block.Header.Height + 1
+	var senders map[string]bool
+	if bc.Config.StakeLockHeight != height {
+		senders = make(map[string]bool)
+		for _, tx := range block.Transactions {
+			if addsStake(&tx) {
+				senders[tx.From] = true
+			}
+		}
+		if len(senders) == 0 {
+			return nil
+		}
+	}
+	var removed []Transaction
+	for txid, e := range bc.Mempool.entries {
+		if e.Tx.Type != "unstake" || senders != nil && !senders[e.Tx.From] {
+			continue
+		}
+		if height < bc.stakeUnlockHeight(e.Tx.From) {
+			removed = append(removed, e.Tx)
+			bc.Mempool.remove(txid)
+		}
+	}
+	return removed
+}
@@ -0,0 +1,93 @@
+package blockchain
+
+// StakingInfo summarises proof-of-stake rewards as of the tip.
+type StakingInfo struct {
+	Height         uint64           `json:"height"`
+	TotalStaked    float64          `json:"total_staked"`
+	EligibleStake  float64          `json:"eligible_stake"` // validators' own and delegated stake at or above min_stake
+	EligibleCount  int              `json:"eligible_stakers"`
+	MinStake       float64          `json:"min_stake"`
+	RewardPerBlock float64          `json:"reward_per_block"` // staker share of the next block
+	BlocksPerYear  uint64           `json:"blocks_per_year"`
+	Projection     *StakeProjection `json:"projection,omitempty"`
+	Earnings       *StakingEarnings `json:"earnings,omitempty"`
+}
+
+// StakeProjection estimates the yield of a new stake of Amount joining the
+// current eligible stake. It follows the emission schedule over the next
+// year but assumes the other stakes stay as they are. A stake below the
+// minimum is not Eligible and earns nothing.
+type StakeProjection struct {
+	Amount         float64 `json:"amount"`
+	Eligible       bool    `json:"eligible"`
+	RewardPerBlock float64 `json:"reward_per_block"`
+	YearlyReward   float64 `json:"yearly_reward"`
+	APY            float64 `json:"apy"` // percent
+}
+
+// StakingEarnings is an address's PoS reward history.
+type StakingEarnings struct {
+	Address    string  `json:"address"`
+	Total      float64 `json:"total"`
+	Blocks     int     `json:"blocks"`      // blocks that paid the address
+	LastHeight uint64  `json:"last_height"` // 0 if never paid
+}
+
+// GetStakingInfo returns staking figures for the block after the tip. A
+// positive amount adds a yield projection for a stake of that size; a
+// non-empty address adds its PoS earnings.
+func (bc *Blockchain) GetStakingInfo(amount float64, address string) StakingInfo {
+	bc.mu.RLock()
+	defer bc.mu.RUnlock()
+	next := bc.Store.GetBlockCount()
+	info := StakingInfo{
+		Height:         next - 1,
+		TotalStaked:    bc.Stakes.GetTotalStaked(),
+		MinStake:       bc.govParam(ParamPOSMinThreshold, next),
+		RewardPerBlock: bc.Engine.StakerShare(bc.CalcBlockReward(next)),
+	}
+	info.EligibleStake, info.EligibleCount = bc.Stakes.eligible(info.MinStake)
+	if bc.Config.BlockTimeSeconds > 0 {
+		info.BlocksPerYear = uint64(365 * 24 * 3600 / bc.Config.BlockTimeSeconds)
+	}
+	if amount > 0 {
+		p := &StakeProjection{Amount: amount, Eligible: amount >= max(info.MinStake, bc.Config.MinStakeAmount)}
+		if p.Eligible {
+			share := amount / (info.EligibleStake + amount)
+			p.RewardPerBlock = FromUnits(ToUnits(info.RewardPerBlock * share))
+			p.YearlyReward = FromUnits(ToUnits(bc.stakerEmission(next, info.BlocksPerYear) * share))
+			p.APY = p.YearlyReward / amount * 100
+		}
+		info.Projection = p
+	}
+	if address != "" {
+		info.Earnings = bc.stakingEarnings(address)
+	}
+	return info
+}
+
+// stakerEmission returns the staker share of the subsidies of the n blocks
+// from height on, taking each halving era at once.
+func (bc *Blockchain) stakerEmission(height, n uint64) float64 {
+	total := 0.0
+	for end := height + n; height < end; {
+		eraEnd := min((height/bc.Config.HalvingInterval+1)*bc.Config.HalvingInterval, end)
+		total += bc.Engine.StakerShare(bc.CalcBlockReward(height)) * float64(eraEnd-height)
+		height = eraEnd
+	}
+	return total
+}
+
+// stakingEarnings totals the rewards paid to address from the reward
+// index. The caller holds bc.mu.
+func (bc *Blockchain) stakingEarnings(address string) *StakingEarnings {
+	e := &StakingEarnings{Address: address}
+	var units int64
+	for _, r := range bc.Store.Rewards(address, 0, 0, nil) {
+		units += ToUnits(r.Amount)
+		e.Blocks++
+		e.LastHeight = r.Height
+	}
+	e.Total = FromUnits(units)
+	return e
+}
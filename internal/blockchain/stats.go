@@ -0,0 +1,154 @@
+package blockchain
+
+import (
+	"encoding/json"
+	"math/big"
+)
+
+// MaxStatsWindow caps how many recent blocks a single stats window reads.
+const MaxStatsWindow = 10000
+
+// WindowStats summarises the most recent Blocks blocks.
+type WindowStats struct {
+	Blocks        int     `json:"blocks"`
+	FromHeight    uint64  `json:"from_height"`
+	ToHeight      uint64  `json:"to_height"`
+	AvgBlockTime  float64 `json:"avg_block_time"` // seconds
+	AvgDifficulty float64 `json:"avg_difficulty"`
+	Transactions  int     `json:"transactions"` // excluding reward txs
+	TxPerBlock    float64 `json:"tx_per_block"`
+	TxPerSecond   float64 `json:"tx_per_second"`
+	TotalFees     float64 `json:"total_fees"`
+	AvgFee        float64 `json:"avg_fee"`
+	HashRate      float64 `json:"hashrate"` // hashes per second
+}
+
+// BlockWork returns the expected number of hashes needed to find a block
+// at bits: 2^256 / (target + 1).
+func BlockWork(bits uint32) *big.Int {
+	target := BitsToTarget(bits)
+	denom := new(big.Int).Add(target, big.NewInt(1))
+	return new(big.Int).Div(new(big.Int).Lsh(big.NewInt(1), 256), denom)
+}
+
+// Difficulty expresses bits relative to the easiest allowed target
+// (minBits), so a block at minimum difficulty has difficulty 1.
+func Difficulty(bits, minBits uint32) float64 {
+	target := BitsToTarget(bits)
+	if target.Sign() == 0 {
+		return 0
+	}
+	d, _ := new(big.Float).Quo(
+		new(big.Float).SetInt(BitsToTarget(minBits)),
+		new(big.Float).SetInt(target),
+	).Float64()
+	return d
+}
+
+// WindowStats computes rolling statistics over the last n blocks.
+func (bc *Blockchain) WindowStats(n int) WindowStats {
+	bc.mu.RLock()
+	defer bc.mu.RUnlock()
+	if n < 2 {
+		n = 2
+	}
+	if n > MaxStatsWindow {
+		n = MaxStatsWindow
+	}
+	raw, err := bc.Store.GetRecentBlocks(uint64(n))
+	if err != nil || len(raw) == 0 {
+		return WindowStats{}
+	}
+	blocks := make([]*Block, 0, len(raw))
+	for _, data := range raw {
+		var b Block
+		if json.Unmarshal(data, &b) == nil {
+			blocks = append(blocks, &b)
+		}
+	}
+	if len(blocks) == 0 {
+		return WindowStats{}
+	}
+	return summarise(blocks, bc.Config.MinDifficultyBits)
+}
+
+func summarise(blocks []*Block, minBits uint32) WindowStats {
+	first, last := blocks[0], blocks[len(blocks)-1]
+	st := WindowStats{
+		Blocks:     len(blocks),
+		FromHeight: first.Header.Height,
+		ToHeight:   last.Header.Height,
+	}
+	diffSum := 0.0
+	for _, b := range blocks {
+		diffSum += Difficulty(b.Header.Bits, minBits)
+		for _, tx := range b.Transactions {
+			if tx.Type == "coinbase" || tx.Type == "pos_reward" {
+				continue
+			}
+			st.Transactions++
+			st.TotalFees += tx.Fee
+		}
+	}
+	st.AvgDifficulty = diffSum / float64(len(blocks))
+	st.TxPerBlock = float64(st.Transactions) / float64(len(blocks))
+	if st.Transactions > 0 {
+		st.AvgFee = st.TotalFees / float64(st.Transactions)
+	}
+	if span := last.Header.Timestamp - first.Header.Timestamp; span > 0 && len(blocks) > 1 {
+		st.AvgBlockTime = float64(span) / float64(len(blocks)-1)
+		st.TxPerSecond = float64(st.Transactions) / float64(span)
+	}
+	st.HashRate = hashRate(blocks)
+	return st
+}
+
+// hashRate estimates hashes per second from the work in blocks and the
+// time between the first and last of them. The first block's work was done
+// before that interval, so it is not counted.
+func hashRate(blocks []*Block) float64 {
+	if len(blocks) < 2 {
+		return 0
+	}
+	span := blocks[len(blocks)-1].Header.Timestamp - blocks[0].Header.Timestamp
+	if span <= 0 {
+		return 0
+	}
+	work := new(big.Int)
+	for _, b := range blocks[1:] {
+		work.Add(work, BlockWork(b.Header.Bits))
+	}
+	hps, _ := new(big.Float).Quo(new(big.Float).SetInt(work), big.NewFloat(float64(span))).Float64()
+	return hps
+}
+
+// NetworkHashPS estimates the network hashrate from the nblocks blocks
+// ending at height (the tip if height < 0).
+func (bc *Blockchain) NetworkHashPS(nblocks int, height int64) float64 {
+	bc.mu.RLock()
+	defer bc.mu.RUnlock()
+	best := bc.Store.GetBestHeight()
+	if best < 0 {
+		return 0
+	}
+	if height < 0 || height > best {
+		height = best
+	}
+	if nblocks < 2 {
+		nblocks = 2
+	}
+	if nblocks > MaxStatsWindow {
+		nblocks = MaxStatsWindow
+	}
+	start := height - int64(nblocks) + 1
+	if start < 0 {
+		start = 0
+	}
+	var blocks []*Block
+	for h := start; h <= height; h++ {
+		if b := bc.loadBlock(uint64(h)); b != nil {
+			blocks = append(blocks, b)
+		}
+	}
+	return hashRate(blocks)
+}
@@ -0,0 +1,36 @@
+package blockchain
+
+import "fmt"
+
+// checkTxLimits applies the per-transaction consensus limits from
+// tx_limits_height, so that no single transaction can fill a block: at
+// most max_tx_size canonical bytes and max_tx_outputs outputs. Reward
+// transactions are exempt; their shape is fixed by consensus.
+func (bc *Blockchain) checkTxLimits(tx *Transaction, height uint64) error {
+	if !bc.Config.TxLimitsActive(height) || !needsSignature(tx) {
+		return nil
+	}
+	if limit := bc.Config.MaxTxOutputs; limit > 0 && uint64(len(tx.Outputs)) > limit {
+		return fmt.Errorf("too many outputs: %d > %d", len(tx.Outputs), limit)
+	}
+	if limit := bc.Config.MaxTxSize; limit > 0 {
+		if size := tx.Size(); uint64(size) > limit {
+			return fmt.Errorf("transaction too large: %d bytes > %d", size, limit)
+		}
+	}
+	return nil
+}
+
+// removeOverLimit drops pending transactions that break the transaction
+// limits when they activate at height, and returns them. The caller holds
+// bc.mu.
+func (bc *Blockchain) removeOverLimit(height uint64) []Transaction {
+	var removed []Transaction
+	for txid, e := range bc.Mempool.entries {
+		if bc.checkTxLimits(&e.Tx, height) != nil {
+			removed = append(removed, e.Tx)
+			bc.Mempool.remove(txid)
+		}
+	}
+	return removed
+}
@@ -0,0 +1,86 @@
+package blockchain
+
+// TxRecord is a transaction together with its current position in the
+// chain. Confirmations is 0 while the transaction is pending.
+type TxRecord struct {
+	Transaction
+	BlockHash     string `json:"block_hash,omitempty"`
+	BlockHeight   uint64 `json:"block_height,omitempty"`
+	Confirmations int64  `json:"confirmations"`
+}
+
+func touches(tx Transaction, address string) bool {
+	if tx.From == address || tx.To == address {
+		return true
+	}
+	for _, out := range tx.Outputs {
+		if out.Address == address {
+			return true
+		}
+	}
+	return false
+}
+
+// GetTransaction looks up a transaction in the chain or the mempool.
+func (bc *Blockchain) GetTransaction(txid string) (*TxRecord, bool) {
+	bc.mu.RLock()
+	defer bc.mu.RUnlock()
+	if height, err := bc.Store.GetTxBlockHeight(txid); err == nil {
+		if block := bc.loadBlock(height); block != nil {
+			for _, tx := range block.Transactions {
+				if tx.TxID == txid {
+					return bc.confirmedRecord(tx, block), true
+				}
+			}
+		}
+	}
+	return bc.unconfirmedRecord(txid)
+}
+
+func (bc *Blockchain) unconfirmedRecord(txid string) (*TxRecord, bool) {
+	if tx, ok := bc.Mempool.Get(txid); ok {
+		return &TxRecord{Transaction: tx}, true
+	}
+	return nil, false
+}
+
+func (bc *Blockchain) confirmedRecord(tx Transaction, block *Block) *TxRecord {
+	tip := bc.Store.GetBlockCount()
+	return &TxRecord{
+		Transaction:   tx,
+		BlockHash:     block.Hash,
+		BlockHeight:   block.Header.Height,
+		Confirmations: int64(tip - block.Header.Height),
+	}
+}
+
+// GetTransactions returns every transaction touching address: confirmed
+// ones in chain order, then pending ones.
+func (bc *Blockchain) GetTransactions(address string) []TxRecord {
+	bc.mu.RLock()
+	defer bc.mu.RUnlock()
+	var result []TxRecord
+	if bc.archive != nil && bc.archive.ready {
+		result = bc.archivedTransactions(address)
+	} else {
+		count := bc.Store.GetBlockCount()
+		for h := uint64(0); h < count; h++ {
+			block := bc.loadBlock(h)
+			if block == nil {
+				continue
+			}
+			for _, tx := range block.Transactions {
+				if touches(tx, address) {
+					result = append(result, *bc.confirmedRecord(tx, block))
+				}
+			}
+		}
+	}
+	for _, tx := range bc.Mempool.Transactions() {
+		if touches(tx, address) {
+			rec, _ := bc.unconfirmedRecord(tx.TxID)
+			result = append(result, *rec)
+		}
+	}
+	return result
+}
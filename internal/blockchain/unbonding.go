@@ -0,0 +1,119 @@
+package blockchain
+
+import (
+	"devinsidercoin/internal/storage"
+	"encoding/json"
+	"fmt"
+	"sort"
+)
+
+// Unbond is stake on its way out. Once unbonding is active an unstake
+// confirmed at Height stops counting as stake at once, and its coins
+// return to Address in the block at ReleaseHeight. Every unstake is its own
+// entry, so a stake can be unbonded in several parts.
+type Unbond struct {
+	TxID          string  `json:"txid"`
+	Address       string  `json:"address"`
+	Amount        float64 `json:"amount"`
+	Height        uint64  `json:"height"`
+	ReleaseHeight uint64  `json:"release_height"`
+}
+
+// UnbondRelease returns the height at which an unstake confirmed at height
+// releases its coins: StakeLockBlocks later, and never in its own block.
+func (bc *Blockchain) UnbondRelease(height uint64) uint64 {
+	return height + max(bc.Config.StakeLockBlocks, 1)
+}
+
+func (bc *Blockchain) loadUnbonding() {
+	bc.unbonding = nil
+	for _, data := range bc.Store.GetUnbondingRaw() {
+		var u Unbond
+		if json.Unmarshal(data, &u) == nil {
+			bc.unbonding = append(bc.unbonding, &u)
+		}
+	}
+}
+
+// dueUnbonds returns the queued unbonds that release at or below height.
+// The queue is kept in release order. The caller holds bc.mu.
+func (bc *Blockchain) dueUnbonds(height uint64) []*Unbond {
+	n := sort.Search(len(bc.unbonding), func(i int) bool {
+		return bc.unbonding[i].ReleaseHeight > height
+	})
+	return bc.unbonding[:n]
+}
+
+// unbondingChanges stages the queue for a block that releases the first
+// released entries and adds queued: the new queue and the commit entries.
+// The caller holds bc.mu.
+func (bc *Blockchain) unbondingChanges(released int, queued []*Unbond) ([]*Unbond, map[string][]byte) {
+	changes := make(map[string][]byte, released+len(queued))
+	for _, u := range bc.unbonding[:released] {
+		changes[storage.UnbondingKey(u.ReleaseHeight, u.TxID)] = nil
+	}
+	next := append(append([]*Unbond(nil), bc.unbonding[released:]...), queued...)
+	for _, u := range queued {
+		changes[storage.UnbondingKey(u.ReleaseHeight, u.TxID)], _ = json.Marshal(u)
+	}
+	sort.SliceStable(next, func(i, j int) bool {
+		if next[i].ReleaseHeight != next[j].ReleaseHeight {
+			return next[i].ReleaseHeight < next[j].ReleaseHeight
+		}
+		return next[i].TxID < next[j].TxID
+	})
+	return next, changes
+}
+
+// checkUnstakes rejects a block whose unstakes, taken in order with its
+// stakes, take out more than their senders have staked. The caller holds
+// bc.mu.
+func (bc *Blockchain) checkUnstakes(block *Block) error {
+	staked := make(map[string]float64)
+	for i := range block.Transactions {
+		tx := &block.Transactions[i]
+		if tx.Type != "unstake" && !addsStake(tx) {
+			continue
+		}
+		if _, ok := staked[tx.From]; !ok {
+			staked[tx.From] = bc.Stakes.GetStake(tx.From)
+		}
+		if addsStake(tx) {
+			staked[tx.From] += tx.Amount
+			continue
+		}
+		if tx.Amount <= 0 {
+			return fmt.Errorf("tx %s: unstake amount must be positive", tx.TxID)
+		}
+		if tx.Amount > staked[tx.From]+0.00000001 {
+			return fmt.Errorf("tx %s: unstake of %.8f exceeds the %.8f staked by %s",
+				tx.TxID, tx.Amount, staked[tx.From], tx.From)
+		}
+		staked[tx.From] -= tx.Amount
+	}
+	return nil
+}
+
+// GetUnbonding returns address's queued unbonds in release order, or
+// every queued unbond if address is empty.
+func (bc *Blockchain) GetUnbonding(address string) []Unbond {
+	bc.mu.RLock()
+	defer bc.mu.RUnlock()
+	out := []Unbond{}
+	for _, u := range bc.unbonding {
+		if address == "" || u.Address == address {
+			out = append(out, *u)
+		}
+	}
+	return out
+}
+
+// unbondingTotal returns the coins waiting in the queue. The caller holds
+// bc.mu.
+func (bc *Blockchain) unbondingTotal() float64 {
+	total := 0.0
+	for _, u := range bc.unbonding {
+		total += u.Amount
+	}
+	return total
+}
@@ -0,0 +1,492 @@
+package blockchain
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"devinsidercoin/internal/config"
+	"devinsidercoin/internal/storage"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/big"
+	"sort"
+	"strconv"
+)
+
+// metaUTXOActivated records the height at which the UTXO set was created,
+// so a node can refuse a manifest that activates the model below its tip.
+const metaUTXOActivated = "utxo_activated"
+
+// UTXO is an unspent output on a utxo network. Inputs spend it in full.
+type UTXO struct {
+	TxID     string  `json:"txid"`
+	Vout     uint32  `json:"vout"`
+	Address  string  `json:"address"`
+	Amount   float64 `json:"amount"`
+	Height   uint64  `json:"height"`
+	Coinbase bool    `json:"coinbase,omitempty"` // paid by a coinbase or pos_reward
+}
+
+func outpoint(txid string, vout uint32) string {
+	return txid + ":" + strconv.FormatUint(uint64(vout), 10)
+}
+
+// MigrationTxID is the txid of the output that carries address's account
+// balance into the UTXO set at utxo_activation_height. The output index
+// is always 0.
+func MigrationTxID(address string) string {
+	hash := SHA256d([]byte("utxo-migration:" + address))
+	return hex.EncodeToString(hash[:])
+}
+
+// MigrationUTXOs returns the outputs that activating the utxo model would
+// create from balances: one per address with a positive balance, sorted by
+// address.
+func MigrationUTXOs(balances map[string]float64, height uint64) []UTXO {
+	var utxos []UTXO
+	for addr, bal := range balances {
+		if bal > 0 {
+			utxos = append(utxos, UTXO{TxID: MigrationTxID(addr), Address: addr, Amount: bal, Height: height})
+		}
+	}
+	sort.Slice(utxos, func(i, j int) bool { return utxos[i].Address < utxos[j].Address })
+	return utxos
+}
+
+// utxoConsumed returns the value tx must take from its inputs beyond what
+// it pays to its outputs: the fee, plus whatever the type moves out of
+// circulation (burn) or into a stake. Unstaking releases value, so an
+// unstake pays out more than it spends, unless unbonding defers the
+// release.
+func utxoConsumed(tx *Transaction, unbonding bool) float64 {
+	c := spendOf(*tx)
+	switch {
+	case tx.Type == "transfer", tx.Type == "unstake" && !unbonding:
+		c -= tx.Amount
+	}
+	return c
+}
+
+// utxoView stages changes to the committed UTXO set for one block, or for
+// a throwaway mempool check, and the balance changes they imply.
+type utxoView struct {
+	store     *storage.Store
+	created   map[string]*UTXO
+	spent     map[string]bool
+	deltas    map[string]float64
+	unbonding bool   // unstakes queue their coins rather than paying them out
+	maturity  uint64 // blocks before reward outputs can be spent; 0 disables
+}
+
+func newUTXOView(store *storage.Store) *utxoView {
+	return &utxoView{
+		store:   store,
+		created: make(map[string]*UTXO),
+		spent:   make(map[string]bool),
+		deltas:  make(map[string]float64),
+	}
+}
+
+func (v *utxoView) get(op string) (*UTXO, bool) {
+	if v.spent[op] {
+		return nil, false
+	}
+	if u, ok := v.created[op]; ok {
+		return u, true
+	}
+	data := v.store.GetUTXO(op)
+	if data == nil {
+		return nil, false
+	}
+	var u UTXO
+	if json.Unmarshal(data, &u) != nil {
+		return nil, false
+	}
+	return &u, true
+}
+
+func (v *utxoView) add(u *UTXO) {
+	v.created[outpoint(u.TxID, u.Vout)] = u
+}
+
+// release pays out an unbond as output 0 of its unstake, which has no
+// outputs of its own.
+func (v *utxoView) release(u *Unbond, height uint64) {
+	v.add(&UTXO{TxID: u.TxID, Vout: 0, Address: u.Address, Amount: u.Amount, Height: height})
+	v.deltas[u.Address] += u.Amount
+}
+
+// changes returns the view as storage.BlockCommit.UTXOs. Outputs created
+// and spent within the view never reach the database.
+func (v *utxoView) changes() map[string][]byte {
+	out := make(map[string][]byte, len(v.created)+len(v.spent))
+	for op := range v.spent {
+		if _, ok := v.created[op]; !ok {
+			out[op] = nil
+		}
+	}
+	for op, u := range v.created {
+		if !v.spent[op] {
+			out[op], _ = json.Marshal(u)
+		}
+	}
+	return out
+}
+
+// connectTx checks tx against the view and applies it: inputs must be
+// unspent outputs owned by the sender, and inputs minus outputs must equal
+// utxoConsumed. A transfer's first output pays Amount to To; an unstake
+// may only pay its sender, and once unbonding is active pays nothing.
+func (v *utxoView) connectTx(tx *Transaction, height uint64) error {
+	switch tx.Type {
+	case "coinbase", "pos_reward":
+		if len(tx.Inputs) > 0 {
+			return fmt.Errorf("%s transaction cannot have inputs", tx.Type)
+		}
+	case "slash":
+		if len(tx.Inputs) > 0 || len(tx.Outputs) > 0 {
+			return fmt.Errorf("slash transaction cannot have inputs or outputs")
+		}
+	case "transfer", "stake", "unstake", "vote", "burn", "delegate":
+		if tx.Version < TxVersionCanonical {
+			return fmt.Errorf("utxo transactions must use version %d or later", TxVersionCanonical)
+		}
+		var in, out float64
+		seen := make(map[string]bool, len(tx.Inputs))
+		for _, input := range tx.Inputs {
+			op := outpoint(input.TxID, input.Vout)
+			if seen[op] {
+				return fmt.Errorf("input %s listed twice", op)
+			}
+			seen[op] = true
+			u, ok := v.get(op)
+			if !ok {
+				return fmt.Errorf("input %s is spent or unknown", op)
+			}
+			if u.Address != tx.From {
+				return fmt.Errorf("input %s does not belong to %s", op, tx.From)
+			}
+			if u.immature(height, v.maturity) {
+				return fmt.Errorf("input %s %w: it matures at height %d", op, ErrImmature, u.Height+v.maturity)
+			}
+			in += u.Amount
+		}
+		for _, o := range tx.Outputs {
+			if o.Amount <= 0 {
+				return fmt.Errorf("output amounts must be positive")
+			}
+			if tx.Type == "unstake" && v.unbonding {
+				return fmt.Errorf("unstake cannot have outputs: its coins are released after unbonding")
+			}
+			if tx.Type == "unstake" && o.Address != tx.From {
+				return fmt.Errorf("unstake outputs must pay the sender")
+			}
+			out += o.Amount
+		}
+		if tx.Type == "transfer" && (len(tx.Outputs) == 0 ||
+			tx.Outputs[0].Address != tx.To || tx.Outputs[0].Amount != tx.Amount) {
+			return fmt.Errorf("first output must pay the transfer amount to the recipient")
+		}
+		if want := utxoConsumed(tx, v.unbonding); math.Abs(in-out-want) > 0.00000001 {
+			return fmt.Errorf("inputs %.8f minus outputs %.8f must equal %.8f", in, out, want)
+		}
+		for _, input := range tx.Inputs {
+			op := outpoint(input.TxID, input.Vout)
+			u, _ := v.get(op)
+			v.spent[op] = true
+			v.deltas[u.Address] -= u.Amount
+		}
+	default:
+		return fmt.Errorf("transaction type %q is not supported on utxo networks", tx.Type)
+	}
+	for i := range tx.Outputs {
+		if _, exists := v.get(outpoint(tx.TxID, uint32(i))); exists {
+			return fmt.Errorf("duplicate transaction: output %d already unspent", i)
+		}
+	}
+	for i, o := range tx.Outputs {
+		v.add(&UTXO{TxID: tx.TxID, Vout: uint32(i), Address: o.Address, Amount: o.Amount, Height: height,
+			Coinbase: tx.Type == "coinbase" || tx.Type == "pos_reward"})
+		v.deltas[o.Address] += o.Amount
+	}
+	return nil
+}
+
+// connectUTXOs validates block's inputs and outputs against the UTXO set
+// and returns the staged view. The block at utxo_activation_height first
+// turns every account balance into a migration output; balances
+// themselves are unchanged by that step. The caller holds bc.mu.
+func (bc *Blockchain) connectUTXOs(block *Block) (*utxoView, error) {
+	height := block.Header.Height
+	view := newUTXOView(bc.Store)
+	view.unbonding = bc.Config.UnbondingActive(height)
+	view.maturity = bc.utxoMaturity(height)
+	if height > 0 && height == bc.Config.UTXOActivationHeight {
+		for _, u := range MigrationUTXOs(bc.Store.GetAllBalances(), height) {
+			u := u
+			view.add(&u)
+		}
+	}
+	for i := range block.Transactions {
+		tx := &block.Transactions[i]
+		if err := view.connectTx(tx, height); err != nil {
+			return nil, fmt.Errorf("tx %s: %w", tx.TxID, err)
+		}
+	}
+	return view, nil
+}
+
+// stampRewards makes reward transactions commit to the block height, so a
+// miner paid the same reward in the same second twice gets two distinct
+// outputs rather than a duplicate txid.
+func stampRewards(txs []Transaction, height uint64) {
+	for i := range txs {
+		if txs[i].Type == "coinbase" || txs[i].Type == "pos_reward" {
+			txs[i].Data = strconv.FormatUint(height, 10)
+			txs[i].TxID = txs[i].ComputeTxID()
+		}
+	}
+}
+
+// checkUTXOActivation refuses a manifest that activates the utxo model at
+// or below a tip the node connected without it.
+func (bc *Blockchain) checkUTXOActivation() error {
+	if bc.Config.TxModel != config.TxModelUTXO || bc.Store.GetMeta(metaUTXOActivated) != nil {
+		return nil
+	}
+	if tip := bc.Store.GetBestHeight(); tip >= 0 && bc.Config.UTXOActive(uint64(tip)) {
+		return fmt.Errorf("utxo_activation_height %d is not above the chain tip %d; "+
+			"choose a future height", bc.Config.UTXOActivationHeight, tip)
+	}
+	return nil
+}
+
+// checkMempoolInputs rejects tx if its inputs are already spent by a
+// pending transaction or don't cover it. The caller holds bc.mu.
+func (bc *Blockchain) checkMempoolInputs(tx *Transaction, height uint64) error {
+	var conflicts []string
+	var need, available float64
+	view := newUTXOView(bc.Store)
+	view.unbonding = bc.Config.UnbondingActive(height)
+	view.maturity = bc.utxoMaturity(height)
+	for _, input := range tx.Inputs {
+		op := outpoint(input.TxID, input.Vout)
+		u, ok := view.get(op)
+		if !ok {
+			continue // reported by connectTx
+		}
+		need += u.Amount
+		if id, ok := bc.Mempool.spentBy[op]; ok {
+			conflicts = append(conflicts, id)
+		} else {
+			available += u.Amount
+		}
+	}
+	if len(conflicts) > 0 {
+		sort.Strings(conflicts)
+		return &DoubleSpendError{TxID: tx.TxID, Conflicts: conflicts, Need: need, Available: available}
+	}
+	return view.connectTx(tx, height)
+}
+
+// spendableUTXOs returns address's confirmed outputs that no pending
+// transaction spends and that the next block may spend, oldest first. The
+// caller holds bc.mu.
+func (bc *Blockchain) spendableUTXOs(address string) []UTXO {
+	var utxos []UTXO
+	height := bc.Store.GetBlockCount()
+	maturity := bc.utxoMaturity(height)
+	for op, data := range bc.Store.GetUTXOsByAddress(address) {
+		var u UTXO
+		if _, pending := bc.Mempool.spentBy[op]; !pending && json.Unmarshal(data, &u) == nil &&
+			!u.immature(height, maturity) {
+			utxos = append(utxos, u)
+		}
+	}
+	sort.Slice(utxos, func(i, j int) bool {
+		if utxos[i].Height != utxos[j].Height {
+			return utxos[i].Height < utxos[j].Height
+		}
+		return outpoint(utxos[i].TxID, utxos[i].Vout) < outpoint(utxos[j].TxID, utxos[j].Vout)
+	})
+	return utxos
+}
+
+// ListUnspent returns address's confirmed unspent outputs that no pending
+// transaction spends, oldest first.
+func (bc *Blockchain) ListUnspent(address string) []UTXO {
+	bc.mu.RLock()
+	defer bc.mu.RUnlock()
+	return bc.spendableUTXOs(address)
+}
+
+// TxOut is an unspent output as reported by gettxout.
+type TxOut struct {
+	UTXO
+	Confirmations  uint64 `json:"confirmations"`
+	BestBlock      string `json:"bestblock"`
+	SpentInMempool bool   `json:"spent_in_mempool"`
+}
+
+// GetTxOut returns an unspent output. With includeMempool, an output a
+// pending transaction spends is reported as spent.
+func (bc *Blockchain) GetTxOut(txid string, vout uint32, includeMempool bool) (*TxOut, bool) {
+	bc.mu.RLock()
+	defer bc.mu.RUnlock()
+	op := outpoint(txid, vout)
+	u, ok := newUTXOView(bc.Store).get(op)
+	if !ok {
+		return nil, false
+	}
+	_, pending := bc.Mempool.spentBy[op]
+	if pending && includeMempool {
+		return nil, false
+	}
+	out := &TxOut{UTXO: *u, SpentInMempool: pending}
+	if bc.lastBlock != nil {
+		out.BestBlock = bc.lastBlock.Hash
+		out.Confirmations = bc.lastBlock.Header.Height - u.Height + 1
+	}
+	return out, true
+}
+
+// UTXOSetInfo summarises the UTXO set and checks it against the supply
+// counters. Every coin minted and not burned is an unspent output, staked
+// or unbonding, so Unaccounted should be zero on a utxo network.
+type UTXOSetInfo struct {
+	Height      uint64  `json:"height"`
+	BestBlock   string  `json:"bestblock"`
+	Outputs     int     `json:"outputs"`
+	Addresses   int     `json:"addresses"`
+	Total       float64 `json:"total"`
+	Staked      float64 `json:"staked"`
+	Unbonding   float64 `json:"unbonding"`
+	Circulating float64 `json:"circulating"` // minted minus burned
+	Unaccounted float64 `json:"unaccounted"` // circulating minus total, staked and unbonding
+	SetHash     string  `json:"hash_serialized"`
+}
+
+// GetUTXOSetInfo walks the UTXO set without consulting balances. The set
+// hash covers every outpoint, address and amount in key order, so two
+// nodes at the same tip can compare sets by hash.
+func (bc *Blockchain) GetUTXOSetInfo() UTXOSetInfo {
+	bc.mu.RLock()
+	defer bc.mu.RUnlock()
+	info := UTXOSetInfo{
+		Height:      uint64(max(bc.Store.GetBestHeight(), 0)),
+		Staked:      bc.Stakes.GetTotalStaked(),
+		Unbonding:   bc.unbondingTotal(),
+		Circulating: bc.TotalMinted - bc.TotalBurned,
+	}
+	if bc.lastBlock != nil {
+		info.BestBlock = bc.lastBlock.Hash
+	}
+	total := new(big.Int)
+	addrs := make(map[string]bool)
+	h := sha256.New()
+	var buf bytes.Buffer
+	bc.Store.ForEachUTXO(func(op string, data []byte) {
+		var u UTXO
+		if json.Unmarshal(data, &u) != nil {
+			return
+		}
+		info.Outputs++
+		addrs[u.Address] = true
+		units := bigUnits(u.Amount)
+		total.Add(total, units)
+		buf.Reset()
+		writeString(&buf, op)
+		writeString(&buf, u.Address)
+		writeString(&buf, units.String())
+		h.Write(buf.Bytes())
+	})
+	info.Addresses = len(addrs)
+	info.Total, _ = new(big.Float).Quo(new(big.Float).SetInt(total), big.NewFloat(UnitsPerCoin)).Float64()
+	info.Unaccounted = info.Circulating - info.Total - info.Staked - info.Unbonding
+	info.SetHash = hex.EncodeToString(h.Sum(nil))
+	return info
+}
+
+// FundTransaction fills in the inputs and outputs of tx on utxo networks
+// and recomputes its TxID; call it before signing. Without inputs the
+// sender's oldest spendable outputs are used; with them (coin control)
+// exactly those are spent. Any excess is paid to change, or back to the
+// sender if change is empty. On account networks it only rejects explicit
+// inputs and change addresses. Once nonce rules are active, a tx without a
+// nonce gets the sender's next one.
+func (bc *Blockchain) FundTransaction(tx *Transaction, inputs []TxInput, change string) error {
+	bc.mu.RLock()
+	defer bc.mu.RUnlock()
+	if tx.Nonce == 0 && needsSignature(tx) && bc.Config.NonceRulesActive(bc.Store.GetBlockCount()) {
+		tx.Nonce = bc.nextNonce(tx.From)
+		tx.TxID = tx.ComputeTxID()
+	}
+	if !bc.Config.UTXOActive(bc.Store.GetBlockCount()) {
+		if len(inputs) > 0 || change != "" {
+			return fmt.Errorf("inputs and change addresses can only be chosen on utxo networks")
+		}
+		return nil
+	}
+	unbonding := bc.Config.UnbondingActive(bc.Store.GetBlockCount())
+	if tx.Type == "unstake" && unbonding {
+		// The coins come back as an output once unbonding ends; the
+		// unstake itself spends and pays nothing.
+		if len(inputs) > 0 || change != "" {
+			return fmt.Errorf("an unstake spends no inputs while unbonding is active")
+		}
+		tx.Inputs, tx.Outputs = nil, nil
+		tx.TxID = tx.ComputeTxID()
+		return nil
+	}
+	if change == "" {
+		change = tx.From
+	} else if tx.Type == "unstake" && change != tx.From {
+		return fmt.Errorf("unstake change must return to the sender")
+	}
+
+	tx.Inputs, tx.Outputs = nil, nil
+	switch tx.Type {
+	case "transfer":
+		tx.Outputs = []TxOutput{{Address: tx.To, Amount: tx.Amount}}
+	case "unstake":
+		tx.Outputs = []TxOutput{{Address: tx.From, Amount: tx.Amount}}
+	}
+	need := utxoConsumed(tx, unbonding)
+	for _, o := range tx.Outputs {
+		need += o.Amount
+	}
+
+	var have float64
+	if len(inputs) > 0 {
+		view := newUTXOView(bc.Store)
+		for _, in := range inputs {
+			op := outpoint(in.TxID, in.Vout)
+			u, ok := view.get(op)
+			if !ok || u.Address != tx.From {
+				return fmt.Errorf("input %s is not an unspent output of %s", op, tx.From)
+			}
+			if _, pending := bc.Mempool.spentBy[op]; pending {
+				return fmt.Errorf("input %s is already spent by a pending transaction", op)
+			}
+			have += u.Amount
+		}
+		tx.Inputs = inputs
+	} else {
+		for _, u := range bc.spendableUTXOs(tx.From) {
+			if have+0.00000001 >= need {
+				break
+			}
+			tx.Inputs = append(tx.Inputs, TxInput{TxID: u.TxID, Vout: u.Vout})
+			have += u.Amount
+		}
+	}
+	if have+0.00000001 < need {
+		return fmt.Errorf("insufficient funds: %.8f in unspent outputs, need %.8f", have, need)
+	}
+	if excess := have - need; excess > 0.00000001 {
+		tx.Outputs = append(tx.Outputs, TxOutput{Address: change, Amount: excess})
+	}
+	tx.TxID = tx.ComputeTxID()
+	return nil
+}
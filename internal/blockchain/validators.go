@@ -0,0 +1,81 @@
+package blockchain
+
+// ValidatorWindow is how many recent blocks ValidatorStats measures
+// activity over.
+const ValidatorWindow = 1000
+
+// ValidatorStats describes how an address has done as a block producer
+// and staker. Blocks have no producer schedule, so there are no missed
+// slots to count: Participation is the share of recent blocks whose
+// pos_reward paid the address.
+type ValidatorStats struct {
+	Address    string  `json:"address"`
+	Stake      float64 `json:"stake"`
+	Eligible   bool    `json:"eligible"`    // own and delegated stake at or above the PoS threshold
+	StakeShare float64 `json:"stake_share"` // own and delegated, of the eligible stake, 0..1
+
+	Validator  string  `json:"validator,omitempty"` // delegated to
+	Delegated  float64 `json:"delegated"`           // stake delegated to the address
+	Delegators int     `json:"delegators"`
+
+	BlocksProduced  int     `json:"blocks_produced"`
+	LastProduced    uint64  `json:"last_produced"`    // 0 if none
+	ProducerRewards float64 `json:"producer_rewards"` // coinbases, fees included
+
+	RewardedBlocks int     `json:"rewarded_blocks"`
+	LastRewarded   uint64  `json:"last_rewarded"` // 0 if none
+	StakingRewards float64 `json:"staking_rewards"`
+
+	Window         uint64  `json:"window"` // recent blocks, up to ValidatorWindow
+	WindowProduced int     `json:"window_produced"`
+	WindowRewarded int     `json:"window_rewarded"`
+	Participation  float64 `json:"participation"` // window_rewarded / window
+}
+
+// GetValidatorStats returns address's production and staking record from
+// the reward indexes.
+func (bc *Blockchain) GetValidatorStats(address string) ValidatorStats {
+	bc.mu.RLock()
+	defer bc.mu.RUnlock()
+	count := bc.Store.GetBlockCount()
+	threshold := bc.govParam(ParamPOSMinThreshold, count)
+	st := ValidatorStats{
+		Address: address,
+		Stake:   bc.Stakes.GetStake(address),
+		Window:  min(count-1, ValidatorWindow), // the genesis block pays no one
+	}
+	st.Validator = bc.Stakes.delegatedTo(address)
+	st.Delegated, st.Delegators = bc.Stakes.delegations(address)
+	group := st.Stake + st.Delegated
+	st.Eligible = st.Validator == "" && st.Stake > 0 && group >= threshold
+	if eligible, _ := bc.Stakes.eligible(threshold); st.Eligible && eligible > 0 {
+		st.StakeShare = group / eligible
+	}
+	windowStart := count - st.Window
+
+	var units int64
+	for _, r := range bc.Store.Produced(address, 0, 0, nil) {
+		st.BlocksProduced++
+		st.LastProduced = r.Height
+		units += ToUnits(r.Amount)
+		if r.Height >= windowStart {
+			st.WindowProduced++
+		}
+	}
+	st.ProducerRewards = FromUnits(units)
+
+	units = 0
+	for _, r := range bc.Store.Rewards(address, 0, 0, nil) {
+		st.RewardedBlocks++
+		st.LastRewarded = r.Height
+		units += ToUnits(r.Amount)
+		if r.Height >= windowStart {
+			st.WindowRewarded++
+		}
+	}
+	st.StakingRewards = FromUnits(units)
+	if st.Window > 0 {
+		st.Participation = float64(st.WindowRewarded) / float64(st.Window)
+	}
+	return st
+}
@@ -0,0 +1,192 @@
+package blockchain
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"time"
+)
+
+// Startup verification levels. Each level includes the checks below it.
+const (
+	// CheckLevelBlocks reads every block, checks that it sits at its
+	// height, links to its parent and is indexed by hash.
+	CheckLevelBlocks = 0
+	// CheckLevelHeaders recomputes header hashes and checks proof of work
+	// and the stored chain work.
+	CheckLevelHeaders = 1
+	// CheckLevelTxs recomputes txids and merkle roots, checks the tx index,
+	// envelope signatures and block and transaction limits.
+	CheckLevelTxs = 2
+	// CheckLevelState checks the stored balances of every address the
+	// blocks touched: never negative and, on utxo networks, equal to the
+	// address's unspent outputs.
+	CheckLevelState = 3
+)
+
+// Defaults for Options.CheckBlocks and Options.CheckLevel.
+const (
+	DefaultCheckBlocks = 6
+	DefaultCheckLevel  = CheckLevelTxs
+)
+
+// verifyChain re-checks the last n stored blocks at level before the chain
+// is used, to catch on-disk corruption and partial writes. It returns the
+// first problem found.
+func (bc *Blockchain) verifyChain(n, level int) error {
+	if n <= 0 || bc.lastBlock == nil {
+		return nil
+	}
+	if level < CheckLevelBlocks || level > CheckLevelState {
+		return fmt.Errorf("check level %d out of range (%d to %d)", level, CheckLevelBlocks, CheckLevelState)
+	}
+	start := time.Now()
+	best := bc.lastBlock.Header.Height
+	var from uint64
+	if uint64(n) <= best {
+		from = best + 1 - uint64(n)
+	}
+	var prev *Block
+	var work []byte
+	if from > 0 {
+		b, err := bc.readBlock(from - 1)
+		if err != nil {
+			return err
+		}
+		prev, work = b, bc.Store.GetChainWork(from-1)
+	}
+	touched := make(map[string]bool)
+	for h := from; h <= best; h++ {
+		b, err := bc.readBlock(h)
+		if err != nil {
+			return err
+		}
+		if work, err = bc.verifyStoredBlock(b, prev, work, level); err != nil {
+			return fmt.Errorf("block %d: %w", h, err)
+		}
+		if level >= CheckLevelState {
+			for _, tx := range b.Transactions {
+				for _, addr := range []string{tx.From, tx.To} {
+					if addr != "" {
+						touched[addr] = true
+					}
+				}
+				for _, out := range tx.Outputs {
+					touched[out.Address] = true
+				}
+			}
+		}
+		prev = b
+	}
+	if level >= CheckLevelState {
+		if err := bc.verifyBalances(touched); err != nil {
+			return err
+		}
+	}
+	logger.Info("verified chain", "blocks", best-from+1, "level", level,
+		"elapsed", time.Since(start).Round(time.Millisecond))
+	return nil
+}
+
+// readBlock decodes the stored block at height.
+func (bc *Blockchain) readBlock(height uint64) (*Block, error) {
+	data, err := bc.Store.GetBlockByHeight(height)
+	if err != nil {
+		return nil, fmt.Errorf("block %d: %w", height, err)
+	}
+	var b Block
+	if err := json.Unmarshal(data, &b); err != nil {
+		return nil, fmt.Errorf("block %d: undecodable: %w", height, err)
+	}
+	return &b, nil
+}
+
+// verifyStoredBlock checks block against its parent prev (nil for
+// genesis) and the parent's stored chain work, and returns block's
+// stored chain work.
+func (bc *Blockchain) verifyStoredBlock(block, prev *Block, prevWork []byte, level int) ([]byte, error) {
+	work := bc.Store.GetChainWork(block.Header.Height)
+	if prev != nil {
+		if block.Header.Height != prev.Header.Height+1 {
+			return nil, fmt.Errorf("stored under the wrong height %d", block.Header.Height)
+		}
+		if block.Header.PrevHash != prev.Hash {
+			return nil, fmt.Errorf("prev hash %s does not match block %d", block.Header.PrevHash, prev.Header.Height)
+		}
+	}
+	indexed, _ := bc.Store.GetBlockByHash(block.Hash)
+	stored, _ := bc.Store.GetBlockByHeight(block.Header.Height)
+	if indexed == nil || !bytes.Equal(indexed, stored) {
+		return nil, fmt.Errorf("hash index missing or wrong for %s", block.Hash)
+	}
+	if level < CheckLevelHeaders {
+		return work, nil
+	}
+	if computed := block.Header.ComputeHash(); computed != block.Hash {
+		return nil, fmt.Errorf("bad hash: computed %s, stored %s", computed, block.Hash)
+	}
+	if block.Header.Height > 0 && bc.Config.ConsensusType != ConsensusPoS {
+		if err := (powConsensus{bc}).VerifySeal(block); err != nil {
+			return nil, err
+		}
+	}
+	want := BlockWork(block.Header.Bits)
+	if prev != nil {
+		want.Add(want, new(big.Int).SetBytes(prevWork))
+	}
+	if !bytes.Equal(work, want.Bytes()) {
+		return nil, fmt.Errorf("stored chain work %x, expected %s", work, FormatChainWork(want))
+	}
+	if level < CheckLevelTxs {
+		return work, nil
+	}
+	if err := checkTxCommitments(block); err != nil {
+		return nil, err
+	}
+	for i := range block.Transactions {
+		tx := &block.Transactions[i]
+		if _, err := bc.Store.GetTxBlockHeight(tx.TxID); err != nil {
+			return nil, fmt.Errorf("tx %s not indexed", tx.TxID)
+		}
+		if err := bc.checkSignature(tx, block.Header.Height); err != nil {
+			return nil, fmt.Errorf("tx %s: %w", tx.TxID, err)
+		}
+		if err := bc.checkTxLimits(tx, block.Header.Height); err != nil {
+			return nil, fmt.Errorf("tx %s: %w", tx.TxID, err)
+		}
+	}
+	if uint64(len(block.Transactions)) > bc.Config.MaxBlockTransactions {
+		return nil, fmt.Errorf("too many transactions: %d > %d", len(block.Transactions), bc.Config.MaxBlockTransactions)
+	}
+	if size := block.Size(); uint64(size) > bc.Config.MaxBlockSize {
+		return nil, fmt.Errorf("block too large: %d bytes > %d", size, bc.Config.MaxBlockSize)
+	}
+	return work, nil
+}
+
+// verifyBalances checks the stored balances of addrs.
+func (bc *Blockchain) verifyBalances(addrs map[string]bool) error {
+	utxo := bc.Config.UTXOActive(bc.lastBlock.Header.Height)
+	for addr := range addrs {
+		bal := ToUnits(bc.Store.GetBalance(addr))
+		if bal < 0 {
+			return fmt.Errorf("address %s: negative balance %v", addr, FromUnits(bal))
+		}
+		if !utxo {
+			continue
+		}
+		var sum int64
+		for op, data := range bc.Store.GetUTXOsByAddress(addr) {
+			var u UTXO
+			if err := json.Unmarshal(data, &u); err != nil {
+				return fmt.Errorf("utxo %s: undecodable: %w", op, err)
+			}
+			sum += ToUnits(u.Amount)
+		}
+		if sum != bal {
+			return fmt.Errorf("address %s: balance %v but unspent outputs total %v", addr, FromUnits(bal), FromUnits(sum))
+		}
+	}
+	return nil
+}
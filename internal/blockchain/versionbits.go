@@ -0,0 +1,194 @@
+package blockchain
+
+import (
+	"sort"
+	"sync"
+)
+
+// Block versions signaling deployments carry VersionBitsTopBits in their
+// top three bits and set the bit of each deployment they support. Blocks
+// without any deployment to signal keep version 2.
+const (
+	VersionBitsTopBits uint32 = 0x20000000
+	VersionBitsTopMask uint32 = 0xe0000000
+)
+
+// DeploymentState is where a deployment is in activation. Every block of
+// a signaling window shares one state.
+type DeploymentState string
+
+const (
+	DeploymentDefined  DeploymentState = "defined"   // before start_height
+	DeploymentStarted  DeploymentState = "started"   // signaling counts
+	DeploymentLockedIn DeploymentState = "locked_in" // active from the next window
+	DeploymentActive   DeploymentState = "active"
+	DeploymentFailed   DeploymentState = "failed" // timed out
+)
+
+// windowState is a deployment's state in one window and the height of the
+// window it was entered in.
+type windowState struct {
+	State DeploymentState
+	Since uint64
+}
+
+// versionBits caches each deployment's state by window start. Windows only
+// depend on the blocks before them, so entries stay valid as the chain
+// grows.
+type versionBits struct {
+	mu     sync.Mutex
+	states map[string]map[uint64]windowState
+}
+
+// signals reports whether a block version signals bit.
+func signals(version uint32, bit uint) bool {
+	return version&VersionBitsTopMask == VersionBitsTopBits && version&(1<<bit) != 0
+}
+
+// deploymentState returns the state of deployment name for a block at
+// height, walking back to the last cached window and forward again. The
+// caller holds bc.mu.
+func (bc *Blockchain) deploymentState(name string, height uint64) windowState {
+	d := bc.Config.Deployments[name]
+	window := bc.Config.VersionBitsWindow
+	vb := &bc.versionBits
+	vb.mu.Lock()
+	defer vb.mu.Unlock()
+	if vb.states == nil {
+		vb.states = make(map[string]map[uint64]windowState)
+	}
+	cache := vb.states[name]
+	if cache == nil {
+		cache = make(map[uint64]windowState)
+		vb.states[name] = cache
+	}
+
+	start := height - height%window
+	var todo []uint64
+	for {
+		if _, ok := cache[start]; ok {
+			break
+		}
+		if start == 0 {
+			cache[0] = windowState{State: DeploymentDefined}
+			break
+		}
+		todo = append(todo, start)
+		start -= window
+	}
+	for i := len(todo) - 1; i >= 0; i-- {
+		start := todo[i]
+		st := cache[start-window]
+		switch st.State {
+		case DeploymentDefined:
+			if start >= d.StartHeight {
+				st = windowState{State: DeploymentStarted, Since: start}
+			}
+		case DeploymentStarted:
+			if bc.countSignals(start-window, start, d.Bit) >= bc.Config.VersionBitsThreshold {
+				st = windowState{State: DeploymentLockedIn, Since: start}
+			} else if start >= d.TimeoutHeight {
+				st = windowState{State: DeploymentFailed, Since: start}
+			}
+		case DeploymentLockedIn:
+			st = windowState{State: DeploymentActive, Since: start}
+		}
+		cache[start] = st
+	}
+	return cache[height-height%window]
+}
+
+// countSignals counts the blocks from height from up to to that signal
+// bit. The caller holds bc.mu.
+func (bc *Blockchain) countSignals(from, to uint64, bit uint) uint64 {
+	var n uint64
+	for h := from; h < to; h++ {
+		if b := bc.loadBlock(h); b != nil && signals(b.Header.Version, bit) {
+			n++
+		}
+	}
+	return n
+}
+
+// deploymentActive reports whether the rules of deployment name apply to
+// a block at height; rules gated on a deployment check this. The caller
+// holds bc.mu.
+func (bc *Blockchain) deploymentActive(name string, height uint64) bool {
+	if _, ok := bc.Config.Deployments[name]; !ok {
+		return false
+	}
+	return bc.deploymentState(name, height).State == DeploymentActive
+}
+
+// blockVersion returns the header version for a new block at height:
+// signaling every deployment started or locked in, or 2 if there is none.
+// The caller holds bc.mu.
+func (bc *Blockchain) blockVersion(height uint64) uint32 {
+	version := VersionBitsTopBits
+	for name, d := range bc.Config.Deployments {
+		switch bc.deploymentState(name, height).State {
+		case DeploymentStarted, DeploymentLockedIn:
+			version |= 1 << d.Bit
+		}
+	}
+	if version == VersionBitsTopBits {
+		return 2
+	}
+	return version
+}
+
+// DeploymentInfo is one deployment's parameters and its state for the
+// next block.
+type DeploymentInfo struct {
+	Name          string            `json:"name"`
+	Bit           uint              `json:"bit"`
+	StartHeight   uint64            `json:"start_height"`
+	TimeoutHeight uint64            `json:"timeout_height"`
+	State         DeploymentState   `json:"state"`
+	Since         uint64            `json:"since"` // height the state began at
+	Signals       *DeploymentSignal `json:"signals,omitempty"`
+}
+
+// DeploymentSignal counts the signaling in the current window of a
+// started deployment.
+type DeploymentSignal struct {
+	WindowStart uint64 `json:"window_start"`
+	Elapsed     uint64 `json:"elapsed"` // blocks of the window so far
+	Count       uint64 `json:"count"`   // of them signaling
+	Threshold   uint64 `json:"threshold"`
+	Possible    bool   `json:"possible"` // the threshold can still be reached this window
+}
+
+// VersionBitsInfo reports every deployment's state for the next block.
+type VersionBitsInfo struct {
+	Height      uint64           `json:"height"` // of the next block
+	Window      uint64           `json:"window"`
+	Threshold   uint64           `json:"threshold"`
+	Deployments []DeploymentInfo `json:"deployments"`
+}
+
+// GetDeploymentInfo returns the state of each deployment for the next
+// block, with the current window's signaling for started ones.
+func (bc *Blockchain) GetDeploymentInfo() *VersionBitsInfo {
+	bc.mu.RLock()
+	defer bc.mu.RUnlock()
+	cfg := bc.Config
+	height := bc.Store.GetBlockCount()
+	info := &VersionBitsInfo{Height: height, Window: cfg.VersionBitsWindow,
+		Threshold: cfg.VersionBitsThreshold, Deployments: []DeploymentInfo{}}
+	for name, d := range cfg.Deployments {
+		st := bc.deploymentState(name, height)
+		di := DeploymentInfo{Name: name, Bit: d.Bit, StartHeight: d.StartHeight,
+			TimeoutHeight: d.TimeoutHeight, State: st.State, Since: st.Since}
+		if st.State == DeploymentStarted {
+			start := height - height%cfg.VersionBitsWindow
+			sig := &DeploymentSignal{WindowStart: start, Elapsed: height - start,
+				Count: bc.countSignals(start, height, d.Bit), Threshold: cfg.VersionBitsThreshold}
+			sig.Possible = sig.Count+cfg.VersionBitsWindow-sig.Elapsed >= sig.Threshold
+			di.Signals = sig
+		}
+		info.Deployments = append(info.Deployments, di)
+	}
+	sort.Slice(info.Deployments, func(i, j int) bool { return info.Deployments[i].Bit < info.Deployments[j].Bit })
+	return info
+}
@@ -1,8 +1,16 @@
 package config
 
 import (
+	"crypto/sha256"
+	"devinsidercoin/networks"
+	"encoding/hex"
 	"encoding/json"
+	"fmt"
+	"math/big"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 )
 
 // NetworkConfig holds all network parameters loaded from JSON manifest.
@@ -22,6 +30,7 @@ type NetworkConfig struct {
 	MinDifficultyBits        uint32  `json:"min_difficulty_bits"`
 	GenesisTimestamp         string  `json:"genesis_timestamp"`
 	GenesisMessage           string  `json:"genesis_message"`
+	GenesisHash              string  `json:"genesis_hash,omitempty"` // expected genesis block hash; nodes refuse to start on mismatch
 	P2PPort                  int     `json:"p2p_port"`
 	RPCPort                  int     `json:"rpc_port"`
 	AddressPrefix            string  `json:"address_prefix"`
@@ -32,6 +41,167 @@ type NetworkConfig struct {
 	MaxBlockTransactions     uint64  `json:"max_block_transactions"`
 	POSMinThreshold          float64 `json:"pos_min_threshold"`
 	DifficultyEpochBlocks    uint64  `json:"difficulty_epoch_blocks"`
+	DifficultyFloorCurve     string  `json:"difficulty_floor_curve,omitempty"` // geometric (default), linear or none
+	DifficultyFloorRatio     float64 `json:"difficulty_floor_ratio,omitempty"` // per-epoch step; 0 = curve default
+	Regtest                  bool    `json:"regtest,omitempty"`
+	PowNoRetargeting         bool    `json:"pow_no_retargeting,omitempty"`
+	FeePolicy                string  `json:"fee_policy,omitempty"`
+	GovernancePeriod         uint64  `json:"governance_period,omitempty"`      // 0 disables on-chain votes
+	TxModel                  string  `json:"tx_model,omitempty"`               // account (default) or utxo
+	UTXOActivationHeight     uint64  `json:"utxo_activation_height,omitempty"` // height the utxo model starts at
+	TimestampRulesHeight     uint64  `json:"timestamp_rules_height,omitempty"` // 0 disables; see TimestampRulesActive
+	AddressRulesHeight       uint64  `json:"address_rules_height,omitempty"`   // 0 disables; see AddressRulesActive
+	UnbondingHeight          uint64  `json:"unbonding_height,omitempty"`       // 0 disables; see UnbondingActive
+	SignatureRulesHeight     uint64  `json:"signature_rules_height,omitempty"` // 0 disables; see SignatureRulesActive
+	NonceRulesHeight         uint64  `json:"nonce_rules_height,omitempty"`     // 0 disables; see NonceRulesActive
+	MaturityRulesHeight      uint64  `json:"maturity_rules_height,omitempty"`  // 0 disables; see MaturityActive
+	CoinbaseMaturity         uint64  `json:"coinbase_maturity,omitempty"`      // blocks before rewards can be spent
+	TxLimitsHeight           uint64  `json:"tx_limits_height,omitempty"`       // 0 disables; see TxLimitsActive
+	MaxTxSize                uint64  `json:"max_tx_size,omitempty"`            // canonical bytes; 0 = no limit
+	MaxTxOutputs             uint64  `json:"max_tx_outputs,omitempty"`         // 0 = no limit
+	RewardRulesHeight        uint64  `json:"reward_rules_height,omitempty"`    // 0 disables; see RewardRulesActive
+	FinalityInterval         uint64  `json:"finality_interval,omitempty"`      // blocks between finality checkpoints; 0 disables
+	StakeLockHeight          uint64  `json:"stake_lock_height,omitempty"`      // 0 disables; see StakeLockActive
+	SlashingHeight           uint64  `json:"slashing_height,omitempty"`        // 0 disables; see SlashingActive
+	SlashPercent             float64 `json:"slash_percent,omitempty"`          // of the offender's stake burned per double-sign
+	DelegationHeight         uint64  `json:"delegation_height,omitempty"`      // 0 disables; see DelegationActive
+	DelegationCommission     float64 `json:"delegation_commission,omitempty"`  // percent of delegators' PoS rewards paid to their validator
+	BalanceRulesHeight       uint64  `json:"balance_rules_height,omitempty"`   // 0 disables; see BalanceRulesActive
+
+	// Soft forks activated by miners signaling in the block version; see
+	// Deployment.
+	VersionBitsWindow    uint64                `json:"version_bits_window,omitempty"`    // blocks per signaling window
+	VersionBitsThreshold uint64                `json:"version_bits_threshold,omitempty"` // signaling blocks in a window to lock in
+	Deployments          map[string]Deployment `json:"deployments,omitempty"`
+
+	// Mempool policy (not consensus).
+	MaxPendingPerSender  int     `json:"max_pending_per_sender,omitempty"`
+	MinOutputAmount      float64 `json:"min_output_amount,omitempty"`
+	MempoolExpiryHours   int     `json:"mempool_expiry_hours,omitempty"`
+	PriorityBlockPercent int     `json:"priority_block_percent,omitempty"` // negative disables
+	MinRelayFee          float64 `json:"min_relay_fee,omitempty"`
+	MaxMempoolTxs        int     `json:"max_mempool_txs,omitempty"`
+
+	// Wallet APIs answer "node syncing" until the best chain reaches both
+	// (not consensus).
+	MinChainWork   string `json:"min_chain_work,omitempty"` // hex cumulative work
+	MinChainHeight uint64 `json:"min_chain_height,omitempty"`
+
+	// Known block hashes by height (not consensus): a chain with another
+	// block at one of these heights is refused, and blocks below the
+	// highest skip signature checks while syncing.
+	Checkpoints map[uint64]string `json:"checkpoints,omitempty"`
+
+	GenesisAllocations []GenesisAllocation `json:"genesis_allocations,omitempty"`
+}
+
+// Transaction models accepted in the tx_model field.
+const (
+	TxModelAccount = "account"
+	TxModelUTXO    = "utxo"
+)
+
+// UTXOActive reports whether blocks at height use the utxo model.
+func (c *NetworkConfig) UTXOActive(height uint64) bool {
+	return c.TxModel == TxModelUTXO && height >= c.UTXOActivationHeight
+}
+
+// TimestampRulesActive reports whether blocks at height must have a
+// timestamp above median-time-past and the exact retarget bits, and
+// whether retargeting clips per-block solve times.
+func (c *NetworkConfig) TimestampRulesActive(height uint64) bool {
+	return c.TimestampRulesHeight > 0 && height >= c.TimestampRulesHeight
+}
+
+// AddressRulesActive reports whether transactions in blocks at height must
+// only name addresses that decode with the network's prefix and checksum.
+func (c *NetworkConfig) AddressRulesActive(height uint64) bool {
+	return c.AddressRulesHeight > 0 && height >= c.AddressRulesHeight
+}
+
+// UnbondingActive reports whether unstakes confirmed in blocks at height
+// queue their coins for StakeLockBlocks blocks instead of releasing them
+// at once, and must not exceed the sender's stake.
+func (c *NetworkConfig) UnbondingActive(height uint64) bool {
+	return c.UnbondingHeight > 0 && height >= c.UnbondingHeight
+}
+
+// SignatureRulesActive reports whether transactions with a sender in
+// blocks at height must carry a verifiable envelope signature.
+func (c *NetworkConfig) SignatureRulesActive(height uint64) bool {
+	return c.SignatureRulesHeight > 0 && height >= c.SignatureRulesHeight
+}
+
+// NonceRulesActive reports whether transactions with a sender in blocks at
+// height must carry a nonce above the sender's last confirmed one, so that
+// a confirmed transaction cannot be replayed.
+func (c *NetworkConfig) NonceRulesActive(height uint64) bool {
+	return c.NonceRulesHeight > 0 && height >= c.NonceRulesHeight
+}
+
+// MaturityActive reports whether transactions in blocks at height may only
+// spend coinbase and pos_reward payments CoinbaseMaturity blocks old or
+// older.
+func (c *NetworkConfig) MaturityActive(height uint64) bool {
+	return c.CoinbaseMaturity > 0 && c.MaturityRulesHeight > 0 && height >= c.MaturityRulesHeight
+}
+
+// TxLimitsActive reports whether transactions with a sender in blocks at
+// height must stay within MaxTxSize and MaxTxOutputs.
+func (c *NetworkConfig) TxLimitsActive(height uint64) bool {
+	return c.TxLimitsHeight > 0 && height >= c.TxLimitsHeight
+}
+
+// RewardRulesActive reports whether blocks at height must pay the stakers'
+// share to exactly the stakers and amounts CalcPOSRewards gives.
+func (c *NetworkConfig) RewardRulesActive(height uint64) bool {
+	return c.RewardRulesHeight > 0 && height >= c.RewardRulesHeight
+}
+
+// StakeLockActive reports whether unstakes in blocks at height must wait
+// StakeLockBlocks blocks after the sender last staked.
+func (c *NetworkConfig) StakeLockActive(height uint64) bool {
+	return c.StakeLockHeight > 0 && height >= c.StakeLockHeight
+}
+
+// SlashingActive reports whether blocks at height may carry slash
+// transactions, which burn SlashPercent of the stake of an address that
+// attested two blocks at one checkpoint height.
+func (c *NetworkConfig) SlashingActive(height uint64) bool {
+	return c.SlashingHeight > 0 && height >= c.SlashingHeight
+}
+
+// DelegationActive reports whether blocks at height may carry delegate
+// transactions, which stake coins behind another address's stake.
+func (c *NetworkConfig) DelegationActive(height uint64) bool {
+	return c.DelegationHeight > 0 && height >= c.DelegationHeight
+}
+
+// BalanceRulesActive reports whether blocks at height may not leave a
+// sender with a negative balance nor unstake more than is staked.
+func (c *NetworkConfig) BalanceRulesActive(height uint64) bool {
+	return c.BalanceRulesHeight > 0 && height >= c.BalanceRulesHeight
+}
+
+// Deployment is a soft fork activated by version bits signaling. From the
+// first window at or after StartHeight, blocks whose version sets Bit
+// signal readiness; once VersionBitsThreshold blocks of a window signal,
+// the deployment locks in and is active a window later. A deployment not
+// locked in by the window starting at TimeoutHeight fails.
+type Deployment struct {
+	Bit           uint   `json:"bit"` // 0 to 28
+	StartHeight   uint64 `json:"start_height"`
+	TimeoutHeight uint64 `json:"timeout_height"`
+}
+
+// MaxDeploymentBit is the highest version bit a deployment can use; the
+// top three bits mark versions that signal.
+const MaxDeploymentBit = 28
+
+// GenesisAllocation is a premine output paid in the genesis block.
+type GenesisAllocation struct {
+	Address string  `json:"address"`
+	Amount  float64 `json:"amount"`
 }
 
 // LoadConfig reads a network configuration from a JSON file.
@@ -40,6 +210,21 @@ func LoadConfig(path string) (*NetworkConfig, error) {
 	if err != nil {
 		return nil, err
 	}
+	return ParseConfig(data)
+}
+
+// LoadNetwork returns one of the built-in network configurations
+// (mainnet, testnet, regtest) embedded in the binary.
+func LoadNetwork(name string) (*NetworkConfig, error) {
+	data, err := networks.FS.ReadFile(name + ".json")
+	if err != nil {
+		return nil, fmt.Errorf("unknown network %q (use -config for custom networks)", name)
+	}
+	return ParseConfig(data)
+}
+
+// ParseConfig decodes a JSON manifest and fills in defaults.
+func ParseConfig(data []byte) (*NetworkConfig, error) {
 	var cfg NetworkConfig
 	if err := json.Unmarshal(data, &cfg); err != nil {
 		return nil, err
@@ -57,5 +242,252 @@ func LoadConfig(path string) (*NetworkConfig, error) {
 	if cfg.DifficultyEpochBlocks == 0 {
 		cfg.DifficultyEpochBlocks = 500000
 	}
+	if _, err := time.Parse(time.RFC3339, cfg.GenesisTimestamp); err != nil {
+		return nil, fmt.Errorf("invalid genesis_timestamp %q (want RFC3339)", cfg.GenesisTimestamp)
+	}
+	if cfg.GenesisHash != "" {
+		if !isBlockHash(cfg.GenesisHash) {
+			return nil, fmt.Errorf("invalid genesis_hash %q", cfg.GenesisHash)
+		}
+	}
+	for height, hash := range cfg.Checkpoints {
+		if !isBlockHash(hash) {
+			return nil, fmt.Errorf("invalid checkpoint %q at height %d", hash, height)
+		}
+	}
+	if _, err := ParseChainWork(cfg.MinChainWork); err != nil {
+		return nil, fmt.Errorf("invalid min_chain_work: %w", err)
+	}
+	if err := cfg.checkDeployments(); err != nil {
+		return nil, err
+	}
+	if cfg.SlashingHeight > 0 && (cfg.SlashPercent <= 0 || cfg.SlashPercent > 100 || cfg.FinalityInterval == 0) {
+		return nil, fmt.Errorf("slashing_height needs slash_percent from 0 to 100 and a finality_interval")
+	}
+	if cfg.DelegationCommission < 0 || cfg.DelegationCommission > 100 {
+		return nil, fmt.Errorf("delegation_commission %v is not a percentage", cfg.DelegationCommission)
+	}
+	switch cfg.FeePolicy {
+	case "", "burn", "miner":
+	default:
+		return nil, fmt.Errorf("invalid fee_policy %q (want burn or miner)", cfg.FeePolicy)
+	}
+	switch cfg.DifficultyFloorCurve {
+	case "", "geometric", "linear", "none":
+	default:
+		return nil, fmt.Errorf("invalid difficulty_floor_curve %q (want geometric, linear or none)", cfg.DifficultyFloorCurve)
+	}
+	if cfg.DifficultyFloorRatio < 0 || (cfg.DifficultyFloorCurve != "linear" && cfg.DifficultyFloorRatio != 0 && cfg.DifficultyFloorRatio <= 1) {
+		return nil, fmt.Errorf("invalid difficulty_floor_ratio %v (geometric needs > 1, linear > 0)", cfg.DifficultyFloorRatio)
+	}
+	switch cfg.TxModel {
+	case "", TxModelAccount, TxModelUTXO:
+	default:
+		return nil, fmt.Errorf("invalid tx_model %q (want account or utxo)", cfg.TxModel)
+	}
+	if cfg.MaxPendingPerSender == 0 {
+		cfg.MaxPendingPerSender = 64
+	}
+	if cfg.MinOutputAmount == 0 {
+		cfg.MinOutputAmount = 0.00001
+	}
+	if cfg.PriorityBlockPercent == 0 {
+		cfg.PriorityBlockPercent = 5
+	}
+	if cfg.MaxMempoolTxs == 0 {
+		cfg.MaxMempoolTxs = 50000
+	}
+	if cfg.MempoolExpiryHours == 0 {
+		cfg.MempoolExpiryHours = 336 // two weeks
+	}
 	return &cfg, nil
 }
+
+// checkDeployments validates the version bits parameters: a window and
+// threshold, and one bit and a start before the timeout per deployment.
+func (c *NetworkConfig) checkDeployments() error {
+	if len(c.Deployments) == 0 {
+		return nil
+	}
+	if c.VersionBitsWindow == 0 || c.VersionBitsThreshold == 0 || c.VersionBitsThreshold > c.VersionBitsWindow {
+		return fmt.Errorf("deployments need version_bits_window > 0 and version_bits_threshold from 1 to the window")
+	}
+	bits := make(map[uint]string)
+	for name, d := range c.Deployments {
+		if d.Bit > MaxDeploymentBit {
+			return fmt.Errorf("deployment %s: bit %d above %d", name, d.Bit, MaxDeploymentBit)
+		}
+		if other, ok := bits[d.Bit]; ok {
+			return fmt.Errorf("deployments %s and %s share bit %d", name, other, d.Bit)
+		}
+		bits[d.Bit] = name
+		if d.TimeoutHeight <= d.StartHeight {
+			return fmt.Errorf("deployment %s: timeout_height must be above start_height", name)
+		}
+	}
+	return nil
+}
+
+// ParseChainWork parses cumulative chain work written as up to 64 hex
+// digits. The empty string is zero work.
+func ParseChainWork(s string) (*big.Int, error) {
+	if s == "" {
+		return new(big.Int), nil
+	}
+	work, ok := new(big.Int).SetString(s, 16)
+	if !ok || len(s) > 64 || work.Sign() < 0 {
+		return nil, fmt.Errorf("%q is not up to 64 hex digits", s)
+	}
+	return work, nil
+}
+
+// isBlockHash reports whether s is a block hash: 64 hex digits.
+func isBlockHash(s string) bool {
+	b, err := hex.DecodeString(s)
+	return err == nil && len(b) == 32
+}
+
+// ParseCheckpoints parses comma-separated height:hash pairs.
+func ParseCheckpoints(s string) (map[uint64]string, error) {
+	checkpoints := make(map[uint64]string)
+	for _, pair := range strings.Split(s, ",") {
+		h, hash, ok := strings.Cut(strings.TrimSpace(pair), ":")
+		height, err := strconv.ParseUint(h, 10, 64)
+		if !ok || err != nil || !isBlockHash(hash) {
+			return nil, fmt.Errorf("%q is not height:hash", pair)
+		}
+		checkpoints[height] = hash
+	}
+	return checkpoints, nil
+}
+
+// LastCheckpoint returns the highest checkpoint height, or 0 without
+// checkpoints.
+func (c *NetworkConfig) LastCheckpoint() uint64 {
+	var last uint64
+	for height := range c.Checkpoints {
+		last = max(last, height)
+	}
+	return last
+}
+
+// ConsensusHash returns a SHA-256 over every field that affects block
+// validity or emission. The fields up to GenesisAllocations are the
+// original set and are always hashed. Any parameter added since must be
+// added here tagged omitempty, so that chains that leave it unset keep
+// their recorded hash.
+func (c *NetworkConfig) ConsensusHash() string {
+	data, _ := json.Marshal(struct {
+		NetworkID                uint32
+		Algorithm                string
+		ConsensusType            string
+		BlockTimeSeconds         int
+		InitialReward            float64
+		POWRewardShare           float64
+		POSRewardShare           float64
+		HalvingInterval          uint64
+		MaxSupply                float64
+		DifficultyAdjustInterval uint64
+		MinDifficultyBits        uint32
+		GenesisTimestamp         string
+		AddressPrefix            string
+		MinStakeAmount           float64
+		StakeLockBlocks          uint64
+		MaxBlockSize             uint64
+		MaxBlockTransactions     uint64
+		POSMinThreshold          float64
+		DifficultyEpochBlocks    uint64
+		PowNoRetargeting         bool
+		GenesisAllocations       []GenesisAllocation
+		FeePolicy                string  `json:",omitempty"`
+		GovernancePeriod         uint64  `json:",omitempty"`
+		TxModel                  string  `json:",omitempty"`
+		UTXOActivationHeight     uint64  `json:",omitempty"`
+		TimestampRulesHeight     uint64  `json:",omitempty"`
+		AddressRulesHeight       uint64  `json:",omitempty"`
+		UnbondingHeight          uint64  `json:",omitempty"`
+		SignatureRulesHeight     uint64  `json:",omitempty"`
+		NonceRulesHeight         uint64  `json:",omitempty"`
+		MaturityRulesHeight      uint64  `json:",omitempty"`
+		CoinbaseMaturity         uint64  `json:",omitempty"`
+		TxLimitsHeight           uint64  `json:",omitempty"`
+		MaxTxSize                uint64  `json:",omitempty"`
+		MaxTxOutputs             uint64  `json:",omitempty"`
+		RewardRulesHeight        uint64  `json:",omitempty"`
+		FinalityInterval         uint64  `json:",omitempty"`
+		StakeLockHeight          uint64  `json:",omitempty"`
+		SlashingHeight           uint64  `json:",omitempty"`
+		SlashPercent             float64 `json:",omitempty"`
+		DelegationHeight         uint64  `json:",omitempty"`
+		DelegationCommission     float64 `json:",omitempty"`
+		BalanceRulesHeight       uint64  `json:",omitempty"`
+		DifficultyFloorCurve     string  `json:",omitempty"`
+		DifficultyFloorRatio     float64 `json:",omitempty"`
+
+		VersionBitsWindow    uint64                `json:",omitempty"`
+		VersionBitsThreshold uint64                `json:",omitempty"`
+		Deployments          map[string]Deployment `json:",omitempty"`
+	}{
+		c.NetworkID, c.Algorithm, c.ConsensusType, c.BlockTimeSeconds,
+		c.InitialReward, c.POWRewardShare, c.POSRewardShare, c.HalvingInterval,
+		c.MaxSupply, c.DifficultyAdjustInterval, c.MinDifficultyBits,
+		c.GenesisTimestamp, c.AddressPrefix, c.MinStakeAmount, c.StakeLockBlocks,
+		c.MaxBlockSize, c.MaxBlockTransactions, c.POSMinThreshold,
+		c.DifficultyEpochBlocks, c.PowNoRetargeting, c.GenesisAllocations,
+		c.FeePolicy, c.GovernancePeriod, c.TxModel, c.UTXOActivationHeight,
+		c.TimestampRulesHeight, c.AddressRulesHeight, c.UnbondingHeight,
+		c.SignatureRulesHeight, c.NonceRulesHeight, c.MaturityRulesHeight, c.CoinbaseMaturity, c.TxLimitsHeight, c.MaxTxSize, c.MaxTxOutputs, c.RewardRulesHeight, c.FinalityInterval, c.StakeLockHeight, c.SlashingHeight, c.SlashPercent, c.DelegationHeight, c.DelegationCommission, c.BalanceRulesHeight, c.DifficultyFloorCurve, c.DifficultyFloorRatio,
+		c.VersionBitsWindow, c.VersionBitsThreshold, c.Deployments,
+	})
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// ScheduledHash is ConsensusHash without the rules that activate at or
+// above height, nor their parameters. None of them applied to a chain of
+// height blocks, so a release that schedules a rule for a later height
+// leaves this hash equal to the one the chain recorded.
+func (c *NetworkConfig) ScheduledHash(height uint64) string {
+	p := *c
+	future := func(h uint64) bool { return h >= height }
+	if future(p.UTXOActivationHeight) {
+		p.UTXOActivationHeight, p.TxModel = 0, ""
+	}
+	if future(p.TimestampRulesHeight) {
+		p.TimestampRulesHeight = 0
+	}
+	if future(p.AddressRulesHeight) {
+		p.AddressRulesHeight = 0
+	}
+	if future(p.UnbondingHeight) {
+		p.UnbondingHeight = 0
+	}
+	if future(p.SignatureRulesHeight) {
+		p.SignatureRulesHeight = 0
+	}
+	if future(p.NonceRulesHeight) {
+		p.NonceRulesHeight = 0
+	}
+	if future(p.MaturityRulesHeight) {
+		p.MaturityRulesHeight, p.CoinbaseMaturity = 0, 0
+	}
+	if future(p.TxLimitsHeight) {
+		p.TxLimitsHeight, p.MaxTxSize, p.MaxTxOutputs = 0, 0, 0
+	}
+	if future(p.RewardRulesHeight) {
+		p.RewardRulesHeight = 0
+	}
+	if future(p.StakeLockHeight) {
+		p.StakeLockHeight = 0
+	}
+	if future(p.SlashingHeight) {
+		p.SlashingHeight, p.SlashPercent = 0, 0
+	}
+	if future(p.DelegationHeight) {
+		p.DelegationHeight, p.DelegationCommission = 0, 0
+	}
+	if future(p.BalanceRulesHeight) {
+		p.BalanceRulesHeight = 0
+	}
+	return p.ConsensusHash()
+}
@@ -0,0 +1,63 @@
+package config
+
+// Relay policy defaults not set by network manifests.
+const (
+	DefaultMaxTxSize          = 100000 // canonical bytes
+	DefaultMaxDataCarrierSize = 256    // bytes of transaction data payload
+)
+
+// RelayPolicy decides which transactions this node accepts into its
+// mempool and relays. It never affects block validity, so operators can
+// tune it freely without risking a consensus split.
+type RelayPolicy struct {
+	MinRelayFee         float64 `json:"min_relay_fee,omitempty"`
+	MaxTxSize           int     `json:"max_tx_size,omitempty"`
+	MaxDataCarrierSize  int     `json:"max_data_carrier_size,omitempty"`
+	MinOutputAmount     float64 `json:"min_output_amount,omitempty"` // dust limit
+	MaxPendingPerSender int     `json:"max_pending_per_sender,omitempty"`
+	MaxMempoolTxs       int     `json:"max_mempool_txs,omitempty"`
+	MempoolExpiryHours  int     `json:"mempool_expiry_hours,omitempty"`
+}
+
+// RelayPolicy returns the network's default relay policy from the
+// manifest's mempool keys. MinRelayFee and MaxMempoolTxs are left zero:
+// the network values are governable, and a node's own settings may only
+// tighten them.
+func (c *NetworkConfig) RelayPolicy() RelayPolicy {
+	return RelayPolicy{
+		MaxTxSize:           DefaultMaxTxSize,
+		MaxDataCarrierSize:  DefaultMaxDataCarrierSize,
+		MinOutputAmount:     c.MinOutputAmount,
+		MaxPendingPerSender: c.MaxPendingPerSender,
+		MempoolExpiryHours:  c.MempoolExpiryHours,
+	}
+}
+
+// Override returns p with every non-zero field of o applied.
+func (p RelayPolicy) Override(o *RelayPolicy) RelayPolicy {
+	if o == nil {
+		return p
+	}
+	if o.MinRelayFee != 0 {
+		p.MinRelayFee = o.MinRelayFee
+	}
+	if o.MaxTxSize != 0 {
+		p.MaxTxSize = o.MaxTxSize
+	}
+	if o.MaxDataCarrierSize != 0 {
+		p.MaxDataCarrierSize = o.MaxDataCarrierSize
+	}
+	if o.MinOutputAmount != 0 {
+		p.MinOutputAmount = o.MinOutputAmount
+	}
+	if o.MaxPendingPerSender != 0 {
+		p.MaxPendingPerSender = o.MaxPendingPerSender
+	}
+	if o.MaxMempoolTxs != 0 {
+		p.MaxMempoolTxs = o.MaxMempoolTxs
+	}
+	if o.MempoolExpiryHours != 0 {
+		p.MempoolExpiryHours = o.MempoolExpiryHours
+	}
+	return p
+}
@@ -0,0 +1,135 @@
+// Package logging provides the node's leveled, component-tagged logger
+// built on log/slog.
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"sync/atomic"
+)
+
+// Options configures the process-wide log output.
+type Options struct {
+	Level      string // debug, info, warn, error
+	JSON       bool   // emit JSON lines instead of key=value text
+	File       string // optional log file; stderr when empty
+	MaxSizeMB  int    // rotate the log file after this size (0 = no rotation)
+	MaxBackups int    // rotated files to keep
+}
+
+var (
+	level slog.LevelVar
+	root  atomic.Pointer[slog.Handler]
+)
+
+func init() {
+	var h slog.Handler = slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: &level})
+	root.Store(&h)
+}
+
+// Setup installs the output handler. Loggers created with For before Setup
+// pick up the new handler automatically.
+func Setup(opts Options) (io.Closer, error) {
+	if err := SetLevel(opts.Level); err != nil {
+		return nil, err
+	}
+
+	var out io.Writer = os.Stderr
+	var closer io.Closer = nopCloser{}
+	if opts.File != "" {
+		rw, err := newRotatingWriter(opts.File, int64(opts.MaxSizeMB)*1024*1024, opts.MaxBackups)
+		if err != nil {
+			return nil, err
+		}
+		out, closer = rw, rw
+	}
+
+	hopts := &slog.HandlerOptions{Level: &level}
+	var h slog.Handler
+	if opts.JSON {
+		h = slog.NewJSONHandler(out, hopts)
+	} else {
+		h = slog.NewTextHandler(out, hopts)
+	}
+	root.Store(&h)
+	return closer, nil
+}
+
+// SetLevel changes the minimum level at runtime. An empty string means info.
+func SetLevel(name string) error {
+	switch strings.ToLower(name) {
+	case "debug":
+		level.Set(slog.LevelDebug)
+	case "", "info":
+		level.Set(slog.LevelInfo)
+	case "warn", "warning":
+		level.Set(slog.LevelWarn)
+	case "error":
+		level.Set(slog.LevelError)
+	default:
+		return fmt.Errorf("unknown log level %q", name)
+	}
+	return nil
+}
+
+// Level returns the current minimum level name.
+func Level() string {
+	return strings.ToLower(level.Level().String())
+}
+
+// For returns a logger tagged with the given component (CHAIN, P2P, RPC...).
+func For(component string) *slog.Logger {
+	return slog.New(&componentHandler{
+		attrs: []slog.Attr{slog.String("component", component)},
+	})
+}
+
+// Fatal logs at error level and exits the process.
+func Fatal(l *slog.Logger, msg string, args ...any) {
+	l.Error(msg, args...)
+	os.Exit(1)
+}
+
+// componentHandler forwards to the current root handler so that package-level
+// loggers follow later Setup calls.
+type componentHandler struct {
+	attrs  []slog.Attr
+	groups []string
+}
+
+func (h *componentHandler) target() slog.Handler {
+	t := (*root.Load()).WithAttrs(h.attrs)
+	for _, g := range h.groups {
+		t = t.WithGroup(g)
+	}
+	return t
+}
+
+func (h *componentHandler) Enabled(_ context.Context, l slog.Level) bool {
+	return l >= level.Level()
+}
+
+func (h *componentHandler) Handle(ctx context.Context, r slog.Record) error {
+	return h.target().Handle(ctx, r)
+}
+
+func (h *componentHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	if len(h.groups) > 0 {
+		return h.target().WithAttrs(attrs)
+	}
+	cp := append(append([]slog.Attr{}, h.attrs...), attrs...)
+	return &componentHandler{attrs: cp}
+}
+
+func (h *componentHandler) WithGroup(name string) slog.Handler {
+	cp := append(append([]string{}, h.groups...), name)
+	return &componentHandler{attrs: h.attrs, groups: cp}
+}
+
+type nopCloser struct{}
+
+func (nopCloser) Close() error { return nil }
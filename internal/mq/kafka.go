@@ -0,0 +1,347 @@
+package mq
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"net"
+	"strconv"
+	"time"
+)
+
+// Kafka API keys and the versions used: Produce v3 (the oldest that Kafka
+// 4 still accepts, carrying record batches) and Metadata v1.
+const (
+	kafkaProduce        int16 = 0
+	kafkaMetadata       int16 = 3
+	kafkaProduceVersion int16 = 3
+	kafkaMetaVersion    int16 = 1
+	kafkaClientID             = "dvcnode"
+)
+
+var castagnoli = crc32.MakeTable(crc32.Castagnoli)
+
+// kafkaConn produces to partition 0 of each topic, so consumers see events
+// in the order the node published them. Topics must exist or the brokers
+// must auto-create them.
+type kafkaConn struct {
+	bootstrap *kafkaBroker
+	brokers   map[int32]*kafkaBroker // by node ID, connected on demand
+	addrs     map[int32]string
+	leaders   map[string]int32 // topic -> leader of partition 0
+}
+
+type kafkaBroker struct {
+	c    net.Conn
+	corr int32
+}
+
+// dialKafka connects to the first reachable bootstrap server.
+func dialKafka(servers []string) (conn, error) {
+	var lastErr error
+	for _, s := range servers {
+		b, err := dialKafkaBroker(s)
+		if err == nil {
+			return &kafkaConn{
+				bootstrap: b,
+				brokers:   make(map[int32]*kafkaBroker),
+				addrs:     make(map[int32]string),
+				leaders:   make(map[string]int32),
+			}, nil
+		}
+		lastErr = fmt.Errorf("kafka %s: %w", s, err)
+	}
+	return nil, lastErr
+}
+
+func dialKafkaBroker(addr string) (*kafkaBroker, error) {
+	c, err := net.DialTimeout("tcp", addr, ioTimeout)
+	if err != nil {
+		return nil, err
+	}
+	return &kafkaBroker{c: c}, nil
+}
+
+// roundTrip sends one request and returns the response body after the
+// correlation ID.
+func (b *kafkaBroker) roundTrip(apiKey, version int16, body []byte) ([]byte, error) {
+	b.corr++
+	var w kafkaWriter
+	w.int16(apiKey)
+	w.int16(version)
+	w.int32(b.corr)
+	w.string(kafkaClientID)
+	w.buf.Write(body)
+	frame := binary.BigEndian.AppendUint32(nil, uint32(w.buf.Len()))
+	frame = append(frame, w.buf.Bytes()...)
+
+	b.c.SetDeadline(time.Now().Add(ioTimeout))
+	defer b.c.SetDeadline(time.Time{})
+	if _, err := b.c.Write(frame); err != nil {
+		return nil, err
+	}
+	var size [4]byte
+	if _, err := io.ReadFull(b.c, size[:]); err != nil {
+		return nil, err
+	}
+	n := binary.BigEndian.Uint32(size[:])
+	if n < 4 || n > 64<<20 {
+		return nil, fmt.Errorf("bad response size %d", n)
+	}
+	resp := make([]byte, n)
+	if _, err := io.ReadFull(b.c, resp); err != nil {
+		return nil, err
+	}
+	if corr := int32(binary.BigEndian.Uint32(resp)); corr != b.corr {
+		return nil, fmt.Errorf("response to request %d, want %d", corr, b.corr)
+	}
+	return resp[4:], nil
+}
+
+// leader returns the connection to the leader of topic's partition 0,
+// asking the bootstrap server for metadata when it isn't known.
+func (kc *kafkaConn) leader(topic string) (*kafkaBroker, error) {
+	id, ok := kc.leaders[topic]
+	if !ok {
+		if err := kc.refresh(topic); err != nil {
+			return nil, err
+		}
+		id = kc.leaders[topic]
+	}
+	if b := kc.brokers[id]; b != nil {
+		return b, nil
+	}
+	addr, ok := kc.addrs[id]
+	if !ok {
+		return nil, fmt.Errorf("leader %d of %s not among the brokers", id, topic)
+	}
+	b, err := dialKafkaBroker(addr)
+	if err != nil {
+		return nil, fmt.Errorf("kafka broker %d (%s): %w", id, addr, err)
+	}
+	kc.brokers[id] = b
+	return b, nil
+}
+
+func (kc *kafkaConn) refresh(topic string) error {
+	var w kafkaWriter
+	w.int32(1)
+	w.string(topic)
+	resp, err := kc.bootstrap.roundTrip(kafkaMetadata, kafkaMetaVersion, w.buf.Bytes())
+	if err != nil {
+		return err
+	}
+	r := kafkaReader{b: resp}
+	for i := r.int32(); i > 0 && r.err == nil; i-- {
+		id, host, port := r.int32(), r.string(), r.int32()
+		r.string() // rack
+		kc.addrs[id] = net.JoinHostPort(host, strconv.Itoa(int(port)))
+	}
+	r.int32() // controller
+	for i := r.int32(); i > 0 && r.err == nil; i-- {
+		code, name := r.int16(), r.string()
+		r.int8() // internal
+		for j := r.int32(); j > 0 && r.err == nil; j-- {
+			pcode, part, leader := r.int16(), r.int32(), r.int32()
+			r.int32s() // replicas
+			r.int32s() // in-sync replicas
+			if name != topic || part != 0 {
+				continue
+			}
+			if pcode != 0 {
+				return fmt.Errorf("partition 0 of %s: %w", topic, kafkaError(pcode))
+			}
+			if leader < 0 {
+				return fmt.Errorf("partition 0 of %s has no leader yet", topic)
+			}
+			kc.leaders[topic] = leader
+		}
+		if name == topic && code != 0 {
+			return fmt.Errorf("metadata for %s: %w", topic, kafkaError(code))
+		}
+	}
+	if r.err != nil {
+		return fmt.Errorf("metadata response: %w", r.err)
+	}
+	if _, ok := kc.leaders[topic]; !ok {
+		return fmt.Errorf("no partition 0 for topic %s", topic)
+	}
+	return nil
+}
+
+func (kc *kafkaConn) publish(topic, key string, value []byte) error {
+	b, err := kc.leader(topic)
+	if err != nil {
+		return err
+	}
+	var w kafkaWriter
+	w.int16(-1)    // transactional ID: none
+	w.int16(1)     // acks: leader
+	w.int32(10000) // timeout ms
+	w.int32(1)
+	w.string(topic)
+	w.int32(1)
+	w.int32(0) // partition
+	batch := recordBatch(key, value, time.Now())
+	w.int32(int32(len(batch)))
+	w.buf.Write(batch)
+	resp, err := b.roundTrip(kafkaProduce, kafkaProduceVersion, w.buf.Bytes())
+	if err != nil {
+		return err
+	}
+	r := kafkaReader{b: resp}
+	r.int32() // topics
+	r.string()
+	r.int32() // partitions
+	r.int32()
+	code := r.int16()
+	if r.err != nil {
+		return fmt.Errorf("produce response: %w", r.err)
+	}
+	if code != 0 {
+		delete(kc.leaders, topic) // the leader may have moved
+		return fmt.Errorf("produce to %s: %w", topic, kafkaError(code))
+	}
+	return nil
+}
+
+func (kc *kafkaConn) close() {
+	kc.bootstrap.c.Close()
+	for _, b := range kc.brokers {
+		b.c.Close()
+	}
+}
+
+// recordBatch encodes a single-record batch in the v2 (magic 2) format.
+func recordBatch(key string, value []byte, t time.Time) []byte {
+	var rec kafkaWriter
+	rec.int8(0)   // attributes
+	rec.varint(0) // timestamp delta
+	rec.varint(0) // offset delta
+	if key == "" {
+		rec.varint(-1) // null key
+	} else {
+		rec.varint(int64(len(key)))
+		rec.buf.WriteString(key)
+	}
+	rec.varint(int64(len(value)))
+	rec.buf.Write(value)
+	rec.varint(0) // headers
+
+	// Everything after the CRC, which covers it.
+	var tail kafkaWriter
+	tail.int16(0) // attributes: no compression
+	tail.int32(0) // last offset delta
+	ms := t.UnixMilli()
+	tail.int64(ms) // first timestamp
+	tail.int64(ms) // max timestamp
+	tail.int64(-1) // producer ID
+	tail.int16(-1) // producer epoch
+	tail.int32(-1) // base sequence
+	tail.int32(1)  // records
+	tail.varint(int64(rec.buf.Len()))
+	tail.buf.Write(rec.buf.Bytes())
+
+	var w kafkaWriter
+	w.int64(0)                                 // base offset
+	w.int32(int32(4 + 1 + 4 + tail.buf.Len())) // batch length: leader epoch, magic, crc, tail
+	w.int32(-1)                                // partition leader epoch
+	w.int8(2)                                  // magic
+	w.int32(int32(crc32.Checksum(tail.buf.Bytes(), castagnoli)))
+	w.buf.Write(tail.buf.Bytes())
+	return w.buf.Bytes()
+}
+
+// kafkaError describes a Kafka error code.
+func kafkaError(code int16) error {
+	switch code {
+	case 3:
+		return errors.New("unknown topic or partition")
+	case 5:
+		return errors.New("leader not available")
+	case 6:
+		return errors.New("not leader for partition")
+	case 10:
+		return errors.New("message too large")
+	case 29:
+		return errors.New("topic authorization failed")
+	}
+	return fmt.Errorf("kafka error %d", code)
+}
+
+type kafkaWriter struct {
+	buf bytes.Buffer
+}
+
+func (w *kafkaWriter) int8(v int8)   { w.buf.WriteByte(byte(v)) }
+func (w *kafkaWriter) int16(v int16) { binary.Write(&w.buf, binary.BigEndian, v) }
+func (w *kafkaWriter) int32(v int32) { binary.Write(&w.buf, binary.BigEndian, v) }
+func (w *kafkaWriter) int64(v int64) { binary.Write(&w.buf, binary.BigEndian, v) }
+
+func (w *kafkaWriter) string(s string) {
+	w.int16(int16(len(s)))
+	w.buf.WriteString(s)
+}
+
+// varint writes a zigzag varint, as record fields use.
+func (w *kafkaWriter) varint(v int64) {
+	w.buf.Write(binary.AppendVarint(nil, v))
+}
+
+// kafkaReader decodes a response; the first short read sets err and every
+// later read returns zero.
+type kafkaReader struct {
+	b   []byte
+	err error
+}
+
+func (r *kafkaReader) next(n int) []byte {
+	if r.err != nil {
+		return nil
+	}
+	if n < 0 || len(r.b) < n {
+		r.err = io.ErrUnexpectedEOF
+		return nil
+	}
+	v := r.b[:n]
+	r.b = r.b[n:]
+	return v
+}
+
+func (r *kafkaReader) int8() int8 {
+	if b := r.next(1); b != nil {
+		return int8(b[0])
+	}
+	return 0
+}
+
+func (r *kafkaReader) int16() int16 {
+	if b := r.next(2); b != nil {
+		return int16(binary.BigEndian.Uint16(b))
+	}
+	return 0
+}
+
+func (r *kafkaReader) int32() int32 {
+	if b := r.next(4); b != nil {
+		return int32(binary.BigEndian.Uint32(b))
+	}
+	return 0
+}
+
+// string reads a nullable string; null reads as "".
+func (r *kafkaReader) string() string {
+	n := r.int16()
+	if n < 0 {
+		return ""
+	}
+	return string(r.next(int(n)))
+}
+
+func (r *kafkaReader) int32s() {
+	n := r.int32()
+	r.next(int(n) * 4)
+}
@@ -0,0 +1,163 @@
+package mq
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// natsConn speaks the NATS client protocol: CONNECT once, then a PUB per
+// message. Publishes are fire-and-forget; a PING after each one makes the
+// server report a rejected message (-ERR) or a dead connection before the
+// next is sent.
+type natsConn struct {
+	c          net.Conn
+	maxPayload int
+	pong       chan error
+	mu         sync.Mutex // serialises writes between publish and the reader's PONGs
+}
+
+type natsInfo struct {
+	MaxPayload   int  `json:"max_payload"`
+	AuthRequired bool `json:"auth_required"`
+	TLSRequired  bool `json:"tls_required"`
+}
+
+// dialNATS connects to the first reachable server.
+func dialNATS(servers []string) (conn, error) {
+	var lastErr error
+	for _, s := range servers {
+		c, err := connectNATS(s)
+		if err == nil {
+			return c, nil
+		}
+		lastErr = fmt.Errorf("nats %s: %w", s, err)
+	}
+	return nil, lastErr
+}
+
+func connectNATS(server string) (*natsConn, error) {
+	addr, user, pass := server, "", ""
+	if strings.Contains(server, "://") {
+		u, err := url.Parse(server)
+		if err != nil {
+			return nil, err
+		}
+		addr = u.Host
+		if u.User != nil {
+			user = u.User.Username()
+			pass, _ = u.User.Password()
+		}
+	}
+	c, err := net.DialTimeout("tcp", addr, ioTimeout)
+	if err != nil {
+		return nil, err
+	}
+	c.SetDeadline(time.Now().Add(ioTimeout))
+	r := bufio.NewReader(c)
+	line, err := r.ReadString('\n')
+	if err != nil {
+		c.Close()
+		return nil, err
+	}
+	var info natsInfo
+	if !strings.HasPrefix(line, "INFO ") || json.Unmarshal([]byte(line[5:]), &info) != nil {
+		c.Close()
+		return nil, errors.New("not a NATS server")
+	}
+	if info.TLSRequired {
+		c.Close()
+		return nil, errors.New("server requires TLS, which is not supported")
+	}
+	opts := map[string]interface{}{"verbose": false, "pedantic": false, "name": "dvcnode", "lang": "go", "version": "1"}
+	if user != "" {
+		opts["user"], opts["pass"] = user, pass
+	}
+	connect, _ := json.Marshal(opts)
+	if _, err := fmt.Fprintf(c, "CONNECT %s\r\nPING\r\n", connect); err != nil {
+		c.Close()
+		return nil, err
+	}
+	// The server answers PONG once it has accepted CONNECT, or -ERR.
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			c.Close()
+			return nil, err
+		}
+		line = strings.TrimSpace(line)
+		if line == "PONG" {
+			break
+		}
+		if strings.HasPrefix(line, "-ERR") {
+			c.Close()
+			return nil, errors.New(strings.TrimSpace(strings.TrimPrefix(line, "-ERR")))
+		}
+	}
+	c.SetDeadline(time.Time{})
+	nc := &natsConn{c: c, maxPayload: info.MaxPayload, pong: make(chan error, 1)}
+	go nc.read(r)
+	return nc, nil
+}
+
+// read answers server PINGs and hands PONG or -ERR to the waiting publish.
+func (nc *natsConn) read(r *bufio.Reader) {
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			nc.signal(err)
+			return
+		}
+		line = strings.TrimSpace(line)
+		switch {
+		case line == "PING":
+			nc.mu.Lock()
+			nc.c.Write([]byte("PONG\r\n"))
+			nc.mu.Unlock()
+		case line == "PONG":
+			nc.signal(nil)
+		case strings.HasPrefix(line, "-ERR"):
+			nc.signal(errors.New(strings.TrimSpace(strings.TrimPrefix(line, "-ERR"))))
+		}
+	}
+}
+
+func (nc *natsConn) signal(err error) {
+	select {
+	case nc.pong <- err:
+	default:
+	}
+}
+
+func (nc *natsConn) publish(subject, _ string, value []byte) error {
+	if nc.maxPayload > 0 && len(value) > nc.maxPayload {
+		return fmt.Errorf("message of %d bytes exceeds the server's max_payload %d", len(value), nc.maxPayload)
+	}
+	select {
+	case <-nc.pong: // stale
+	default:
+	}
+	nc.mu.Lock()
+	nc.c.SetWriteDeadline(time.Now().Add(ioTimeout))
+	_, err := fmt.Fprintf(nc.c, "PUB %s %d\r\n%s\r\nPING\r\n", subject, len(value), value)
+	nc.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	select {
+	case err := <-nc.pong:
+		return err
+	case <-time.After(ioTimeout):
+		return errors.New("no PONG from server")
+	}
+}
+
+func (nc *natsConn) close() {
+	nc.c.Close()
+}
@@ -0,0 +1,239 @@
+package network
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	maxAddrPerMsg      = 1000
+	maxAddrRelay       = 10 // larger addr messages are answers to getaddr and not relayed
+	maxOutboundPeers   = 8  // stop dialling gossiped addresses at this many peers
+	announcementMaxAge = 3 * time.Hour
+	announcementSkew   = 10 * time.Minute
+	reannounceInterval = time.Hour
+)
+
+// Announcement advertises that the node with NodeID accepts connections at
+// Address. It is signed by the node's identity key, so a third party can
+// relay it but cannot forge one.
+type Announcement struct {
+	NodeID    string `json:"node_id"`
+	Address   string `json:"address"`
+	Timestamp int64  `json:"timestamp"`
+	Signature string `json:"signature"`
+}
+
+func (a *Announcement) signingBytes(networkID uint32) []byte {
+	return []byte(fmt.Sprintf("dvc-addr:%d:%s:%s:%d", networkID, a.NodeID, a.Address, a.Timestamp))
+}
+
+func (a *Announcement) verify(networkID uint32) error {
+	if a.Address == "" || !verifyNodeSig(a.NodeID, a.signingBytes(networkID), a.Signature) {
+		return fmt.Errorf("invalid announcement signature for %s", a.Address)
+	}
+	return nil
+}
+
+func (a *Announcement) fresh(now time.Time) bool {
+	t := time.Unix(a.Timestamp, 0)
+	return t.After(now.Add(-announcementMaxAge)) && t.Before(now.Add(announcementSkew))
+}
+
+// addrBook holds the newest verified announcement per node, and the
+// operator's pinned address-to-node-ID relationships.
+type addrBook struct {
+	mu      sync.Mutex
+	entries map[string]Announcement
+	pins    map[string]string
+}
+
+func newAddrBook() *addrBook {
+	return &addrBook{
+		entries: make(map[string]Announcement),
+		pins:    make(map[string]string),
+	}
+}
+
+// add stores a verified announcement and reports whether it was new.
+func (b *addrBook) add(a Announcement) (bool, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if pin, ok := b.pins[a.Address]; ok && pin != a.NodeID {
+		return false, fmt.Errorf("address %s is pinned to another node", a.Address)
+	}
+	if old, ok := b.entries[a.NodeID]; ok && old.Timestamp >= a.Timestamp {
+		return false, nil
+	}
+	b.entries[a.NodeID] = a
+	return true, nil
+}
+
+// list drops stale entries and returns the rest, newest first.
+func (b *addrBook) list(now time.Time) []Announcement {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	out := make([]Announcement, 0, len(b.entries))
+	for id, a := range b.entries {
+		if !a.fresh(now) {
+			delete(b.entries, id)
+			continue
+		}
+		out = append(out, a)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Timestamp > out[j].Timestamp })
+	if len(out) > maxAddrPerMsg {
+		out = out[:maxAddrPerMsg]
+	}
+	return out
+}
+
+// forget drops the announcement of nodeID.
+func (b *addrBook) forget(nodeID string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	delete(b.entries, nodeID)
+}
+
+func (b *addrBook) pin(address string) (string, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	id, ok := b.pins[address]
+	return id, ok
+}
+
+// SetPeerKeys replaces the pinned peers: a map from address (host:port) to
+// the node ID expected there. Outbound connections to a pinned address are
+// dropped unless the peer proves it holds that key, and announcements
+// claiming a pinned address for any other node are rejected.
+func (n *Node) SetPeerKeys(pins map[string]string) {
+	n.book.mu.Lock()
+	n.book.pins = make(map[string]string, len(pins))
+	for addr, id := range pins {
+		n.book.pins[addr] = id
+	}
+	n.book.mu.Unlock()
+}
+
+// KnownAddresses returns the verified announcements learned from peers.
+func (n *Node) KnownAddresses() []Announcement {
+	return n.book.list(time.Now())
+}
+
+// selfAnnouncement signs a fresh announcement of ExternalAddr, if set.
+func (n *Node) selfAnnouncement() (Announcement, bool) {
+	if n.ExternalAddr == "" {
+		return Announcement{}, false
+	}
+	a := Announcement{
+		NodeID:    n.Identity.ID(),
+		Address:   n.ExternalAddr,
+		Timestamp: time.Now().Unix(),
+	}
+	a.Signature = n.Identity.sign(a.signingBytes(n.Config.NetworkID))
+	return a, true
+}
+
+func (n *Node) sendAddr(peer *Peer) {
+	addrs := n.book.list(time.Now())
+	if self, ok := n.selfAnnouncement(); ok {
+		addrs = append([]Announcement{self}, addrs...)
+		if len(addrs) > maxAddrPerMsg {
+			addrs = addrs[:maxAddrPerMsg]
+		}
+	}
+	payload, _ := json.Marshal(addrs)
+	peer.Send(Message{Type: "addr", Payload: payload})
+}
+
+// announceLoop periodically re-signs our own announcement so peers keep it
+// fresh.
+func (n *Node) announceLoop() {
+	ticker := time.NewTicker(reannounceInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		self, ok := n.selfAnnouncement()
+		if !ok {
+			continue
+		}
+		payload, _ := json.Marshal([]Announcement{self})
+		n.broadcast(Message{Type: "addr", Payload: payload}, "")
+	}
+}
+
+// handleAddr verifies and stores gossiped announcements, relays small
+// batches of new ones and dials new nodes while we are short of peers.
+// A forged signature is a protocol error.
+func (n *Node) handleAddr(peer *Peer, addrs []Announcement) error {
+	if len(addrs) > maxAddrPerMsg {
+		return fmt.Errorf("addr message with %d entries", len(addrs))
+	}
+	now := time.Now()
+	self := n.Identity.ID()
+	var fresh []Announcement
+	for _, a := range addrs {
+		if err := a.verify(n.Config.NetworkID); err != nil {
+			return err
+		}
+		if a.NodeID == self || !a.fresh(now) {
+			continue
+		}
+		added, err := n.book.add(a)
+		if err != nil {
+			logger.Warn("announcement rejected", "peer", peer.Address, "node", a.NodeID, "err", err)
+			continue
+		}
+		if added {
+			fresh = append(fresh, a)
+		}
+	}
+	if len(fresh) == 0 {
+		return nil
+	}
+	if len(fresh) <= maxAddrRelay {
+		payload, _ := json.Marshal(fresh)
+		n.broadcast(Message{Type: "addr", Payload: payload}, peer.Address)
+	}
+	slots := maxOutboundPeers - n.GetPeerCount()
+	for _, a := range fresh {
+		if slots <= 0 {
+			break
+		}
+		if n.IsConnected(a.Address) || n.hasNode(a.NodeID) {
+			continue
+		}
+		slots--
+		logger.Info("connecting to announced peer", "peer", a.Address, "node", a.NodeID)
+		go func(addr string) {
+			if err := n.ConnectPeer(addr); err != nil {
+				logger.Debug("announced peer unreachable", "peer", addr, "err", err)
+			}
+		}(a.Address)
+	}
+	return nil
+}
+
+func (n *Node) hasNode(nodeID string) bool {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+	for _, p := range n.Peers {
+		if p.NodeID == nodeID {
+			return true
+		}
+	}
+	return false
+}
+
+// broadcast sends msg to every peer except origin.
+func (n *Node) broadcast(msg Message, origin string) {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+	for addr, p := range n.Peers {
+		if addr != origin {
+			p.Send(msg)
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package network
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// closeWriteTimeout bounds how long Close waits on a peer that isn't
+// reading.
+const closeWriteTimeout = 2 * time.Second
+
+// ClosingPayload tells peers the sender is shutting down, so they drop the
+// connection at once instead of waiting for it to time out.
+type ClosingPayload struct {
+	Reason string `json:"reason,omitempty"`
+}
+
+func (cp *ClosingPayload) check() error {
+	if len(cp.Reason) > maxRejectReasonLen {
+		return fmt.Errorf("closing reason too long")
+	}
+	return nil
+}
+
+// Close stops accepting connections, says goodbye to every peer with a
+// closing message and disconnects them.
+func (n *Node) Close(reason string) {
+	n.closed.Store(true)
+	if n.listener != nil {
+		n.listener.Close()
+	}
+	n.mu.RLock()
+	peers := make([]*Peer, 0, len(n.Peers))
+	for _, p := range n.Peers {
+		peers = append(peers, p)
+	}
+	n.mu.RUnlock()
+
+	payload, _ := json.Marshal(ClosingPayload{Reason: reason})
+	var wg sync.WaitGroup
+	for _, p := range peers {
+		wg.Add(1)
+		go func(p *Peer) {
+			defer wg.Done()
+			p.Conn.SetWriteDeadline(time.Now().Add(closeWriteTimeout))
+			p.Send(Message{Type: "closing", Payload: payload})
+			p.Conn.Close()
+		}(p)
+	}
+	wg.Wait()
+	logger.Info("disconnected from peers", "peers", len(peers))
+}
+
+// handleClosing drops a peer that is shutting down. Its announced address
+// is forgotten, so it is neither dialled nor gossiped until it announces
+// itself again, and if it was the sync peer the sync moves on at once.
+func (n *Node) handleClosing(peer *Peer, cp ClosingPayload) {
+	logger.Info("peer closing", "peer", peer.Address, "reason", cp.Reason)
+	n.mu.Lock()
+	if n.Peers[peer.Address] == peer {
+		delete(n.Peers, peer.Address)
+	}
+	n.mu.Unlock()
+	peer.Conn.Close()
+	if peer.NodeID != "" {
+		n.book.forget(peer.NodeID)
+	}
+	n.checkSyncPeer()
+}
@@ -0,0 +1,175 @@
+package network
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"devinsidercoin/internal/blockchain"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Bounds on fields of peer messages. Anything larger is a protocol error
+// rather than something for consensus code to cope with.
+const (
+	maxMessageTypeLen = 32
+	maxTxTypeLen      = 32
+	maxAddressLen     = 128  // wallet addresses
+	maxHostLen        = 262  // host:port in announcements
+	maxNonceLen       = 64   // handshake nonce, hex
+	maxSignatureLen   = 1024 // transaction signature, hex
+	maxUserAgentLen   = 256  // version user_agent
+	maxTxIO           = 10000
+)
+
+// decodePayload decodes data into v strictly: unknown fields, a null or
+// empty value and trailing data are rejected. check, if not nil, then
+// validates the decoded value against its schema.
+func decodePayload(data []byte, v interface{}, check func() error) error {
+	if t := bytes.TrimSpace(data); len(t) == 0 || bytes.Equal(t, []byte("null")) {
+		return errors.New("missing payload")
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		return err
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return errors.New("trailing data after payload")
+	}
+	if check != nil {
+		return check()
+	}
+	return nil
+}
+
+// isHex reports whether s is lowercase hex of exactly n characters.
+func isHex(s string, n int) bool {
+	if len(s) != n {
+		return false
+	}
+	_, err := hex.DecodeString(s)
+	return err == nil && s == string(bytes.ToLower([]byte(s)))
+}
+
+func (m *Message) check() error {
+	if m.Type == "" || len(m.Type) > maxMessageTypeLen {
+		return fmt.Errorf("bad message type %q", m.Type)
+	}
+	if m.Checksum != "" && !isHex(m.Checksum, 8) {
+		return fmt.Errorf("bad checksum %q", m.Checksum)
+	}
+	return nil
+}
+
+func (vp *VersionPayload) check() error {
+	if vp.NodeID != "" && !isHex(vp.NodeID, 64) {
+		return errors.New("bad node_id")
+	}
+	if len(vp.Nonce) > maxNonceLen {
+		return errors.New("nonce too long")
+	}
+	if vp.ChainWork != "" && !isHex(vp.ChainWork, 64) {
+		return errors.New("bad chain_work")
+	}
+	if len(vp.UserAgent) > maxUserAgentLen {
+		return errors.New("user_agent too long")
+	}
+	if vp.Genesis != "" && !isHex(vp.Genesis, 64) {
+		return errors.New("bad genesis")
+	}
+	return nil
+}
+
+func (va *VerackPayload) check() error {
+	if va.NodeID != "" && !isHex(va.NodeID, 64) {
+		return errors.New("bad node_id")
+	}
+	if va.Signature != "" && !isHex(va.Signature, 128) {
+		return errors.New("bad signature")
+	}
+	return nil
+}
+
+func checkAnnouncements(addrs []Announcement) error {
+	if len(addrs) > maxAddrPerMsg {
+		return fmt.Errorf("addr message with %d entries", len(addrs))
+	}
+	for i, a := range addrs {
+		if !isHex(a.NodeID, 64) || !isHex(a.Signature, 128) {
+			return fmt.Errorf("announcement %d: bad node_id or signature", i)
+		}
+		if a.Address == "" || len(a.Address) > maxHostLen {
+			return fmt.Errorf("announcement %d: bad address", i)
+		}
+	}
+	return nil
+}
+
+// checkBlock validates the shape of a block: hashes are 64 hex characters
+// and there are between one and maxTxs transactions, each well formed.
+func checkBlock(b *blockchain.Block, maxTxs uint64) error {
+	if !isHex(b.Hash, 64) || !isHex(b.Header.PrevHash, 64) || !isHex(b.Header.MerkleRoot, 64) {
+		return errors.New("bad block or header hash")
+	}
+	if len(b.Transactions) == 0 || uint64(len(b.Transactions)) > maxTxs {
+		return fmt.Errorf("block with %d transactions", len(b.Transactions))
+	}
+	for i := range b.Transactions {
+		if err := checkTx(&b.Transactions[i]); err != nil {
+			return fmt.Errorf("tx %d: %w", i, err)
+		}
+	}
+	return nil
+}
+
+// checkTx validates the shape of a transaction. Whether it is valid is
+// left to the chain.
+func checkTx(tx *blockchain.Transaction) error {
+	switch {
+	case !isHex(tx.TxID, 64):
+		return errors.New("bad txid")
+	case tx.Type == "" || len(tx.Type) > maxTxTypeLen:
+		return fmt.Errorf("bad type %q", tx.Type)
+	case len(tx.From) > maxAddressLen || len(tx.To) > maxAddressLen:
+		return errors.New("address too long")
+	case tx.Amount < 0 || tx.Fee < 0:
+		return errors.New("negative amount or fee")
+	case len(tx.Signature) > maxSignatureLen:
+		return errors.New("signature too long")
+	case len(tx.Inputs) > maxTxIO || len(tx.Outputs) > maxTxIO:
+		return fmt.Errorf("%d inputs and %d outputs", len(tx.Inputs), len(tx.Outputs))
+	}
+	if tx.Signature != "" {
+		if _, err := hex.DecodeString(tx.Signature); err != nil {
+			return errors.New("signature is not hex")
+		}
+	}
+	for i, in := range tx.Inputs {
+		if !isHex(in.TxID, 64) {
+			return fmt.Errorf("input %d: bad txid", i)
+		}
+	}
+	for i, out := range tx.Outputs {
+		if out.Address == "" || len(out.Address) > maxAddressLen || out.Amount < 0 {
+			return fmt.Errorf("output %d: bad address or amount", i)
+		}
+	}
+	return nil
+}
+
+// checkAttestation validates the shape of a finality attestation; the
+// chain checks the signature.
+func checkAttestation(a *blockchain.Attestation) error {
+	switch {
+	case a.Height == 0 || !isHex(a.Hash, 64):
+		return errors.New("bad checkpoint height or hash")
+	case a.Address == "" || len(a.Address) > maxAddressLen:
+		return errors.New("bad address")
+	case !isHex(a.Signature, 2*(1+ed25519.PublicKeySize+ed25519.SignatureSize)):
+		return errors.New("bad signature")
+	}
+	return nil
+}
@@ -0,0 +1,61 @@
+package network
+
+import (
+	"devinsidercoin/internal/blockchain"
+	"encoding/json"
+)
+
+// BroadcastAttestation sends a finality attestation to all peers.
+func (n *Node) BroadcastAttestation(a *blockchain.Attestation) {
+	payload, _ := json.Marshal(a)
+	n.sendAttestation(Message{Type: "attestation", Payload: payload}, "")
+}
+
+// sendAttestation sends an attestation message to every peer except origin.
+func (n *Node) sendAttestation(msg Message, origin string) {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+	for addr, p := range n.Peers {
+		if addr != origin {
+			p.Send(msg)
+		}
+	}
+}
+
+// handleAttestation records an attestation from peer and relays it if it
+// was new to us. Attestations the chain refuses, for a checkpoint we
+// haven't reached or a block we don't have, are dropped without penalty.
+func (n *Node) handleAttestation(peer *Peer, msg Message) error {
+	var a blockchain.Attestation
+	if err := decodePayload(msg.Payload, &a, func() error { return checkAttestation(&a) }); err != nil {
+		return err
+	}
+	added, err := n.Chain.AddAttestation(a)
+	if err != nil {
+		logger.Debug("attestation rejected", "peer", peer.Address, "height", a.Height,
+			"address", a.Address, "err", err)
+		return nil
+	}
+	if added {
+		n.sendAttestation(msg, peer.Address)
+	}
+	return nil
+}
+
+// requestAttestations asks peer for its recent attestations, once we have
+// the checkpoint blocks they sign.
+func (n *Node) requestAttestations(peer *Peer) {
+	payload, _ := json.Marshal(struct{}{})
+	peer.Send(Message{Type: "getattestations", Payload: payload})
+}
+
+// sendRecentAttestations gives peer the signatures on our latest and
+// finalized checkpoints, on connect and when it asks after a sync.
+func (n *Node) sendRecentAttestations(peer *Peer) {
+	for _, a := range n.Chain.RecentAttestations() {
+		payload, _ := json.Marshal(a)
+		if peer.Send(Message{Type: "attestation", Payload: payload}) != nil {
+			return
+		}
+	}
+}
@@ -0,0 +1,97 @@
+package network
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Identity is a node's long-lived ed25519 key. Its public key, hex encoded,
+// is the node ID peers use to recognise it across address changes.
+type Identity struct {
+	key ed25519.PrivateKey
+}
+
+// NewIdentity generates a fresh identity.
+func NewIdentity() *Identity {
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		panic(err)
+	}
+	return &Identity{key: priv}
+}
+
+// LoadIdentity reads the node key at path, creating it on first start.
+func LoadIdentity(path string) (*Identity, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		id := NewIdentity()
+		if err := os.WriteFile(path, []byte(hex.EncodeToString(id.key.Seed())+"\n"), 0600); err != nil {
+			return nil, err
+		}
+		return id, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	seed, err := hex.DecodeString(strings.TrimSpace(string(data)))
+	if err != nil || len(seed) != ed25519.SeedSize {
+		return nil, fmt.Errorf("invalid node key in %s", path)
+	}
+	return &Identity{key: ed25519.NewKeyFromSeed(seed)}, nil
+}
+
+// ID returns the node ID.
+func (id *Identity) ID() string {
+	return hex.EncodeToString(id.key.Public().(ed25519.PublicKey))
+}
+
+func (id *Identity) sign(msg []byte) string {
+	return hex.EncodeToString(ed25519.Sign(id.key, msg))
+}
+
+// verifyNodeSig checks sig over msg against a node ID.
+func verifyNodeSig(nodeID string, msg []byte, sig string) bool {
+	pub, err := hex.DecodeString(nodeID)
+	if err != nil || len(pub) != ed25519.PublicKeySize {
+		return false
+	}
+	s, err := hex.DecodeString(sig)
+	if err != nil {
+		return false
+	}
+	return ed25519.Verify(ed25519.PublicKey(pub), msg, s)
+}
+
+// handshakeChallenge is what a peer signs to prove it holds its node key:
+// the nonce we sent in our version message.
+func handshakeChallenge(networkID uint32, nonce string) []byte {
+	return []byte(fmt.Sprintf("dvc-verack:%d:%s", networkID, nonce))
+}
+
+// verifyHandshake checks a peer's verack. A valid signature records the
+// peer's node ID. It reports false if the connection must be dropped: the
+// peer is ourselves, or it sits at a pinned address without proving the
+// pinned key.
+func (n *Node) verifyHandshake(peer *Peer, va VerackPayload) bool {
+	if va.Signature != "" && verifyNodeSig(va.NodeID, handshakeChallenge(n.Config.NetworkID, peer.nonce), va.Signature) {
+		if va.NodeID == n.Identity.ID() {
+			logger.Info("dropping connection to self", "peer", peer.Address)
+			return false
+		}
+		n.mu.Lock()
+		peer.NodeID = va.NodeID
+		n.mu.Unlock()
+	}
+	pin, ok := n.book.pin(peer.Address)
+	if ok && peer.NodeID != pin {
+		logger.Warn("pinned peer failed identity check", "peer", peer.Address,
+			"want", pin, "got", va.NodeID)
+		return false
+	}
+	return true
+}
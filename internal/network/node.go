@@ -2,27 +2,54 @@ package network
 
 import (
 	"bufio"
+	"crypto/rand"
+	"crypto/tls"
 	"devinsidercoin/internal/blockchain"
 	"devinsidercoin/internal/config"
+	"devinsidercoin/internal/logging"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"log"
+	"math/big"
 	"net"
+	"sort"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
-// Message is the P2P wire format.
+var logger = logging.For("P2P")
+
+// Message is the P2P wire format. Checksum covers Payload; see
+// payloadChecksum.
 type Message struct {
-	Type    string          `json:"type"`
-	Payload json.RawMessage `json:"payload"`
+	Type     string          `json:"type"`
+	Payload  json.RawMessage `json:"payload"`
+	Checksum string          `json:"checksum,omitempty"`
 }
 
+// DefaultUserAgent is announced by nodes that don't set Node.UserAgent.
+const DefaultUserAgent = "/devinsidercoin/"
+
 // VersionPayload is sent during handshake.
 type VersionPayload struct {
-	Version   uint32 `json:"version"`
-	Height    uint64 `json:"height"`
-	NetworkID uint32 `json:"network_id"`
+	Version   uint32      `json:"version"`
+	Height    uint64      `json:"height"`
+	NetworkID uint32      `json:"network_id"`
+	Services  ServiceFlag `json:"services,omitempty"`
+	NodeID    string      `json:"node_id,omitempty"`
+	Nonce     string      `json:"nonce,omitempty"`      // signed back in the peer's verack
+	ChainWork string      `json:"chain_work,omitempty"` // 64 hex digits of cumulative work
+	UserAgent string      `json:"user_agent,omitempty"` // software and version, e.g. /dvcnode:1.2.0/
+	Genesis   string      `json:"genesis,omitempty"`    // genesis block hash; peers on another chain are dropped
+}
+
+// VerackPayload completes the handshake. Signature proves the sender holds
+// the key for NodeID by signing our version nonce.
+type VerackPayload struct {
+	NodeID    string `json:"node_id,omitempty"`
+	Signature string `json:"signature,omitempty"`
 }
 
 // GetBlocksPayload requests blocks from a height.
@@ -32,16 +59,31 @@ type GetBlocksPayload struct {
 
 // Peer represents a connected peer.
 type Peer struct {
-	Conn    net.Conn
-	Address string
-	Height  uint64
-	writer  *bufio.Writer
-	mu      sync.Mutex
+	Conn      net.Conn
+	Address   string
+	Height    uint64
+	ChainWork *big.Int // nil if the peer doesn't advertise it
+	Version   uint32
+	Services  ServiceFlag
+	NodeID    string // set once the peer has proved it holds the key
+	CertPin   string // TLS certificate pin, on TLS networks
+	UserAgent string // as the peer announced it in its version
+	Inbound   bool   // the peer connected to us
+	writer    *bufio.Writer
+	mu        sync.Mutex
+	nonce     string
+
+	connectedAt time.Time
+	lastSend    atomic.Int64 // unix nanoseconds
+	lastRecv    atomic.Int64
+	protoErrors atomic.Int32
+	serving     atomic.Bool // a getblocks response is in progress
 }
 
 func (p *Peer) Send(msg Message) error {
 	p.mu.Lock()
 	defer p.mu.Unlock()
+	msg.Checksum = payloadChecksum(msg.Payload)
 	data, err := json.Marshal(msg)
 	if err != nil {
 		return err
@@ -50,25 +92,61 @@ func (p *Peer) Send(msg Message) error {
 	if err != nil {
 		return err
 	}
-	return p.writer.Flush()
+	if err := p.writer.Flush(); err != nil {
+		return err
+	}
+	p.lastSend.Store(time.Now().UnixNano())
+	return nil
 }
 
 // Node is the P2P networking layer.
 type Node struct {
-	Config     *config.NetworkConfig
-	Chain      *blockchain.Blockchain
-	Peers      map[string]*Peer
-	listener   net.Listener
-	mu         sync.RWMutex
-	OnNewBlock func(*blockchain.Block)
+	Config       *config.NetworkConfig
+	Chain        *blockchain.Blockchain
+	Peers        map[string]*Peer
+	Services     ServiceFlag // advertised to peers
+	Identity     *Identity   // signs handshakes and address announcements
+	ExternalAddr string      // host:port announced to peers, if any
+	UserAgent    string      // announced in our version message
+	genesis      string      // our genesis block hash, announced and compared in version messages
+	listener     net.Listener
+	banned       map[string]bool // from the settings banlist
+	adminBans    map[string]bool // added at runtime through the admin API
+	relay        *txRelay
+	prop         *propagationTracker
+	orphans      *orphanPool
+	book         *addrBook
+	serveSlots   chan struct{} // bounds concurrent getblocks responses
+	tls          *tls.Config   // mutual TLS for peers; nil for plain TCP
+	tlsPins      map[string]bool
+	sync         syncState
+	syncMu       sync.Mutex
+	closed       atomic.Bool // set by Close
+	mu           sync.RWMutex
 }
 
 // NewNode creates a P2P node.
 func NewNode(cfg *config.NetworkConfig, chain *blockchain.Blockchain) *Node {
+	var genesis string
+	if g := chain.GetBlockByHeight(0); g != nil {
+		genesis = g.Hash
+	}
 	return &Node{
-		Config: cfg,
-		Chain:  chain,
-		Peers:  make(map[string]*Peer),
+		Config:    cfg,
+		Chain:     chain,
+		Peers:     make(map[string]*Peer),
+		Services:  DefaultServices,
+		Identity:  NewIdentity(),
+		UserAgent: DefaultUserAgent,
+		genesis:   genesis,
+		banned:    make(map[string]bool),
+		adminBans: make(map[string]bool),
+		relay:     newTxRelay(chain),
+		prop:      newPropagationTracker(),
+		orphans:   newOrphanPool(),
+		book:      newAddrBook(),
+
+		serveSlots: make(chan struct{}, maxConcurrentServes),
 	}
 }
 
@@ -79,8 +157,14 @@ func (n *Node) Start(port int) error {
 	if err != nil {
 		return err
 	}
-	log.Printf("[P2P] Listening on :%d", port)
+	if n.tls != nil {
+		n.listener = tls.NewListener(n.listener, n.tls)
+	}
+	logger.Info("listening", "port", port, "node_id", n.Identity.ID(), "tls", n.tls != nil)
+	n.loadSyncProgress()
 	go n.acceptLoop()
+	go n.announceLoop()
+	go n.syncLoop()
 	return nil
 }
 
@@ -88,22 +172,135 @@ func (n *Node) acceptLoop() {
 	for {
 		conn, err := n.listener.Accept()
 		if err != nil {
+			if n.closed.Load() {
+				return
+			}
 			continue
 		}
-		go n.handlePeer(conn)
+		go n.handlePeer(conn, true)
 	}
 }
 
 // ConnectPeer connects to a remote peer.
 func (n *Node) ConnectPeer(address string) error {
-	conn, err := net.DialTimeout("tcp", address, 10*time.Second)
+	if n.isBanned(address) {
+		return fmt.Errorf("peer %s is banned", address)
+	}
+	conn, err := n.dial(address)
 	if err != nil {
 		return err
 	}
-	go n.handlePeer(conn)
+	go n.handlePeer(conn, false)
+	return nil
+}
+
+// SetBanlist replaces the set of banned peer IPs and drops any connected
+// peer that is now banned. Bans made with Ban are kept.
+func (n *Node) SetBanlist(ips []string) {
+	n.mu.Lock()
+	n.banned = make(map[string]bool, len(ips))
+	for _, ip := range ips {
+		n.banned[ip] = true
+	}
+	n.mu.Unlock()
+	n.dropBanned()
+}
+
+// Ban refuses ip until Unban or a restart, on top of the settings
+// banlist, and drops its connected peers.
+func (n *Node) Ban(ip string) {
+	n.mu.Lock()
+	n.adminBans[ip] = true
+	n.mu.Unlock()
+	n.dropBanned()
+}
+
+// Unban lifts a ban made with Ban. IPs on the settings banlist stay
+// banned until they are removed there.
+func (n *Node) Unban(ip string) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if !n.adminBans[ip] {
+		return fmt.Errorf("%s is not banned at runtime", ip)
+	}
+	delete(n.adminBans, ip)
 	return nil
 }
 
+// BanEntry is one banned peer IP.
+type BanEntry struct {
+	IP     string `json:"ip"`
+	Source string `json:"source"` // settings or admin
+}
+
+// Banlist returns every banned IP, sorted.
+func (n *Node) Banlist() []BanEntry {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+	list := []BanEntry{}
+	for ip := range n.banned {
+		list = append(list, BanEntry{IP: ip, Source: "settings"})
+	}
+	for ip := range n.adminBans {
+		if !n.banned[ip] {
+			list = append(list, BanEntry{IP: ip, Source: "admin"})
+		}
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].IP < list[j].IP })
+	return list
+}
+
+// dropBanned disconnects every connected peer that is banned.
+func (n *Node) dropBanned() {
+	n.mu.RLock()
+	var drop []*Peer
+	for addr, p := range n.Peers {
+		if host := hostOf(addr); n.banned[host] || n.adminBans[host] {
+			drop = append(drop, p)
+		}
+	}
+	n.mu.RUnlock()
+
+	for _, p := range drop {
+		logger.Info("disconnecting banned peer", "peer", p.Address)
+		p.Conn.Close()
+	}
+}
+
+// DisconnectPeer closes the connection to a peer.
+func (n *Node) DisconnectPeer(address string) error {
+	n.mu.RLock()
+	p, ok := n.Peers[address]
+	n.mu.RUnlock()
+	if !ok {
+		return fmt.Errorf("peer not connected: %s", address)
+	}
+	return p.Conn.Close()
+}
+
+// IsConnected reports whether a peer with this address is connected.
+func (n *Node) IsConnected(address string) bool {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+	_, ok := n.Peers[address]
+	return ok
+}
+
+func (n *Node) isBanned(address string) bool {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+	host := hostOf(address)
+	return n.banned[host] || n.adminBans[host]
+}
+
+func hostOf(address string) string {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return address
+	}
+	return host
+}
+
 // GetPeerCount returns number of connected peers.
 func (n *Node) GetPeerCount() int {
 	n.mu.RLock()
@@ -122,6 +319,83 @@ func (n *Node) GetPeerAddresses() []string {
 	return addrs
 }
 
+// PeerInfo describes a connected peer for RPC clients.
+type PeerInfo struct {
+	Address        string   `json:"address"`
+	Version        uint32   `json:"version"`
+	Height         uint64   `json:"height"`
+	ChainWork      string   `json:"chain_work,omitempty"`
+	Services       []string `json:"services"`
+	NodeID         string   `json:"node_id,omitempty"`
+	CertPin        string   `json:"cert_pin,omitempty"`
+	ProtocolErrors int32    `json:"protocol_errors"`
+
+	UserAgent string    `json:"user_agent,omitempty"`
+	Direction string    `json:"direction"` // inbound or outbound
+	ConnTime  time.Time `json:"conn_time"`
+	LastSend  time.Time `json:"last_send"`
+	LastRecv  time.Time `json:"last_recv"`
+}
+
+// GetPeerInfo returns details of connected peers.
+func (n *Node) GetPeerInfo() []PeerInfo {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+	infos := make([]PeerInfo, 0, len(n.Peers))
+	for addr, p := range n.Peers {
+		var work string
+		if p.ChainWork != nil {
+			work = blockchain.FormatChainWork(p.ChainWork)
+		}
+		infos = append(infos, PeerInfo{
+			Address:        addr,
+			Version:        p.Version,
+			Height:         p.Height,
+			ChainWork:      work,
+			Services:       p.Services.Names(),
+			NodeID:         p.NodeID,
+			CertPin:        p.CertPin,
+			ProtocolErrors: p.protoErrors.Load(),
+			UserAgent:      p.UserAgent,
+			Direction:      p.direction(),
+			ConnTime:       p.connectedAt,
+			LastSend:       unixNano(p.lastSend.Load()),
+			LastRecv:       unixNano(p.lastRecv.Load()),
+		})
+	}
+	return infos
+}
+
+func (p *Peer) direction() string {
+	if p.Inbound {
+		return "inbound"
+	}
+	return "outbound"
+}
+
+// unixNano converts a timestamp stored as unix nanoseconds, where 0 means
+// never, to a time.
+func unixNano(ns int64) time.Time {
+	if ns == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, ns)
+}
+
+// PeersWithServices returns the addresses of peers advertising every
+// service in f.
+func (n *Node) PeersWithServices(f ServiceFlag) []string {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+	var addrs []string
+	for addr, p := range n.Peers {
+		if p.Services.Has(f) {
+			addrs = append(addrs, addr)
+		}
+	}
+	return addrs
+}
+
 // BroadcastBlock sends a block to all connected peers.
 func (n *Node) BroadcastBlock(block *blockchain.Block) {
 	n.mu.RLock()
@@ -133,109 +407,258 @@ func (n *Node) BroadcastBlock(block *blockchain.Block) {
 	}
 }
 
-// BroadcastTx sends a transaction to all peers.
-func (n *Node) BroadcastTx(tx *blockchain.Transaction) {
+// relayBlock sends a block to every peer except origin.
+func (n *Node) relayBlock(block *blockchain.Block, origin string) {
 	n.mu.RLock()
 	defer n.mu.RUnlock()
-	payload, _ := json.Marshal(tx)
-	msg := Message{Type: "tx", Payload: payload}
-	for _, peer := range n.Peers {
-		peer.Send(msg)
+	payload, _ := json.Marshal(block)
+	msg := Message{Type: "block", Payload: payload}
+	for addr, p := range n.Peers {
+		if addr != origin {
+			p.Send(msg)
+		}
 	}
 }
 
-func (n *Node) handlePeer(conn net.Conn) {
+// BroadcastTx sends a transaction to all peers and returns how many it
+// reached.
+func (n *Node) BroadcastTx(tx *blockchain.Transaction) int {
+	payload, _ := json.Marshal(tx)
+	return n.sendTx(tx, Message{Type: "tx", Payload: payload}, "")
+}
+
+// handlePeer runs a connection until it closes. inbound is true for
+// connections the peer opened.
+func (n *Node) handlePeer(conn net.Conn, inbound bool) {
+	if n.isBanned(conn.RemoteAddr().String()) {
+		conn.Close()
+		return
+	}
+	pin, err := tlsHandshake(conn)
+	if err != nil {
+		logger.Info("peer TLS handshake failed", "peer", conn.RemoteAddr().String(), "err", err)
+		conn.Close()
+		return
+	}
+	nonce := make([]byte, 16)
+	rand.Read(nonce)
 	peer := &Peer{
 		Conn:    conn,
 		Address: conn.RemoteAddr().String(),
+		CertPin: pin,
+		Inbound: inbound,
 		writer:  bufio.NewWriter(conn),
+		nonce:   hex.EncodeToString(nonce),
+
+		connectedAt: time.Now(),
 	}
 
 	n.mu.Lock()
 	n.Peers[peer.Address] = peer
 	n.mu.Unlock()
 
-	log.Printf("[P2P] Peer connected: %s", peer.Address)
+	logger.Info("peer connected", "peer", peer.Address, "inbound", inbound)
 
 	// Send version
 	vp, _ := json.Marshal(VersionPayload{
 		Version:   n.Config.ProtocolVersion,
 		Height:    n.Chain.GetBestHeight(),
 		NetworkID: n.Config.NetworkID,
+		Services:  n.Services,
+		NodeID:    n.Identity.ID(),
+		Nonce:     peer.nonce,
+		ChainWork: blockchain.FormatChainWork(n.Chain.GetChainWork()),
+		UserAgent: n.UserAgent,
+		Genesis:   n.genesis,
 	})
 	peer.Send(Message{Type: "version", Payload: vp})
 
+	// Messages are JSON, which is several times larger than the canonical
+	// encoding max_block_size is measured in.
+	maxMsg := 10 * 1024 * 1024
+	if m := int(4 * n.Config.MaxBlockSize); m > maxMsg {
+		maxMsg = m
+	}
 	scanner := bufio.NewScanner(conn)
-	scanner.Buffer(make([]byte, 10*1024*1024), 10*1024*1024)
+	scanner.Buffer(make([]byte, 64*1024), maxMsg)
 
 	for scanner.Scan() {
+		peer.lastRecv.Store(time.Now().UnixNano())
 		var msg Message
-		if err := json.Unmarshal(scanner.Bytes(), &msg); err != nil {
-			continue
+		err := decodePayload(scanner.Bytes(), &msg, msg.check)
+		if err == nil {
+			err = msg.verify()
+		}
+		if err == nil {
+			err = n.handleMessage(peer, msg)
+		}
+		if err != nil && n.protocolError(peer, err) {
+			break
 		}
-		n.handleMessage(peer, msg)
+	}
+	if err := scanner.Err(); err != nil && !errors.Is(err, net.ErrClosed) {
+		logger.Warn("peer read failed", "peer", peer.Address, "err", err)
 	}
 
 	n.mu.Lock()
 	delete(n.Peers, peer.Address)
 	n.mu.Unlock()
 	conn.Close()
-	log.Printf("[P2P] Peer disconnected: %s", peer.Address)
+	logger.Info("peer disconnected", "peer", peer.Address)
 }
 
-func (n *Node) handleMessage(peer *Peer, msg Message) {
+// handleMessage processes one message. It returns an error only for
+// malformed payloads; rejected blocks and transactions are not protocol
+// errors.
+func (n *Node) handleMessage(peer *Peer, msg Message) error {
 	switch msg.Type {
 	case "version":
 		var vp VersionPayload
-		json.Unmarshal(msg.Payload, &vp)
+		if err := decodePayload(msg.Payload, &vp, vp.check); err != nil {
+			return fmt.Errorf("bad version payload: %w", err)
+		}
+		if vp.Genesis != "" && vp.Genesis != n.genesis {
+			logger.Warn("dropping peer on another chain", "peer", peer.Address, "genesis", vp.Genesis)
+			peer.Conn.Close()
+			return nil
+		}
 		peer.Height = vp.Height
-		log.Printf("[P2P] Peer %s: version=%d height=%d", peer.Address, vp.Version, vp.Height)
+		if vp.ChainWork != "" {
+			peer.ChainWork, _ = new(big.Int).SetString(vp.ChainWork, 16)
+		}
+		peer.Version = vp.Version
+		peer.Services = vp.Services
+		peer.UserAgent = vp.UserAgent
+		logger.Info("peer version", "peer", peer.Address, "version", vp.Version,
+			"user_agent", vp.UserAgent, "height", vp.Height, "services", vp.Services)
 
-		ack, _ := json.Marshal(struct{}{})
+		va := VerackPayload{NodeID: n.Identity.ID()}
+		if vp.Nonce != "" {
+			va.Signature = n.Identity.sign(handshakeChallenge(n.Config.NetworkID, vp.Nonce))
+		}
+		ack, _ := json.Marshal(va)
 		peer.Send(Message{Type: "verack", Payload: ack})
 
-		if vp.Height > n.Chain.GetBestHeight() {
-			n.requestBlocks(peer, n.Chain.GetBestHeight()+1)
-		}
+		n.maybeSync(peer)
 
 	case "verack":
-		// Handshake complete
+		var va VerackPayload
+		if err := decodePayload(msg.Payload, &va, va.check); err != nil {
+			return fmt.Errorf("bad verack payload: %w", err)
+		}
+		if !n.verifyHandshake(peer, va) {
+			peer.Conn.Close()
+			return nil
+		}
+		getaddr, _ := json.Marshal(struct{}{})
+		peer.Send(Message{Type: "getaddr", Payload: getaddr})
+		n.sendRecentAttestations(peer)
+
+	case "getaddr":
+		n.sendAddr(peer)
+
+	case "addr":
+		var addrs []Announcement
+		if err := decodePayload(msg.Payload, &addrs, func() error { return checkAnnouncements(addrs) }); err != nil {
+			return fmt.Errorf("bad addr payload: %w", err)
+		}
+		return n.handleAddr(peer, addrs)
 
 	case "getblocks":
 		var gb GetBlocksPayload
-		json.Unmarshal(msg.Payload, &gb)
-		n.sendBlocks(peer, gb.FromHeight)
+		if err := decodePayload(msg.Payload, &gb, nil); err != nil {
+			return fmt.Errorf("bad getblocks payload: %w", err)
+		}
+		// One response per peer at a time; a peer asking again before the
+		// last batch finished is ignored.
+		if !peer.serving.CompareAndSwap(false, true) {
+			logger.Debug("getblocks already in progress", "peer", peer.Address)
+			return nil
+		}
+		go n.sendBlocks(peer, gb.FromHeight)
+
+	case "moreblocks":
+		var gb GetBlocksPayload
+		if err := decodePayload(msg.Payload, &gb, nil); err != nil {
+			return fmt.Errorf("bad moreblocks payload: %w", err)
+		}
+		// Continue from our own tip rather than gb.FromHeight, in case
+		// some of the last batch was rejected, and only with the sync peer.
+		n.syncCheckpoint()
+		if next := n.Chain.GetBestHeight() + 1; gb.FromHeight >= next && n.SyncStatus().Peer == peer.Address {
+			n.requestBlocks(peer, next)
+		}
 
 	case "block":
+		heard := time.Now()
 		var block blockchain.Block
-		json.Unmarshal(msg.Payload, &block)
+		if err := decodePayload(msg.Payload, &block, func() error {
+			return checkBlock(&block, n.Config.MaxBlockTransactions)
+		}); err != nil {
+			n.sendReject(peer, "block", block.Hash, blockchain.RejectMalformed, err)
+			return fmt.Errorf("bad block payload: %w", err)
+		}
 		if block.Header.Height <= n.Chain.GetBestHeight() {
-			return
+			return nil
+		}
+		// A block beyond the next height waits for its parents.
+		if block.Header.Height > n.Chain.GetBestHeight()+1 {
+			n.addOrphan(peer, &block)
+			return nil
 		}
 		err := n.Chain.AddBlock(&block)
 		if err != nil {
-			log.Printf("[P2P] Block rejected from %s: %v", peer.Address, err)
-			return
+			logger.Warn("block rejected", "peer", peer.Address, "err", err)
+			n.sendReject(peer, "block", block.Hash, blockchain.RejectCode(err), err)
+			return nil
 		}
-		if n.OnNewBlock != nil {
-			n.OnNewBlock(&block)
+		validated := time.Now()
+		syncing := n.SyncStatus().Syncing
+		n.syncedBlock(peer, block.Header.Height)
+		n.relayBlock(&block, peer.Address)
+		if !syncing {
+			n.prop.record(heard, validated, time.Now())
 		}
-		// Relay to other peers
-		n.mu.RLock()
-		payload, _ := json.Marshal(&block)
-		relay := Message{Type: "block", Payload: payload}
-		for addr, p := range n.Peers {
-			if addr != peer.Address {
-				p.Send(relay)
-			}
-		}
-		n.mu.RUnlock()
+		n.connectOrphans(block.Hash)
 
 	case "tx":
 		var tx blockchain.Transaction
-		json.Unmarshal(msg.Payload, &tx)
-		n.Chain.AddToMempool(tx)
+		if err := decodePayload(msg.Payload, &tx, func() error { return checkTx(&tx) }); err != nil {
+			n.sendReject(peer, "tx", tx.TxID, blockchain.RejectMalformed, err)
+			return fmt.Errorf("bad tx payload: %w", err)
+		}
+		// Only relay what our own mempool policy accepts, so spam stops
+		// at the first honest node.
+		if err := n.Chain.AddToMempool(tx); err != nil {
+			logger.Debug("tx rejected", "peer", peer.Address, "txid", tx.TxID, "err", err)
+			n.sendReject(peer, "tx", tx.TxID, blockchain.RejectCode(err), err)
+			return nil
+		}
+		n.sendTx(&tx, msg, peer.Address)
+
+	case "getattestations":
+		n.sendRecentAttestations(peer)
+
+	case "attestation":
+		if err := n.handleAttestation(peer, msg); err != nil {
+			return fmt.Errorf("bad attestation payload: %w", err)
+		}
+
+	case "closing":
+		var cp ClosingPayload
+		if err := decodePayload(msg.Payload, &cp, cp.check); err != nil {
+			return fmt.Errorf("bad closing payload: %w", err)
+		}
+		n.handleClosing(peer, cp)
+
+	case "reject":
+		var rp RejectPayload
+		if err := decodePayload(msg.Payload, &rp, rp.check); err != nil {
+			return fmt.Errorf("bad reject payload: %w", err)
+		}
+		n.handleReject(peer, rp)
 	}
+	return nil
 }
 
 func (n *Node) requestBlocks(peer *Peer, fromHeight uint64) {
@@ -243,10 +666,26 @@ func (n *Node) requestBlocks(peer *Peer, fromHeight uint64) {
 	peer.Send(Message{Type: "getblocks", Payload: payload})
 }
 
+// sendBlocks answers getblocks with at most maxBlocksPerRequest blocks. If
+// more remain it ends with a moreblocks message carrying the next height,
+// and the peer asks again once it has processed the batch.
 func (n *Node) sendBlocks(peer *Peer, fromHeight uint64) {
-	blocks := n.Chain.GetBlocks(fromHeight)
+	defer peer.serving.Store(false)
+	n.serveSlots <- struct{}{}
+	defer func() { <-n.serveSlots }()
+
+	blocks := n.Chain.GetBlocks(fromHeight, maxBlocksPerRequest)
 	for _, block := range blocks {
 		payload, _ := json.Marshal(block)
-		peer.Send(Message{Type: "block", Payload: payload})
+		if peer.Send(Message{Type: "block", Payload: payload}) != nil {
+			return
+		}
+	}
+	if len(blocks) == maxBlocksPerRequest {
+		next := blocks[len(blocks)-1].Header.Height + 1
+		if next <= n.Chain.GetBestHeight() {
+			payload, _ := json.Marshal(GetBlocksPayload{FromHeight: next})
+			peer.Send(Message{Type: "moreblocks", Payload: payload})
+		}
 	}
 }
@@ -0,0 +1,145 @@
+package network
+
+import (
+	"devinsidercoin/internal/blockchain"
+	"sync"
+	"time"
+)
+
+const (
+	maxOrphans   = 100              // blocks held while their parents are fetched
+	orphanExpiry = 10 * time.Minute // orphans whose parents never arrive are dropped
+)
+
+type orphan struct {
+	block *blockchain.Block
+	peer  *Peer // who sent it
+	added time.Time
+}
+
+// orphanPool holds blocks that arrived ahead of their parents, keyed by
+// the hash of the parent they wait for.
+type orphanPool struct {
+	mu     sync.Mutex
+	byHash map[string]*orphan
+	byPrev map[string][]*orphan
+}
+
+func newOrphanPool() *orphanPool {
+	return &orphanPool{
+		byHash: make(map[string]*orphan),
+		byPrev: make(map[string][]*orphan),
+	}
+}
+
+// add stores block from peer. Expired orphans are dropped first, then the
+// oldest if the pool is full. It reports false if block is already held.
+func (p *orphanPool) add(block *blockchain.Block, peer *Peer) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if _, ok := p.byHash[block.Hash]; ok {
+		return false
+	}
+	now := time.Now()
+	var oldest *orphan
+	for _, o := range p.byHash {
+		if now.Sub(o.added) > orphanExpiry {
+			p.remove(o)
+		} else if oldest == nil || o.added.Before(oldest.added) {
+			oldest = o
+		}
+	}
+	if len(p.byHash) >= maxOrphans && oldest != nil {
+		p.remove(oldest)
+	}
+	o := &orphan{block: block, peer: peer, added: now}
+	p.byHash[block.Hash] = o
+	p.byPrev[block.Header.PrevHash] = append(p.byPrev[block.Header.PrevHash], o)
+	return true
+}
+
+// take removes and returns the orphans waiting for parent.
+func (p *orphanPool) take(parent string) []*orphan {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	children := p.byPrev[parent]
+	for _, o := range children {
+		p.remove(o)
+	}
+	return children
+}
+
+// remove drops o. The caller holds p.mu.
+func (p *orphanPool) remove(o *orphan) {
+	delete(p.byHash, o.block.Hash)
+	prev := o.block.Header.PrevHash
+	siblings := p.byPrev[prev]
+	for i, s := range siblings {
+		if s == o {
+			siblings = append(siblings[:i], siblings[i+1:]...)
+			break
+		}
+	}
+	if len(siblings) == 0 {
+		delete(p.byPrev, prev)
+	} else {
+		p.byPrev[prev] = siblings
+	}
+}
+
+func (p *orphanPool) len() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.byHash)
+}
+
+// addOrphan keeps a block from peer that is ahead of our tip and asks the
+// peer for the blocks in between.
+func (n *Node) addOrphan(peer *Peer, block *blockchain.Block) {
+	if !n.orphans.add(block, peer) {
+		return
+	}
+	logger.Info("holding orphan block", "peer", peer.Address, "height", block.Header.Height,
+		"hash", block.Hash, "orphans", n.orphans.len())
+	if block.Header.Height > peer.Height {
+		peer.Height = block.Header.Height
+		// The peer has blocks beyond the work it advertised; until we
+		// have them, go by height.
+		peer.ChainWork = nil
+	}
+	// Already fetching from this peer: just sync further.
+	n.syncMu.Lock()
+	st := &n.sync.status
+	if st.Syncing && st.Peer == peer.Address {
+		st.TargetHeight = max(st.TargetHeight, block.Header.Height)
+		n.syncMu.Unlock()
+		return
+	}
+	n.syncMu.Unlock()
+	n.maybeSync(peer)
+}
+
+// connectOrphans connects the held blocks that descend from hash, now that
+// it is on our chain, and relays them.
+func (n *Node) connectOrphans(hash string) {
+	for queue := []string{hash}; len(queue) > 0; queue = queue[1:] {
+		for _, o := range n.orphans.take(queue[0]) {
+			if o.block.Header.Height <= n.Chain.GetBestHeight() {
+				continue
+			}
+			if err := n.Chain.AddBlock(o.block); err != nil {
+				logger.Warn("orphan block rejected", "peer", o.peer.Address, "hash", o.block.Hash, "err", err)
+				continue
+			}
+			logger.Info("connected orphan block", "height", o.block.Header.Height, "hash", o.block.Hash)
+			n.syncedBlock(o.peer, o.block.Header.Height)
+			n.relayBlock(o.block, o.peer.Address)
+			queue = append(queue, o.block.Hash)
+		}
+	}
+}
+
+// OrphanCount returns how many blocks are waiting for their parents.
+func (n *Node) OrphanCount() int {
+	return n.orphans.len()
+}
@@ -0,0 +1,88 @@
+package network
+
+import (
+	"math"
+	"sort"
+	"sync"
+	"time"
+)
+
+// propagationSamples is how many recent relayed blocks the propagation
+// percentiles cover.
+const propagationSamples = 1000
+
+// LatencySummary gives percentiles of a latency in milliseconds.
+type LatencySummary struct {
+	P50 float64 `json:"p50"`
+	P90 float64 `json:"p90"`
+	P99 float64 `json:"p99"`
+	Max float64 `json:"max"`
+}
+
+// PropagationStats measures how long this node holds new blocks from
+// peers: Validate from first hearing a block to having connected it, and
+// Relay from first hearing it to having sent it to every other peer.
+// Blocks downloaded during a sync are not counted.
+type PropagationStats struct {
+	Samples  int            `json:"samples"`
+	Validate LatencySummary `json:"validate_ms"`
+	Relay    LatencySummary `json:"relay_ms"`
+}
+
+// propagationTracker keeps the latencies of the last propagationSamples
+// blocks in a ring.
+type propagationTracker struct {
+	mu       sync.Mutex
+	validate []float64
+	relay    []float64
+	next     int
+}
+
+func newPropagationTracker() *propagationTracker {
+	return &propagationTracker{}
+}
+
+// record adds a block first heard at heard, connected at validated and
+// relayed at relayed.
+func (t *propagationTracker) record(heard, validated, relayed time.Time) {
+	v := float64(validated.Sub(heard).Microseconds()) / 1000
+	r := float64(relayed.Sub(heard).Microseconds()) / 1000
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if len(t.validate) < propagationSamples {
+		t.validate = append(t.validate, v)
+		t.relay = append(t.relay, r)
+		return
+	}
+	t.validate[t.next], t.relay[t.next] = v, r
+	t.next = (t.next + 1) % propagationSamples
+}
+
+func (t *propagationTracker) stats() PropagationStats {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return PropagationStats{
+		Samples:  len(t.validate),
+		Validate: summarize(t.validate),
+		Relay:    summarize(t.relay),
+	}
+}
+
+// summarize returns nearest-rank percentiles of samples.
+func summarize(samples []float64) LatencySummary {
+	if len(samples) == 0 {
+		return LatencySummary{}
+	}
+	sorted := append([]float64(nil), samples...)
+	sort.Float64s(sorted)
+	rank := func(p float64) float64 {
+		i := int(math.Ceil(p*float64(len(sorted)))) - 1
+		return sorted[max(i, 0)]
+	}
+	return LatencySummary{P50: rank(0.5), P90: rank(0.9), P99: rank(0.99), Max: sorted[len(sorted)-1]}
+}
+
+// PropagationStats returns the block propagation latencies of this node.
+func (n *Node) PropagationStats() PropagationStats {
+	return n.prop.stats()
+}
@@ -0,0 +1,54 @@
+package network
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+)
+
+const (
+	// maxProtocolErrors is how many malformed frames a peer may send before
+	// it is disconnected.
+	maxProtocolErrors = 10
+
+	// maxBlocksPerRequest caps the blocks sent for one getblocks; the rest
+	// follow on request (see sendBlocks).
+	maxBlocksPerRequest = 500
+
+	// maxConcurrentServes bounds getblocks responses in progress across all
+	// peers.
+	maxConcurrentServes = 4
+)
+
+// payloadChecksum returns the first four bytes of SHA-256d(payload), hex
+// encoded.
+func payloadChecksum(payload []byte) string {
+	first := sha256.Sum256(payload)
+	second := sha256.Sum256(first[:])
+	return hex.EncodeToString(second[:4])
+}
+
+// verify checks the payload against its checksum. Messages from peers
+// that predate checksums carry none and are accepted.
+func (m *Message) verify() error {
+	if m.Checksum == "" {
+		return nil
+	}
+	if sum := payloadChecksum(m.Payload); sum != m.Checksum {
+		return fmt.Errorf("checksum mismatch on %s: got %s, want %s", m.Type, m.Checksum, sum)
+	}
+	return nil
+}
+
+// protocolError records a malformed frame from peer and reports whether the
+// peer has now exceeded maxProtocolErrors and should be dropped.
+func (n *Node) protocolError(peer *Peer, err error) bool {
+	count := peer.protoErrors.Add(1)
+	logger.Debug("protocol error", "peer", peer.Address, "count", count, "err", err)
+	if count < maxProtocolErrors {
+		return false
+	}
+	logger.Warn("disconnecting peer after repeated protocol errors",
+		"peer", peer.Address, "errors", count, "last", err)
+	return true
+}
@@ -0,0 +1,76 @@
+package network
+
+import (
+	"devinsidercoin/internal/blockchain"
+	"encoding/json"
+	"fmt"
+)
+
+// maxRejectReasonLen bounds the human-readable reason in a reject message.
+const maxRejectReasonLen = 256
+
+// RejectPayload tells a peer why a block or transaction it sent was
+// refused. Code is one of the blockchain.Reject* codes.
+type RejectPayload struct {
+	Message string `json:"message"` // "block" or "tx"
+	Code    string `json:"code"`
+	Reason  string `json:"reason,omitempty"`
+	Hash    string `json:"hash,omitempty"` // block hash or txid, if it could be decoded
+}
+
+func (rp *RejectPayload) check() error {
+	switch {
+	case rp.Message != "block" && rp.Message != "tx":
+		return fmt.Errorf("reject for unknown message %q", rp.Message)
+	case rp.Code == "" || len(rp.Code) > maxTxTypeLen:
+		return fmt.Errorf("bad reject code %q", rp.Code)
+	case len(rp.Reason) > maxRejectReasonLen:
+		return fmt.Errorf("reject reason too long")
+	case rp.Hash != "" && !isHex(rp.Hash, 64):
+		return fmt.Errorf("bad reject hash")
+	}
+	return nil
+}
+
+// Rejection is a peer's refusal of a transaction this node relayed to it.
+type Rejection struct {
+	Peer   string `json:"peer"`
+	Code   string `json:"code"`
+	Reason string `json:"reason,omitempty"`
+}
+
+// sendReject tells peer why the block or tx with hash was refused.
+// Duplicates are normal gossip and are not reported.
+func (n *Node) sendReject(peer *Peer, message, hash, code string, err error) {
+	if code == blockchain.RejectDuplicate {
+		return
+	}
+	reason := err.Error()
+	if len(reason) > maxRejectReasonLen {
+		reason = reason[:maxRejectReasonLen]
+	}
+	if hash != "" && !isHex(hash, 64) {
+		hash = ""
+	}
+	payload, _ := json.Marshal(RejectPayload{Message: message, Code: code, Reason: reason, Hash: hash})
+	peer.Send(Message{Type: "reject", Payload: payload})
+}
+
+// handleReject records a peer's refusal. A refused transaction is not
+// sent to that peer again unless it was only outside the peer's relay
+// policy.
+func (n *Node) handleReject(peer *Peer, rp RejectPayload) {
+	switch rp.Message {
+	case "tx":
+		logger.Debug("peer rejected tx", "peer", peer.Address, "txid", rp.Hash, "code", rp.Code, "reason", rp.Reason)
+		if rp.Hash != "" {
+			n.relay.rejected(rp.Hash, Rejection{Peer: peer.Address, Code: rp.Code, Reason: rp.Reason})
+		}
+	case "block":
+		if rp.Code == blockchain.RejectUnconnected {
+			logger.Debug("peer could not connect block", "peer", peer.Address, "hash", rp.Hash)
+			return
+		}
+		logger.Warn("peer rejected block", "peer", peer.Address, "hash", rp.Hash, "code", rp.Code, "reason", rp.Reason)
+	}
+}
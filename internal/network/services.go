@@ -0,0 +1,66 @@
+package network
+
+import "strings"
+
+// ServiceFlag is a bitfield advertised in the version message describing
+// what a node can serve. Peers that predate the field send 0.
+type ServiceFlag uint64
+
+const (
+	// ServiceFullBlocks serves blocks to syncing peers.
+	ServiceFullBlocks ServiceFlag = 1 << iota
+	// ServicePruned keeps only recent blocks; cannot serve a full sync.
+	ServicePruned
+	// ServiceSPV answers light-client queries (headers, proofs).
+	ServiceSPV
+	// ServiceStratum runs a stratum endpoint for miners.
+	ServiceStratum
+	// ServiceArchive keeps every optional index (address and balance
+	// history, rich list, block filters) and answers deep historical
+	// queries.
+	ServiceArchive
+)
+
+var serviceNames = []struct {
+	flag ServiceFlag
+	name string
+}{
+	{ServiceFullBlocks, "full_blocks"},
+	{ServicePruned, "pruned"},
+	{ServiceSPV, "spv"},
+	{ServiceStratum, "stratum"},
+	{ServiceArchive, "archive"},
+}
+
+// DefaultServices is what an unpruned node advertises. Archive nodes add
+// ServiceArchive.
+const DefaultServices = ServiceFullBlocks
+
+// Has reports whether every bit in f is set.
+func (s ServiceFlag) Has(f ServiceFlag) bool {
+	return s&f == f
+}
+
+// Names returns the names of the set flags.
+func (s ServiceFlag) Names() []string {
+	names := []string{}
+	for _, sn := range serviceNames {
+		if s.Has(sn.flag) {
+			names = append(names, sn.name)
+		}
+	}
+	return names
+}
+
+func (s ServiceFlag) String() string {
+	if s == 0 {
+		return "none"
+	}
+	return strings.Join(s.Names(), "|")
+}
+
+// servesBlocks reports whether a peer can be asked for historical blocks.
+// Legacy peers advertise nothing and are assumed to be full nodes.
+func (s ServiceFlag) servesBlocks() bool {
+	return s == 0 || (s.Has(ServiceFullBlocks) && !s.Has(ServicePruned))
+}
@@ -0,0 +1,218 @@
+package network
+
+import (
+	"encoding/json"
+	"time"
+)
+
+const (
+	metaSyncProgress = "sync_progress"
+	syncStallTimeout = time.Minute // switch sync peer after this long without a block
+	syncCheckPeriod  = 10 * time.Second
+)
+
+// SyncStatus is the block download checkpoint, persisted after every batch
+// so a restarted node knows where it was and whom it was syncing from.
+type SyncStatus struct {
+	Syncing      bool      `json:"syncing"`
+	Peer         string    `json:"peer,omitempty"`
+	Height       uint64    `json:"height"` // last validated block
+	Hash         string    `json:"hash"`
+	TargetHeight uint64    `json:"target_height"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+// syncState tracks the single peer blocks are downloaded from, so the same
+// range is never requested from several peers at once.
+type syncState struct {
+	status    SyncStatus
+	lastBlock time.Time
+}
+
+// loadSyncProgress restores the persisted checkpoint. A checkpoint whose
+// block is no longer on our chain (after a reorg or a wiped datadir) is
+// dropped; sync always continues from the local tip.
+func (n *Node) loadSyncProgress() {
+	raw := n.Chain.Store.GetMeta(metaSyncProgress)
+	if raw == nil {
+		return
+	}
+	var st SyncStatus
+	if err := json.Unmarshal(raw, &st); err != nil || !st.Syncing {
+		return
+	}
+	if b := n.Chain.GetBlockByHeight(st.Height); b == nil || b.Hash != st.Hash {
+		logger.Warn("discarding sync checkpoint not on our chain", "height", st.Height, "hash", st.Hash)
+		return
+	}
+	best := n.Chain.GetBestHeight()
+	if st.TargetHeight <= best {
+		return
+	}
+	logger.Info("resuming sync", "height", best, "target", st.TargetHeight, "peer", st.Peer)
+	n.syncMu.Lock()
+	n.sync.status = st
+	n.syncMu.Unlock()
+	if st.Peer != "" && !n.IsConnected(st.Peer) {
+		go func() {
+			if err := n.ConnectPeer(st.Peer); err != nil {
+				logger.Info("previous sync peer unreachable", "peer", st.Peer, "err", err)
+			}
+		}()
+	}
+}
+
+// saveSyncProgress persists the checkpoint. Callers hold syncMu.
+func (n *Node) saveSyncProgress() {
+	st := &n.sync.status
+	if best := n.Chain.GetBestBlock(); best != nil {
+		st.Height, st.Hash = best.Header.Height, best.Hash
+	}
+	st.UpdatedAt = time.Now()
+	data, _ := json.Marshal(st)
+	if err := n.Chain.Store.PutMeta(metaSyncProgress, data); err != nil {
+		logger.Warn("failed to save sync progress", "err", err)
+	}
+}
+
+// maybeSync starts downloading from peer if it is ahead of us and no other
+// sync peer is active.
+func (n *Node) maybeSync(peer *Peer) {
+	best := n.Chain.GetBestHeight()
+	if !n.aheadOfUs(peer) || !peer.Services.servesBlocks() {
+		return
+	}
+	n.syncMu.Lock()
+	st := &n.sync.status
+	if peer.Height > st.TargetHeight {
+		st.TargetHeight = peer.Height
+	}
+	if st.Syncing && st.Peer != peer.Address && n.IsConnected(st.Peer) &&
+		time.Since(n.sync.lastBlock) < syncStallTimeout {
+		n.syncMu.Unlock()
+		return
+	}
+	st.Syncing = true
+	st.Peer = peer.Address
+	n.sync.lastBlock = time.Now()
+	n.saveSyncProgress()
+	n.syncMu.Unlock()
+
+	logger.Info("syncing blocks", "peer", peer.Address, "from", best+1, "target", peer.Height)
+	n.requestBlocks(peer, best+1)
+}
+
+// syncedBlock records a block accepted from peer.
+func (n *Node) syncedBlock(peer *Peer, height uint64) {
+	if height > peer.Height {
+		peer.Height = height
+	}
+	// The peer's chain includes our new tip, so has at least its work.
+	if w := n.Chain.GetChainWork(); peer.ChainWork != nil && w.Cmp(peer.ChainWork) > 0 {
+		peer.ChainWork = w
+	}
+	n.syncMu.Lock()
+	defer n.syncMu.Unlock()
+	st := &n.sync.status
+	if !st.Syncing || st.Peer != peer.Address {
+		return
+	}
+	n.sync.lastBlock = time.Now()
+	if height >= st.TargetHeight {
+		st.Syncing = false
+		st.Peer = ""
+		n.saveSyncProgress()
+		logger.Info("sync complete", "height", height)
+		if n.Config.FinalityInterval > 0 {
+			go n.requestAttestations(peer)
+		}
+	}
+}
+
+// syncCheckpoint persists progress at a batch boundary.
+func (n *Node) syncCheckpoint() {
+	n.syncMu.Lock()
+	defer n.syncMu.Unlock()
+	if n.sync.status.Syncing {
+		n.saveSyncProgress()
+	}
+}
+
+// syncLoop moves the sync to another peer when the current one disconnects
+// or stops sending blocks.
+func (n *Node) syncLoop() {
+	ticker := time.NewTicker(syncCheckPeriod)
+	defer ticker.Stop()
+	for range ticker.C {
+		n.checkSyncPeer()
+	}
+}
+
+// checkSyncPeer switches to the best other peer if the sync peer has
+// disconnected or stalled.
+func (n *Node) checkSyncPeer() {
+	n.syncMu.Lock()
+	st := &n.sync.status
+	stale := ""
+	if st.Syncing && (!n.IsConnected(st.Peer) || time.Since(n.sync.lastBlock) >= syncStallTimeout) {
+		stale = st.Peer
+		st.Peer = ""
+		n.sync.lastBlock = time.Time{}
+	}
+	n.syncMu.Unlock()
+	if stale == "" {
+		return
+	}
+	if next := n.bestSyncPeer(stale); next != nil {
+		logger.Info("switching sync peer", "from", stale, "to", next.Address)
+		n.maybeSync(next)
+	}
+}
+
+// bestSyncPeer returns the connected block-serving peer with the best
+// chain by betterChain, other than exclude.
+func (n *Node) bestSyncPeer(exclude string) *Peer {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+	var best *Peer
+	for addr, p := range n.Peers {
+		if addr == exclude || !p.Services.servesBlocks() {
+			continue
+		}
+		if best == nil || betterChain(p, best) {
+			best = p
+		}
+	}
+	return best
+}
+
+// aheadOfUs reports whether peer has blocks worth downloading: a chain
+// with more work than ours, going by height only for peers that don't
+// advertise their work. A longer chain with less work is cheap to make,
+// so its peer isn't synced from.
+func (n *Node) aheadOfUs(peer *Peer) bool {
+	if peer.Height <= n.Chain.GetBestHeight() {
+		return false
+	}
+	return peer.ChainWork == nil || peer.ChainWork.Cmp(n.Chain.GetChainWork()) > 0
+}
+
+// betterChain reports whether a's chain beats b's: by work when both
+// advertise it, by height otherwise.
+func betterChain(a, b *Peer) bool {
+	if a.ChainWork != nil && b.ChainWork != nil {
+		return a.ChainWork.Cmp(b.ChainWork) > 0
+	}
+	return a.Height > b.Height
+}
+
+// SyncStatus returns the current block download state.
+func (n *Node) SyncStatus() SyncStatus {
+	n.syncMu.Lock()
+	defer n.syncMu.Unlock()
+	st := n.sync.status
+	if best := n.Chain.GetBestBlock(); best != nil {
+		st.Height, st.Hash = best.Header.Height, best.Hash
+	}
+	return st
+}
@@ -0,0 +1,122 @@
+package network
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+)
+
+// tlsHandshakeTimeout bounds the TLS handshake of a new peer connection.
+const tlsHandshakeTimeout = 10 * time.Second
+
+// CertPin returns the pin of a certificate: the hex SHA-256 of its
+// public key (SubjectPublicKeyInfo), so it survives renewal with the same
+// key.
+func CertPin(cert *x509.Certificate) string {
+	sum := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+	return hex.EncodeToString(sum[:])
+}
+
+// EnableTLS makes every peer connection mutual TLS with the key pair in
+// certFile and keyFile. A peer is accepted only if its certificate's pin
+// is set with SetTLSPins; until then all peers are refused. Call it
+// before Start. It returns this node's own pin.
+func (n *Node) EnableTLS(certFile, keyFile string) (string, error) {
+	pair, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return "", err
+	}
+	leaf, err := x509.ParseCertificate(pair.Certificate[0])
+	if err != nil {
+		return "", err
+	}
+	n.tls = &tls.Config{
+		Certificates: []tls.Certificate{pair},
+		MinVersion:   tls.VersionTLS13,
+		ClientAuth:   tls.RequireAnyClientCert,
+		// Peers use self-signed certificates; trust comes from the pin
+		// check instead of a CA.
+		InsecureSkipVerify:    true,
+		VerifyPeerCertificate: n.verifyPin,
+	}
+	return CertPin(leaf), nil
+}
+
+// SetTLSPins replaces the certificate pins peers must present and drops
+// connected peers whose pin is no longer listed.
+func (n *Node) SetTLSPins(pins []string) {
+	n.mu.Lock()
+	n.tlsPins = make(map[string]bool, len(pins))
+	for _, p := range pins {
+		n.tlsPins[strings.ToLower(p)] = true
+	}
+	var drop []*Peer
+	for _, p := range n.Peers {
+		if p.CertPin != "" && !n.tlsPins[p.CertPin] {
+			drop = append(drop, p)
+		}
+	}
+	n.mu.Unlock()
+
+	for _, p := range drop {
+		logger.Info("disconnecting peer with unpinned certificate", "peer", p.Address)
+		p.Conn.Close()
+	}
+}
+
+func (n *Node) verifyPin(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+	if len(rawCerts) == 0 {
+		return errors.New("peer sent no certificate")
+	}
+	cert, err := x509.ParseCertificate(rawCerts[0])
+	if err != nil {
+		return err
+	}
+	pin := CertPin(cert)
+	n.mu.RLock()
+	ok := n.tlsPins[pin]
+	n.mu.RUnlock()
+	if !ok {
+		return fmt.Errorf("certificate %s is not pinned", pin)
+	}
+	return nil
+}
+
+// dial opens a connection to a peer, over TLS if enabled. The host is sent
+// as SNI.
+func (n *Node) dial(address string) (net.Conn, error) {
+	d := &net.Dialer{Timeout: 10 * time.Second}
+	if n.tls == nil {
+		return d.Dial("tcp", address)
+	}
+	conf := n.tls.Clone()
+	if host := hostOf(address); net.ParseIP(host) == nil {
+		conf.ServerName = host
+	}
+	return tls.DialWithDialer(d, "tcp", address, conf)
+}
+
+// tlsHandshake completes the handshake on a TLS peer connection and
+// returns the peer's certificate pin, or "" for plain connections.
+func tlsHandshake(conn net.Conn) (string, error) {
+	tc, ok := conn.(*tls.Conn)
+	if !ok {
+		return "", nil
+	}
+	tc.SetDeadline(time.Now().Add(tlsHandshakeTimeout))
+	if err := tc.Handshake(); err != nil {
+		return "", err
+	}
+	tc.SetDeadline(time.Time{})
+	certs := tc.ConnectionState().PeerCertificates
+	if len(certs) == 0 {
+		return "", errors.New("peer sent no certificate")
+	}
+	return CertPin(certs[0]), nil
+}
@@ -0,0 +1,149 @@
+package network
+
+import (
+	"devinsidercoin/internal/blockchain"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// BroadcastState records how a pending transaction has been announced.
+type BroadcastState struct {
+	TxID           string      `json:"txid"`
+	ReceivedFrom   string      `json:"received_from,omitempty"` // empty for local transactions
+	Peers          []string    `json:"peers"`                   // peers it was sent to
+	Announcements  int         `json:"announcements"`           // total sends, including repeats
+	FirstBroadcast time.Time   `json:"first_broadcast"`
+	LastBroadcast  time.Time   `json:"last_broadcast"`
+	Rejections     []Rejection `json:"rejections,omitempty"` // peers that refused it
+}
+
+// txRelay tracks broadcast state for pending transactions. Entries are
+// dropped once the transaction is confirmed or expires.
+type txRelay struct {
+	mu      sync.Mutex
+	states  map[string]*BroadcastState
+	peers   map[string]map[string]bool
+	rejects map[string]map[string]Rejection // txid -> peer -> rejection
+}
+
+func newTxRelay(chain *blockchain.Blockchain) *txRelay {
+	r := &txRelay{
+		states:  make(map[string]*BroadcastState),
+		peers:   make(map[string]map[string]bool),
+		rejects: make(map[string]map[string]Rejection),
+	}
+	chain.Events.Subscribe(func(ev blockchain.Event) {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		if ev.Type == blockchain.EventTxExpired {
+			r.forget(ev.Tx.TxID)
+			return
+		}
+		for _, tx := range ev.Block.Transactions {
+			r.forget(tx.TxID)
+		}
+	}, blockchain.EventBlockConnected, blockchain.EventTxExpired)
+	return r
+}
+
+func (r *txRelay) forget(txid string) {
+	delete(r.states, txid)
+	delete(r.peers, txid)
+	delete(r.rejects, txid)
+}
+
+// rejected records that a peer refused txid. Only transactions this node
+// has relayed are tracked.
+func (r *txRelay) rejected(txid string, rej Rejection) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.states[txid]; !ok {
+		return
+	}
+	if r.rejects[txid] == nil {
+		r.rejects[txid] = make(map[string]Rejection)
+	}
+	r.rejects[txid][rej.Peer] = rej
+}
+
+// skip reports whether txid should not be sent to peer again: the peer
+// refused it for anything other than its relay policy.
+func (r *txRelay) skip(txid, peer string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	rej, ok := r.rejects[txid][peer]
+	return ok && rej.Code != blockchain.RejectPolicy
+}
+
+// record notes that txid was sent to peers, having arrived from origin.
+func (r *txRelay) record(txid, origin string, peers []string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := time.Now()
+	st, ok := r.states[txid]
+	if !ok {
+		st = &BroadcastState{TxID: txid, ReceivedFrom: origin, FirstBroadcast: now}
+		r.states[txid] = st
+		r.peers[txid] = make(map[string]bool)
+	}
+	st.LastBroadcast = now
+	st.Announcements += len(peers)
+	for _, p := range peers {
+		r.peers[txid][p] = true
+	}
+}
+
+func (r *txRelay) get(txid string) (BroadcastState, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	st, ok := r.states[txid]
+	if !ok {
+		return BroadcastState{}, false
+	}
+	out := *st
+	out.Peers = make([]string, 0, len(r.peers[txid]))
+	for p := range r.peers[txid] {
+		out.Peers = append(out.Peers, p)
+	}
+	sort.Strings(out.Peers)
+	for _, rej := range r.rejects[txid] {
+		out.Rejections = append(out.Rejections, rej)
+	}
+	sort.Slice(out.Rejections, func(i, j int) bool { return out.Rejections[i].Peer < out.Rejections[j].Peer })
+	return out, true
+}
+
+// sendTx sends a tx message to every peer except origin and those that
+// refused it, and records the peers it reached.
+func (n *Node) sendTx(tx *blockchain.Transaction, msg Message, origin string) int {
+	n.mu.RLock()
+	var sent []string
+	for addr, p := range n.Peers {
+		if addr == origin || n.relay.skip(tx.TxID, addr) {
+			continue
+		}
+		if p.Send(msg) == nil {
+			sent = append(sent, addr)
+		}
+	}
+	n.mu.RUnlock()
+	n.relay.record(tx.TxID, origin, sent)
+	return len(sent)
+}
+
+// BroadcastStatus returns the broadcast state of a pending transaction.
+func (n *Node) BroadcastStatus(txid string) (BroadcastState, bool) {
+	return n.relay.get(txid)
+}
+
+// Rebroadcast announces a pending transaction to all peers again and
+// returns how many peers it reached.
+func (n *Node) Rebroadcast(txid string) (int, error) {
+	tx, ok := n.Chain.GetPendingTx(txid)
+	if !ok {
+		return 0, fmt.Errorf("transaction %s is not pending", txid)
+	}
+	return n.BroadcastTx(&tx), nil
+}
@@ -0,0 +1,105 @@
+package pool
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Config is the part of a pool settings file the node reads: the share
+// difficulty settings of its [workers] and [vardiff] sections. Other
+// sections are for the pool's payout software and are ignored. See
+// scripts/docs/mining/pool-example.toml.
+type Config struct {
+	VarDiff VarDiffConfig
+	Enabled bool // false pins every miner at VarDiff.StartBits
+}
+
+// LoadConfig reads the pool settings file at path. The file is TOML, of
+// which only sections and single-line key = value pairs with string,
+// number and boolean values are understood. defaultBits is the starting
+// share difficulty if [workers] sets none.
+func LoadConfig(path string, defaultBits uint32) (*Config, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	cfg := &Config{VarDiff: DefaultVarDiffConfig(defaultBits), Enabled: true}
+	section := ""
+	sc := bufio.NewScanner(f)
+	for line := 1; sc.Scan(); line++ {
+		text := strings.TrimSpace(stripComment(sc.Text()))
+		if text == "" {
+			continue
+		}
+		if strings.HasPrefix(text, "[") && strings.HasSuffix(text, "]") {
+			section = strings.TrimSpace(text[1 : len(text)-1])
+			continue
+		}
+		key, value, ok := strings.Cut(text, "=")
+		if !ok {
+			return nil, fmt.Errorf("%s:%d: expected key = value", path, line)
+		}
+		key, value = strings.TrimSpace(key), strings.TrimSpace(value)
+		if err := cfg.set(section, key, value); err != nil {
+			return nil, fmt.Errorf("%s:%d: %s.%s: %w", path, line, section, key, err)
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	if !cfg.Enabled {
+		cfg.VarDiff.MinBits = cfg.VarDiff.StartBits
+		cfg.VarDiff.MaxBits = cfg.VarDiff.StartBits
+	}
+	return cfg, nil
+}
+
+// set applies one key of section.
+func (c *Config) set(section, key, value string) error {
+	v := &c.VarDiff
+	var err error
+	switch section + "." + key {
+	case "workers.default_difficulty":
+		v.StartBits, err = parseBits(value)
+	case "vardiff.enabled":
+		c.Enabled, err = strconv.ParseBool(value)
+	case "vardiff.target_shares_per_minute":
+		v.TargetSharesPerMinute, err = strconv.ParseFloat(value, 64)
+	case "vardiff.retarget_seconds":
+		v.RetargetSeconds, err = strconv.Atoi(value)
+	case "vardiff.variance_percent":
+		v.VariancePercent, err = strconv.ParseFloat(value, 64)
+	case "vardiff.min_difficulty":
+		v.MinBits, err = parseBits(value)
+	case "vardiff.max_difficulty":
+		v.MaxBits, err = parseBits(value)
+	}
+	return err
+}
+
+// parseBits reads a compact difficulty, in decimal or 0x hex.
+func parseBits(value string) (uint32, error) {
+	bits, err := strconv.ParseUint(value, 0, 32)
+	return uint32(bits), err
+}
+
+// stripComment drops a # comment outside a quoted string.
+func stripComment(line string) string {
+	quoted := false
+	for i, r := range line {
+		switch r {
+		case '"':
+			quoted = !quoted
+		case '#':
+			if !quoted {
+				return line[:i]
+			}
+		}
+	}
+	return line
+}
@@ -0,0 +1,154 @@
+package pool
+
+import (
+	"devinsidercoin/internal/blockchain"
+	"math/big"
+	"sync"
+	"time"
+)
+
+// EasiestShareBits is the easiest share difficulty a pool hands out by
+// default: the largest target a compact value can hold with a positive
+// mantissa, the same as regtest's proof-of-work limit.
+const EasiestShareBits uint32 = 0x207fffff
+
+// VarDiffConfig controls per-miner share difficulty adjustment.
+type VarDiffConfig struct {
+	TargetSharesPerMinute float64 // desired share rate for every miner
+	RetargetSeconds       int     // how often a miner's difficulty is re-evaluated
+	VariancePercent       float64 // tolerated deviation before adjusting
+	StartBits             uint32  // share difficulty assigned to new miners
+	MinBits               uint32  // easiest allowed share difficulty (largest target)
+	MaxBits               uint32  // hardest allowed share difficulty (smallest target)
+}
+
+// DefaultVarDiffConfig returns settings suitable for a mixed CPU/ASIC pool.
+func DefaultVarDiffConfig(startBits uint32) VarDiffConfig {
+	return VarDiffConfig{
+		TargetSharesPerMinute: 20,
+		RetargetSeconds:       90,
+		VariancePercent:       30,
+		StartBits:             startBits,
+		MinBits:               EasiestShareBits,
+		MaxBits:               0x1d00ffff,
+	}
+}
+
+type minerState struct {
+	bits        uint32
+	shares      int
+	windowStart time.Time
+}
+
+// VarDiff tracks share submissions per miner and retunes each miner's
+// share difficulty so that it submits roughly TargetSharesPerMinute.
+type VarDiff struct {
+	cfg    VarDiffConfig
+	miners map[string]*minerState
+	mu     sync.Mutex
+}
+
+// NewVarDiff creates a vardiff tracker.
+func NewVarDiff(cfg VarDiffConfig) *VarDiff {
+	if cfg.TargetSharesPerMinute <= 0 {
+		cfg.TargetSharesPerMinute = 20
+	}
+	if cfg.RetargetSeconds <= 0 {
+		cfg.RetargetSeconds = 90
+	}
+	if cfg.MinBits == 0 {
+		cfg.MinBits = EasiestShareBits
+	}
+	return &VarDiff{cfg: cfg, miners: make(map[string]*minerState)}
+}
+
+// Bits returns the current share difficulty for a miner, registering it
+// with the starting difficulty if it is new.
+func (v *VarDiff) Bits(minerID string) uint32 {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	return v.state(minerID, time.Now()).bits
+}
+
+// RecordShare registers an accepted share and returns the miner's share
+// difficulty, which may have been retargeted. changed reports whether the
+// pool should push a new difficulty to the miner.
+func (v *VarDiff) RecordShare(minerID string, now time.Time) (bits uint32, changed bool) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	st := v.state(minerID, now)
+	st.shares++
+
+	elapsed := now.Sub(st.windowStart)
+	if elapsed < time.Duration(v.cfg.RetargetSeconds)*time.Second {
+		return st.bits, false
+	}
+
+	actualRate := float64(st.shares) / elapsed.Minutes()
+	st.shares = 0
+	st.windowStart = now
+
+	deviation := (actualRate - v.cfg.TargetSharesPerMinute) / v.cfg.TargetSharesPerMinute * 100
+	if deviation < 0 {
+		deviation = -deviation
+	}
+	if deviation <= v.cfg.VariancePercent {
+		return st.bits, false
+	}
+
+	newBits := v.retarget(st.bits, actualRate)
+	if newBits == st.bits {
+		return st.bits, false
+	}
+	st.bits = newBits
+	return st.bits, true
+}
+
+// Remove forgets a disconnected miner.
+func (v *VarDiff) Remove(minerID string) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	delete(v.miners, minerID)
+}
+
+func (v *VarDiff) state(minerID string, now time.Time) *minerState {
+	st, ok := v.miners[minerID]
+	if !ok {
+		st = &minerState{bits: v.cfg.StartBits, windowStart: now}
+		v.miners[minerID] = st
+	}
+	return st
+}
+
+// retarget scales the share target by actualRate/targetRate, limited to a
+// 4x change per step and clamped to [MaxBits, MinBits].
+func (v *VarDiff) retarget(bits uint32, actualRate float64) uint32 {
+	ratio := v.cfg.TargetSharesPerMinute / actualRate
+	if ratio < 0.25 {
+		ratio = 0.25
+	}
+	if ratio > 4 {
+		ratio = 4
+	}
+
+	// Too many shares -> ratio < 1 -> smaller target (harder).
+	target := new(big.Float).SetInt(blockchain.BitsToTarget(bits))
+	target.Mul(target, big.NewFloat(ratio))
+	newTarget, _ := target.Int(nil)
+
+	easiest := blockchain.BitsToTarget(v.cfg.MinBits)
+	if newTarget.Cmp(easiest) > 0 {
+		newTarget.Set(easiest)
+	}
+	if v.cfg.MaxBits != 0 {
+		hardest := blockchain.BitsToTarget(v.cfg.MaxBits)
+		if newTarget.Cmp(hardest) < 0 {
+			newTarget.Set(hardest)
+		}
+	}
+	if newTarget.Sign() == 0 {
+		newTarget.SetInt64(1)
+	}
+	return blockchain.TargetToBits(newTarget)
+}
@@ -0,0 +1,132 @@
+package rpc
+
+import (
+	"devinsidercoin/internal/address"
+	"devinsidercoin/internal/blockchain"
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// upgradeFee is the fee paid when moving a legacy address's funds.
+const upgradeFee = 0.001
+
+// validAddress reports why addr is not an address on this network. The
+// burn address is valid: it is never spendable, but it may be queried.
+func (s *Server) validAddress(addr string) error {
+	prefix := s.Chain.Config.AddressPrefix
+	if addr == blockchain.BurnAddress(prefix) {
+		return nil
+	}
+	return address.Validate(prefix, addr)
+}
+
+// checkAddress answers 400 and returns false unless addr is valid.
+func (s *Server) checkAddress(w http.ResponseWriter, field, addr string) bool {
+	if err := s.validAddress(addr); err != nil {
+		jsonErr(w, 400, fmt.Sprintf("invalid %s %s: %v", field, addr, err))
+		return false
+	}
+	return true
+}
+
+// AddressInfo is the result of validateaddress.
+type AddressInfo struct {
+	Address     string `json:"address"`
+	IsValid     bool   `json:"isvalid"`
+	Error       string `json:"error,omitempty"`
+	Format      string `json:"format,omitempty"`      // checksummed, legacy or burn
+	Checksummed string `json:"checksummed,omitempty"` // for legacy addresses, the form to upgrade to
+	IsMine      bool   `json:"ismine"`
+}
+
+func (s *Server) rpcValidateAddress(w http.ResponseWriter, req JSONRPCRequest) {
+	var params struct {
+		Address string `json:"address"`
+	}
+	json.Unmarshal(req.Params, &params)
+	if params.Address == "" {
+		writeRPCError(w, req.ID, "address required")
+		return
+	}
+	prefix := s.Chain.Config.AddressPrefix
+	info := AddressInfo{Address: params.Address}
+	if params.Address == blockchain.BurnAddress(prefix) {
+		info.IsValid, info.Format = true, "burn"
+		writeRPCResult(w, req.ID, info)
+		return
+	}
+	_, version, err := address.Decode(prefix, params.Address)
+	if err != nil {
+		info.Error = err.Error()
+		writeRPCResult(w, req.ID, info)
+		return
+	}
+	info.IsValid = true
+	info.Format = "checksummed"
+	if version == address.VersionLegacy {
+		info.Format = "legacy"
+		info.Checksummed, _ = address.Upgrade(prefix, params.Address)
+	}
+	_, info.IsMine = s.Wallets.GetWallet(params.Address)
+	writeRPCResult(w, req.ID, info)
+}
+
+// handleWalletUpgrade migrates a legacy wallet: it adds the checksummed
+// address of the same key and moves the legacy address's spendable funds
+// there. Staked funds stay until they are unstaked; upgrading again then
+// moves them too.
+func (s *Server) handleWalletUpgrade(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		jsonErr(w, 405, "POST required")
+		return
+	}
+	key, ok := s.walletKey(w, r)
+	if !ok || !unrestricted(w, key) {
+		return
+	}
+	var req struct {
+		Address string `json:"address"`
+	}
+	if err := decodeAdmin(r, &req); err != nil || req.Address == "" {
+		jsonErr(w, 400, "address required")
+		return
+	}
+	upgraded, err := s.Wallets.Upgrade(req.Address)
+	if err != nil {
+		jsonErr(w, 400, err.Error())
+		return
+	}
+	resp := map[string]interface{}{
+		"legacy":  req.Address,
+		"address": upgraded.Address,
+		"status":  "upgraded",
+	}
+
+	amount := s.Chain.GetSpendable(req.Address) - upgradeFee
+	if amount < s.Chain.RelayPolicy().MinOutputAmount {
+		jsonOK(w, resp)
+		return
+	}
+	tx := blockchain.NewTransferTransaction(req.Address, upgraded.Address, amount, upgradeFee, "")
+	if err := s.Chain.FundTransaction(&tx, nil, ""); err != nil {
+		jsonErr(w, 400, err.Error())
+		return
+	}
+	if err := s.Wallets.SignTx(&tx, s.Chain.Config.NetworkID); err != nil {
+		jsonErr(w, 400, "cannot sign: "+err.Error())
+		return
+	}
+	if err := s.Chain.AddToMempool(tx); err != nil {
+		jsonErr(w, 400, err.Error())
+		return
+	}
+	s.Node.BroadcastTx(&tx)
+	logger.Info("legacy address upgraded", "legacy", req.Address, "address", upgraded.Address,
+		"txid", tx.TxID, "amount", amount)
+	resp["txid"] = tx.TxID
+	resp["amount"] = amount
+	resp["fee"] = tx.Fee
+	resp["status"] = "pending"
+	jsonOK(w, resp)
+}
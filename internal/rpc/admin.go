@@ -0,0 +1,198 @@
+package rpc
+
+import (
+	"crypto/subtle"
+	"devinsidercoin/internal/blockchain"
+	"devinsidercoin/internal/logging"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// SetAdminToken sets the bearer token the /api/admin endpoints require.
+// An empty token disables them.
+func (s *Server) SetAdminToken(token string) {
+	s.adminMu.Lock()
+	defer s.adminMu.Unlock()
+	s.adminToken = token
+}
+
+// adminAuthorized reports whether r carries the admin token, and whether
+// one is configured at all.
+func (s *Server) adminAuthorized(r *http.Request) (ok, configured bool) {
+	s.adminMu.RLock()
+	token := s.adminToken
+	s.adminMu.RUnlock()
+	if token == "" {
+		return false, false
+	}
+	got, found := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return found && subtle.ConstantTimeCompare([]byte(got), []byte(token)) == 1, true
+}
+
+// admin wraps an /api/admin endpoint. It answers 403 while no admin token
+// is configured and 401 unless the request carries it.
+func (s *Server) admin(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ok, configured := s.adminAuthorized(r)
+		switch {
+		case !configured:
+			jsonErr(w, 403, "admin API disabled; set admin_token in the node settings")
+		case !ok:
+			w.Header().Set("WWW-Authenticate", `Bearer realm="admin"`)
+			jsonErr(w, 401, "admin token required")
+		default:
+			h(w, r)
+		}
+	}
+}
+
+// rpcAdminAllowed gates the peer-management JSON-RPC methods: once an
+// admin token is configured they need it too, and on the mining listener
+// they always do.
+func (s *Server) rpcAdminAllowed(r *http.Request) bool {
+	ok, configured := s.adminAuthorized(r)
+	if r.Context().Value(miningListener{}) != nil {
+		return ok
+	}
+	return ok || !configured
+}
+
+// decodeAdmin reads a POST body into v.
+func decodeAdmin(r *http.Request, v interface{}) error {
+	body, _ := io.ReadAll(r.Body)
+	if err := json.Unmarshal(body, v); err != nil {
+		return errors.New("invalid request body")
+	}
+	return nil
+}
+
+func (s *Server) handleAdminBanlist(w http.ResponseWriter, r *http.Request) {
+	jsonOK(w, s.Node.Banlist())
+}
+
+func (s *Server) handleAdminBan(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		IP string `json:"ip"`
+	}
+	if err := decodeAdmin(r, &req); err != nil {
+		jsonErr(w, 400, err.Error())
+		return
+	}
+	if net.ParseIP(req.IP) == nil {
+		jsonErr(w, 400, "ip must be an IP address")
+		return
+	}
+	s.Node.Ban(req.IP)
+	logger.Info("peer IP banned", "ip", req.IP)
+	jsonOK(w, map[string]string{"banned": req.IP})
+}
+
+func (s *Server) handleAdminUnban(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		IP string `json:"ip"`
+	}
+	if err := decodeAdmin(r, &req); err != nil {
+		jsonErr(w, 400, err.Error())
+		return
+	}
+	if err := s.Node.Unban(req.IP); err != nil {
+		jsonErr(w, 404, err.Error())
+		return
+	}
+	logger.Info("peer IP unbanned", "ip", req.IP)
+	jsonOK(w, map[string]string{"unbanned": req.IP})
+}
+
+func (s *Server) handleAdminPeers(w http.ResponseWriter, r *http.Request) {
+	jsonOK(w, s.Node.GetPeerInfo())
+}
+
+func (s *Server) handleAdminConnect(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Address string `json:"address"`
+	}
+	if err := decodeAdmin(r, &req); err != nil || req.Address == "" {
+		jsonErr(w, 400, "address required")
+		return
+	}
+	if err := s.Node.ConnectPeer(req.Address); err != nil {
+		jsonErr(w, 502, err.Error())
+		return
+	}
+	jsonOK(w, map[string]string{"connecting": req.Address})
+}
+
+func (s *Server) handleAdminDisconnect(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Address string `json:"address"`
+	}
+	if err := decodeAdmin(r, &req); err != nil || req.Address == "" {
+		jsonErr(w, 400, "address required")
+		return
+	}
+	if err := s.Node.DisconnectPeer(req.Address); err != nil {
+		jsonErr(w, 404, err.Error())
+		return
+	}
+	jsonOK(w, map[string]string{"disconnected": req.Address})
+}
+
+// handleAdminLogLevel reports the log level, or changes it on POST. The
+// change lasts until the settings are next reloaded.
+func (s *Server) handleAdminLogLevel(w http.ResponseWriter, r *http.Request) {
+	if r.Method == "POST" {
+		var req struct {
+			Level string `json:"level"`
+		}
+		if err := decodeAdmin(r, &req); err != nil {
+			jsonErr(w, 400, err.Error())
+			return
+		}
+		if err := logging.SetLevel(req.Level); err != nil {
+			jsonErr(w, 400, err.Error())
+			return
+		}
+		logger.Info("log level changed", "level", logging.Level())
+	}
+	jsonOK(w, map[string]string{"level": logging.Level()})
+}
+
+func (s *Server) handleAdminBackup(w http.ResponseWriter, r *http.Request) {
+	path, err := s.Chain.Backup()
+	if err != nil {
+		jsonErr(w, 500, err.Error())
+		return
+	}
+	jsonOK(w, map[string]string{"path": path})
+}
+
+func (s *Server) handleAdminReindex(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Index string `json:"index"`
+	}
+	if err := decodeAdmin(r, &req); err != nil {
+		jsonErr(w, 400, err.Error())
+		return
+	}
+	err := s.Chain.Reindex(req.Index)
+	switch {
+	case errors.Is(err, blockchain.ErrUnknownIndex):
+		jsonErr(w, 400, err.Error())
+		return
+	case errors.Is(err, blockchain.ErrNotArchive):
+		jsonErr(w, 501, err.Error())
+		return
+	case err != nil:
+		jsonErr(w, 500, err.Error())
+		return
+	}
+	status := "rebuilt"
+	if req.Index == blockchain.IndexArchive {
+		status = "started" // see getindexinfo
+	}
+	jsonOK(w, map[string]string{"index": req.Index, "status": status})
+}
@@ -0,0 +1,170 @@
+package rpc
+
+import (
+	"devinsidercoin/internal/blockchain"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+// archiveErr reports a failed historical query; nodes without -archive
+// answer 501 so explorers can look for an archive peer instead, and 503
+// while the indexes are still being built.
+func archiveErr(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, blockchain.ErrNotArchive):
+		jsonErr(w, 501, err.Error())
+	case errors.Is(err, blockchain.ErrIndexing):
+		jsonErr(w, 503, err.Error())
+	default:
+		jsonErr(w, 404, err.Error())
+	}
+}
+
+// historyParams parses the address, from and limit query parameters.
+func historyParams(q url.Values) (address string, from uint64, limit int, err error) {
+	address = q.Get("address")
+	if address == "" {
+		return "", 0, 0, errors.New("address required")
+	}
+	if v := q.Get("from"); v != "" {
+		if from, err = strconv.ParseUint(v, 10, 64); err != nil {
+			return "", 0, 0, fmt.Errorf("invalid from %q", v)
+		}
+	}
+	if limit, err = limitParam(q); err != nil {
+		return "", 0, 0, err
+	}
+	return address, from, limit, nil
+}
+
+// limitParam parses the limit query parameter, 100 by default.
+func limitParam(q url.Values) (int, error) {
+	v := q.Get("limit")
+	if v == "" {
+		return 100, nil
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 1 || n > blockchain.MaxHistoryResults {
+		return 0, fmt.Errorf("invalid limit %q (1..%d)", v, blockchain.MaxHistoryResults)
+	}
+	return n, nil
+}
+
+// pinnedHeight parses the height query parameter a paged listing is
+// answered as of, the best block by default, and reports it in the
+// X-Chain-Height header so the next pages can pin it.
+func (s *Server) pinnedHeight(w http.ResponseWriter, q url.Values) (uint64, error) {
+	height := s.Chain.GetBestHeight()
+	if v := q.Get("height"); v != "" {
+		var err error
+		if height, err = strconv.ParseUint(v, 10, 64); err != nil {
+			return 0, fmt.Errorf("invalid height %q", v)
+		}
+	}
+	w.Header().Set("X-Chain-Height", strconv.FormatUint(height, 10))
+	return height, nil
+}
+
+func (s *Server) handleAddressHistory(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	address, from, limit, err := historyParams(q)
+	if err != nil {
+		jsonErr(w, 400, err.Error())
+		return
+	}
+	height, err := s.pinnedHeight(w, q)
+	if err != nil {
+		jsonErr(w, 400, err.Error())
+		return
+	}
+	txs, err := s.Chain.AddressHistory(address, from, height, limit)
+	if err != nil {
+		archiveErr(w, err)
+		return
+	}
+	jsonOK(w, txs)
+}
+
+func (s *Server) handleBalanceHistory(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	address, from, limit, err := historyParams(q)
+	if err != nil {
+		jsonErr(w, 400, err.Error())
+		return
+	}
+	height, err := s.pinnedHeight(w, q)
+	if err != nil {
+		jsonErr(w, 400, err.Error())
+		return
+	}
+	points, err := s.Chain.BalanceHistory(address, from, height, limit)
+	if err != nil {
+		archiveErr(w, err)
+		return
+	}
+	jsonOK(w, points)
+}
+
+func (s *Server) handleBalanceAt(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	address := q.Get("address")
+	if address == "" {
+		jsonErr(w, 400, "address required")
+		return
+	}
+	height, err := strconv.ParseUint(q.Get("height"), 10, 64)
+	if err != nil {
+		jsonErr(w, 400, "invalid height")
+		return
+	}
+	bal, err := s.Chain.BalanceAt(address, height)
+	if err != nil {
+		archiveErr(w, err)
+		return
+	}
+	jsonOK(w, bal)
+}
+
+func (s *Server) handleRichList(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	limit, err := limitParam(q)
+	if err != nil {
+		jsonErr(w, 400, err.Error())
+		return
+	}
+	var offset int
+	if v := q.Get("offset"); v != "" {
+		if offset, err = strconv.Atoi(v); err != nil || offset < 0 {
+			jsonErr(w, 400, fmt.Sprintf("invalid offset %q", v))
+			return
+		}
+	}
+	height, err := s.pinnedHeight(w, q)
+	if err != nil {
+		jsonErr(w, 400, err.Error())
+		return
+	}
+	list, err := s.Chain.RichList(height, offset, limit)
+	if err != nil {
+		archiveErr(w, err)
+		return
+	}
+	jsonOK(w, list)
+}
+
+func (s *Server) handleBlockFilter(w http.ResponseWriter, r *http.Request) {
+	height, err := strconv.ParseUint(r.URL.Query().Get("height"), 10, 64)
+	if err != nil {
+		jsonErr(w, 400, "invalid height")
+		return
+	}
+	f, err := s.Chain.GetBlockFilter(height)
+	if err != nil {
+		archiveErr(w, err)
+		return
+	}
+	jsonOK(w, f)
+}
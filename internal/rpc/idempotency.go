@@ -0,0 +1,126 @@
+package rpc
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"devinsidercoin/internal/storage"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Requests repeated with the same Idempotency-Key within idempotencyTTL
+// get the first successful response back instead of being executed again.
+const (
+	idempotencyTTL    = 24 * time.Hour
+	maxIdempotencyKey = 255
+)
+
+// idempotencyState tracks keys whose first request is still running.
+type idempotencyState struct {
+	inFlight  map[string]bool
+	lastPrune time.Time
+	mu        sync.Mutex
+}
+
+// captureWriter passes a response through while keeping a copy.
+type captureWriter struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (c *captureWriter) WriteHeader(status int) {
+	c.status = status
+	c.ResponseWriter.WriteHeader(status)
+}
+
+func (c *captureWriter) Write(b []byte) (int, error) {
+	c.body.Write(b)
+	return c.ResponseWriter.Write(b)
+}
+
+// idempotent wraps an endpoint that creates a transaction. A request with
+// an Idempotency-Key header runs once; its successful response is stored
+// and replayed for later requests with the same key and body. Keys are
+// scoped to the caller's credentials. Failed requests aren't stored, so
+// they can be retried with the same key once the cause is fixed.
+func (s *Server) idempotent(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get("Idempotency-Key")
+		if key == "" {
+			h(w, r)
+			return
+		}
+		if len(key) > maxIdempotencyKey {
+			jsonErr(w, 400, "Idempotency-Key too long")
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		scopeSum := sha256.Sum256([]byte(r.Header.Get("Authorization")))
+		scope := hex.EncodeToString(scopeSum[:8])
+		reqSum := sha256.Sum256(append([]byte(r.Method+" "+r.URL.Path+"\n"), body...))
+		reqHash := hex.EncodeToString(reqSum[:])
+
+		st := &s.idem
+		st.mu.Lock()
+		if st.inFlight == nil {
+			st.inFlight = make(map[string]bool)
+		}
+		slot := scope + "\x00" + key
+		if st.inFlight[slot] {
+			st.mu.Unlock()
+			jsonErr(w, 409, "a request with this Idempotency-Key is in progress")
+			return
+		}
+		store := s.Chain.Store
+		if prev := store.GetIdempotent(scope, key); prev != nil && time.Since(time.Unix(prev.Created, 0)) < idempotencyTTL {
+			st.mu.Unlock()
+			if prev.RequestHash != reqHash {
+				jsonErr(w, 422, "Idempotency-Key was used for a different request")
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			w.Header().Set("Idempotent-Replayed", "true")
+			w.WriteHeader(prev.Status)
+			w.Write(prev.Body)
+			return
+		}
+		st.inFlight[slot] = true
+		prune := time.Since(st.lastPrune) > time.Hour
+		if prune {
+			st.lastPrune = time.Now()
+		}
+		st.mu.Unlock()
+		defer func() {
+			st.mu.Lock()
+			delete(st.inFlight, slot)
+			st.mu.Unlock()
+		}()
+		if prune {
+			if n, err := store.PruneIdempotent(time.Now().Add(-idempotencyTTL).Unix()); err != nil {
+				logger.Warn("pruning idempotency keys failed", "err", err)
+			} else if n > 0 {
+				logger.Debug("pruned idempotency keys", "count", n)
+			}
+		}
+
+		cw := &captureWriter{ResponseWriter: w, status: http.StatusOK}
+		h(cw, r)
+		if cw.status >= 300 {
+			return
+		}
+		res := &storage.IdempotentResult{
+			RequestHash: reqHash,
+			Status:      cw.status,
+			Body:        bytes.TrimSpace(cw.body.Bytes()),
+			Created:     time.Now().Unix(),
+		}
+		if err := store.PutIdempotent(scope, key, res); err != nil {
+			logger.Error("storing idempotency key failed", "err", err)
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package rpc
+
+import (
+	"devinsidercoin/internal/blockchain"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// maxJournalWait caps how long a journal request waits for new entries.
+const maxJournalWait = 60 * time.Second
+
+// handleJournal serves the event journal from seq `from`. With `wait`
+// seconds it long-polls: an indexer that has caught up gets the next
+// entries as soon as they are written.
+func (s *Server) handleJournal(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	var from uint64 = 1
+	if v := q.Get("from"); v != "" {
+		n, err := strconv.ParseUint(v, 10, 64)
+		if err != nil {
+			jsonErr(w, 400, "invalid from")
+			return
+		}
+		from = n
+	}
+	limit := 100
+	if v := q.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > blockchain.MaxJournalEntries {
+			jsonErr(w, 400, "invalid limit (1.."+strconv.Itoa(blockchain.MaxJournalEntries)+")")
+			return
+		}
+		limit = n
+	}
+	var wait time.Duration
+	if v := q.Get("wait"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			jsonErr(w, 400, "invalid wait")
+			return
+		}
+		wait = min(time.Duration(n)*time.Second, maxJournalWait)
+	}
+	page, err := s.Chain.ReadJournal(from, limit, wait, r.Context().Done())
+	switch {
+	case errors.Is(err, blockchain.ErrNoJournal):
+		jsonErr(w, 501, err.Error())
+		return
+	case err != nil:
+		jsonErr(w, 500, err.Error())
+		return
+	}
+	jsonOK(w, page)
+}
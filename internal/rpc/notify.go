@@ -0,0 +1,153 @@
+package rpc
+
+import (
+	"devinsidercoin/internal/blockchain"
+	"devinsidercoin/internal/webhook"
+	"encoding/json"
+	"time"
+)
+
+// Deposit is the payload of a "deposit" notification: funds arriving at a
+// deposit address created with a reference.
+type Deposit struct {
+	TxID          string  `json:"txid"`
+	Address       string  `json:"address"`
+	Reference     string  `json:"reference"`
+	Amount        float64 `json:"amount"`
+	Status        string  `json:"status"` // pending or confirmed
+	Confirmations int64   `json:"confirmations"`
+	BlockHash     string  `json:"block_hash,omitempty"`
+	BlockHeight   uint64  `json:"block_height,omitempty"`
+}
+
+// TxActivity is the payload of a "tx" event, streamed to websocket clients
+// watching one of the addresses the transaction touches.
+type TxActivity struct {
+	Status      string                  `json:"status"`    // pending or confirmed
+	Addresses   []string                `json:"addresses"` // addresses it touches
+	BlockHash   string                  `json:"block_hash,omitempty"`
+	BlockHeight uint64                  `json:"block_height,omitempty"`
+	Tx          *blockchain.Transaction `json:"tx"`
+}
+
+// BlockActivity is the payload of a "block" event, streamed to every
+// websocket client with an address filter so it can count confirmations.
+type BlockActivity struct {
+	Status string `json:"status"` // always connected
+	Hash   string `json:"hash"`
+	Height uint64 `json:"height"`
+}
+
+// notify sends an event to the configured webhooks and to the websocket
+// clients wants accepts.
+func (s *Server) notify(event string, data interface{}, wants func(*wsClient) bool) {
+	if s.Hooks != nil {
+		s.Hooks.Notify(event, data)
+	}
+	s.stream(event, data, wants)
+}
+
+// stream sends an event to the websocket clients wants accepts only.
+func (s *Server) stream(event string, data interface{}, wants func(*wsClient) bool) {
+	if !s.ws.wanted(wants) {
+		return
+	}
+	msg, err := json.Marshal(webhook.Payload{Event: event, Timestamp: time.Now().Unix(), Data: data})
+	if err == nil {
+		s.ws.publish(msg, wants)
+	}
+}
+
+// watchDeposits reports credits to tagged deposit addresses once when they
+// enter the mempool and again when they are confirmed.
+func (s *Server) watchDeposits() {
+	s.Chain.Events.Subscribe(func(ev blockchain.Event) {
+		switch ev.Type {
+		case blockchain.EventTxAdded:
+			s.notifyDeposits(ev.Tx, nil)
+		case blockchain.EventBlockConnected:
+			for i := range ev.Block.Transactions {
+				s.notifyDeposits(&ev.Block.Transactions[i], ev.Block)
+			}
+		}
+	}, blockchain.EventTxAdded, blockchain.EventBlockConnected)
+}
+
+func (s *Server) notifyDeposits(tx *blockchain.Transaction, block *blockchain.Block) {
+	credits := tx.Outputs
+	if tx.Type == "transfer" {
+		credits = []blockchain.TxOutput{{Address: tx.To, Amount: tx.Amount}}
+	}
+	for _, out := range credits {
+		ref := s.Wallets.Reference(out.Address)
+		if ref == "" {
+			continue
+		}
+		d := Deposit{
+			TxID:      tx.TxID,
+			Address:   out.Address,
+			Reference: ref,
+			Amount:    out.Amount,
+			Status:    "pending",
+		}
+		if block != nil {
+			d.Status = "confirmed"
+			d.Confirmations = 1
+			d.BlockHash = block.Hash
+			d.BlockHeight = block.Header.Height
+		}
+		s.notify("deposit", d, func(c *wsClient) bool {
+			return c.watch == nil || c.watch[d.Address]
+		})
+	}
+}
+
+// watchActivity streams transactions and blocks to websocket clients that
+// filter on addresses.
+func (s *Server) watchActivity() {
+	s.Chain.Events.Subscribe(func(ev blockchain.Event) {
+		if !s.ws.watching() {
+			return
+		}
+		switch ev.Type {
+		case blockchain.EventTxAdded:
+			s.streamTx(ev.Tx, nil)
+		case blockchain.EventBlockConnected:
+			b := BlockActivity{Status: "connected", Hash: ev.Block.Hash, Height: ev.Block.Header.Height}
+			s.stream("block", b, func(c *wsClient) bool { return c.watch != nil })
+			for i := range ev.Block.Transactions {
+				s.streamTx(&ev.Block.Transactions[i], ev.Block)
+			}
+		}
+	}, blockchain.EventTxAdded, blockchain.EventBlockConnected)
+}
+
+// streamTx sends a "tx" event for tx to the clients watching its
+// addresses; block is nil while it is pending.
+func (s *Server) streamTx(tx *blockchain.Transaction, block *blockchain.Block) {
+	a := TxActivity{Status: "pending", Addresses: txAddresses(tx), Tx: tx}
+	if block != nil {
+		a.Status = "confirmed"
+		a.BlockHash = block.Hash
+		a.BlockHeight = block.Header.Height
+	}
+	s.stream("tx", a, func(c *wsClient) bool { return c.watches(a.Addresses...) })
+}
+
+// txAddresses returns the addresses tx moves coins from or to, once each.
+func txAddresses(tx *blockchain.Transaction) []string {
+	var addrs []string
+	seen := make(map[string]bool)
+	add := func(a string) {
+		if a != "" && !seen[a] {
+			seen[a] = true
+			addrs = append(addrs, a)
+		}
+	}
+	add(tx.From)
+	add(tx.To)
+	for _, out := range tx.Outputs {
+		add(out.Address)
+	}
+	return addrs
+}
@@ -0,0 +1,130 @@
+package rpc
+
+import (
+	"devinsidercoin/internal/blockchain"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// shareJobs remembers the templates handed to pool workers on the current
+// tip, by merkle root, so that shares are only credited for work on them.
+type shareJobs struct {
+	mu   sync.Mutex
+	tip  string
+	bits map[string]uint32 // merkle root -> block bits
+}
+
+func (j *shareJobs) add(b *blockchain.Block) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.tip != b.Header.PrevHash || j.bits == nil {
+		j.tip, j.bits = b.Header.PrevHash, make(map[string]uint32)
+	}
+	j.bits[b.Header.MerkleRoot] = b.Header.Bits
+}
+
+// lookup returns the block bits of the job b was mined from, and whether
+// there is one.
+func (j *shareJobs) lookup(b *blockchain.Block) (uint32, bool) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.tip != b.Header.PrevHash {
+		return 0, false
+	}
+	bits, ok := j.bits[b.Header.MerkleRoot]
+	return bits, ok
+}
+
+// shareBits returns the share difficulty for a worker at vardiff bits on
+// a block of blockBits: never harder than the block itself.
+func shareBits(bits, blockBits uint32) uint32 {
+	if blockchain.BitsToTarget(bits).Cmp(blockchain.BitsToTarget(blockBits)) < 0 {
+		return blockBits
+	}
+	return bits
+}
+
+// rpcGetShareJob returns a block template paying miner_address and the
+// share difficulty worker should mine it to.
+func (s *Server) rpcGetShareJob(w http.ResponseWriter, req JSONRPCRequest) {
+	if s.Pool == nil {
+		writeRPCError(w, req.ID, "pool mode is off; start the node with -poolconfig")
+		return
+	}
+	var params struct {
+		Worker       string `json:"worker"`
+		MinerAddress string `json:"miner_address"`
+	}
+	json.Unmarshal(req.Params, &params)
+	if params.MinerAddress == "" {
+		writeRPCError(w, req.ID, "miner_address required")
+		return
+	}
+	if err := s.validAddress(params.MinerAddress); err != nil {
+		writeRPCError(w, req.ID, "invalid miner_address: "+err.Error())
+		return
+	}
+	if params.Worker == "" {
+		params.Worker = params.MinerAddress
+	}
+	tmpl := s.Chain.CreateBlockTemplate(params.MinerAddress)
+	s.jobs.add(tmpl)
+	writeRPCResult(w, req.ID, map[string]interface{}{
+		"worker":     params.Worker,
+		"share_bits": shareBits(s.Pool.Bits(params.Worker), tmpl.Header.Bits),
+		"block":      tmpl,
+	})
+}
+
+// rpcSubmitShare credits a share of a job from getsharejob to its worker
+// and retunes the worker's share difficulty. A share that also meets the
+// block difficulty is submitted as a block.
+func (s *Server) rpcSubmitShare(w http.ResponseWriter, req JSONRPCRequest) {
+	if s.Pool == nil {
+		writeRPCError(w, req.ID, "pool mode is off; start the node with -poolconfig")
+		return
+	}
+	var params struct {
+		Worker string           `json:"worker"`
+		Block  blockchain.Block `json:"block"`
+	}
+	if err := json.Unmarshal(req.Params, &params); err != nil || params.Worker == "" {
+		writeRPCError(w, req.ID, "worker and block required")
+		return
+	}
+	b := &params.Block
+	blockBits, ok := s.jobs.lookup(b)
+	if !ok || b.Header.Bits != blockBits {
+		writeRPCError(w, req.ID, "stale or unknown job; fetch a new one with getsharejob")
+		return
+	}
+	if b.Header.ComputeHash() != b.Hash {
+		writeRPCError(w, req.ID, "share hash does not match its header")
+		return
+	}
+	hash, _ := new(big.Int).SetString(b.Hash, 16)
+	bits := shareBits(s.Pool.Bits(params.Worker), blockBits)
+	if hash.Cmp(blockchain.BitsToTarget(bits)) > 0 {
+		writeRPCError(w, req.ID, "share does not meet the share difficulty")
+		return
+	}
+	next, changed := s.Pool.RecordShare(params.Worker, time.Now())
+	res := map[string]interface{}{
+		"accepted":   true,
+		"share_bits": shareBits(next, blockBits),
+		"retargeted": changed,
+	}
+	if hash.Cmp(blockchain.BitsToTarget(blockBits)) <= 0 {
+		if err := s.Chain.AddBlock(b); err != nil {
+			res["block_error"] = err.Error()
+		} else {
+			s.Node.BroadcastBlock(b)
+			res["block"] = true
+			res["height"] = b.Header.Height
+		}
+	}
+	writeRPCResult(w, req.ID, res)
+}
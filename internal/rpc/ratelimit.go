@@ -0,0 +1,72 @@
+package rpc
+
+import (
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// rateLimiter is a per-client-IP token bucket.
+type rateLimiter struct {
+	rate    float64 // tokens per second, 0 = unlimited
+	burst   float64
+	buckets map[string]*bucket
+	mu      sync.Mutex
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+func newRateLimiter() *rateLimiter {
+	return &rateLimiter{buckets: make(map[string]*bucket)}
+}
+
+// Set changes the limit; existing buckets are reset.
+func (rl *rateLimiter) Set(rate float64, burst int) {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	rl.rate = rate
+	rl.burst = float64(burst)
+	rl.buckets = make(map[string]*bucket)
+}
+
+func (rl *rateLimiter) allow(ip string) bool {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	if rl.rate <= 0 {
+		return true
+	}
+	now := time.Now()
+	b, ok := rl.buckets[ip]
+	if !ok {
+		b = &bucket{tokens: rl.burst, last: now}
+		rl.buckets[ip] = b
+	}
+	b.tokens += now.Sub(b.last).Seconds() * rl.rate
+	if b.tokens > rl.burst {
+		b.tokens = rl.burst
+	}
+	b.last = now
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+func (rl *rateLimiter) wrap(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ip, _, err := net.SplitHostPort(r.RemoteAddr)
+		if err != nil {
+			ip = r.RemoteAddr
+		}
+		if !rl.allow(ip) {
+			jsonErr(w, 429, "rate limit exceeded")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
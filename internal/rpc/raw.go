@@ -0,0 +1,84 @@
+package rpc
+
+import (
+	"devinsidercoin/internal/blockchain"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+)
+
+// rawTx is a decoded transaction with its canonical size.
+type rawTx struct {
+	*blockchain.Transaction
+	Size int `json:"size"`
+}
+
+// rawBlock is a decoded block with its canonical size.
+type rawBlock struct {
+	*blockchain.Block
+	Size int `json:"size"`
+}
+
+func decodeHexParam(req JSONRPCRequest) ([]byte, error) {
+	var params struct {
+		Hex string `json:"hex"`
+	}
+	json.Unmarshal(req.Params, &params)
+	return hex.DecodeString(params.Hex)
+}
+
+func (s *Server) rpcDecodeRawTransaction(w http.ResponseWriter, req JSONRPCRequest) {
+	data, err := decodeHexParam(req)
+	if err != nil || len(data) == 0 {
+		writeRPCError(w, req.ID, "hex (canonical transaction encoding) required")
+		return
+	}
+	tx, err := blockchain.DeserializeTransaction(data)
+	if err != nil {
+		writeRPCError(w, req.ID, err.Error())
+		return
+	}
+	writeRPCResult(w, req.ID, rawTx{tx, len(data)})
+}
+
+func (s *Server) rpcDecodeBlock(w http.ResponseWriter, req JSONRPCRequest) {
+	data, err := decodeHexParam(req)
+	if err != nil || len(data) == 0 {
+		writeRPCError(w, req.ID, "hex (canonical block encoding) required")
+		return
+	}
+	block, err := blockchain.DeserializeBlock(data)
+	if err != nil {
+		writeRPCError(w, req.ID, err.Error())
+		return
+	}
+	writeRPCResult(w, req.ID, rawBlock{block, len(data)})
+}
+
+// rpcEncodeRawTransaction takes a transaction in its JSON form and returns
+// the canonical encoding and the TxID it implies.
+func (s *Server) rpcEncodeRawTransaction(w http.ResponseWriter, req JSONRPCRequest) {
+	var tx blockchain.Transaction
+	if err := json.Unmarshal(req.Params, &tx); err != nil || tx.Type == "" {
+		writeRPCError(w, req.ID, "transaction object required")
+		return
+	}
+	writeRPCResult(w, req.ID, map[string]interface{}{
+		"hex":  hex.EncodeToString(tx.Serialize()),
+		"txid": tx.ComputeTxID(),
+	})
+}
+
+// rpcEncodeBlock takes a block in its JSON form and returns the canonical
+// encoding and the hash it implies.
+func (s *Server) rpcEncodeBlock(w http.ResponseWriter, req JSONRPCRequest) {
+	var block blockchain.Block
+	if err := json.Unmarshal(req.Params, &block); err != nil || block.Header.PrevHash == "" {
+		writeRPCError(w, req.ID, "block object required")
+		return
+	}
+	writeRPCResult(w, req.ID, map[string]interface{}{
+		"hex":  hex.EncodeToString(block.Serialize()),
+		"hash": block.Header.ComputeHash(),
+	})
+}
@@ -1,23 +1,68 @@
 package rpc
 
 import (
+	"context"
 	"devinsidercoin/internal/blockchain"
+	"devinsidercoin/internal/logging"
 	"devinsidercoin/internal/network"
+	"devinsidercoin/internal/pool"
 	"devinsidercoin/internal/wallet"
+	"devinsidercoin/internal/webhook"
 	"encoding/json"
 	"fmt"
 	"io"
-	"log"
 	"net/http"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 )
 
+var logger = logging.For("RPC")
+
+// hashRateWindow is the number of recent blocks used for the network
+// hashrate estimate.
+const hashRateWindow = 120
+
 // Server handles JSON-RPC (mining) and REST (wallet) HTTP endpoints.
 type Server struct {
 	Chain   *blockchain.Blockchain
 	Node    *network.Node
 	Wallets *wallet.WalletManager
+	Keys    *wallet.KeyStore  // optional; API keys scoped to wallet addresses
+	Hooks   *webhook.Notifier // optional; deposit notifications
+	Pool    *pool.VarDiff     // optional; share difficulty for getsharejob and submitshare
 	Addr    string
+
+	// MiningAddr, if set, is a second listener serving only /rpc, so
+	// mining can be exposed to a farm network while Addr stays local.
+	MiningAddr string
+
+	ws      wsHub
+	jobs    shareJobs
+	limiter *rateLimiter
+	limOnce sync.Once
+	methods map[string]MethodHandler
+	methMu  sync.RWMutex
+	idem    idempotencyState
+
+	adminToken string
+	requireKey bool // wallet endpoints need an API key or the admin token
+	adminMu    sync.RWMutex
+}
+
+// MethodHandler implements an extra JSON-RPC method registered by
+// downstream code.
+type MethodHandler func(params json.RawMessage) (interface{}, error)
+
+// RegisterMethod adds a JSON-RPC method. Built-in methods take precedence.
+func (s *Server) RegisterMethod(name string, fn MethodHandler) {
+	s.methMu.Lock()
+	defer s.methMu.Unlock()
+	if s.methods == nil {
+		s.methods = make(map[string]MethodHandler)
+	}
+	s.methods[name] = fn
 }
 
 // JSONRPCRequest is the incoming JSON-RPC format.
@@ -34,8 +79,38 @@ type JSONRPCResponse struct {
 	ID     interface{} `json:"id"`
 }
 
-// Start begins the HTTP server.
+// Start begins the HTTP server, and the mining listener if MiningAddr is
+// set. It returns the first listener's error.
 func (s *Server) Start() error {
+	h := s.Handler()
+	errc := make(chan error, 2)
+	if s.MiningAddr != "" {
+		m := s.MiningHandler()
+		logger.Info("mining RPC listening", "addr", s.MiningAddr)
+		go func() { errc <- http.ListenAndServe(s.MiningAddr, m) }()
+	}
+	logger.Info("HTTP server listening", "addr", s.Addr)
+	go func() { errc <- http.ListenAndServe(s.Addr, h) }()
+	return <-errc
+}
+
+// miningListener marks requests that arrived on the mining listener.
+type miningListener struct{}
+
+// MiningHandler returns the JSON-RPC endpoint alone, for a listener that
+// miners can reach but wallet clients never use. Requests on it that
+// manage peers always need the admin token.
+func (s *Server) MiningHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/rpc", func(w http.ResponseWriter, r *http.Request) {
+		s.handleRPC(w, r.WithContext(context.WithValue(r.Context(), miningListener{}, true)))
+	})
+	return withCORS(s.rateLimiter().wrap(mux))
+}
+
+// Handler returns the RPC and REST endpoints as an http.Handler, for
+// serving on a listener of the caller's choosing. Call it once.
+func (s *Server) Handler() http.Handler {
 	mux := http.NewServeMux()
 
 	// JSON-RPC endpoint (mining)
@@ -43,21 +118,83 @@ func (s *Server) Start() error {
 
 	// REST wallet API
 	mux.HandleFunc("/api/wallet/create", s.handleWalletCreate)
+	mux.HandleFunc("/api/wallet/deposit-address", s.handleWalletDepositAddress)
 	mux.HandleFunc("/api/wallet/list", s.handleWalletList)
 	mux.HandleFunc("/api/wallet/backup", s.handleWalletBackup)
 	mux.HandleFunc("/api/wallet/restore", s.handleWalletRestore)
-	mux.HandleFunc("/api/wallet/send", s.handleWalletSend)
-	mux.HandleFunc("/api/wallet/balance", s.handleWalletBalance)
-	mux.HandleFunc("/api/wallet/transactions", s.handleWalletTransactions)
-	mux.HandleFunc("/api/wallet/stake", s.handleWalletStake)
-	mux.HandleFunc("/api/wallet/unstake", s.handleWalletUnstake)
+	mux.HandleFunc("/api/wallet/send", s.synced(s.idempotent(s.handleWalletSend)))
+	mux.HandleFunc("/api/wallet/balance", s.synced(s.handleWalletBalance))
+	mux.HandleFunc("/api/wallet/listunspent", s.synced(s.handleWalletListUnspent))
+	mux.HandleFunc("/api/wallet/transactions", s.synced(s.handleWalletTransactions))
+	mux.HandleFunc("/api/wallet/tx", s.handleWalletTx)
+	mux.HandleFunc("/api/wallet/stake", s.synced(s.idempotent(s.handleWalletStake)))
+	mux.HandleFunc("/api/wallet/unstake", s.synced(s.idempotent(s.handleWalletUnstake)))
+	mux.HandleFunc("/api/wallet/delegate", s.synced(s.idempotent(s.handleWalletDelegate)))
+	mux.HandleFunc("/api/wallet/unbonding", s.handleWalletUnbonding)
+	mux.HandleFunc("/api/wallet/rewards", s.handleWalletRewards)
+	mux.HandleFunc("GET /api/wallet/restake", s.handleWalletRestake)
+	mux.HandleFunc("POST /api/wallet/restake", s.handleWalletSetRestake)
+	mux.HandleFunc("/api/wallet/vote", s.synced(s.idempotent(s.handleWalletVote)))
+	mux.HandleFunc("/api/wallet/burn", s.synced(s.idempotent(s.handleWalletBurn)))
+	mux.HandleFunc("/api/wallet/upgrade", s.synced(s.idempotent(s.handleWalletUpgrade)))
 
 	// Chain info API
 	mux.HandleFunc("/api/chain/info", s.handleChainInfo)
 	mux.HandleFunc("/api/chain/block", s.handleChainBlock)
+	mux.HandleFunc("/api/chain/stats", s.handleChainStats)
+	mux.HandleFunc("/api/chain/difficulty", s.handleChainDifficulty)
+	mux.HandleFunc("/api/chain/charts", s.handleChainCharts)
+	mux.HandleFunc("/api/chain/governance", s.handleChainGovernance)
+	mux.HandleFunc("/api/chain/governance/snapshot", s.handleGovernanceSnapshot)
+	mux.HandleFunc("/api/chain/supply", s.handleChainSupply)
+	mux.HandleFunc("/api/chain/finality", s.handleChainFinality)
+	mux.HandleFunc("/api/staking/info", s.handleStakingInfo)
+	mux.HandleFunc("GET /api/validators/{addr}/stats", s.handleValidatorStats)
+
+	// Historical indexes (archive nodes)
+	mux.HandleFunc("/api/address/history", s.handleAddressHistory)
+	mux.HandleFunc("/api/address/balances", s.handleBalanceHistory)
+	mux.HandleFunc("/api/address/balanceat", s.handleBalanceAt)
+	mux.HandleFunc("/api/chain/richlist", s.handleRichList)
+	mux.HandleFunc("/api/chain/filter", s.handleBlockFilter)
+
+	// Event journal (nodes started with -journal)
+	mux.HandleFunc("GET /api/journal", s.handleJournal)
 
-	log.Printf("[RPC] HTTP server listening on %s", s.Addr)
-	return http.ListenAndServe(s.Addr, withCORS(mux))
+	// Transaction relay
+	mux.HandleFunc("GET /api/tx/{txid}/broadcast", s.handleTxBroadcast)
+	mux.HandleFunc("POST /api/tx/{txid}/rebroadcast", s.handleTxRebroadcast)
+
+	// Node operator API, behind the admin token
+	mux.HandleFunc("GET /api/admin/banlist", s.admin(s.handleAdminBanlist))
+	mux.HandleFunc("POST /api/admin/ban", s.admin(s.handleAdminBan))
+	mux.HandleFunc("POST /api/admin/unban", s.admin(s.handleAdminUnban))
+	mux.HandleFunc("GET /api/admin/peers", s.admin(s.handleAdminPeers))
+	mux.HandleFunc("POST /api/admin/peers/connect", s.admin(s.handleAdminConnect))
+	mux.HandleFunc("POST /api/admin/peers/disconnect", s.admin(s.handleAdminDisconnect))
+	mux.HandleFunc("/api/admin/loglevel", s.admin(s.handleAdminLogLevel))
+	mux.HandleFunc("POST /api/admin/backup", s.admin(s.handleAdminBackup))
+	mux.HandleFunc("POST /api/admin/reindex", s.admin(s.handleAdminReindex))
+	mux.HandleFunc("GET /api/admin/apikeys", s.admin(s.handleAdminAPIKeys))
+	mux.HandleFunc("POST /api/admin/apikeys", s.admin(s.handleAdminIssueAPIKey))
+	mux.HandleFunc("DELETE /api/admin/apikeys/{id}", s.admin(s.handleAdminRevokeAPIKey))
+
+	// Notification stream
+	mux.HandleFunc("/api/ws", s.handleWS)
+	s.watchDeposits()
+	s.watchActivity()
+
+	return withCORS(s.rateLimiter().wrap(mux))
+}
+
+// SetRateLimit changes the per-client request limit (0 = unlimited).
+func (s *Server) SetRateLimit(perSecond float64, burst int) {
+	s.rateLimiter().Set(perSecond, burst)
+}
+
+func (s *Server) rateLimiter() *rateLimiter {
+	s.limOnce.Do(func() { s.limiter = newRateLimiter() })
+	return s.limiter
 }
 
 func withCORS(next http.Handler) http.Handler {
@@ -73,6 +210,19 @@ func withCORS(next http.Handler) http.Handler {
 	})
 }
 
+// synced answers 503 "node syncing" instead of calling h while the chain
+// is below the network's minimum chain work or height, so wallet balances
+// and spends are never based on a chain that is far behind.
+func (s *Server) synced(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := s.Chain.CheckSynced(); err != nil {
+			jsonErr(w, 503, err.Error())
+			return
+		}
+		h(w, r)
+	}
+}
+
 func jsonOK(w http.ResponseWriter, data interface{}) {
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]interface{}{"ok": true, "data": data})
@@ -97,8 +247,14 @@ func (s *Server) handleRPC(w http.ResponseWriter, r *http.Request) {
 	switch req.Method {
 	case "getblocktemplate":
 		s.rpcGetBlockTemplate(w, req)
+	case "getblocktemplateproposal":
+		writeRPCResult(w, req.ID, s.Chain.ProposeBlock())
 	case "submitblock":
 		s.rpcSubmitBlock(w, req)
+	case "getsharejob":
+		s.rpcGetShareJob(w, req)
+	case "submitshare":
+		s.rpcSubmitShare(w, req)
 	case "getblockcount":
 		writeRPCResult(w, req.ID, s.Chain.GetBlockCount())
 	case "getbestblockhash":
@@ -114,20 +270,94 @@ func (s *Server) handleRPC(w http.ResponseWriter, r *http.Request) {
 		if best != nil {
 			bits = best.Header.Bits
 		}
+		floor := s.Chain.FloorStatus()
 		writeRPCResult(w, req.ID, map[string]interface{}{
-			"blocks":       s.Chain.GetBlockCount(),
-			"difficulty":   bits,
-			"network_hash": 0,
-			"max_supply":   s.Chain.Config.MaxSupply,
-			"total_minted": s.Chain.GetTotalMinted(),
-			"staked_total": s.Chain.Stakes.GetTotalStaked(),
-			"mempool_size": len(s.Chain.GetMempool()),
-			"peers":        s.Node.GetPeerCount(),
+			"blocks":            s.Chain.GetBlockCount(),
+			"difficulty":        bits,
+			"network_hash":      s.Chain.NetworkHashPS(hashRateWindow, -1),
+			"max_supply":        s.Chain.Config.MaxSupply,
+			"total_minted":      s.Chain.GetTotalMinted(),
+			"staked_total":      s.Chain.Stakes.GetTotalStaked(),
+			"mempool_size":      s.Chain.GetMempoolSize(),
+			"peers":             s.Node.GetPeerCount(),
+			"floor_epoch":       floor.Epoch,
+			"floor_bits":        floor.FloorBits,
+			"next_floor_height": floor.NextFloorHeight,
+			"next_floor_bits":   floor.NextFloorBits,
 		})
 	case "getpeerinfo":
-		writeRPCResult(w, req.ID, s.Node.GetPeerAddresses())
+		writeRPCResult(w, req.ID, s.Node.GetPeerInfo())
+	case "getnetworkinfo":
+		writeRPCResult(w, req.ID, map[string]interface{}{
+			"node_id":       s.Node.Identity.ID(),
+			"external_addr": s.Node.ExternalAddr,
+			"services":      s.Node.Services.Names(),
+			"connections":   s.Node.GetPeerCount(),
+			"propagation":   s.Node.PropagationStats(),
+			"orphans":       s.Node.OrphanCount(),
+		})
+	case "getnodeaddresses":
+		writeRPCResult(w, req.ID, s.Node.KnownAddresses())
+	case "getsyncstatus":
+		writeRPCResult(w, req.ID, s.Node.SyncStatus())
+	case "getindexinfo":
+		writeRPCResult(w, req.ID, s.Chain.IndexInfo())
+	case "getdeploymentinfo":
+		writeRPCResult(w, req.ID, s.Chain.GetDeploymentInfo())
+	case "submitevidence":
+		s.rpcSubmitEvidence(w, req)
+	case "getevidence":
+		writeRPCResult(w, req.ID, s.Chain.PendingEvidence())
+	case "addnode", "disconnectnode":
+		if !s.rpcAdminAllowed(r) {
+			writeRPCError(w, req.ID, req.Method+" needs the admin token")
+			return
+		}
+		if req.Method == "addnode" {
+			s.rpcAddNode(w, req)
+		} else {
+			s.rpcDisconnectNode(w, req)
+		}
+	case "generatetoaddress":
+		s.rpcGenerateToAddress(w, req)
+	case "gettransaction":
+		s.rpcGetTransaction(w, req)
+	case "getnetworkhashps":
+		s.rpcGetNetworkHashPS(w, req)
+	case "getreceivedbyaddress":
+		s.rpcGetReceivedByAddress(w, req)
+	case "getnonce":
+		s.rpcGetNonce(w, req)
+	case "validateaddress":
+		s.rpcValidateAddress(w, req)
+	case "decoderawtransaction":
+		s.rpcDecodeRawTransaction(w, req)
+	case "decodeblock":
+		s.rpcDecodeBlock(w, req)
+	case "encoderawtransaction":
+		s.rpcEncodeRawTransaction(w, req)
+	case "encodeblock":
+		s.rpcEncodeBlock(w, req)
+	case "listunspent":
+		s.rpcListUnspent(w, req)
+	case "gettxout":
+		s.rpcGetTxOut(w, req)
+	case "gettxoutsetinfo":
+		writeRPCResult(w, req.ID, s.Chain.GetUTXOSetInfo())
 	default:
-		writeRPCError(w, req.ID, "unknown method: "+req.Method)
+		s.methMu.RLock()
+		fn, ok := s.methods[req.Method]
+		s.methMu.RUnlock()
+		if !ok {
+			writeRPCError(w, req.ID, "unknown method: "+req.Method)
+			return
+		}
+		result, err := fn(req.Params)
+		if err != nil {
+			writeRPCError(w, req.ID, err.Error())
+			return
+		}
+		writeRPCResult(w, req.ID, result)
 	}
 }
 
@@ -140,6 +370,10 @@ func (s *Server) rpcGetBlockTemplate(w http.ResponseWriter, req JSONRPCRequest)
 		writeRPCError(w, req.ID, "miner_address required")
 		return
 	}
+	if err := s.validAddress(params.MinerAddress); err != nil {
+		writeRPCError(w, req.ID, "invalid miner_address: "+err.Error())
+		return
+	}
 	tmpl := s.Chain.CreateBlockTemplate(params.MinerAddress)
 	writeRPCResult(w, req.ID, tmpl)
 }
@@ -163,6 +397,174 @@ func (s *Server) rpcSubmitBlock(w http.ResponseWriter, req JSONRPCRequest) {
 	})
 }
 
+func (s *Server) rpcGetNetworkHashPS(w http.ResponseWriter, req JSONRPCRequest) {
+	params := struct {
+		NBlocks int   `json:"nblocks"`
+		Height  int64 `json:"height"`
+	}{NBlocks: hashRateWindow, Height: -1}
+	json.Unmarshal(req.Params, &params)
+	if params.NBlocks < 2 || params.NBlocks > blockchain.MaxStatsWindow {
+		writeRPCError(w, req.ID, fmt.Sprintf("nblocks must be between 2 and %d", blockchain.MaxStatsWindow))
+		return
+	}
+	writeRPCResult(w, req.ID, s.Chain.NetworkHashPS(params.NBlocks, params.Height))
+}
+
+func (s *Server) rpcGetTransaction(w http.ResponseWriter, req JSONRPCRequest) {
+	var params struct {
+		TxID string `json:"txid"`
+	}
+	json.Unmarshal(req.Params, &params)
+	if params.TxID == "" {
+		writeRPCError(w, req.ID, "txid required")
+		return
+	}
+	rec, ok := s.Chain.GetTransaction(params.TxID)
+	if !ok {
+		writeRPCError(w, req.ID, "transaction not found")
+		return
+	}
+	writeRPCResult(w, req.ID, rec)
+}
+
+func (s *Server) rpcGetReceivedByAddress(w http.ResponseWriter, req JSONRPCRequest) {
+	params := struct {
+		Address string `json:"address"`
+		MinConf int    `json:"minconf"`
+	}{MinConf: 1}
+	json.Unmarshal(req.Params, &params)
+	if params.Address == "" {
+		writeRPCError(w, req.ID, "address required")
+		return
+	}
+	if err := s.validAddress(params.Address); err != nil {
+		writeRPCError(w, req.ID, "invalid address: "+err.Error())
+		return
+	}
+	if err := s.Chain.CheckSynced(); err != nil {
+		writeRPCError(w, req.ID, err.Error())
+		return
+	}
+	total, err := s.Chain.GetReceivedByAddress(params.Address, params.MinConf)
+	if err != nil {
+		writeRPCError(w, req.ID, err.Error())
+		return
+	}
+	writeRPCResult(w, req.ID, total)
+}
+
+func (s *Server) rpcGetNonce(w http.ResponseWriter, req JSONRPCRequest) {
+	var params struct {
+		Address string `json:"address"`
+	}
+	json.Unmarshal(req.Params, &params)
+	if params.Address == "" {
+		writeRPCError(w, req.ID, "address required")
+		return
+	}
+	if err := s.validAddress(params.Address); err != nil {
+		writeRPCError(w, req.ID, "invalid address: "+err.Error())
+		return
+	}
+	confirmed, next := s.Chain.GetNonce(params.Address)
+	writeRPCResult(w, req.ID, map[string]interface{}{
+		"address":    params.Address,
+		"nonce":      confirmed,
+		"next_nonce": next,
+	})
+}
+
+func (s *Server) rpcAddNode(w http.ResponseWriter, req JSONRPCRequest) {
+	var params struct {
+		Address string `json:"address"`
+	}
+	json.Unmarshal(req.Params, &params)
+	if params.Address == "" {
+		writeRPCError(w, req.ID, "address required")
+		return
+	}
+	if err := s.Node.ConnectPeer(params.Address); err != nil {
+		writeRPCError(w, req.ID, err.Error())
+		return
+	}
+	writeRPCResult(w, req.ID, map[string]interface{}{"connecting": params.Address})
+}
+
+// rpcSubmitEvidence pools double-sign evidence and gossips both
+// attestations, so that peers holding either half pool it too.
+func (s *Server) rpcSubmitEvidence(w http.ResponseWriter, req JSONRPCRequest) {
+	var ev blockchain.Evidence
+	if err := json.Unmarshal(req.Params, &ev); err != nil {
+		writeRPCError(w, req.ID, "params must be {first, second} attestations")
+		return
+	}
+	added, err := s.Chain.AddEvidence(ev)
+	if err != nil {
+		writeRPCError(w, req.ID, err.Error())
+		return
+	}
+	if added {
+		s.Node.BroadcastAttestation(&ev.First)
+		s.Node.BroadcastAttestation(&ev.Second)
+	}
+	writeRPCResult(w, req.ID, map[string]interface{}{
+		"address": ev.First.Address, "height": ev.First.Height, "added": added,
+	})
+}
+
+func (s *Server) rpcDisconnectNode(w http.ResponseWriter, req JSONRPCRequest) {
+	var params struct {
+		Address string `json:"address"`
+	}
+	json.Unmarshal(req.Params, &params)
+	if params.Address == "" {
+		writeRPCError(w, req.ID, "address required")
+		return
+	}
+	if err := s.Node.DisconnectPeer(params.Address); err != nil {
+		writeRPCError(w, req.ID, err.Error())
+		return
+	}
+	writeRPCResult(w, req.ID, map[string]interface{}{"disconnected": params.Address})
+}
+
+// rpcGenerateToAddress mines blocks in-process. Only available on regtest.
+func (s *Server) rpcGenerateToAddress(w http.ResponseWriter, req JSONRPCRequest) {
+	if !s.Chain.Config.Regtest {
+		writeRPCError(w, req.ID, "generatetoaddress is only available on regtest")
+		return
+	}
+	var params struct {
+		NBlocks int    `json:"nblocks"`
+		Address string `json:"address"`
+	}
+	json.Unmarshal(req.Params, &params)
+	if params.Address == "" || params.NBlocks <= 0 {
+		writeRPCError(w, req.ID, "nblocks (>0) and address required")
+		return
+	}
+	if err := s.validAddress(params.Address); err != nil {
+		writeRPCError(w, req.ID, "invalid address: "+err.Error())
+		return
+	}
+
+	hashes := make([]string, 0, params.NBlocks)
+	for i := 0; i < params.NBlocks; i++ {
+		block := s.Chain.CreateBlockTemplate(params.Address)
+		if !blockchain.SolveBlock(block, 1<<32) {
+			writeRPCError(w, req.ID, "failed to solve block")
+			return
+		}
+		if err := s.Chain.AddBlock(block); err != nil {
+			writeRPCError(w, req.ID, err.Error())
+			return
+		}
+		s.Node.BroadcastBlock(block)
+		hashes = append(hashes, block.Hash)
+	}
+	writeRPCResult(w, req.ID, hashes)
+}
+
 func writeRPCResult(w http.ResponseWriter, id interface{}, result interface{}) {
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(JSONRPCResponse{Result: result, ID: id})
@@ -180,6 +582,10 @@ func (s *Server) handleWalletCreate(w http.ResponseWriter, r *http.Request) {
 		jsonErr(w, 405, "POST required")
 		return
 	}
+	key, ok := s.walletKey(w, r)
+	if !ok || !unrestricted(w, key) {
+		return
+	}
 	wlt, err := s.Wallets.CreateWallet()
 	if err != nil {
 		jsonErr(w, 500, err.Error())
@@ -191,8 +597,53 @@ func (s *Server) handleWalletCreate(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+// handleWalletDepositAddress creates a fresh address tagged with the
+// caller's reference ID. Deposits to it are reported with that reference.
+func (s *Server) handleWalletDepositAddress(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		jsonErr(w, 405, "POST required")
+		return
+	}
+	var req struct {
+		Reference string `json:"reference"`
+	}
+	body, _ := io.ReadAll(r.Body)
+	json.Unmarshal(body, &req)
+	if req.Reference == "" {
+		jsonErr(w, 400, "reference required")
+		return
+	}
+	key, ok := s.walletKey(w, r)
+	if !ok {
+		return
+	}
+	if key != nil && !key.Has(wallet.ScopeDeposit) {
+		jsonErr(w, 403, "API key "+key.ID+" has no deposit access")
+		return
+	}
+	wlt, err := s.Wallets.CreateDepositAddress(req.Reference)
+	if err != nil {
+		jsonErr(w, 500, err.Error())
+		return
+	}
+	if key != nil {
+		if err := s.Keys.AddAddress(key.ID, wlt.Address); err != nil {
+			jsonErr(w, 500, err.Error())
+			return
+		}
+	}
+	jsonOK(w, map[string]string{"address": wlt.Address, "reference": wlt.Reference})
+}
+
 func (s *Server) handleWalletList(w http.ResponseWriter, r *http.Request) {
+	key, ok := s.walletKey(w, r)
+	if !ok {
+		return
+	}
 	addrs := s.Wallets.ListWallets()
+	if key != nil {
+		addrs = keyAddresses(key, wallet.ScopeRead, addrs)
+	}
 	type walletInfo struct {
 		Address string  `json:"address"`
 		Balance float64 `json:"balance"`
@@ -215,6 +666,10 @@ func (s *Server) handleWalletBackup(w http.ResponseWriter, r *http.Request) {
 		jsonErr(w, 400, "address parameter required")
 		return
 	}
+	key, ok := s.walletKey(w, r)
+	if !ok || !unrestricted(w, key) {
+		return
+	}
 	data, err := s.Wallets.Backup(address)
 	if err != nil {
 		jsonErr(w, 404, err.Error())
@@ -230,6 +685,10 @@ func (s *Server) handleWalletRestore(w http.ResponseWriter, r *http.Request) {
 		jsonErr(w, 405, "POST required")
 		return
 	}
+	key, ok := s.walletKey(w, r)
+	if !ok || !unrestricted(w, key) {
+		return
+	}
 	body, _ := io.ReadAll(r.Body)
 	wlt, err := s.Wallets.Restore(body)
 	if err != nil {
@@ -245,9 +704,11 @@ func (s *Server) handleWalletSend(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	var req struct {
-		From   string  `json:"from"`
-		To     string  `json:"to"`
-		Amount float64 `json:"amount"`
+		From          string               `json:"from"`
+		To            string               `json:"to"`
+		Amount        float64              `json:"amount"`
+		Inputs        []blockchain.TxInput `json:"inputs"`         // coin control, utxo networks only
+		ChangeAddress string               `json:"change_address"` // utxo networks only; default from
 	}
 	body, _ := io.ReadAll(r.Body)
 	if err := json.Unmarshal(body, &req); err != nil {
@@ -258,19 +719,35 @@ func (s *Server) handleWalletSend(w http.ResponseWriter, r *http.Request) {
 		jsonErr(w, 400, "from, to, and amount (>0) required")
 		return
 	}
-
-	// Sign the transaction
-	txData := fmt.Sprintf("%s:%s:%.8f:%d", req.From, req.To, req.Amount, time.Now().Unix())
-	sig, err := s.Wallets.Sign(req.From, []byte(txData))
-	if err != nil {
-		jsonErr(w, 400, "cannot sign: "+err.Error())
+	if !s.checkAddress(w, "to", req.To) {
+		return
+	}
+	if req.ChangeAddress != "" && !s.checkAddress(w, "change_address", req.ChangeAddress) {
+		return
+	}
+	key, ok := s.walletKey(w, r)
+	if !ok || !authorize(w, key, wallet.ScopeSend, req.From) {
 		return
 	}
 
+	// Sign the canonical transaction payload
 	fee := 0.001
-	tx := blockchain.NewTransferTransaction(req.From, req.To, req.Amount, fee, sig)
+	tx := blockchain.NewTransferTransaction(req.From, req.To, req.Amount, fee, "")
+	if err := s.Chain.FundTransaction(&tx, req.Inputs, req.ChangeAddress); err != nil {
+		jsonErr(w, 400, err.Error())
+		return
+	}
+	if err := s.Wallets.SignTx(&tx, s.Chain.Config.NetworkID); err != nil {
+		jsonErr(w, 400, "cannot sign: "+err.Error())
+		return
+	}
 
+	refund, ok := s.chargeKey(w, key, tx.Amount+tx.Fee)
+	if !ok {
+		return
+	}
 	if err := s.Chain.AddToMempool(tx); err != nil {
+		refund()
 		jsonErr(w, 400, err.Error())
 		return
 	}
@@ -284,24 +761,66 @@ func (s *Server) handleWalletSend(w http.ResponseWriter, r *http.Request) {
 		"to":     tx.To,
 		"amount": tx.Amount,
 		"fee":    tx.Fee,
+		"inputs": tx.Inputs,
 		"status": "pending",
 	})
 }
 
+// handleWalletListUnspent lists spendable outputs of one address, or of
+// every wallet held by the node when no address is given.
+func (s *Server) handleWalletListUnspent(w http.ResponseWriter, r *http.Request) {
+	key, ok := s.walletKey(w, r)
+	if !ok {
+		return
+	}
+	addrs := s.Wallets.ListWallets()
+	if address := r.URL.Query().Get("address"); address != "" {
+		if !s.checkAddress(w, "address", address) || !authorize(w, key, wallet.ScopeRead, address) {
+			return
+		}
+		addrs = []string{address}
+	} else if key != nil {
+		addrs = keyAddresses(key, wallet.ScopeRead, addrs)
+	}
+	utxos := []blockchain.UTXO{}
+	for _, addr := range addrs {
+		utxos = append(utxos, s.Chain.ListUnspent(addr)...)
+	}
+	jsonOK(w, utxos)
+}
+
 func (s *Server) handleWalletBalance(w http.ResponseWriter, r *http.Request) {
 	address := r.URL.Query().Get("address")
 	if address == "" {
 		jsonErr(w, 400, "address parameter required")
 		return
 	}
+	if !s.checkAddress(w, "address", address) {
+		return
+	}
+	if key, ok := s.walletKey(w, r); !ok || !authorize(w, key, wallet.ScopeRead, address) {
+		return
+	}
 	balance := s.Chain.GetBalance(address)
 	staked := s.Chain.Stakes.GetStake(address)
-	jsonOK(w, map[string]interface{}{
+	pending := s.Chain.GetImmature(address)
+	unbonding := 0.0
+	for _, u := range s.Chain.GetUnbonding(address) {
+		unbonding += u.Amount
+	}
+	resp := map[string]interface{}{
 		"address":   address,
 		"balance":   balance,
 		"staked":    staked,
-		"available": balance - staked,
-	})
+		"pending":   pending,
+		"unbonding": unbonding,
+		"available": balance - staked - pending,
+	}
+	if unlock := s.Chain.StakeUnlockHeight(address); unlock > 0 {
+		resp["stake_unlock_height"] = unlock
+		resp["stake_lock_remaining"] = unlock - min(unlock, s.Chain.GetBlockCount())
+	}
+	jsonOK(w, resp)
 }
 
 func (s *Server) handleWalletTransactions(w http.ResponseWriter, r *http.Request) {
@@ -310,10 +829,34 @@ func (s *Server) handleWalletTransactions(w http.ResponseWriter, r *http.Request
 		jsonErr(w, 400, "address parameter required")
 		return
 	}
+	if !s.checkAddress(w, "address", address) {
+		return
+	}
+	if key, ok := s.walletKey(w, r); !ok || !authorize(w, key, wallet.ScopeRead, address) {
+		return
+	}
 	txs := s.Chain.GetTransactions(address)
 	jsonOK(w, txs)
 }
 
+func (s *Server) handleWalletTx(w http.ResponseWriter, r *http.Request) {
+	txid := r.URL.Query().Get("txid")
+	if txid == "" {
+		jsonErr(w, 400, "txid parameter required")
+		return
+	}
+	key, ok := s.walletKey(w, r)
+	if !ok {
+		return
+	}
+	rec, ok := s.Chain.GetTransaction(txid)
+	if !ok || (key != nil && !touchesKey(key, &rec.Transaction)) {
+		jsonErr(w, 404, "transaction not found")
+		return
+	}
+	jsonOK(w, rec)
+}
+
 func (s *Server) handleWalletStake(w http.ResponseWriter, r *http.Request) {
 	if r.Method != "POST" {
 		jsonErr(w, 405, "POST required")
@@ -329,14 +872,26 @@ func (s *Server) handleWalletStake(w http.ResponseWriter, r *http.Request) {
 		jsonErr(w, 400, "address and amount (>0) required")
 		return
 	}
+	if key, ok := s.walletKey(w, r); !ok || !authorize(w, key, wallet.ScopeStake, req.Address) {
+		return
+	}
 
 	tx := blockchain.Transaction{
+		Version:   blockchain.TxVersionCanonical,
 		Type:      "stake",
 		From:      req.Address,
 		Amount:    req.Amount,
 		Timestamp: time.Now().Unix(),
 	}
 	tx.TxID = tx.ComputeTxID()
+	if err := s.Chain.FundTransaction(&tx, nil, ""); err != nil {
+		jsonErr(w, 400, err.Error())
+		return
+	}
+	if err := s.Wallets.SignTx(&tx, s.Chain.Config.NetworkID); err != nil {
+		jsonErr(w, 400, "cannot sign: "+err.Error())
+		return
+	}
 
 	if err := s.Chain.AddToMempool(tx); err != nil {
 		jsonErr(w, 400, err.Error())
@@ -361,14 +916,87 @@ func (s *Server) handleWalletUnstake(w http.ResponseWriter, r *http.Request) {
 		jsonErr(w, 400, "address and amount (>0) required")
 		return
 	}
+	if key, ok := s.walletKey(w, r); !ok || !authorize(w, key, wallet.ScopeStake, req.Address) {
+		return
+	}
 
 	tx := blockchain.Transaction{
+		Version:   blockchain.TxVersionCanonical,
 		Type:      "unstake",
 		From:      req.Address,
 		Amount:    req.Amount,
 		Timestamp: time.Now().Unix(),
 	}
 	tx.TxID = tx.ComputeTxID()
+	if err := s.Chain.FundTransaction(&tx, nil, ""); err != nil {
+		jsonErr(w, 400, err.Error())
+		return
+	}
+	if err := s.Wallets.SignTx(&tx, s.Chain.Config.NetworkID); err != nil {
+		jsonErr(w, 400, "cannot sign: "+err.Error())
+		return
+	}
+
+	if err := s.Chain.AddToMempool(tx); err != nil {
+		jsonErr(w, 400, err.Error())
+		return
+	}
+	s.Node.BroadcastTx(&tx)
+	resp := map[string]interface{}{"txid": tx.TxID, "status": "pending"}
+	// Assuming it confirms in the next block; /api/wallet/unbonding has the
+	// exact height once it has.
+	if next := s.Chain.GetBlockCount(); s.Chain.Config.UnbondingActive(next) {
+		resp["release_height"] = s.Chain.UnbondRelease(next)
+	}
+	jsonOK(w, resp)
+}
+
+// handleWalletDelegate stakes amount behind a validator, moving the
+// address's whole stake to it.
+func (s *Server) handleWalletDelegate(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		jsonErr(w, 405, "POST required")
+		return
+	}
+	var req struct {
+		Address   string  `json:"address"`
+		Validator string  `json:"validator"`
+		Amount    float64 `json:"amount"`
+	}
+	body, _ := io.ReadAll(r.Body)
+	json.Unmarshal(body, &req)
+	if req.Address == "" || req.Validator == "" || req.Amount <= 0 {
+		jsonErr(w, 400, "address, validator and amount (>0) required")
+		return
+	}
+	if !s.checkAddress(w, "validator", req.Validator) {
+		return
+	}
+	if key, ok := s.walletKey(w, r); !ok || !authorize(w, key, wallet.ScopeStake, req.Address) {
+		return
+	}
+	if !s.Chain.Config.DelegationActive(s.Chain.GetBlockCount()) {
+		jsonErr(w, 400, blockchain.ErrDelegationDisabled.Error())
+		return
+	}
+
+	tx := blockchain.Transaction{
+		Version:   blockchain.TxVersionCanonical,
+		Type:      "delegate",
+		From:      req.Address,
+		To:        req.Validator,
+		Amount:    req.Amount,
+		Timestamp: time.Now().Unix(),
+	}
+	tx.TxID = tx.ComputeTxID()
+	if err := s.Chain.FundTransaction(&tx, nil, ""); err != nil {
+		jsonErr(w, 400, err.Error())
+		return
+	}
+	if err := s.Wallets.SignTx(&tx, s.Chain.Config.NetworkID); err != nil {
+		jsonErr(w, 400, "cannot sign: "+err.Error())
+		return
+	}
 
 	if err := s.Chain.AddToMempool(tx); err != nil {
 		jsonErr(w, 400, err.Error())
@@ -378,8 +1006,389 @@ func (s *Server) handleWalletUnstake(w http.ResponseWriter, r *http.Request) {
 	jsonOK(w, map[string]interface{}{"txid": tx.TxID, "status": "pending"})
 }
 
+// handleWalletUnbonding lists an address's unstakes waiting for release.
+func (s *Server) handleWalletUnbonding(w http.ResponseWriter, r *http.Request) {
+	address := r.URL.Query().Get("address")
+	if address == "" {
+		jsonErr(w, 400, "address parameter required")
+		return
+	}
+	if !s.checkAddress(w, "address", address) {
+		return
+	}
+	if key, ok := s.walletKey(w, r); !ok || !authorize(w, key, wallet.ScopeRead, address) {
+		return
+	}
+	jsonOK(w, s.Chain.GetUnbonding(address))
+}
+
+// restakeStatus is the auto-restake policy of a wallet.
+type restakeStatus struct {
+	Address   string  `json:"address"`
+	Enabled   bool    `json:"enabled"`
+	Threshold float64 `json:"threshold"`
+	Pending   float64 `json:"pending"` // released and rewarded since the last restake
+}
+
+func (s *Server) restakeStatus(address string) restakeStatus {
+	st := restakeStatus{Address: address}
+	if p, ok := s.Wallets.Restaking()[address]; ok {
+		st.Enabled, st.Threshold, st.Pending = true, p.Threshold, p.Pending
+	}
+	return st
+}
+
+// handleWalletRestake returns a local wallet's auto-restake policy.
+func (s *Server) handleWalletRestake(w http.ResponseWriter, r *http.Request) {
+	address := r.URL.Query().Get("address")
+	if address == "" {
+		jsonErr(w, 400, "address parameter required")
+		return
+	}
+	if key, ok := s.walletKey(w, r); !ok || !authorize(w, key, wallet.ScopeRead, address) {
+		return
+	}
+	if _, ok := s.Wallets.GetWallet(address); !ok {
+		jsonErr(w, 404, "wallet not found: "+address)
+		return
+	}
+	jsonOK(w, s.restakeStatus(address))
+}
+
+// handleWalletSetRestake turns auto-restake on or off for a local wallet.
+// Turning it on again keeps what is already pending.
+func (s *Server) handleWalletSetRestake(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Address   string  `json:"address"`
+		Enabled   bool    `json:"enabled"`
+		Threshold float64 `json:"threshold"`
+	}
+	body, _ := io.ReadAll(r.Body)
+	if err := json.Unmarshal(body, &req); err != nil || req.Address == "" {
+		jsonErr(w, 400, "address required")
+		return
+	}
+	if req.Threshold < 0 || req.Threshold > s.Chain.Config.MaxSupply {
+		jsonErr(w, 400, fmt.Sprintf("invalid threshold %v", req.Threshold))
+		return
+	}
+	if key, ok := s.walletKey(w, r); !ok || !authorize(w, key, wallet.ScopeStake, req.Address) {
+		return
+	}
+	var policy *wallet.RestakePolicy
+	if req.Enabled {
+		policy = &wallet.RestakePolicy{Threshold: req.Threshold}
+		if old, ok := s.Wallets.Restaking()[req.Address]; ok {
+			policy.Pending = old.Pending
+		}
+	}
+	if err := s.Wallets.SetRestake(req.Address, policy); err != nil {
+		jsonErr(w, 404, err.Error())
+		return
+	}
+	jsonOK(w, s.restakeStatus(req.Address))
+}
+
+// handleWalletRewards pages through the staking rewards paid to an
+// address, optionally between two dates.
+func (s *Server) handleWalletRewards(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	address, from, limit, err := historyParams(q)
+	if err != nil {
+		jsonErr(w, 400, err.Error())
+		return
+	}
+	since, err := parseDate(q.Get("since"), false)
+	if err != nil {
+		jsonErr(w, 400, "invalid since: "+err.Error())
+		return
+	}
+	until, err := parseDate(q.Get("until"), true)
+	if err != nil {
+		jsonErr(w, 400, "invalid until: "+err.Error())
+		return
+	}
+	if !s.checkAddress(w, "address", address) {
+		return
+	}
+	if key, ok := s.walletKey(w, r); !ok || !authorize(w, key, wallet.ScopeRead, address) {
+		return
+	}
+	jsonOK(w, s.Chain.GetRewards(address, from, limit, since, until))
+}
+
+// parseDate reads a unix timestamp or a YYYY-MM-DD date (UTC), which
+// stands for the start of the day, or its last second if end is set. An
+// empty value is 0.
+func parseDate(v string, end bool) (int64, error) {
+	if v == "" {
+		return 0, nil
+	}
+	if n, err := strconv.ParseInt(v, 10, 64); err == nil && n > 0 {
+		return n, nil
+	}
+	t, err := time.Parse(time.DateOnly, v)
+	if err != nil {
+		return 0, fmt.Errorf("%q is neither a unix time nor YYYY-MM-DD", v)
+	}
+	if end {
+		return t.Unix() + 86400 - 1, nil
+	}
+	return t.Unix(), nil
+}
+
+// handleWalletVote casts a stake-weighted vote on a governable parameter.
+func (s *Server) handleWalletVote(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		jsonErr(w, 405, "POST required")
+		return
+	}
+	var req struct {
+		Address string  `json:"address"`
+		Param   string  `json:"param"`
+		Value   float64 `json:"value"`
+	}
+	body, _ := io.ReadAll(r.Body)
+	json.Unmarshal(body, &req)
+	if req.Address == "" || req.Param == "" {
+		jsonErr(w, 400, "address, param and value required")
+		return
+	}
+	if key, ok := s.walletKey(w, r); !ok || !authorize(w, key, wallet.ScopeStake, req.Address) {
+		return
+	}
+
+	data, _ := json.Marshal(blockchain.Vote{Param: req.Param, Value: req.Value})
+	tx := blockchain.Transaction{
+		Version:   blockchain.TxVersionCanonical,
+		Type:      "vote",
+		From:      req.Address,
+		Fee:       0.001,
+		Timestamp: time.Now().Unix(),
+		Data:      string(data),
+	}
+	if err := s.Chain.FundTransaction(&tx, nil, ""); err != nil {
+		jsonErr(w, 400, err.Error())
+		return
+	}
+	if err := s.Wallets.SignTx(&tx, s.Chain.Config.NetworkID); err != nil {
+		jsonErr(w, 400, "cannot sign: "+err.Error())
+		return
+	}
+	tx.TxID = tx.ComputeTxID()
+
+	if err := s.Chain.AddToMempool(tx); err != nil {
+		jsonErr(w, 400, err.Error())
+		return
+	}
+	s.Node.BroadcastTx(&tx)
+	jsonOK(w, map[string]interface{}{"txid": tx.TxID, "status": "pending"})
+}
+
+// handleWalletBurn destroys coins by paying them to the burn address.
+func (s *Server) handleWalletBurn(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		jsonErr(w, 405, "POST required")
+		return
+	}
+	var req struct {
+		Address string  `json:"address"`
+		Amount  float64 `json:"amount"`
+	}
+	body, _ := io.ReadAll(r.Body)
+	json.Unmarshal(body, &req)
+	if req.Address == "" || req.Amount <= 0 {
+		jsonErr(w, 400, "address and amount (>0) required")
+		return
+	}
+	key, ok := s.walletKey(w, r)
+	if !ok || !authorize(w, key, wallet.ScopeSend, req.Address) {
+		return
+	}
+
+	sink := blockchain.BurnAddress(s.Chain.Config.AddressPrefix)
+	tx := blockchain.NewBurnTransaction(req.Address, sink, req.Amount, 0.001)
+	if err := s.Chain.FundTransaction(&tx, nil, ""); err != nil {
+		jsonErr(w, 400, err.Error())
+		return
+	}
+	if err := s.Wallets.SignTx(&tx, s.Chain.Config.NetworkID); err != nil {
+		jsonErr(w, 400, "cannot sign: "+err.Error())
+		return
+	}
+
+	refund, ok := s.chargeKey(w, key, tx.Amount+tx.Fee)
+	if !ok {
+		return
+	}
+	if err := s.Chain.AddToMempool(tx); err != nil {
+		refund()
+		jsonErr(w, 400, err.Error())
+		return
+	}
+	s.Node.BroadcastTx(&tx)
+	jsonOK(w, map[string]interface{}{"txid": tx.TxID, "burn_address": sink, "status": "pending"})
+}
+
+// ========== Transaction Relay API ==========
+
+// handleTxBroadcast reports which peers a pending transaction was sent to.
+func (s *Server) handleTxBroadcast(w http.ResponseWriter, r *http.Request) {
+	txid := r.PathValue("txid")
+	st, ok := s.Node.BroadcastStatus(txid)
+	if !ok {
+		if _, pending := s.Chain.GetPendingTx(txid); !pending {
+			jsonErr(w, 404, "transaction is not pending")
+			return
+		}
+		st = network.BroadcastState{TxID: txid, Peers: []string{}}
+	}
+	jsonOK(w, st)
+}
+
+// handleTxRebroadcast announces a pending transaction to all peers again.
+func (s *Server) handleTxRebroadcast(w http.ResponseWriter, r *http.Request) {
+	txid := r.PathValue("txid")
+	sent, err := s.Node.Rebroadcast(txid)
+	if err != nil {
+		jsonErr(w, 404, err.Error())
+		return
+	}
+	st, _ := s.Node.BroadcastStatus(txid)
+	jsonOK(w, map[string]interface{}{"txid": txid, "sent_to": sent, "broadcast": st})
+}
+
 // ========== Chain Info API ==========
 
+func (s *Server) handleChainSupply(w http.ResponseWriter, r *http.Request) {
+	jsonOK(w, s.Chain.GetSupply())
+}
+
+// handleChainFinality returns the finalized checkpoint and the latest one,
+// or with ?height= the checkpoint opened at that height.
+func (s *Server) handleChainFinality(w http.ResponseWriter, r *http.Request) {
+	v := r.URL.Query().Get("height")
+	if v == "" {
+		jsonOK(w, s.Chain.GetFinality())
+		return
+	}
+	height, err := strconv.ParseUint(v, 10, 64)
+	if err != nil {
+		jsonErr(w, 400, fmt.Sprintf("invalid height %q", v))
+		return
+	}
+	cp := s.Chain.GetCheckpoint(height)
+	if cp == nil {
+		jsonErr(w, 404, fmt.Sprintf("no checkpoint at height %d", height))
+		return
+	}
+	jsonOK(w, cp)
+}
+
+// handleStakingInfo returns stake and PoS reward figures, with
+// ?amount= a yield projection for a stake of that size and with
+// ?address= the address's PoS earnings so far.
+func (s *Server) handleStakingInfo(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	amount := 0.0
+	if v := q.Get("amount"); v != "" {
+		a, err := strconv.ParseFloat(v, 64)
+		if err != nil || !(a > 0) || a > s.Chain.Config.MaxSupply {
+			jsonErr(w, 400, fmt.Sprintf("invalid amount %q", v))
+			return
+		}
+		amount = a
+	}
+	address := q.Get("address")
+	if address != "" && !s.checkAddress(w, "address", address) {
+		return
+	}
+	jsonOK(w, s.Chain.GetStakingInfo(amount, address))
+}
+
+// handleValidatorStats returns an address's block production and staking
+// record.
+func (s *Server) handleValidatorStats(w http.ResponseWriter, r *http.Request) {
+	address := r.PathValue("addr")
+	if !s.checkAddress(w, "address", address) {
+		return
+	}
+	jsonOK(w, s.Chain.GetValidatorStats(address))
+}
+
+func (s *Server) handleChainGovernance(w http.ResponseWriter, r *http.Request) {
+	jsonOK(w, s.Chain.GetGovernanceInfo())
+}
+
+func (s *Server) handleGovernanceSnapshot(w http.ResponseWriter, r *http.Request) {
+	snap, err := s.Chain.GetVoteSnapshot()
+	if err != nil {
+		jsonErr(w, 404, err.Error())
+		return
+	}
+	jsonOK(w, snap)
+}
+
+// handleChainStats returns rolling statistics for each requested window,
+// e.g. /api/chain/stats?windows=10,100,1000.
+func (s *Server) handleChainStats(w http.ResponseWriter, r *http.Request) {
+	windows := []int{10, 100, 1000}
+	if q := r.URL.Query().Get("windows"); q != "" {
+		windows = nil
+		for _, part := range strings.Split(q, ",") {
+			n, err := strconv.Atoi(strings.TrimSpace(part))
+			if err != nil || n < 2 || n > blockchain.MaxStatsWindow {
+				jsonErr(w, 400, fmt.Sprintf("invalid window %q (2..%d)", part, blockchain.MaxStatsWindow))
+				return
+			}
+			windows = append(windows, n)
+		}
+	}
+	stats := make([]blockchain.WindowStats, len(windows))
+	for i, n := range windows {
+		stats[i] = s.Chain.WindowStats(n)
+	}
+	jsonOK(w, map[string]interface{}{
+		"height":  s.Chain.GetBestHeight(),
+		"windows": stats,
+	})
+}
+
+func (s *Server) handleChainDifficulty(w http.ResponseWriter, r *http.Request) {
+	intervals, epochs := 20, 4
+	q := r.URL.Query()
+	if v := q.Get("intervals"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > blockchain.MaxDifficultyIntervals {
+			jsonErr(w, 400, fmt.Sprintf("invalid intervals %q (1..%d)", v, blockchain.MaxDifficultyIntervals))
+			return
+		}
+		intervals = n
+	}
+	if v := q.Get("epochs"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			jsonErr(w, 400, fmt.Sprintf("invalid epochs %q", v))
+			return
+		}
+		epochs = n
+	}
+	jsonOK(w, s.Chain.DifficultyHistory(intervals, epochs))
+}
+
+func (s *Server) handleChainCharts(w http.ResponseWriter, r *http.Request) {
+	days := 30
+	if v := r.URL.Query().Get("days"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > blockchain.MaxChartDays {
+			jsonErr(w, 400, fmt.Sprintf("invalid days %q (1..%d)", v, blockchain.MaxChartDays))
+			return
+		}
+		days = n
+	}
+	jsonOK(w, s.Chain.Charts(days))
+}
+
 func (s *Server) handleChainInfo(w http.ResponseWriter, r *http.Request) {
 	best := s.Chain.GetBestBlock()
 	hash := ""
@@ -389,16 +1398,19 @@ func (s *Server) handleChainInfo(w http.ResponseWriter, r *http.Request) {
 		bits = best.Header.Bits
 	}
 	jsonOK(w, map[string]interface{}{
-		"name":         s.Chain.Config.Name,
-		"ticker":       s.Chain.Config.Ticker,
-		"blocks":       s.Chain.GetBlockCount(),
-		"best_hash":    hash,
-		"difficulty":   bits,
-		"max_supply":   s.Chain.Config.MaxSupply,
-		"total_minted": s.Chain.GetTotalMinted(),
-		"staked_total": s.Chain.Stakes.GetTotalStaked(),
-		"mempool_size": len(s.Chain.GetMempool()),
-		"peers":        s.Node.GetPeerCount(),
+		"name":           s.Chain.Config.Name,
+		"ticker":         s.Chain.Config.Ticker,
+		"blocks":         s.Chain.GetBlockCount(),
+		"best_hash":      hash,
+		"difficulty":     bits,
+		"chainwork":      blockchain.FormatChainWork(s.Chain.GetChainWork()),
+		"network_hashps": s.Chain.NetworkHashPS(hashRateWindow, -1),
+		"max_supply":     s.Chain.Config.MaxSupply,
+		"total_minted":   s.Chain.GetTotalMinted(),
+		"staked_total":   s.Chain.Stakes.GetTotalStaked(),
+		"mempool_size":   s.Chain.GetMempoolSize(),
+		"relay_policy":   s.Chain.RelayPolicy(),
+		"peers":          s.Node.GetPeerCount(),
 	})
 }
 
@@ -413,5 +1425,19 @@ func (s *Server) handleChainBlock(w http.ResponseWriter, r *http.Request) {
 		jsonOK(w, block)
 		return
 	}
-	jsonErr(w, 400, "hash parameter required")
+	if hs := r.URL.Query().Get("height"); hs != "" {
+		height, err := strconv.ParseUint(hs, 10, 64)
+		if err != nil {
+			jsonErr(w, 400, "invalid height")
+			return
+		}
+		block := s.Chain.GetBlockByHeight(height)
+		if block == nil {
+			jsonErr(w, 404, "block not found")
+			return
+		}
+		jsonOK(w, block)
+		return
+	}
+	jsonErr(w, 400, "hash or height parameter required")
 }
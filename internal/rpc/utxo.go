@@ -0,0 +1,43 @@
+package rpc
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+func (s *Server) rpcListUnspent(w http.ResponseWriter, req JSONRPCRequest) {
+	var params struct {
+		Address string `json:"address"`
+	}
+	json.Unmarshal(req.Params, &params)
+	if params.Address == "" {
+		writeRPCError(w, req.ID, "address required")
+		return
+	}
+	if err := s.validAddress(params.Address); err != nil {
+		writeRPCError(w, req.ID, "invalid address: "+err.Error())
+		return
+	}
+	writeRPCResult(w, req.ID, s.Chain.ListUnspent(params.Address))
+}
+
+// rpcGetTxOut returns an unspent output, or null if it is spent or
+// unknown. Outputs spent by pending transactions count as spent unless
+// include_mempool is false.
+func (s *Server) rpcGetTxOut(w http.ResponseWriter, req JSONRPCRequest) {
+	params := struct {
+		TxID           string `json:"txid"`
+		Vout           uint32 `json:"vout"`
+		IncludeMempool bool   `json:"include_mempool"`
+	}{IncludeMempool: true}
+	json.Unmarshal(req.Params, &params)
+	if params.TxID == "" {
+		writeRPCError(w, req.ID, "txid required")
+		return
+	}
+	if out, ok := s.Chain.GetTxOut(params.TxID, params.Vout, params.IncludeMempool); ok {
+		writeRPCResult(w, req.ID, out)
+		return
+	}
+	writeRPCResult(w, req.ID, nil)
+}
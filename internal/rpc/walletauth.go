@@ -0,0 +1,164 @@
+package rpc
+
+import (
+	"devinsidercoin/internal/blockchain"
+	"devinsidercoin/internal/wallet"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// SetRequireAPIKey makes every wallet endpoint need an API key or the
+// admin token. Otherwise requests without credentials are unrestricted
+// and only requests carrying an API key are scoped.
+func (s *Server) SetRequireAPIKey(required bool) {
+	s.adminMu.Lock()
+	defer s.adminMu.Unlock()
+	s.requireKey = required
+}
+
+// walletKey resolves the credentials of a wallet request: the caller's API
+// key, or nil for unrestricted access (the admin token, or no credentials
+// while API keys aren't required). On false it has already answered.
+func (s *Server) walletKey(w http.ResponseWriter, r *http.Request) (*wallet.APIKey, bool) {
+	auth := r.Header.Get("Authorization")
+	if auth == "" {
+		s.adminMu.RLock()
+		required := s.requireKey
+		s.adminMu.RUnlock()
+		if required {
+			jsonErr(w, 401, "API key required")
+			return nil, false
+		}
+		return nil, true
+	}
+	if token := strings.TrimPrefix(auth, "Bearer "); wallet.IsAPIKey(token) {
+		if s.Keys != nil {
+			if key, ok := s.Keys.Lookup(token); ok {
+				return &key, true
+			}
+		}
+		jsonErr(w, 401, "invalid API key")
+		return nil, false
+	}
+	if ok, _ := s.adminAuthorized(r); !ok {
+		jsonErr(w, 401, "invalid token")
+		return nil, false
+	}
+	return nil, true
+}
+
+// authorize answers 403 and returns false unless key, if any, grants
+// scope on address.
+func authorize(w http.ResponseWriter, key *wallet.APIKey, scope, address string) bool {
+	if key == nil || key.Allows(scope, address) {
+		return true
+	}
+	jsonErr(w, 403, fmt.Sprintf("API key %s has no %s access to %s", key.ID, scope, address))
+	return false
+}
+
+// keyAddresses returns the addresses in addrs that key grants scope on.
+func keyAddresses(key *wallet.APIKey, scope string, addrs []string) []string {
+	var out []string
+	for _, a := range addrs {
+		if key.Allows(scope, a) {
+			out = append(out, a)
+		}
+	}
+	return out
+}
+
+// touchesKey reports whether tx involves an address key may read.
+func touchesKey(key *wallet.APIKey, tx *blockchain.Transaction) bool {
+	if key.Allows(wallet.ScopeRead, tx.From) || key.Allows(wallet.ScopeRead, tx.To) {
+		return true
+	}
+	for _, out := range tx.Outputs {
+		if key.Allows(wallet.ScopeRead, out.Address) {
+			return true
+		}
+	}
+	return false
+}
+
+// unrestricted answers 403 and returns false for API key callers, for
+// endpoints that manage the wallets themselves.
+func unrestricted(w http.ResponseWriter, key *wallet.APIKey) bool {
+	if key == nil {
+		return true
+	}
+	jsonErr(w, 403, "API keys cannot manage wallets")
+	return false
+}
+
+// chargeKey counts amount against key's daily send limit. The returned
+// func gives it back, for when the transaction is then refused.
+func (s *Server) chargeKey(w http.ResponseWriter, key *wallet.APIKey, amount float64) (refund func(), ok bool) {
+	if key == nil {
+		return func() {}, true
+	}
+	now := time.Now()
+	if err := s.Keys.Spend(key.ID, amount, now); err != nil {
+		jsonErr(w, 403, err.Error())
+		return nil, false
+	}
+	return func() { s.Keys.Refund(key.ID, amount, now) }, true
+}
+
+// ========== API key administration ==========
+
+func (s *Server) handleAdminAPIKeys(w http.ResponseWriter, r *http.Request) {
+	if s.Keys == nil {
+		jsonErr(w, 501, "API keys are not enabled on this node")
+		return
+	}
+	jsonOK(w, s.Keys.List())
+}
+
+// handleAdminIssueAPIKey issues a key for wallets on this node. The token
+// is only ever shown in this response.
+func (s *Server) handleAdminIssueAPIKey(w http.ResponseWriter, r *http.Request) {
+	if s.Keys == nil {
+		jsonErr(w, 501, "API keys are not enabled on this node")
+		return
+	}
+	var req struct {
+		Name      string   `json:"name"`
+		Addresses []string `json:"addresses"`
+		Scopes    []string `json:"scopes"`
+		SendLimit float64  `json:"send_limit"`
+	}
+	if err := decodeAdmin(r, &req); err != nil {
+		jsonErr(w, 400, err.Error())
+		return
+	}
+	for _, addr := range req.Addresses {
+		if _, ok := s.Wallets.GetWallet(addr); !ok {
+			jsonErr(w, 400, "not a wallet on this node: "+addr)
+			return
+		}
+	}
+	token, key, err := s.Keys.Issue(req.Name, req.Addresses, req.Scopes, req.SendLimit)
+	if err != nil {
+		jsonErr(w, 400, err.Error())
+		return
+	}
+	logger.Info("API key issued", "id", key.ID, "name", key.Name, "scopes", key.Scopes)
+	jsonOK(w, map[string]interface{}{"token": token, "key": key})
+}
+
+func (s *Server) handleAdminRevokeAPIKey(w http.ResponseWriter, r *http.Request) {
+	if s.Keys == nil {
+		jsonErr(w, 501, "API keys are not enabled on this node")
+		return
+	}
+	id := r.PathValue("id")
+	if err := s.Keys.Revoke(id); err != nil {
+		jsonErr(w, 404, err.Error())
+		return
+	}
+	logger.Info("API key revoked", "id", id)
+	jsonOK(w, map[string]string{"revoked": id})
+}
@@ -0,0 +1,242 @@
+package rpc
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// wsGUID is the RFC 6455 handshake constant.
+const wsGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// wsSendQueue is how many notifications may wait for a slow client before
+// it is dropped.
+const wsSendQueue = 256
+
+// wsMaxAddresses bounds the addresses one websocket client may watch.
+const wsMaxAddresses = 1000
+
+// wsHub fans notifications out to websocket clients. The stream is
+// server-to-client only; client frames other than close are ignored.
+type wsHub struct {
+	mu       sync.Mutex
+	clients  map[*wsClient]bool
+	watchers int // clients with an address filter
+}
+
+type wsClient struct {
+	conn  net.Conn
+	send  chan []byte
+	once  sync.Once
+	watch map[string]bool // addresses filtered on; nil for the unfiltered stream
+}
+
+// watches reports whether c filters on any of addrs.
+func (c *wsClient) watches(addrs ...string) bool {
+	for _, a := range addrs {
+		if c.watch[a] {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *wsClient) close() {
+	c.once.Do(func() {
+		close(c.send)
+		c.conn.Close()
+	})
+}
+
+func (h *wsHub) add(c *wsClient) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.clients == nil {
+		h.clients = make(map[*wsClient]bool)
+	}
+	h.clients[c] = true
+	if c.watch != nil {
+		h.watchers++
+	}
+}
+
+func (h *wsHub) remove(c *wsClient) {
+	h.mu.Lock()
+	h.drop(c)
+	h.mu.Unlock()
+	c.close()
+}
+
+// drop forgets c. The caller holds h.mu.
+func (h *wsHub) drop(c *wsClient) {
+	if h.clients[c] && c.watch != nil {
+		h.watchers--
+	}
+	delete(h.clients, c)
+}
+
+// watching reports whether any client has an address filter, so events
+// only filtered clients receive need not be built otherwise.
+func (h *wsHub) watching() bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.watchers > 0
+}
+
+// wanted reports whether any client accepts an event, so it need only be
+// encoded then.
+func (h *wsHub) wanted(wants func(*wsClient) bool) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for c := range h.clients {
+		if wants(c) {
+			return true
+		}
+	}
+	return false
+}
+
+// publish queues msg for every client wants accepts, dropping clients
+// that fall behind.
+func (h *wsHub) publish(msg []byte, wants func(*wsClient) bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for c := range h.clients {
+		if !wants(c) {
+			continue
+		}
+		select {
+		case c.send <- msg:
+		default:
+			h.drop(c)
+			c.close()
+		}
+	}
+}
+
+// handleWS upgrades the connection and streams notifications as JSON text
+// frames until the client disconnects. With an addresses parameter the
+// client gets only events touching those addresses, plus block events.
+func (s *Server) handleWS(w http.ResponseWriter, r *http.Request) {
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if !strings.EqualFold(r.Header.Get("Upgrade"), "websocket") || key == "" {
+		jsonErr(w, 400, "websocket upgrade required")
+		return
+	}
+	watch, err := s.wsWatchList(r.URL.Query().Get("addresses"))
+	if err != nil {
+		jsonErr(w, 400, err.Error())
+		return
+	}
+	hj, ok := w.(http.Hijacker)
+	if !ok {
+		jsonErr(w, 500, "websocket not supported")
+		return
+	}
+	conn, rw, err := hj.Hijack()
+	if err != nil {
+		return
+	}
+	sum := sha1.Sum([]byte(key + wsGUID))
+	rw.WriteString("HTTP/1.1 101 Switching Protocols\r\n" +
+		"Upgrade: websocket\r\nConnection: Upgrade\r\n" +
+		"Sec-WebSocket-Accept: " + base64.StdEncoding.EncodeToString(sum[:]) + "\r\n\r\n")
+	if err := rw.Flush(); err != nil {
+		conn.Close()
+		return
+	}
+
+	c := &wsClient{conn: conn, send: make(chan []byte, wsSendQueue), watch: watch}
+	s.ws.add(c)
+	go func() {
+		for msg := range c.send {
+			conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+			if _, err := conn.Write(wsFrame(0x1, msg)); err != nil {
+				break
+			}
+		}
+		s.ws.remove(c)
+	}()
+	go func() {
+		readWSFrames(rw.Reader)
+		s.ws.remove(c)
+	}()
+}
+
+// wsWatchList parses a comma-separated addresses parameter into a filter,
+// or nil if list is empty.
+func (s *Server) wsWatchList(list string) (map[string]bool, error) {
+	if list == "" {
+		return nil, nil
+	}
+	addrs := strings.Split(list, ",")
+	if len(addrs) > wsMaxAddresses {
+		return nil, fmt.Errorf("at most %d addresses", wsMaxAddresses)
+	}
+	watch := make(map[string]bool, len(addrs))
+	for _, a := range addrs {
+		a = strings.TrimSpace(a)
+		if err := s.validAddress(a); err != nil {
+			return nil, fmt.Errorf("invalid address %q: %v", a, err)
+		}
+		watch[a] = true
+	}
+	return watch, nil
+}
+
+// wsFrame encodes an unmasked server frame with the FIN bit set.
+func wsFrame(opcode byte, payload []byte) []byte {
+	hdr := []byte{0x80 | opcode}
+	switch n := len(payload); {
+	case n < 126:
+		hdr = append(hdr, byte(n))
+	case n <= 0xFFFF:
+		hdr = append(hdr, 126, byte(n>>8), byte(n))
+	default:
+		hdr = append(hdr, 127)
+		hdr = binary.BigEndian.AppendUint64(hdr, uint64(n))
+	}
+	return append(hdr, payload...)
+}
+
+// readWSFrames consumes client frames until a close frame or read error.
+func readWSFrames(r *bufio.Reader) {
+	var hdr [2]byte
+	for {
+		if _, err := io.ReadFull(r, hdr[:]); err != nil {
+			return
+		}
+		if hdr[0]&0x0F == 0x8 {
+			return
+		}
+		n := uint64(hdr[1] & 0x7F)
+		switch n {
+		case 126:
+			var b [2]byte
+			if _, err := io.ReadFull(r, b[:]); err != nil {
+				return
+			}
+			n = uint64(binary.BigEndian.Uint16(b[:]))
+		case 127:
+			var b [8]byte
+			if _, err := io.ReadFull(r, b[:]); err != nil {
+				return
+			}
+			n = binary.BigEndian.Uint64(b[:])
+		}
+		if hdr[1]&0x80 != 0 {
+			n += 4 // masking key
+		}
+		if _, err := io.CopyN(io.Discard, r, int64(n)); err != nil {
+			return
+		}
+	}
+}
@@ -0,0 +1,339 @@
+package storage
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"sort"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// Archive index buckets, kept only by nodes running in archive mode.
+var (
+	bucketAddrHistory    = []byte("addr_history")    // address + 0x00 + height + tx position -> txid
+	bucketBalanceHistory = []byte("balance_history") // address + 0x00 + height -> balance after the block (float)
+	bucketRichList       = []byte("rich_list")       // inverted balance bits + address -> empty
+	bucketBlockFilters   = []byte("block_filters")   // height -> address filter
+)
+
+// metaArchiveHeight is the last height the archive indexes cover.
+var metaArchiveHeight = []byte("archive_height")
+
+var archiveBuckets = [][]byte{bucketAddrHistory, bucketBalanceHistory, bucketRichList, bucketBlockFilters}
+
+// ArchiveCommit holds the archive index entries for one block.
+type ArchiveCommit struct {
+	History map[string][]uint32 // address -> positions in TxIDs of the transactions touching it
+	Filter  []byte
+}
+
+// AddrTx is one transaction in an address's history.
+type AddrTx struct {
+	Height uint64
+	Index  uint32 // position in the block
+	TxID   string
+}
+
+// BalancePoint is an address's balance after the block at Height.
+type BalancePoint struct {
+	Height  uint64
+	Balance float64
+}
+
+// RichEntry is one address in the rich list.
+type RichEntry struct {
+	Address string
+	Balance float64
+}
+
+func addrHeightKey(address string, height uint64) []byte {
+	return append(append([]byte(address), 0), heightKey(height)...)
+}
+
+func richKey(address string, balance float64) []byte {
+	k := make([]byte, 8, 8+len(address))
+	binary.BigEndian.PutUint64(k, ^math.Float64bits(balance))
+	return append(k, address...)
+}
+
+// ArchiveHeight returns the last height the archive indexes cover, and
+// false if they have never been built.
+func (s *Store) ArchiveHeight() (uint64, bool) {
+	var h uint64
+	var ok bool
+	s.db.View(func(tx *bolt.Tx) error {
+		if v := tx.Bucket(bucketMeta).Get(metaArchiveHeight); v != nil {
+			h, ok = keyToHeight(v), true
+		}
+		return nil
+	})
+	return h, ok
+}
+
+// ResetArchive empties the archive indexes, ready for WriteArchive to
+// replay every block.
+func (s *Store) ResetArchive() error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		for _, name := range archiveBuckets {
+			if err := tx.DeleteBucket(name); err != nil && err != bolt.ErrBucketNotFound {
+				return err
+			}
+			if _, err := tx.CreateBucket(name); err != nil {
+				return err
+			}
+		}
+		return tx.Bucket(bucketMeta).Delete(metaArchiveHeight)
+	})
+}
+
+// RebuildRichList replaces the rich list with the current balances. Once
+// it is built, CommitBlock keeps it current for commits carrying archive
+// entries.
+func (s *Store) RebuildRichList() error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		if err := tx.DeleteBucket(bucketRichList); err != nil && err != bolt.ErrBucketNotFound {
+			return err
+		}
+		rl, err := tx.CreateBucket(bucketRichList)
+		if err != nil {
+			return err
+		}
+		return tx.Bucket(bucketBalances).ForEach(func(k, v []byte) error {
+			if bal := bytesToFloat(v); bal > 0 {
+				return rl.Put(richKey(string(k), bal), nil)
+			}
+			return nil
+		})
+	})
+}
+
+// ArchiveBlock is the input to WriteArchive: one block's entries and the
+// balances of the addresses it changed.
+type ArchiveBlock struct {
+	Height   uint64
+	TxIDs    []string
+	Balances map[string]float64
+	ArchiveCommit
+}
+
+// WriteArchive adds the history, balance history and filter of already
+// committed blocks, in height order, and records the last as the archive
+// height. It leaves the rich list alone.
+func (s *Store) WriteArchive(blocks []ArchiveBlock) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		for i := range blocks {
+			b := &blocks[i]
+			if err := putArchive(tx, b.Height, b.TxIDs, b.Balances, &b.ArchiveCommit); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// putArchive writes one block's archive entries and advances the archive
+// height.
+func putArchive(tx *bolt.Tx, height uint64, txids []string, balances map[string]float64, a *ArchiveCommit) error {
+	hist := tx.Bucket(bucketAddrHistory)
+	for addr, positions := range a.History {
+		for _, pos := range positions {
+			k := binary.BigEndian.AppendUint32(addrHeightKey(addr, height), pos)
+			if err := hist.Put(k, []byte(txids[pos])); err != nil {
+				return err
+			}
+		}
+	}
+	bh := tx.Bucket(bucketBalanceHistory)
+	for addr, bal := range balances {
+		if err := bh.Put(addrHeightKey(addr, height), floatToBytes(bal)); err != nil {
+			return err
+		}
+	}
+	if a.Filter != nil {
+		if err := tx.Bucket(bucketBlockFilters).Put(heightKey(height), a.Filter); err != nil {
+			return err
+		}
+	}
+	return tx.Bucket(bucketMeta).Put(metaArchiveHeight, heightKey(height))
+}
+
+// updateRichList moves the addresses in balances to their new rank. It
+// must run before the balances bucket is updated, since the old balance
+// locates the old entry.
+func updateRichList(tx *bolt.Tx, balances map[string]float64) error {
+	rl := tx.Bucket(bucketRichList)
+	bb := tx.Bucket(bucketBalances)
+	for addr, bal := range balances {
+		if old := bb.Get([]byte(addr)); old != nil {
+			if err := rl.Delete(richKey(addr, bytesToFloat(old))); err != nil {
+				return err
+			}
+		}
+		if bal > 0 {
+			if err := rl.Put(richKey(addr, bal), nil); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// AddressHistory returns the transactions touching address from
+// fromHeight to toHeight, oldest first. Once limit is reached it stops at
+// the next height, so a caller can continue from the last height plus one;
+// limit 0 returns everything.
+func (s *Store) AddressHistory(address string, fromHeight, toHeight uint64, limit int) []AddrTx {
+	var out []AddrTx
+	prefix := append([]byte(address), 0)
+	s.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket(bucketAddrHistory)
+		if b == nil {
+			return nil
+		}
+		c := b.Cursor()
+		for k, v := c.Seek(addrHeightKey(address, fromHeight)); k != nil && bytes.HasPrefix(k, prefix); k, v = c.Next() {
+			rest := k[len(prefix):]
+			height := binary.BigEndian.Uint64(rest)
+			if height > toHeight || limit > 0 && len(out) >= limit && out[len(out)-1].Height != height {
+				break
+			}
+			out = append(out, AddrTx{
+				Height: height,
+				Index:  binary.BigEndian.Uint32(rest[8:]),
+				TxID:   string(v),
+			})
+		}
+		return nil
+	})
+	return out
+}
+
+// BalanceHistory returns up to limit balance changes of address from
+// fromHeight to toHeight, oldest first.
+func (s *Store) BalanceHistory(address string, fromHeight, toHeight uint64, limit int) []BalancePoint {
+	var out []BalancePoint
+	prefix := append([]byte(address), 0)
+	s.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket(bucketBalanceHistory)
+		if b == nil {
+			return nil
+		}
+		c := b.Cursor()
+		for k, v := c.Seek(addrHeightKey(address, fromHeight)); k != nil && bytes.HasPrefix(k, prefix); k, v = c.Next() {
+			height := binary.BigEndian.Uint64(k[len(prefix):])
+			if height > toHeight || len(out) >= limit {
+				break
+			}
+			out = append(out, BalancePoint{Height: height, Balance: bytesToFloat(v)})
+		}
+		return nil
+	})
+	return out
+}
+
+// BalanceAt returns the last balance change of address at or below
+// height, and false if there is none. height must be below MaxUint64.
+func (s *Store) BalanceAt(address string, height uint64) (BalancePoint, bool) {
+	var p BalancePoint
+	var found bool
+	prefix := append([]byte(address), 0)
+	s.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket(bucketBalanceHistory)
+		if b == nil {
+			return nil
+		}
+		c := b.Cursor()
+		k, v := c.Seek(addrHeightKey(address, height+1))
+		if k == nil {
+			k, v = c.Last()
+		} else {
+			k, v = c.Prev()
+		}
+		if k != nil && bytes.HasPrefix(k, prefix) {
+			p = BalancePoint{Height: binary.BigEndian.Uint64(k[len(prefix):]), Balance: bytesToFloat(v)}
+			found = true
+		}
+		return nil
+	})
+	return p, found
+}
+
+// RichList returns n balances from the offset-th largest on, largest
+// first.
+func (s *Store) RichList(offset, n int) []RichEntry {
+	var out []RichEntry
+	s.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket(bucketRichList)
+		if b == nil {
+			return nil
+		}
+		c := b.Cursor()
+		k, _ := c.First()
+		for ; k != nil && offset > 0; k, _ = c.Next() {
+			offset--
+		}
+		for ; k != nil && len(out) < n; k, _ = c.Next() {
+			out = append(out, RichEntry{
+				Address: string(k[8:]),
+				Balance: math.Float64frombits(^binary.BigEndian.Uint64(k)),
+			})
+		}
+		return nil
+	})
+	return out
+}
+
+// RichListAt returns every positive balance after the block at height,
+// largest first and in the rich list's order, from the balance history.
+// It reads the whole history.
+func (s *Store) RichListAt(height uint64) []RichEntry {
+	var out []RichEntry
+	s.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket(bucketBalanceHistory)
+		if b == nil {
+			return nil
+		}
+		var last RichEntry
+		flush := func() {
+			if last.Balance > 0 {
+				out = append(out, last)
+			}
+			last = RichEntry{}
+		}
+		err := b.ForEach(func(k, v []byte) error {
+			addr := string(k[:len(k)-9])
+			if addr != last.Address {
+				flush()
+				last.Address = addr
+			}
+			if binary.BigEndian.Uint64(k[len(k)-8:]) <= height {
+				last.Balance = bytesToFloat(v)
+			}
+			return nil
+		})
+		flush()
+		return err
+	})
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Balance != out[j].Balance {
+			return out[i].Balance > out[j].Balance
+		}
+		return out[i].Address < out[j].Address
+	})
+	return out
+}
+
+// GetBlockFilter returns the address filter of the block at height, or nil.
+func (s *Store) GetBlockFilter(height uint64) []byte {
+	var v []byte
+	s.db.View(func(tx *bolt.Tx) error {
+		if b := tx.Bucket(bucketBlockFilters); b != nil {
+			if f := b.Get(heightKey(height)); f != nil {
+				v = append([]byte(nil), f...)
+			}
+		}
+		return nil
+	})
+	return v
+}
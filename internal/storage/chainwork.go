@@ -0,0 +1,55 @@
+package storage
+
+import (
+	bolt "go.etcd.io/bbolt"
+)
+
+// bucketChainWork holds the cumulative proof of work at each height.
+var bucketChainWork = []byte("chainwork") // height (8 bytes BE) -> big-endian work
+
+// metaChainWorkOK is set once every block has its chain work.
+var metaChainWorkOK = []byte("chainwork_indexed")
+
+// GetChainWork returns the cumulative work of the chain up to height as
+// big-endian bytes, or nil if it isn't stored.
+func (s *Store) GetChainWork(height uint64) []byte {
+	var data []byte
+	s.db.View(func(tx *bolt.Tx) error {
+		if v := tx.Bucket(bucketChainWork).Get(heightKey(height)); v != nil {
+			data = append([]byte(nil), v...)
+		}
+		return nil
+	})
+	return data
+}
+
+// ChainWorkIndexed reports whether every block has its chain work.
+// Databases created before it was tracked need RebuildChainWork.
+func (s *Store) ChainWorkIndexed() bool {
+	var ok bool
+	s.db.View(func(tx *bolt.Tx) error {
+		ok = tx.Bucket(bucketMeta).Get(metaChainWorkOK) != nil
+		return nil
+	})
+	return ok
+}
+
+// RebuildChainWork replaces the chain work of every height and marks it
+// built.
+func (s *Store) RebuildChainWork(work map[uint64][]byte) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		if err := tx.DeleteBucket(bucketChainWork); err != nil && err != bolt.ErrBucketNotFound {
+			return err
+		}
+		b, err := tx.CreateBucket(bucketChainWork)
+		if err != nil {
+			return err
+		}
+		for height, w := range work {
+			if err := b.Put(heightKey(height), w); err != nil {
+				return err
+			}
+		}
+		return tx.Bucket(bucketMeta).Put(metaChainWorkOK, []byte{1})
+	})
+}
@@ -0,0 +1,115 @@
+package storage
+
+import (
+	"encoding/json"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// bucketDailyStats holds the per-day aggregates behind explorer charts.
+var bucketDailyStats = []byte("daily_stats") // UTC day number (8 bytes BE) -> JSON DayStats
+
+// metaChartsOK is set once the daily stats cover the chain.
+var metaChartsOK = []byte("charts_indexed")
+
+// DayStats aggregates the blocks whose timestamps fall on one UTC day.
+type DayStats struct {
+	Date         string  `json:"date"` // YYYY-MM-DD
+	Blocks       int     `json:"blocks"`
+	Transactions int     `json:"transactions"` // excluding reward txs
+	Volume       float64 `json:"volume"`       // transferred, excluding rewards
+	Fees         float64 `json:"fees"`
+	Minted       float64 `json:"minted"`
+	NewAddresses int     `json:"new_addresses"` // first balance change
+}
+
+// Day returns the UTC day number of a unix timestamp.
+func Day(timestamp int64) uint64 {
+	return uint64(timestamp / 86400)
+}
+
+// Add accumulates o into d.
+func (d *DayStats) Add(o *DayStats) {
+	d.Blocks += o.Blocks
+	d.Transactions += o.Transactions
+	d.Volume += o.Volume
+	d.Fees += o.Fees
+	d.Minted += o.Minted
+	d.NewAddresses += o.NewAddresses
+}
+
+// putDayStats adds one block's stats to its day. Addresses in balances
+// that have no balance entry yet count as new, so it must run before the
+// balances bucket is updated.
+func putDayStats(tx *bolt.Tx, day uint64, block *DayStats, balances map[string]float64) error {
+	b := tx.Bucket(bucketDailyStats)
+	k := heightKey(day)
+	st := DayStats{Date: time.Unix(int64(day)*86400, 0).UTC().Format(time.DateOnly)}
+	if v := b.Get(k); v != nil {
+		if err := json.Unmarshal(v, &st); err != nil {
+			return err
+		}
+	}
+	st.Add(block)
+	bb := tx.Bucket(bucketBalances)
+	for addr := range balances {
+		if bb.Get([]byte(addr)) == nil {
+			st.NewAddresses++
+		}
+	}
+	data, _ := json.Marshal(st)
+	return b.Put(k, data)
+}
+
+// ChartsIndexed reports whether the daily stats cover every block.
+// Databases created before they existed need RebuildDailyStats.
+func (s *Store) ChartsIndexed() bool {
+	var ok bool
+	s.db.View(func(tx *bolt.Tx) error {
+		ok = tx.Bucket(bucketMeta).Get(metaChartsOK) != nil
+		return nil
+	})
+	return ok
+}
+
+// RebuildDailyStats replaces all daily stats and marks them built.
+func (s *Store) RebuildDailyStats(days map[uint64]*DayStats) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		if err := tx.DeleteBucket(bucketDailyStats); err != nil && err != bolt.ErrBucketNotFound {
+			return err
+		}
+		b, err := tx.CreateBucket(bucketDailyStats)
+		if err != nil {
+			return err
+		}
+		for day, st := range days {
+			st.Date = time.Unix(int64(day)*86400, 0).UTC().Format(time.DateOnly)
+			data, _ := json.Marshal(st)
+			if err := b.Put(heightKey(day), data); err != nil {
+				return err
+			}
+		}
+		return tx.Bucket(bucketMeta).Put(metaChartsOK, []byte{1})
+	})
+}
+
+// DailyStats returns the stats of the last n days that have blocks,
+// oldest first.
+func (s *Store) DailyStats(n int) []DayStats {
+	var out []DayStats
+	s.db.View(func(tx *bolt.Tx) error {
+		c := tx.Bucket(bucketDailyStats).Cursor()
+		for k, v := c.Last(); k != nil && len(out) < n; k, v = c.Prev() {
+			var st DayStats
+			if json.Unmarshal(v, &st) == nil {
+				out = append(out, st)
+			}
+		}
+		return nil
+	})
+	for i, j := 0, len(out)-1; i < j; i, j = i+1, j-1 {
+		out[i], out[j] = out[j], out[i]
+	}
+	return out
+}
@@ -0,0 +1,71 @@
+package storage
+
+import (
+	"encoding/binary"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// bucketCheckpoints holds finality checkpoints and the attestations
+// collected for them.
+var bucketCheckpoints = []byte("checkpoints") // height -> JSON checkpoint
+
+// metaFinalized is the height of the latest finalized checkpoint.
+var metaFinalized = []byte("finalized_height")
+
+// GetCheckpoint returns the checkpoint stored for height, or nil.
+func (s *Store) GetCheckpoint(height uint64) []byte {
+	var data []byte
+	s.db.View(func(tx *bolt.Tx) error {
+		if v := tx.Bucket(bucketCheckpoints).Get(heightKey(height)); v != nil {
+			data = append([]byte(nil), v...)
+		}
+		return nil
+	})
+	return data
+}
+
+// LastCheckpoint returns the highest stored checkpoint and its height, or
+// nil if there is none.
+func (s *Store) LastCheckpoint() (uint64, []byte) {
+	var height uint64
+	var data []byte
+	s.db.View(func(tx *bolt.Tx) error {
+		k, v := tx.Bucket(bucketCheckpoints).Cursor().Last()
+		if k != nil {
+			height = binary.BigEndian.Uint64(k)
+			data = append([]byte(nil), v...)
+		}
+		return nil
+	})
+	return height, data
+}
+
+// PutCheckpoint replaces the checkpoint at height. With finalized set it
+// also records height as the latest finalized checkpoint.
+func (s *Store) PutCheckpoint(height uint64, data []byte, finalized bool) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		hk := heightKey(height)
+		if err := tx.Bucket(bucketCheckpoints).Put(hk, data); err != nil {
+			return err
+		}
+		if !finalized {
+			return nil
+		}
+		return tx.Bucket(bucketMeta).Put(metaFinalized, hk)
+	})
+}
+
+// FinalizedHeight returns the height of the latest finalized checkpoint
+// and whether there is one.
+func (s *Store) FinalizedHeight() (uint64, bool) {
+	var height uint64
+	var ok bool
+	s.db.View(func(tx *bolt.Tx) error {
+		if v := tx.Bucket(bucketMeta).Get(metaFinalized); len(v) == 8 {
+			height, ok = binary.BigEndian.Uint64(v), true
+		}
+		return nil
+	})
+	return height, ok
+}
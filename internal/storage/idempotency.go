@@ -0,0 +1,71 @@
+package storage
+
+import (
+	"encoding/json"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// bucketIdempotency remembers the results of wallet requests sent with an
+// Idempotency-Key, so retries return the original result.
+var bucketIdempotency = []byte("idempotency") // caller scope + 0x00 + key -> JSON IdempotentResult
+
+// IdempotentResult is the stored response to a request.
+type IdempotentResult struct {
+	RequestHash string          `json:"request_hash"` // of method, path and body
+	Status      int             `json:"status"`
+	Body        json.RawMessage `json:"body"`
+	Created     int64           `json:"created"`
+}
+
+func idempotencyKey(scope, key string) []byte {
+	return append(append([]byte(scope), 0), key...)
+}
+
+// GetIdempotent returns the result stored for key, or nil.
+func (s *Store) GetIdempotent(scope, key string) *IdempotentResult {
+	var res *IdempotentResult
+	s.db.View(func(tx *bolt.Tx) error {
+		if v := tx.Bucket(bucketIdempotency).Get(idempotencyKey(scope, key)); v != nil {
+			var r IdempotentResult
+			if json.Unmarshal(v, &r) == nil {
+				res = &r
+			}
+		}
+		return nil
+	})
+	return res
+}
+
+// PutIdempotent stores the result for key.
+func (s *Store) PutIdempotent(scope, key string, res *IdempotentResult) error {
+	data, _ := json.Marshal(res)
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(bucketIdempotency).Put(idempotencyKey(scope, key), data)
+	})
+}
+
+// PruneIdempotent deletes results created before the unix time before and
+// returns how many it removed.
+func (s *Store) PruneIdempotent(before int64) (int, error) {
+	var n int
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(bucketIdempotency)
+		var expired [][]byte
+		b.ForEach(func(k, v []byte) error {
+			var r IdempotentResult
+			if json.Unmarshal(v, &r) != nil || r.Created < before {
+				expired = append(expired, k)
+			}
+			return nil
+		})
+		for _, k := range expired {
+			if err := b.Delete(k); err != nil {
+				return err
+			}
+		}
+		n = len(expired)
+		return nil
+	})
+	return n, err
+}
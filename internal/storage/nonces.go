@@ -0,0 +1,36 @@
+package storage
+
+import (
+	"encoding/binary"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// bucketNonces holds the highest confirmed nonce of each sender.
+var bucketNonces = []byte("nonces") // address -> nonce (8 bytes BE)
+
+// GetNonce returns the highest nonce address has used in a confirmed
+// transaction, or 0 if it has used none.
+func (s *Store) GetNonce(address string) uint64 {
+	var n uint64
+	s.db.View(func(tx *bolt.Tx) error {
+		if v := tx.Bucket(bucketNonces).Get([]byte(address)); len(v) == 8 {
+			n = binary.BigEndian.Uint64(v)
+		}
+		return nil
+	})
+	return n
+}
+
+// putNonces records the senders' new highest nonces.
+func putNonces(tx *bolt.Tx, nonces map[string]uint64) error {
+	b := tx.Bucket(bucketNonces)
+	for addr, n := range nonces {
+		v := make([]byte, 8)
+		binary.BigEndian.PutUint64(v, n)
+		if err := b.Put([]byte(addr), v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
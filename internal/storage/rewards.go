@@ -0,0 +1,118 @@
+package storage
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// Reward index buckets.
+var (
+	bucketRewards  = []byte("rewards")  // address + 0x00 + height -> JSON Reward (pos_reward payment)
+	bucketProduced = []byte("produced") // address + 0x00 + height -> JSON Reward (coinbase of a produced block)
+)
+
+// Set once the reward and production indexes cover the chain.
+var (
+	metaRewardsOK  = []byte("rewards_indexed")
+	metaProducedOK = []byte("produced_indexed")
+)
+
+// Reward is one payment to an address: a pos_reward share, or the
+// coinbase of a block it produced.
+type Reward struct {
+	Height    uint64  `json:"-"` // from the key
+	TxID      string  `json:"txid"`
+	Amount    float64 `json:"amount"`
+	Timestamp int64   `json:"timestamp"` // block time
+}
+
+// putRewards adds one block's payments to bucket, keyed by address.
+func putRewards(tx *bolt.Tx, bucket []byte, height uint64, rewards map[string]Reward) error {
+	b := tx.Bucket(bucket)
+	for addr, r := range rewards {
+		data, _ := json.Marshal(r)
+		if err := b.Put(addrHeightKey(addr, height), data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RewardsIndexed reports whether the reward and production indexes cover
+// every block. Databases created before they existed need RebuildRewards.
+func (s *Store) RewardsIndexed() bool {
+	var ok bool
+	s.db.View(func(tx *bolt.Tx) error {
+		meta := tx.Bucket(bucketMeta)
+		ok = meta.Get(metaRewardsOK) != nil && meta.Get(metaProducedOK) != nil
+		return nil
+	})
+	return ok
+}
+
+// RebuildRewards replaces the reward and production indexes, each given
+// by height and then address, and marks them built.
+func (s *Store) RebuildRewards(rewards, produced map[uint64]map[string]Reward) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		for _, idx := range []struct {
+			bucket []byte
+			data   map[uint64]map[string]Reward
+		}{{bucketRewards, rewards}, {bucketProduced, produced}} {
+			if err := tx.DeleteBucket(idx.bucket); err != nil && err != bolt.ErrBucketNotFound {
+				return err
+			}
+			if _, err := tx.CreateBucket(idx.bucket); err != nil {
+				return err
+			}
+			for height, byAddr := range idx.data {
+				if err := putRewards(tx, idx.bucket, height, byAddr); err != nil {
+					return err
+				}
+			}
+		}
+		meta := tx.Bucket(bucketMeta)
+		if err := meta.Put(metaRewardsOK, []byte{1}); err != nil {
+			return err
+		}
+		return meta.Put(metaProducedOK, []byte{1})
+	})
+}
+
+// Rewards returns up to limit staking rewards paid to address at or above
+// fromHeight, oldest first; limit 0 returns them all. A non-nil keep
+// filters them.
+func (s *Store) Rewards(address string, fromHeight uint64, limit int, keep func(Reward) bool) []Reward {
+	return s.scanRewards(bucketRewards, address, fromHeight, limit, keep)
+}
+
+// Produced returns the coinbases of the blocks address produced at or
+// above fromHeight, like Rewards.
+func (s *Store) Produced(address string, fromHeight uint64, limit int, keep func(Reward) bool) []Reward {
+	return s.scanRewards(bucketProduced, address, fromHeight, limit, keep)
+}
+
+func (s *Store) scanRewards(bucket []byte, address string, fromHeight uint64, limit int, keep func(Reward) bool) []Reward {
+	var out []Reward
+	prefix := append([]byte(address), 0)
+	s.db.View(func(tx *bolt.Tx) error {
+		c := tx.Bucket(bucket).Cursor()
+		for k, v := c.Seek(addrHeightKey(address, fromHeight)); k != nil && bytes.HasPrefix(k, prefix); k, v = c.Next() {
+			if limit > 0 && len(out) >= limit {
+				break
+			}
+			var r Reward
+			if json.Unmarshal(v, &r) != nil {
+				continue
+			}
+			r.Height = binary.BigEndian.Uint64(k[len(prefix):])
+			if keep == nil || keep(r) {
+				out = append(out, r)
+			}
+		}
+		return nil
+	})
+	return out
+}
@@ -1,11 +1,13 @@
 package storage
 
 import (
+	"bytes"
 	"encoding/binary"
 	"encoding/json"
 	"fmt"
 	"os"
 	"path/filepath"
+	"time"
 
 	bolt "go.etcd.io/bbolt"
 )
@@ -16,13 +18,20 @@ var (
 	bucketBlockHash = []byte("block_hashes") // hash -> height (8 bytes BE)
 	bucketBalances  = []byte("balances")     // address -> JSON float
 	bucketStakes    = []byte("stakes")       // address -> JSON stake
+	bucketUnbonding = []byte("unbonding")    // release height (8 bytes BE) + txid -> JSON unbond
 	bucketTxIndex   = []byte("tx_index")     // txid -> height (8 bytes BE)
 	bucketMeta      = []byte("meta")         // key -> value
+	bucketReceived  = []byte("received")     // address -> cumulative credits (float)
+	bucketUTXOs     = []byte("utxos")        // "txid:vout" -> JSON unspent output
+	bucketUTXOAddr  = []byte("utxo_addr")    // address + 0x00 + "txid:vout" -> empty
 )
 
 var (
 	metaBestHeight  = []byte("best_height")
 	metaTotalMinted = []byte("total_minted")
+	metaConfigHash  = []byte("config_hash")
+	metaConfig      = []byte("config")           // JSON network config the hash was taken of
+	metaReceivedOK  = []byte("received_indexed") // set once the received bucket covers the chain
 )
 
 // Store wraps BoltDB for blockchain persistence.
@@ -44,7 +53,9 @@ func NewStore(dataDir string) (*Store, error) {
 	err = db.Update(func(tx *bolt.Tx) error {
 		for _, b := range [][]byte{
 			bucketBlocks, bucketBlockHash, bucketBalances,
-			bucketStakes, bucketTxIndex, bucketMeta,
+			bucketStakes, bucketUnbonding, bucketTxIndex, bucketMeta, bucketReceived,
+			bucketUTXOs, bucketUTXOAddr, bucketDailyStats, bucketRewards, bucketProduced, bucketIdempotency,
+			bucketCheckpoints, bucketChainWork, bucketNonces,
 		} {
 			if _, err := tx.CreateBucketIfNotExists(b); err != nil {
 				return err
@@ -144,12 +155,17 @@ func (s *Store) GetBlockByHash(hash string) ([]byte, error) {
 	return data, err
 }
 
-func (s *Store) GetBlocksFrom(startHeight uint64) ([][]byte, error) {
+// GetBlocksFrom returns up to limit serialized blocks starting at
+// startHeight; limit 0 returns them all.
+func (s *Store) GetBlocksFrom(startHeight uint64, limit int) ([][]byte, error) {
 	var blocks [][]byte
 	err := s.db.View(func(tx *bolt.Tx) error {
 		b := tx.Bucket(bucketBlocks)
 		c := b.Cursor()
 		for k, v := c.Seek(heightKey(startHeight)); k != nil; k, v = c.Next() {
+			if limit > 0 && len(blocks) >= limit {
+				break
+			}
 			data := make([]byte, len(v))
 			copy(data, v)
 			blocks = append(blocks, data)
@@ -228,6 +244,74 @@ func (s *Store) GetAllStakesRaw() map[string][]byte {
 	return stakes
 }
 
+// --- Unbonding queue ---
+
+// UnbondingKey returns the BlockCommit.Unbonding key of an unstake that
+// releases at height, so the queue is kept in release order.
+func UnbondingKey(release uint64, txid string) string {
+	return string(heightKey(release)) + txid
+}
+
+// GetUnbondingRaw returns every queued unbond as JSON, in release order.
+func (s *Store) GetUnbondingRaw() [][]byte {
+	var entries [][]byte
+	s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(bucketUnbonding).ForEach(func(k, v []byte) error {
+			entries = append(entries, append([]byte(nil), v...))
+			return nil
+		})
+	})
+	return entries
+}
+
+// --- UTXO set ---
+
+func utxoAddrKey(address, outpoint string) []byte {
+	return append(append([]byte(address), 0), outpoint...)
+}
+
+// GetUTXO returns the JSON unspent output stored under outpoint
+// ("txid:vout"), or nil if it is spent or unknown.
+func (s *Store) GetUTXO(outpoint string) []byte {
+	var v []byte
+	s.db.View(func(tx *bolt.Tx) error {
+		if b := tx.Bucket(bucketUTXOs).Get([]byte(outpoint)); b != nil {
+			v = append([]byte(nil), b...)
+		}
+		return nil
+	})
+	return v
+}
+
+// GetUTXOsByAddress returns the JSON unspent outputs paying address, keyed
+// by outpoint.
+func (s *Store) GetUTXOsByAddress(address string) map[string][]byte {
+	utxos := make(map[string][]byte)
+	prefix := utxoAddrKey(address, "")
+	s.db.View(func(tx *bolt.Tx) error {
+		set := tx.Bucket(bucketUTXOs)
+		c := tx.Bucket(bucketUTXOAddr).Cursor()
+		for k, _ := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, _ = c.Next() {
+			op := k[len(prefix):]
+			if v := set.Get(op); v != nil {
+				utxos[string(op)] = append([]byte(nil), v...)
+			}
+		}
+		return nil
+	})
+	return utxos
+}
+
+// ForEachUTXO calls fn for every unspent output.
+func (s *Store) ForEachUTXO(fn func(outpoint string, data []byte)) {
+	s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(bucketUTXOs).ForEach(func(k, v []byte) error {
+			fn(string(k), v)
+			return nil
+		})
+	})
+}
+
 // --- TX Index ---
 
 func (s *Store) GetTxBlockHeight(txid string) (uint64, error) {
@@ -257,6 +341,108 @@ func (s *Store) GetTotalMinted() float64 {
 	return total
 }
 
+// GetConfigHash returns the consensus config hash recorded for this chain,
+// or "" if none was recorded (databases created before the check existed).
+func (s *Store) GetConfigHash() string {
+	var h string
+	s.db.View(func(tx *bolt.Tx) error {
+		h = string(tx.Bucket(bucketMeta).Get(metaConfigHash))
+		return nil
+	})
+	return h
+}
+
+// GetConfig returns the network config recorded with the config hash, or
+// nil if none was recorded (databases created before it was kept).
+func (s *Store) GetConfig() []byte {
+	var cfg []byte
+	s.db.View(func(tx *bolt.Tx) error {
+		if v := tx.Bucket(bucketMeta).Get(metaConfig); v != nil {
+			cfg = append([]byte(nil), v...)
+		}
+		return nil
+	})
+	return cfg
+}
+
+// SetConfigHash records the consensus config hash for this chain and the
+// network config it was taken of.
+func (s *Store) SetConfigHash(hash string, cfg []byte) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(bucketMeta)
+		if err := b.Put(metaConfigHash, []byte(hash)); err != nil {
+			return err
+		}
+		return b.Put(metaConfig, cfg)
+	})
+}
+
+// GetReceived returns the total ever credited to address by confirmed
+// transactions.
+func (s *Store) GetReceived(address string) float64 {
+	var total float64
+	s.db.View(func(tx *bolt.Tx) error {
+		if b := tx.Bucket(bucketReceived); b != nil {
+			if v := b.Get([]byte(address)); v != nil {
+				total = bytesToFloat(v)
+			}
+		}
+		return nil
+	})
+	return total
+}
+
+// ReceivedIndexed reports whether the received totals cover every block.
+// Databases created before the index existed need RebuildReceived.
+func (s *Store) ReceivedIndexed() bool {
+	var ok bool
+	s.db.View(func(tx *bolt.Tx) error {
+		ok = tx.Bucket(bucketMeta).Get(metaReceivedOK) != nil
+		return nil
+	})
+	return ok
+}
+
+// RebuildReceived replaces all received totals and marks the index built.
+func (s *Store) RebuildReceived(totals map[string]float64) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		if err := tx.DeleteBucket(bucketReceived); err != nil && err != bolt.ErrBucketNotFound {
+			return err
+		}
+		b, err := tx.CreateBucket(bucketReceived)
+		if err != nil {
+			return err
+		}
+		for addr, v := range totals {
+			if err := b.Put([]byte(addr), floatToBytes(v)); err != nil {
+				return err
+			}
+		}
+		return tx.Bucket(bucketMeta).Put(metaReceivedOK, []byte{1})
+	})
+}
+
+// GetMeta returns the value of an extra meta key written by CommitBlock or
+// PutMeta, or nil if it is unset.
+func (s *Store) GetMeta(key string) []byte {
+	var v []byte
+	s.db.View(func(tx *bolt.Tx) error {
+		if b := tx.Bucket(bucketMeta).Get([]byte(key)); b != nil {
+			v = append([]byte(nil), b...)
+		}
+		return nil
+	})
+	return v
+}
+
+// PutMeta sets a meta key outside a block commit, for node state such as
+// sync progress that isn't derived from the chain.
+func (s *Store) PutMeta(key string, value []byte) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(bucketMeta).Put([]byte(key), value)
+	})
+}
+
 // --- Atomic block commit ---
 
 // BlockCommit holds all state changes for a new block.
@@ -265,9 +451,21 @@ type BlockCommit struct {
 	Hash        string
 	BlockJSON   []byte
 	Balances    map[string]float64 // address -> new balance
+	Received    map[string]float64 // address -> new cumulative received total
 	Stakes      map[string][]byte  // address -> JSON stake (nil = delete)
+	Unbonding   map[string][]byte  // UnbondingKey -> JSON unbond (nil = released)
+	UTXOs       map[string][]byte  // outpoint -> JSON unspent output (nil = spent)
 	TxIDs       []string
 	TotalMinted float64
+	Meta        map[string][]byte // extra meta keys written with the block
+	Archive     *ArchiveCommit    // archive index entries; nil unless in archive mode
+	Stats       *DayStats         // the block's contribution to its day's stats
+	Rewards     map[string]Reward // address -> staking reward paid by the block
+	Produced    map[string]Reward // producer -> the block's coinbase
+	Checkpoint  []byte            // JSON finality checkpoint opened by the block, if any
+	ChainWork   []byte            // big-endian cumulative work up to the block
+	Nonces      map[string]uint64 // sender -> highest nonce used in the block
+	Timestamp   int64             // block time, which picks the day for Stats
 }
 
 // CommitBlock atomically writes all changes for a new block.
@@ -282,6 +480,36 @@ func (s *Store) CommitBlock(c *BlockCommit) error {
 			return err
 		}
 
+		if c.Stats != nil {
+			if err := putDayStats(tx, Day(c.Timestamp), c.Stats, c.Balances); err != nil {
+				return err
+			}
+		}
+		if err := putRewards(tx, bucketRewards, c.Height, c.Rewards); err != nil {
+			return err
+		}
+		if err := putRewards(tx, bucketProduced, c.Height, c.Produced); err != nil {
+			return err
+		}
+		if c.Checkpoint != nil {
+			if err := tx.Bucket(bucketCheckpoints).Put(hk, c.Checkpoint); err != nil {
+				return err
+			}
+		}
+		if c.ChainWork != nil {
+			if err := tx.Bucket(bucketChainWork).Put(hk, c.ChainWork); err != nil {
+				return err
+			}
+		}
+		if c.Archive != nil {
+			if err := updateRichList(tx, c.Balances); err != nil {
+				return err
+			}
+			if err := putArchive(tx, c.Height, c.TxIDs, c.Balances, c.Archive); err != nil {
+				return err
+			}
+		}
+
 		bb := tx.Bucket(bucketBalances)
 		for addr, bal := range c.Balances {
 			if err := bb.Put([]byte(addr), floatToBytes(bal)); err != nil {
@@ -289,6 +517,13 @@ func (s *Store) CommitBlock(c *BlockCommit) error {
 			}
 		}
 
+		rb := tx.Bucket(bucketReceived)
+		for addr, total := range c.Received {
+			if err := rb.Put([]byte(addr), floatToBytes(total)); err != nil {
+				return err
+			}
+		}
+
 		sb := tx.Bucket(bucketStakes)
 		for addr, data := range c.Stakes {
 			if data == nil {
@@ -300,6 +535,22 @@ func (s *Store) CommitBlock(c *BlockCommit) error {
 			}
 		}
 
+		ub := tx.Bucket(bucketUnbonding)
+		for key, data := range c.Unbonding {
+			if data == nil {
+				ub.Delete([]byte(key))
+			} else if err := ub.Put([]byte(key), data); err != nil {
+				return err
+			}
+		}
+
+		if err := putUTXOs(tx, c.UTXOs); err != nil {
+			return err
+		}
+		if err := putNonces(tx, c.Nonces); err != nil {
+			return err
+		}
+
 		tb := tx.Bucket(bucketTxIndex)
 		for _, txid := range c.TxIDs {
 			if err := tb.Put([]byte(txid), hk); err != nil {
@@ -307,9 +558,168 @@ func (s *Store) CommitBlock(c *BlockCommit) error {
 			}
 		}
 
-		if err := tx.Bucket(bucketMeta).Put(metaBestHeight, hk); err != nil {
+		mb := tx.Bucket(bucketMeta)
+		for key, data := range c.Meta {
+			if err := mb.Put([]byte(key), data); err != nil {
+				return err
+			}
+		}
+		if err := mb.Put(metaBestHeight, hk); err != nil {
+			return err
+		}
+		return mb.Put(metaTotalMinted, floatToBytes(c.TotalMinted))
+	})
+}
+
+// putUTXOs applies UTXO set changes and keeps the address index in step.
+func putUTXOs(tx *bolt.Tx, changes map[string][]byte) error {
+	set := tx.Bucket(bucketUTXOs)
+	index := tx.Bucket(bucketUTXOAddr)
+	var out struct {
+		Address string `json:"address"`
+	}
+	for op, data := range changes {
+		key := []byte(op)
+		if data == nil {
+			if old := set.Get(key); old != nil && json.Unmarshal(old, &out) == nil {
+				index.Delete(utxoAddrKey(out.Address, op))
+			}
+			set.Delete(key)
+			continue
+		}
+		if err := json.Unmarshal(data, &out); err != nil {
+			return fmt.Errorf("utxo %s: %w", op, err)
+		}
+		if err := set.Put(key, data); err != nil {
 			return err
 		}
-		return tx.Bucket(bucketMeta).Put(metaTotalMinted, floatToBytes(c.TotalMinted))
+		if err := index.Put(utxoAddrKey(out.Address, op), nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// --- Offline maintenance ---
+
+// OpenReadOnly opens an existing database without write access. It fails
+// quickly if another process (a running node) holds the write lock.
+func OpenReadOnly(dbPath string) (*Store, error) {
+	if _, err := os.Stat(dbPath); err != nil {
+		return nil, err
+	}
+	db, err := bolt.Open(dbPath, 0600, &bolt.Options{ReadOnly: true, Timeout: time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("open db (is the node running?): %w", err)
+	}
+	return &Store{db: db, Path: dbPath}, nil
+}
+
+// BucketStat summarizes one bucket.
+type BucketStat struct {
+	Name      string `json:"name"`
+	Keys      int    `json:"keys"`
+	LeafBytes int    `json:"leaf_bytes"`
+	Depth     int    `json:"depth"`
+}
+
+// BucketStats returns key counts and sizes for every top-level bucket.
+func (s *Store) BucketStats() ([]BucketStat, error) {
+	var stats []BucketStat
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return tx.ForEach(func(name []byte, b *bolt.Bucket) error {
+			st := b.Stats()
+			stats = append(stats, BucketStat{
+				Name:      string(name),
+				Keys:      st.KeyN,
+				LeafBytes: st.LeafInuse,
+				Depth:     st.Depth,
+			})
+			return nil
+		})
+	})
+	return stats, err
+}
+
+// VerifyIndexes cross-checks the blocks bucket against the hash and tx
+// indexes and the best-height marker. It returns one line per problem.
+func (s *Store) VerifyIndexes() ([]string, error) {
+	var problems []string
+	err := s.db.View(func(tx *bolt.Tx) error {
+		blocks := tx.Bucket(bucketBlocks)
+		hashes := tx.Bucket(bucketBlockHash)
+		txIndex := tx.Bucket(bucketTxIndex)
+
+		var last uint64
+		var count uint64
+		c := blocks.Cursor()
+		for k, v := c.First(); k != nil; k, v = c.Next() {
+			h := keyToHeight(k)
+			if h != count {
+				problems = append(problems, fmt.Sprintf("gap in blocks: expected height %d, found %d", count, h))
+			}
+			count = h + 1
+			last = h
+
+			var blk struct {
+				Hash         string `json:"hash"`
+				Transactions []struct {
+					TxID string `json:"txid"`
+				} `json:"transactions"`
+			}
+			if err := json.Unmarshal(v, &blk); err != nil {
+				problems = append(problems, fmt.Sprintf("block %d: undecodable: %v", h, err))
+				continue
+			}
+			if hk := hashes.Get([]byte(blk.Hash)); hk == nil || keyToHeight(hk) != h {
+				problems = append(problems, fmt.Sprintf("block %d: hash index missing or wrong for %s", h, blk.Hash))
+			}
+			for _, t := range blk.Transactions {
+				if txIndex.Get([]byte(t.TxID)) == nil {
+					problems = append(problems, fmt.Sprintf("block %d: tx %s not indexed", h, t.TxID))
+				}
+			}
+		}
+
+		hashes.ForEach(func(k, v []byte) error {
+			if blocks.Get(v) == nil {
+				problems = append(problems, fmt.Sprintf("hash index %s points to missing height %d", k, keyToHeight(v)))
+			}
+			return nil
+		})
+		txIndex.ForEach(func(k, v []byte) error {
+			if blocks.Get(v) == nil {
+				problems = append(problems, fmt.Sprintf("tx index %s points to missing height %d", k, keyToHeight(v)))
+			}
+			return nil
+		})
+
+		best := tx.Bucket(bucketMeta).Get(metaBestHeight)
+		switch {
+		case best == nil && count > 0:
+			problems = append(problems, "best_height missing")
+		case best != nil && keyToHeight(best) != last:
+			problems = append(problems, fmt.Sprintf("best_height %d does not match last block %d", keyToHeight(best), last))
+		}
+		return nil
 	})
+	return problems, err
+}
+
+// BackupTo writes a consistent copy of the database to dstPath while it
+// stays open for writes.
+func (s *Store) BackupTo(dstPath string) error {
+	return s.db.View(func(tx *bolt.Tx) error {
+		return tx.CopyFile(dstPath, 0600)
+	})
+}
+
+// CompactTo writes a compacted copy of the database to dstPath.
+func (s *Store) CompactTo(dstPath string) error {
+	dst, err := bolt.Open(dstPath, 0600, nil)
+	if err != nil {
+		return fmt.Errorf("open destination: %w", err)
+	}
+	defer dst.Close()
+	return bolt.Compact(dst, s.db, 64*1024*1024)
 }
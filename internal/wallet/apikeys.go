@@ -0,0 +1,231 @@
+package wallet
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Scopes an API key can grant on its addresses.
+const (
+	ScopeRead    = "read"    // balances, transactions and unspent outputs
+	ScopeSend    = "send"    // transfers and burns, within the key's send limit
+	ScopeStake   = "stake"   // stake, unstake, delegate and vote
+	ScopeDeposit = "deposit" // create deposit addresses, which join the key
+)
+
+var validScopes = []string{ScopeRead, ScopeSend, ScopeStake, ScopeDeposit}
+
+// apiKeyPrefix starts every API key token, so leaked keys are easy to
+// recognise in logs and code.
+const apiKeyPrefix = "dvck_"
+
+// APIKey is a token scoped to some of the node's wallet addresses. Only a
+// hash of the token is stored.
+type APIKey struct {
+	ID        string   `json:"id"`
+	Name      string   `json:"name,omitempty"`
+	Hash      string   `json:"hash,omitempty"` // SHA-256 of the token
+	Addresses []string `json:"addresses"`
+	Scopes    []string `json:"scopes"`
+	SendLimit float64  `json:"send_limit,omitempty"` // amount plus fees per UTC day; 0 = no limit
+	Created   int64    `json:"created"`
+
+	SpentDay string  `json:"spent_day,omitempty"` // YYYY-MM-DD Spent applies to
+	Spent    float64 `json:"spent,omitempty"`
+}
+
+// Allows reports whether the key grants scope on address.
+func (k *APIKey) Allows(scope, address string) bool {
+	return slices.Contains(k.Scopes, scope) && slices.Contains(k.Addresses, address)
+}
+
+// Has reports whether the key grants scope on any address.
+func (k *APIKey) Has(scope string) bool {
+	return slices.Contains(k.Scopes, scope)
+}
+
+// KeyStore holds the API keys issued for a node's wallets, persisted in
+// api_keys.json next to wallets.json.
+type KeyStore struct {
+	path string
+	keys map[string]*APIKey // by ID
+	mu   sync.Mutex
+}
+
+// NewKeyStore loads the API keys kept in dir.
+func NewKeyStore(dir string) *KeyStore {
+	ks := &KeyStore{
+		path: filepath.Join(dir, "api_keys.json"),
+		keys: make(map[string]*APIKey),
+	}
+	if data, err := os.ReadFile(ks.path); err == nil {
+		json.Unmarshal(data, &ks.keys)
+	}
+	return ks
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// Issue creates a key and returns its token, which is not stored and
+// cannot be shown again.
+func (ks *KeyStore) Issue(name string, addresses, scopes []string, sendLimit float64) (string, APIKey, error) {
+	if len(addresses) == 0 {
+		return "", APIKey{}, fmt.Errorf("at least one address required")
+	}
+	if len(scopes) == 0 {
+		return "", APIKey{}, fmt.Errorf("at least one scope required")
+	}
+	for _, sc := range scopes {
+		if !slices.Contains(validScopes, sc) {
+			return "", APIKey{}, fmt.Errorf("unknown scope %q (%s)", sc, strings.Join(validScopes, ", "))
+		}
+	}
+	if sendLimit < 0 {
+		return "", APIKey{}, fmt.Errorf("send_limit must not be negative")
+	}
+
+	var id [4]byte
+	var secret [24]byte
+	rand.Read(id[:])
+	rand.Read(secret[:])
+	key := &APIKey{
+		ID:        hex.EncodeToString(id[:]),
+		Name:      name,
+		Addresses: slices.Clone(addresses),
+		Scopes:    slices.Clone(scopes),
+		SendLimit: sendLimit,
+		Created:   time.Now().Unix(),
+	}
+	token := apiKeyPrefix + key.ID + "_" + hex.EncodeToString(secret[:])
+	key.Hash = hashToken(token)
+
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+	ks.keys[key.ID] = key
+	if err := ks.save(); err != nil {
+		delete(ks.keys, key.ID)
+		return "", APIKey{}, err
+	}
+	return token, ks.public(key), nil
+}
+
+// Revoke deletes a key.
+func (ks *KeyStore) Revoke(id string) error {
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+	if _, ok := ks.keys[id]; !ok {
+		return fmt.Errorf("no API key %s", id)
+	}
+	delete(ks.keys, id)
+	return ks.save()
+}
+
+// List returns every key, without hashes, oldest first.
+func (ks *KeyStore) List() []APIKey {
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+	list := make([]APIKey, 0, len(ks.keys))
+	for _, k := range ks.keys {
+		list = append(list, ks.public(k))
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Created != list[j].Created {
+			return list[i].Created < list[j].Created
+		}
+		return list[i].ID < list[j].ID
+	})
+	return list
+}
+
+// IsAPIKey reports whether token has the form of an API key, as opposed
+// to the admin token.
+func IsAPIKey(token string) bool {
+	return strings.HasPrefix(token, apiKeyPrefix)
+}
+
+// Lookup returns the key token belongs to.
+func (ks *KeyStore) Lookup(token string) (APIKey, bool) {
+	id, _, ok := strings.Cut(strings.TrimPrefix(token, apiKeyPrefix), "_")
+	if !ok || !IsAPIKey(token) {
+		return APIKey{}, false
+	}
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+	k, found := ks.keys[id]
+	if !found || subtle.ConstantTimeCompare([]byte(hashToken(token)), []byte(k.Hash)) != 1 {
+		return APIKey{}, false
+	}
+	return ks.public(k), true
+}
+
+// Spend charges amount against the key's daily send limit, failing if it
+// would be exceeded. Refund undoes it when the send doesn't go through.
+func (ks *KeyStore) Spend(id string, amount float64, now time.Time) error {
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+	k, ok := ks.keys[id]
+	if !ok {
+		return fmt.Errorf("no API key %s", id)
+	}
+	day := now.UTC().Format(time.DateOnly)
+	if k.SpentDay != day {
+		k.SpentDay, k.Spent = day, 0
+	}
+	if k.SendLimit > 0 && k.Spent+amount > k.SendLimit+1e-9 {
+		return fmt.Errorf("API key send limit reached: %.8f of %.8f left today", max(0, k.SendLimit-k.Spent), k.SendLimit)
+	}
+	k.Spent += amount
+	return ks.save()
+}
+
+// Refund returns amount charged by Spend on the same day.
+func (ks *KeyStore) Refund(id string, amount float64, now time.Time) {
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+	if k, ok := ks.keys[id]; ok && k.SpentDay == now.UTC().Format(time.DateOnly) {
+		k.Spent = max(0, k.Spent-amount)
+		ks.save()
+	}
+}
+
+// AddAddress adds address to a key, for deposit addresses it created.
+func (ks *KeyStore) AddAddress(id, address string) error {
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+	k, ok := ks.keys[id]
+	if !ok {
+		return fmt.Errorf("no API key %s", id)
+	}
+	k.Addresses = append(k.Addresses, address)
+	return ks.save()
+}
+
+// public returns a copy of k without its hash. The caller holds ks.mu.
+func (ks *KeyStore) public(k *APIKey) APIKey {
+	c := *k
+	c.Hash = ""
+	c.Addresses = slices.Clone(k.Addresses)
+	c.Scopes = slices.Clone(k.Scopes)
+	return c
+}
+
+// save writes the keys to disk. The caller holds ks.mu.
+func (ks *KeyStore) save() error {
+	os.MkdirAll(filepath.Dir(ks.path), 0755)
+	data, _ := json.MarshalIndent(ks.keys, "", "  ")
+	return os.WriteFile(ks.path, data, 0600)
+}
@@ -3,15 +3,14 @@ package wallet
 import (
 	"crypto/ed25519"
 	"crypto/rand"
-	"crypto/sha256"
+	"devinsidercoin/internal/address"
+	"devinsidercoin/internal/blockchain"
 	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"os"
 	"path/filepath"
 	"sync"
-
-	"github.com/mr-tron/base58"
 )
 
 // Wallet holds a keypair and derived address.
@@ -19,6 +18,17 @@ type Wallet struct {
 	Address    string `json:"address"`
 	PublicKey  string `json:"public_key"`
 	PrivateKey string `json:"private_key"`
+	Reference  string `json:"reference,omitempty"` // external ID for deposit addresses
+
+	Restake *RestakePolicy `json:"restake,omitempty"` // nil unless auto-restake is on
+}
+
+// RestakePolicy stakes a wallet's released unbonds and PoS rewards again
+// once Pending, what has come in since the last restake, reaches
+// Threshold.
+type RestakePolicy struct {
+	Threshold float64 `json:"threshold"`
+	Pending   float64 `json:"pending"`
 }
 
 // WalletManager manages multiple wallets.
@@ -42,6 +52,13 @@ func NewWalletManager(dataDir, prefix string) *WalletManager {
 
 // CreateWallet generates a new ed25519 keypair and derives an address.
 func (wm *WalletManager) CreateWallet() (*Wallet, error) {
+	return wm.CreateDepositAddress("")
+}
+
+// CreateDepositAddress generates a fresh wallet tagged with an external
+// reference (an order or customer ID), so incoming funds can be matched
+// to it.
+func (wm *WalletManager) CreateDepositAddress(reference string) (*Wallet, error) {
 	wm.mu.Lock()
 	defer wm.mu.Unlock()
 
@@ -50,16 +67,43 @@ func (wm *WalletManager) CreateWallet() (*Wallet, error) {
 		return nil, err
 	}
 
-	hash := sha256.Sum256(pub)
-	address := wm.Prefix + base58.Encode(hash[:20])
-
+	addr := address.FromPublicKey(wm.Prefix, pub)
 	w := &Wallet{
-		Address:    address,
+		Address:    addr,
 		PublicKey:  hex.EncodeToString(pub),
 		PrivateKey: hex.EncodeToString(priv),
+		Reference:  reference,
 	}
 
-	wm.Wallets[address] = w
+	wm.Wallets[addr] = w
+	wm.saveToDisk()
+	return w, nil
+}
+
+// Upgrade adds the checksummed address of a legacy wallet's key, keeping
+// the legacy one so its funds can still be moved. It returns the new
+// wallet, or the existing one if the key was already upgraded.
+func (wm *WalletManager) Upgrade(legacy string) (*Wallet, error) {
+	wm.mu.Lock()
+	defer wm.mu.Unlock()
+	old, ok := wm.Wallets[legacy]
+	if !ok {
+		return nil, fmt.Errorf("wallet not found: %s", legacy)
+	}
+	addr, err := address.Upgrade(wm.Prefix, legacy)
+	if err != nil {
+		return nil, err
+	}
+	if w, ok := wm.Wallets[addr]; ok {
+		return w, nil
+	}
+	w := &Wallet{
+		Address:    addr,
+		PublicKey:  old.PublicKey,
+		PrivateKey: old.PrivateKey,
+		Reference:  old.Reference,
+	}
+	wm.Wallets[addr] = w
 	wm.saveToDisk()
 	return w, nil
 }
@@ -72,6 +116,17 @@ func (wm *WalletManager) GetWallet(address string) (*Wallet, bool) {
 	return w, ok
 }
 
+// Reference returns the external reference of a local deposit address,
+// or "" if address is not a tagged local wallet.
+func (wm *WalletManager) Reference(address string) string {
+	wm.mu.RLock()
+	defer wm.mu.RUnlock()
+	if w, ok := wm.Wallets[address]; ok {
+		return w.Reference
+	}
+	return ""
+}
+
 // ListWallets returns all wallet addresses.
 func (wm *WalletManager) ListWallets() []string {
 	wm.mu.RLock()
@@ -83,6 +138,63 @@ func (wm *WalletManager) ListWallets() []string {
 	return addrs
 }
 
+// SetRestake installs p as the auto-restake policy of a local wallet; nil
+// turns it off.
+func (wm *WalletManager) SetRestake(address string, p *RestakePolicy) error {
+	wm.mu.Lock()
+	defer wm.mu.Unlock()
+	w, ok := wm.Wallets[address]
+	if !ok {
+		return fmt.Errorf("wallet not found: %s", address)
+	}
+	if p != nil {
+		cp := *p
+		p = &cp
+	}
+	w.Restake = p
+	wm.saveToDisk()
+	return nil
+}
+
+// Restaking returns the policy of every wallet with auto-restake on.
+func (wm *WalletManager) Restaking() map[string]RestakePolicy {
+	wm.mu.RLock()
+	defer wm.mu.RUnlock()
+	out := make(map[string]RestakePolicy)
+	for addr, w := range wm.Wallets {
+		if w.Restake != nil {
+			out[addr] = *w.Restake
+		}
+	}
+	return out
+}
+
+// CreditRestake adds amount to the pending total of address's restake
+// policy and returns the policy, or false if auto-restake is off.
+func (wm *WalletManager) CreditRestake(address string, amount float64) (RestakePolicy, bool) {
+	wm.mu.Lock()
+	defer wm.mu.Unlock()
+	w, ok := wm.Wallets[address]
+	if !ok || w.Restake == nil {
+		return RestakePolicy{}, false
+	}
+	w.Restake.Pending = blockchain.FromUnits(blockchain.ToUnits(w.Restake.Pending) + blockchain.ToUnits(amount))
+	wm.saveToDisk()
+	return *w.Restake, true
+}
+
+// DebitRestake takes a restaked amount off address's pending total.
+func (wm *WalletManager) DebitRestake(address string, amount float64) {
+	wm.mu.Lock()
+	defer wm.mu.Unlock()
+	w, ok := wm.Wallets[address]
+	if !ok || w.Restake == nil {
+		return
+	}
+	w.Restake.Pending = blockchain.FromUnits(max(blockchain.ToUnits(w.Restake.Pending)-blockchain.ToUnits(amount), 0))
+	wm.saveToDisk()
+}
+
 // Sign signs data with the wallet's private key.
 func (wm *WalletManager) Sign(address string, data []byte) (string, error) {
 	wm.mu.RLock()
@@ -98,6 +210,42 @@ func (wm *WalletManager) Sign(address string, data []byte) (string, error) {
 	return hex.EncodeToString(sig), nil
 }
 
+// SignTx signs tx with the wallet of its sender for networkID, using the
+// transaction signature envelope.
+func (wm *WalletManager) SignTx(tx *blockchain.Transaction, networkID uint32) error {
+	wm.mu.RLock()
+	defer wm.mu.RUnlock()
+
+	w, ok := wm.Wallets[tx.From]
+	if !ok {
+		return fmt.Errorf("wallet not found: %s", tx.From)
+	}
+	privBytes, err := hex.DecodeString(w.PrivateKey)
+	if err != nil || len(privBytes) != ed25519.PrivateKeySize {
+		return fmt.Errorf("wallet %s has a malformed private key", tx.From)
+	}
+	blockchain.SignTx(tx, networkID, ed25519.PrivateKey(privBytes))
+	return nil
+}
+
+// SignAttestation signs a finality attestation with the wallet of its
+// address for networkID.
+func (wm *WalletManager) SignAttestation(a *blockchain.Attestation, networkID uint32) error {
+	wm.mu.RLock()
+	defer wm.mu.RUnlock()
+
+	w, ok := wm.Wallets[a.Address]
+	if !ok {
+		return fmt.Errorf("wallet not found: %s", a.Address)
+	}
+	privBytes, err := hex.DecodeString(w.PrivateKey)
+	if err != nil || len(privBytes) != ed25519.PrivateKeySize {
+		return fmt.Errorf("wallet %s has a malformed private key", a.Address)
+	}
+	blockchain.SignAttestation(a, networkID, ed25519.PrivateKey(privBytes))
+	return nil
+}
+
 // VerifySignature verifies an ed25519 signature.
 func VerifySignature(publicKeyHex string, data []byte, signatureHex string) bool {
 	pubBytes, err := hex.DecodeString(publicKeyHex)
@@ -130,6 +278,9 @@ func (wm *WalletManager) Restore(data []byte) (*Wallet, error) {
 	if err := json.Unmarshal(data, &w); err != nil {
 		return nil, err
 	}
+	if err := address.Validate(wm.Prefix, w.Address); err != nil {
+		return nil, fmt.Errorf("invalid wallet address %s: %w", w.Address, err)
+	}
 	wm.Wallets[w.Address] = &w
 	wm.saveToDisk()
 	return &w, nil
@@ -0,0 +1,113 @@
+// Package webhook delivers node notifications to operator-configured HTTP
+// endpoints.
+package webhook
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"devinsidercoin/internal/logging"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+var logger = logging.For("WEBHOOK")
+
+// Delivery attempts per endpoint; retries back off 1s, 2s, 4s...
+const maxAttempts = 5
+
+// Payload is the JSON body POSTed to every endpoint.
+type Payload struct {
+	Event     string      `json:"event"`
+	Timestamp int64       `json:"timestamp"`
+	Data      interface{} `json:"data"`
+}
+
+// Notifier posts events to a set of URLs. When a secret is set, each
+// request carries X-DVC-Signature: hex HMAC-SHA256 of the body, so
+// receivers can reject forged callbacks.
+type Notifier struct {
+	client *http.Client
+	mu     sync.RWMutex
+	urls   []string
+	secret string
+}
+
+// New returns a Notifier with no endpoints.
+func New() *Notifier {
+	return &Notifier{client: &http.Client{Timeout: 10 * time.Second}}
+}
+
+// Configure replaces the endpoints and signing secret. It is safe to call
+// while notifications are in flight.
+func (n *Notifier) Configure(urls []string, secret string) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.urls = append([]string(nil), urls...)
+	n.secret = secret
+}
+
+// Notify delivers event to every endpoint in the background.
+func (n *Notifier) Notify(event string, data interface{}) {
+	n.mu.RLock()
+	urls, secret := n.urls, n.secret
+	n.mu.RUnlock()
+	if len(urls) == 0 {
+		return
+	}
+	body, err := json.Marshal(Payload{Event: event, Timestamp: time.Now().Unix(), Data: data})
+	if err != nil {
+		logger.Error("cannot encode webhook", "event", event, "err", err)
+		return
+	}
+	sig := ""
+	if secret != "" {
+		mac := hmac.New(sha256.New, []byte(secret))
+		mac.Write(body)
+		sig = hex.EncodeToString(mac.Sum(nil))
+	}
+	for _, u := range urls {
+		go n.deliver(u, event, body, sig)
+	}
+}
+
+func (n *Notifier) deliver(url, event string, body []byte, sig string) {
+	backoff := time.Second
+	for attempt := 1; ; attempt++ {
+		err := n.post(url, body, sig)
+		if err == nil {
+			logger.Debug("webhook delivered", "url", url, "event", event)
+			return
+		}
+		if attempt == maxAttempts {
+			logger.Warn("webhook delivery failed", "url", url, "event", event, "err", err)
+			return
+		}
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+}
+
+func (n *Notifier) post(url string, body []byte, sig string) error {
+	req, err := http.NewRequest("POST", url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if sig != "" {
+		req.Header.Set("X-DVC-Signature", sig)
+	}
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("status %d", resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,8 @@
+// Package networks embeds the official network manifests so the node can
+// start without the JSON files being present next to the binary.
+package networks
+
+import "embed"
+
+//go:embed *.json
+var FS embed.FS
@@ -0,0 +1,183 @@
+// Package client is a Go SDK for a DevInsiderCoin node's JSON-RPC (/rpc),
+// REST (/api/...) and notification (/api/ws) endpoints.
+//
+//	c := client.New("127.0.0.1:9334")
+//	height, err := c.GetBlockCount()
+//	res, err := c.Send(from, to, 1.5)
+//
+// Typed methods cover the common calls; Call, Get and Post reach any other
+// endpoint.
+package client
+
+import (
+	"bytes"
+	"cmp"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Client talks to one node. The zero value is not usable; use New.
+type Client struct {
+	BaseURL   string       // e.g. http://127.0.0.1:9334
+	HTTP      *http.Client // transport for RPC and REST calls
+	Retries   int          // extra attempts for requests the node didn't process
+	RetryWait time.Duration
+
+	AdminToken string // sent as a bearer token; needed for /api/admin
+	APIKey     string // scoped wallet key, sent instead of AdminToken when set
+}
+
+// New returns a client for a node at addr, given as host:port or a full
+// http(s) URL.
+func New(addr string) *Client {
+	if !strings.Contains(addr, "://") {
+		addr = "http://" + addr
+	}
+	return &Client{
+		BaseURL:   strings.TrimRight(addr, "/"),
+		HTTP:      &http.Client{Timeout: 30 * time.Second},
+		Retries:   3,
+		RetryWait: 500 * time.Millisecond,
+	}
+}
+
+// Error is an error reported by the node, as opposed to a transport
+// failure.
+type Error struct {
+	Endpoint string // RPC method or REST path
+	Status   int    // HTTP status; 200 for JSON-RPC errors
+	Message  string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s: %s", e.Endpoint, e.Message)
+}
+
+// Call invokes a JSON-RPC method and decodes its result into result, which
+// may be nil.
+func (c *Client) Call(method string, params, result interface{}) error {
+	body, _ := json.Marshal(map[string]interface{}{"method": method, "params": params, "id": 1})
+	resp, err := c.do("POST", "/rpc", body, "")
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	raw, _ := io.ReadAll(resp.Body)
+	var rr struct {
+		Result json.RawMessage `json:"result"`
+		Error  interface{}     `json:"error"`
+	}
+	if err := json.Unmarshal(raw, &rr); err != nil {
+		return fmt.Errorf("%s: bad response (%d): %s", method, resp.StatusCode, bytes.TrimSpace(raw))
+	}
+	if rr.Error != nil {
+		return &Error{Endpoint: method, Status: resp.StatusCode, Message: fmt.Sprint(rr.Error)}
+	}
+	return decodeInto(rr.Result, result)
+}
+
+// Get calls a REST endpoint with query parameters q (may be nil) and
+// decodes its data into result.
+func (c *Client) Get(path string, q url.Values, result interface{}) error {
+	if len(q) > 0 {
+		path += "?" + q.Encode()
+	}
+	resp, err := c.do("GET", path, nil, "")
+	if err != nil {
+		return err
+	}
+	return decodeREST(path, resp, result)
+}
+
+// Post sends body as JSON to a REST endpoint and decodes its data into
+// result.
+func (c *Client) Post(path string, body, result interface{}) error {
+	return c.PostIdempotent(path, "", body, result)
+}
+
+// PostIdempotent is Post with an Idempotency-Key header. The node runs a
+// request with a given key once and answers retries with the first
+// result, so the request is also retried after a timeout or a dropped
+// connection. An empty key is plain Post.
+func (c *Client) PostIdempotent(path, key string, body, result interface{}) error {
+	data, _ := json.Marshal(body)
+	resp, err := c.do("POST", path, data, key)
+	if err != nil {
+		return err
+	}
+	return decodeREST(path, resp, result)
+}
+
+// do sends a request, retrying only when the node certainly did not act
+// on it: the connection could not be made, or it answered 429 or 503. A
+// retried payment is therefore never sent twice. Requests with an
+// idempotency key are also retried after any transport error, and while
+// the node answers 409 for the first attempt still in progress.
+func (c *Client) do(method, path string, body []byte, idempotencyKey string) (*http.Response, error) {
+	wait := c.RetryWait
+	for attempt := 0; ; attempt++ {
+		req, err := http.NewRequest(method, c.BaseURL+path, bytes.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+		if body != nil {
+			req.Header.Set("Content-Type", "application/json")
+		}
+		if token := cmp.Or(c.APIKey, c.AdminToken); token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		if idempotencyKey != "" {
+			req.Header.Set("Idempotency-Key", idempotencyKey)
+		}
+		resp, err := c.HTTP.Do(req)
+		retry := false
+		switch {
+		case err != nil:
+			var op *net.OpError
+			retry = idempotencyKey != "" || errors.As(err, &op) && op.Op == "dial"
+		case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusServiceUnavailable:
+			retry = true
+		case resp.StatusCode == http.StatusConflict:
+			retry = idempotencyKey != ""
+		}
+		if !retry || attempt >= c.Retries {
+			return resp, err
+		}
+		if resp != nil {
+			resp.Body.Close()
+		}
+		time.Sleep(wait)
+		wait *= 2
+	}
+}
+
+func decodeREST(path string, resp *http.Response, result interface{}) error {
+	defer resp.Body.Close()
+	raw, _ := io.ReadAll(resp.Body)
+	var rr struct {
+		OK    bool            `json:"ok"`
+		Data  json.RawMessage `json:"data"`
+		Error string          `json:"error"`
+	}
+	if err := json.Unmarshal(raw, &rr); err != nil {
+		return fmt.Errorf("%s: bad response (%d): %s", path, resp.StatusCode, bytes.TrimSpace(raw))
+	}
+	if !rr.OK {
+		return &Error{Endpoint: path, Status: resp.StatusCode, Message: rr.Error}
+	}
+	return decodeInto(rr.Data, result)
+}
+
+func decodeInto(raw json.RawMessage, result interface{}) error {
+	if result == nil || len(raw) == 0 {
+		return nil
+	}
+	return json.Unmarshal(raw, result)
+}
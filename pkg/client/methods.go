@@ -0,0 +1,600 @@
+package client
+
+import (
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// GetBlockCount returns the number of blocks in the chain.
+func (c *Client) GetBlockCount() (uint64, error) {
+	var n uint64
+	err := c.Call("getblockcount", nil, &n)
+	return n, err
+}
+
+// GetBestBlockHash returns the hash of the tip.
+func (c *Client) GetBestBlockHash() (string, error) {
+	var h string
+	err := c.Call("getbestblockhash", nil, &h)
+	return h, err
+}
+
+// GetMiningInfo returns the mining summary.
+func (c *Client) GetMiningInfo() (*MiningInfo, error) {
+	var info MiningInfo
+	if err := c.Call("getmininginfo", nil, &info); err != nil {
+		return nil, err
+	}
+	return &info, nil
+}
+
+// GetBlockTemplate returns a block to mine paying minerAddress.
+func (c *Client) GetBlockTemplate(minerAddress string) (*Block, error) {
+	var b Block
+	if err := c.Call("getblocktemplate", map[string]string{"miner_address": minerAddress}, &b); err != nil {
+		return nil, err
+	}
+	return &b, nil
+}
+
+// SubmitBlock submits a solved block.
+func (c *Client) SubmitBlock(b *Block) (*SubmitResult, error) {
+	var res SubmitResult
+	if err := c.Call("submitblock", b, &res); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// GetShareJob returns a block to mine paying minerAddress and the share
+// difficulty the pool wants from worker (pool mode nodes only).
+func (c *Client) GetShareJob(worker, minerAddress string) (*ShareJob, error) {
+	var job ShareJob
+	if err := c.Call("getsharejob", map[string]string{"worker": worker, "miner_address": minerAddress}, &job); err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+// SubmitShare submits a share of a job from GetShareJob.
+func (c *Client) SubmitShare(worker string, b *Block) (*ShareResult, error) {
+	var res ShareResult
+	if err := c.Call("submitshare", map[string]interface{}{"worker": worker, "block": b}, &res); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// GetTransaction looks a transaction up in the chain or mempool.
+func (c *Client) GetTransaction(txid string) (*TxRecord, error) {
+	var rec TxRecord
+	if err := c.Call("gettransaction", map[string]string{"txid": txid}, &rec); err != nil {
+		return nil, err
+	}
+	return &rec, nil
+}
+
+// GetReceivedByAddress returns the total paid to address by transactions
+// with at least minconf confirmations.
+func (c *Client) GetReceivedByAddress(address string, minconf int) (float64, error) {
+	var total float64
+	err := c.Call("getreceivedbyaddress", map[string]interface{}{"address": address, "minconf": minconf}, &total)
+	return total, err
+}
+
+// GetNonce returns address's highest confirmed nonce and the nonce its
+// next transaction should carry.
+func (c *Client) GetNonce(address string) (*NonceInfo, error) {
+	var info NonceInfo
+	if err := c.Call("getnonce", map[string]string{"address": address}, &info); err != nil {
+		return nil, err
+	}
+	return &info, nil
+}
+
+// ValidateAddress checks an address's format and checksum.
+func (c *Client) ValidateAddress(address string) (*AddressInfo, error) {
+	var info AddressInfo
+	if err := c.Call("validateaddress", map[string]string{"address": address}, &info); err != nil {
+		return nil, err
+	}
+	return &info, nil
+}
+
+// GetPeerInfo returns the connected peers.
+func (c *Client) GetPeerInfo() ([]PeerInfo, error) {
+	var peers []PeerInfo
+	err := c.Call("getpeerinfo", nil, &peers)
+	return peers, err
+}
+
+// GetSyncStatus returns block download progress.
+func (c *Client) GetSyncStatus() (*SyncStatus, error) {
+	var st SyncStatus
+	if err := c.Call("getsyncstatus", nil, &st); err != nil {
+		return nil, err
+	}
+	return &st, nil
+}
+
+// ListUnspent returns address's spendable outputs on a utxo network.
+func (c *Client) ListUnspent(address string) ([]UTXO, error) {
+	var utxos []UTXO
+	err := c.Call("listunspent", map[string]string{"address": address}, &utxos)
+	return utxos, err
+}
+
+// GetTxOut returns an unspent output, or nil if it is spent (including by
+// a pending transaction) or unknown.
+func (c *Client) GetTxOut(txid string, vout uint32) (*TxOut, error) {
+	var out *TxOut
+	err := c.Call("gettxout", map[string]interface{}{"txid": txid, "vout": vout}, &out)
+	return out, err
+}
+
+// GetBlockProposal previews which pending transactions the next block
+// would include.
+func (c *Client) GetBlockProposal() (*BlockProposal, error) {
+	var p BlockProposal
+	if err := c.Call("getblocktemplateproposal", nil, &p); err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// GetDeploymentInfo returns the activation state of each version bits
+// deployment for the next block.
+func (c *Client) GetDeploymentInfo() (*DeploymentInfo, error) {
+	var info DeploymentInfo
+	if err := c.Call("getdeploymentinfo", nil, &info); err != nil {
+		return nil, err
+	}
+	return &info, nil
+}
+
+// SubmitEvidence hands the node double-sign evidence for the next blocks
+// to slash.
+func (c *Client) SubmitEvidence(ev Evidence) (*EvidenceResult, error) {
+	var res EvidenceResult
+	if err := c.Call("submitevidence", ev, &res); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// GetEvidence returns the double-sign evidence waiting for a block.
+func (c *Client) GetEvidence() ([]Evidence, error) {
+	var evs []Evidence
+	if err := c.Call("getevidence", nil, &evs); err != nil {
+		return nil, err
+	}
+	return evs, nil
+}
+
+// GetTxOutSetInfo returns UTXO set statistics and the supply audit.
+func (c *Client) GetTxOutSetInfo() (*UTXOSetInfo, error) {
+	var info UTXOSetInfo
+	if err := c.Call("gettxoutsetinfo", nil, &info); err != nil {
+		return nil, err
+	}
+	return &info, nil
+}
+
+// GenerateToAddress mines n blocks to address (regtest only) and returns
+// their hashes.
+func (c *Client) GenerateToAddress(n int, address string) ([]string, error) {
+	var hashes []string
+	err := c.Call("generatetoaddress", map[string]interface{}{"nblocks": n, "address": address}, &hashes)
+	return hashes, err
+}
+
+// ChainInfo returns the network and chain summary.
+func (c *Client) ChainInfo() (*ChainInfo, error) {
+	var info ChainInfo
+	if err := c.Get("/api/chain/info", nil, &info); err != nil {
+		return nil, err
+	}
+	return &info, nil
+}
+
+// GetBlock returns the block at height.
+func (c *Client) GetBlock(height uint64) (*Block, error) {
+	var b Block
+	q := url.Values{"height": {strconv.FormatUint(height, 10)}}
+	if err := c.Get("/api/chain/block", q, &b); err != nil {
+		return nil, err
+	}
+	return &b, nil
+}
+
+// GetBlockByHash returns the block with the given hash.
+func (c *Client) GetBlockByHash(hash string) (*Block, error) {
+	var b Block
+	if err := c.Get("/api/chain/block", url.Values{"hash": {hash}}, &b); err != nil {
+		return nil, err
+	}
+	return &b, nil
+}
+
+// Supply returns the coin supply summary.
+func (c *Client) Supply() (*Supply, error) {
+	var s Supply
+	if err := c.Get("/api/chain/supply", nil, &s); err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// StakingInfo returns stake and PoS reward figures. A positive amount adds
+// a yield projection for a stake of that size; a non-empty address adds
+// its PoS earnings.
+func (c *Client) StakingInfo(amount float64, address string) (*StakingInfo, error) {
+	q := url.Values{}
+	if amount > 0 {
+		q.Set("amount", strconv.FormatFloat(amount, 'f', -1, 64))
+	}
+	if address != "" {
+		q.Set("address", address)
+	}
+	var info StakingInfo
+	if err := c.Get("/api/staking/info", q, &info); err != nil {
+		return nil, err
+	}
+	return &info, nil
+}
+
+// ValidatorStats returns address's block production and staking record.
+func (c *Client) ValidatorStats(address string) (*ValidatorStats, error) {
+	var st ValidatorStats
+	if err := c.Get("/api/validators/"+url.PathEscape(address)+"/stats", nil, &st); err != nil {
+		return nil, err
+	}
+	return &st, nil
+}
+
+// Finality returns the finalized checkpoint height and the latest
+// checkpoint.
+func (c *Client) Finality() (*Finality, error) {
+	var f Finality
+	if err := c.Get("/api/chain/finality", nil, &f); err != nil {
+		return nil, err
+	}
+	return &f, nil
+}
+
+// Checkpoint returns the finality checkpoint opened at height.
+func (c *Client) Checkpoint(height uint64) (*Checkpoint, error) {
+	var cp Checkpoint
+	q := url.Values{"height": {strconv.FormatUint(height, 10)}}
+	if err := c.Get("/api/chain/finality", q, &cp); err != nil {
+		return nil, err
+	}
+	return &cp, nil
+}
+
+// DifficultyHistory returns the last intervals retarget intervals and the
+// next epochs steps of the progressive difficulty floor.
+func (c *Client) DifficultyHistory(intervals, epochs int) (*DifficultyHistory, error) {
+	var h DifficultyHistory
+	q := url.Values{
+		"intervals": {strconv.Itoa(intervals)},
+		"epochs":    {strconv.Itoa(epochs)},
+	}
+	if err := c.Get("/api/chain/difficulty", q, &h); err != nil {
+		return nil, err
+	}
+	return &h, nil
+}
+
+// Charts returns the daily stats of the last days days that have blocks,
+// oldest first.
+func (c *Client) Charts(days int) ([]DayStats, error) {
+	var stats []DayStats
+	err := c.Get("/api/chain/charts", url.Values{"days": {strconv.Itoa(days)}}, &stats)
+	return stats, err
+}
+
+// AddressHistory returns about limit transactions touching address from
+// fromHeight on (archive nodes only). The next page starts at the last
+// height plus one.
+func (c *Client) AddressHistory(address string, fromHeight uint64, limit int) ([]AddressTx, error) {
+	var txs []AddressTx
+	err := c.Get("/api/address/history", historyQuery(address, fromHeight, limit), &txs)
+	return txs, err
+}
+
+// BalanceHistory returns address's balance after each block from
+// fromHeight on that changed it (archive nodes only).
+func (c *Client) BalanceHistory(address string, fromHeight uint64, limit int) ([]BalancePoint, error) {
+	var points []BalancePoint
+	err := c.Get("/api/address/balances", historyQuery(address, fromHeight, limit), &points)
+	return points, err
+}
+
+// AddressHistoryAt is AddressHistory as of the block at height, so pages
+// fetched while new blocks arrive stay consistent.
+func (c *Client) AddressHistoryAt(address string, fromHeight, height uint64, limit int) ([]AddressTx, error) {
+	var txs []AddressTx
+	q := historyQuery(address, fromHeight, limit)
+	q.Set("height", strconv.FormatUint(height, 10))
+	err := c.Get("/api/address/history", q, &txs)
+	return txs, err
+}
+
+// BalanceHistoryAt is BalanceHistory as of the block at height.
+func (c *Client) BalanceHistoryAt(address string, fromHeight, height uint64, limit int) ([]BalancePoint, error) {
+	var points []BalancePoint
+	q := historyQuery(address, fromHeight, limit)
+	q.Set("height", strconv.FormatUint(height, 10))
+	err := c.Get("/api/address/balances", q, &points)
+	return points, err
+}
+
+// BalanceAt returns what address held after the block at height
+// (archive nodes only).
+func (c *Client) BalanceAt(address string, height uint64) (*HistoricalBalance, error) {
+	var bal HistoricalBalance
+	q := url.Values{"address": {address}, "height": {strconv.FormatUint(height, 10)}}
+	if err := c.Get("/api/address/balanceat", q, &bal); err != nil {
+		return nil, err
+	}
+	return &bal, nil
+}
+
+func historyQuery(address string, fromHeight uint64, limit int) url.Values {
+	return url.Values{
+		"address": {address},
+		"from":    {strconv.FormatUint(fromHeight, 10)},
+		"limit":   {strconv.Itoa(limit)},
+	}
+}
+
+// RichList returns the n largest balances (archive nodes only).
+func (c *Client) RichList(n int) ([]RichEntry, error) {
+	var list []RichEntry
+	err := c.Get("/api/chain/richlist", url.Values{"limit": {strconv.Itoa(n)}}, &list)
+	return list, err
+}
+
+// RichListAt returns n entries of the rich list after the block at
+// height, from rank offset+1 on, so a client can page through one
+// ranking while new blocks arrive (archive nodes only).
+func (c *Client) RichListAt(height uint64, offset, n int) ([]RichEntry, error) {
+	var list []RichEntry
+	q := url.Values{
+		"height": {strconv.FormatUint(height, 10)},
+		"offset": {strconv.Itoa(offset)},
+		"limit":  {strconv.Itoa(n)},
+	}
+	err := c.Get("/api/chain/richlist", q, &list)
+	return list, err
+}
+
+// BlockFilter returns the address filter of the block at height (archive
+// nodes only).
+func (c *Client) BlockFilter(height uint64) (*BlockFilter, error) {
+	var f BlockFilter
+	q := url.Values{"height": {strconv.FormatUint(height, 10)}}
+	if err := c.Get("/api/chain/filter", q, &f); err != nil {
+		return nil, err
+	}
+	return &f, nil
+}
+
+// GetIndexInfo returns the build status of each optional index, keyed by
+// name ("received", and "archive" on archive nodes).
+func (c *Client) GetIndexInfo() (map[string]IndexStatus, error) {
+	var info map[string]IndexStatus
+	err := c.Call("getindexinfo", nil, &info)
+	return info, err
+}
+
+// Journal returns up to limit entries of the node's event journal starting
+// at seq from (nodes started with -journal). When no entry is available yet
+// the node holds the request for up to wait; keep wait below the client's
+// 30s HTTP timeout.
+func (c *Client) Journal(from uint64, limit int, wait time.Duration) (*JournalPage, error) {
+	var page JournalPage
+	q := url.Values{
+		"from":  {strconv.FormatUint(from, 10)},
+		"limit": {strconv.Itoa(limit)},
+		"wait":  {strconv.Itoa(int(wait / time.Second))},
+	}
+	if err := c.Get("/api/journal", q, &page); err != nil {
+		return nil, err
+	}
+	return &page, nil
+}
+
+// CreateWallet creates a wallet on the node.
+func (c *Client) CreateWallet() (*Wallet, error) {
+	var w Wallet
+	if err := c.Post("/api/wallet/create", struct{}{}, &w); err != nil {
+		return nil, err
+	}
+	return &w, nil
+}
+
+// DepositAddress creates a wallet tagged with an external reference;
+// credits to it produce "deposit" notifications.
+func (c *Client) DepositAddress(reference string) (*Wallet, error) {
+	var w Wallet
+	if err := c.Post("/api/wallet/deposit-address", map[string]string{"reference": reference}, &w); err != nil {
+		return nil, err
+	}
+	return &w, nil
+}
+
+// WalletUnspent returns the spendable outputs of address, or of every
+// wallet held by the node if address is empty.
+func (c *Client) WalletUnspent(address string) ([]UTXO, error) {
+	q := url.Values{}
+	if address != "" {
+		q.Set("address", address)
+	}
+	var utxos []UTXO
+	err := c.Get("/api/wallet/listunspent", q, &utxos)
+	return utxos, err
+}
+
+// Balance returns an address's confirmed balance.
+func (c *Client) Balance(address string) (*Balance, error) {
+	var b Balance
+	if err := c.Get("/api/wallet/balance", url.Values{"address": {address}}, &b); err != nil {
+		return nil, err
+	}
+	return &b, nil
+}
+
+// Unbonding returns an address's unstakes that are waiting for release,
+// in release order.
+func (c *Client) Unbonding(address string) ([]Unbond, error) {
+	var list []Unbond
+	err := c.Get("/api/wallet/unbonding", url.Values{"address": {address}}, &list)
+	return list, err
+}
+
+// Restake returns the auto-restake policy of a wallet held by the node.
+func (c *Client) Restake(address string) (*RestakeStatus, error) {
+	var st RestakeStatus
+	if err := c.Get("/api/wallet/restake", url.Values{"address": {address}}, &st); err != nil {
+		return nil, err
+	}
+	return &st, nil
+}
+
+// SetRestake turns auto-restake on or off for a wallet held by the node.
+// While on, released unbonds and PoS rewards are staked again once they
+// add up to threshold.
+func (c *Client) SetRestake(address string, enabled bool, threshold float64) (*RestakeStatus, error) {
+	var st RestakeStatus
+	body := map[string]interface{}{"address": address, "enabled": enabled, "threshold": threshold}
+	if err := c.Post("/api/wallet/restake", body, &st); err != nil {
+		return nil, err
+	}
+	return &st, nil
+}
+
+// Rewards returns up to limit staking rewards paid to address from
+// fromHeight on. Non-zero since and until keep only rewards from blocks
+// timestamped between them.
+func (c *Client) Rewards(address string, fromHeight uint64, limit int, since, until time.Time) (*RewardPage, error) {
+	q := historyQuery(address, fromHeight, limit)
+	if !since.IsZero() {
+		q.Set("since", strconv.FormatInt(since.Unix(), 10))
+	}
+	if !until.IsZero() {
+		q.Set("until", strconv.FormatInt(until.Unix(), 10))
+	}
+	var page RewardPage
+	if err := c.Get("/api/wallet/rewards", q, &page); err != nil {
+		return nil, err
+	}
+	return &page, nil
+}
+
+// Send pays amount from a wallet held by the node.
+func (c *Client) Send(from, to string, amount float64) (*SendResult, error) {
+	return c.SendInputs(from, to, amount, nil)
+}
+
+// SendInputs is Send spending exactly the given outputs (coin control, utxo
+// networks only); change returns to from.
+func (c *Client) SendInputs(from, to string, amount float64, inputs []TxInput) (*SendResult, error) {
+	return c.SendWithChange(from, to, amount, inputs, "")
+}
+
+// SendWithChange is SendInputs paying change to changeAddress instead of
+// from. Nil inputs select outputs automatically.
+func (c *Client) SendWithChange(from, to string, amount float64, inputs []TxInput, changeAddress string) (*SendResult, error) {
+	var res SendResult
+	body := map[string]interface{}{"from": from, "to": to, "amount": amount}
+	if len(inputs) > 0 {
+		body["inputs"] = inputs
+	}
+	if changeAddress != "" {
+		body["change_address"] = changeAddress
+	}
+	if err := c.Post("/api/wallet/send", body, &res); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// SendOnce is Send under an idempotency key, such as an order or payout
+// ID: however often it is called or retried with the same key within a
+// day, the node sends the payment once and returns its result.
+func (c *Client) SendOnce(key, from, to string, amount float64) (*SendResult, error) {
+	var res SendResult
+	body := map[string]interface{}{"from": from, "to": to, "amount": amount}
+	if err := c.PostIdempotent("/api/wallet/send", key, body, &res); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// UpgradeAddress moves a legacy wallet to the checksummed address of the
+// same key, sending its spendable balance there.
+func (c *Client) UpgradeAddress(legacy string) (*UpgradeResult, error) {
+	var res UpgradeResult
+	if err := c.Post("/api/wallet/upgrade", map[string]string{"address": legacy}, &res); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// BroadcastStatus returns how a pending transaction has been announced.
+func (c *Client) BroadcastStatus(txid string) (*BroadcastState, error) {
+	var st BroadcastState
+	if err := c.Get("/api/tx/"+url.PathEscape(txid)+"/broadcast", nil, &st); err != nil {
+		return nil, err
+	}
+	return &st, nil
+}
+
+// Rebroadcast announces a pending transaction to all peers again and
+// returns how many it reached.
+func (c *Client) Rebroadcast(txid string) (int, error) {
+	var res struct {
+		SentTo int `json:"sent_to"`
+	}
+	err := c.Post("/api/tx/"+url.PathEscape(txid)+"/rebroadcast", struct{}{}, &res)
+	return res.SentTo, err
+}
+
+// DecodeRawTransaction decodes a hex canonical transaction encoding.
+func (c *Client) DecodeRawTransaction(rawHex string) (*Transaction, error) {
+	var tx Transaction
+	if err := c.Call("decoderawtransaction", map[string]string{"hex": rawHex}, &tx); err != nil {
+		return nil, err
+	}
+	return &tx, nil
+}
+
+// DecodeBlock decodes a hex canonical block encoding.
+func (c *Client) DecodeBlock(rawHex string) (*Block, error) {
+	var b Block
+	if err := c.Call("decodeblock", map[string]string{"hex": rawHex}, &b); err != nil {
+		return nil, err
+	}
+	return &b, nil
+}
+
+// EncodeRawTransaction returns the hex canonical encoding of tx.
+func (c *Client) EncodeRawTransaction(tx *Transaction) (string, error) {
+	var res struct {
+		Hex string `json:"hex"`
+	}
+	err := c.Call("encoderawtransaction", tx, &res)
+	return res.Hex, err
+}
+
+// EncodeBlock returns the hex canonical encoding of b.
+func (c *Client) EncodeBlock(b *Block) (string, error) {
+	var res struct {
+		Hex string `json:"hex"`
+	}
+	err := c.Call("encodeblock", b, &res)
+	return res.Hex, err
+}
@@ -0,0 +1,585 @@
+package client
+
+import "time"
+
+// BlockHeader mirrors the node's block header.
+type BlockHeader struct {
+	Version    uint32 `json:"version"`
+	PrevHash   string `json:"prev_hash"`
+	MerkleRoot string `json:"merkle_root"`
+	Timestamp  int64  `json:"timestamp"`
+	Bits       uint32 `json:"bits"`
+	Nonce      uint64 `json:"nonce"`
+	Height     uint64 `json:"height"`
+}
+
+// TxOutput is a payment made by a transaction. On account networks only
+// coinbase and reward transactions have outputs.
+type TxOutput struct {
+	Address string  `json:"address"`
+	Amount  float64 `json:"amount"`
+}
+
+// TxInput spends an earlier output on a utxo network.
+type TxInput struct {
+	TxID string `json:"txid"`
+	Vout uint32 `json:"vout"`
+}
+
+// UTXO is an unspent output on a utxo network.
+type UTXO struct {
+	TxID     string  `json:"txid"`
+	Vout     uint32  `json:"vout"`
+	Address  string  `json:"address"`
+	Amount   float64 `json:"amount"`
+	Height   uint64  `json:"height"`
+	Coinbase bool    `json:"coinbase,omitempty"`
+}
+
+// TxOut is returned by GetTxOut.
+type TxOut struct {
+	UTXO
+	Confirmations  uint64 `json:"confirmations"`
+	BestBlock      string `json:"bestblock"`
+	SpentInMempool bool   `json:"spent_in_mempool"`
+}
+
+// UTXOSetInfo is returned by GetTxOutSetInfo. Unaccounted is circulating
+// supply minus unspent outputs, stakes and unbonding coins; it is zero on a
+// healthy utxo network.
+type UTXOSetInfo struct {
+	Height      uint64  `json:"height"`
+	BestBlock   string  `json:"bestblock"`
+	Outputs     int     `json:"outputs"`
+	Addresses   int     `json:"addresses"`
+	Total       float64 `json:"total"`
+	Staked      float64 `json:"staked"`
+	Unbonding   float64 `json:"unbonding"`
+	Circulating float64 `json:"circulating"`
+	Unaccounted float64 `json:"unaccounted"`
+	SetHash     string  `json:"hash_serialized"`
+}
+
+// Transaction mirrors the node's transaction. It carries every field, so a
+// block template decoded into Block can be submitted back unchanged.
+type Transaction struct {
+	TxID      string     `json:"txid"`
+	Version   uint32     `json:"version,omitempty"`
+	Type      string     `json:"type"`
+	From      string     `json:"from,omitempty"`
+	To        string     `json:"to,omitempty"`
+	Amount    float64    `json:"amount"`
+	Fee       float64    `json:"fee"`
+	Timestamp int64      `json:"timestamp"`
+	Signature string     `json:"signature,omitempty"`
+	Inputs    []TxInput  `json:"inputs,omitempty"`
+	Outputs   []TxOutput `json:"outputs,omitempty"`
+	Data      string     `json:"data,omitempty"`
+	Nonce     uint64     `json:"nonce,omitempty"`
+}
+
+// Block is a full block or block template.
+type Block struct {
+	Header       BlockHeader   `json:"header"`
+	Transactions []Transaction `json:"transactions"`
+	Hash         string        `json:"hash"`
+}
+
+// TxRecord is a transaction with its confirmation status.
+type TxRecord struct {
+	Transaction
+	BlockHash     string `json:"block_hash,omitempty"`
+	BlockHeight   uint64 `json:"block_height,omitempty"`
+	Confirmations int64  `json:"confirmations"`
+}
+
+// SubmitResult is returned by SubmitBlock.
+type SubmitResult struct {
+	Accepted bool   `json:"accepted"`
+	Hash     string `json:"hash"`
+	Height   uint64 `json:"height"`
+}
+
+// ShareJob is returned by GetShareJob.
+type ShareJob struct {
+	Worker    string `json:"worker"`
+	ShareBits uint32 `json:"share_bits"`
+	Block     *Block `json:"block"`
+}
+
+// ShareResult is returned by SubmitShare. Block is set when the share also
+// solved the block, and BlockError when that block was rejected.
+type ShareResult struct {
+	Accepted   bool   `json:"accepted"`
+	ShareBits  uint32 `json:"share_bits"` // for the worker's next shares
+	Retargeted bool   `json:"retargeted"`
+	Block      bool   `json:"block,omitempty"`
+	Height     uint64 `json:"height,omitempty"`
+	BlockError string `json:"block_error,omitempty"`
+}
+
+// BlockProposal is returned by GetBlockProposal: the pending transactions
+// the next block template includes, in block order, and their fees.
+type BlockProposal struct {
+	Height        uint64       `json:"height"`
+	Transactions  []ProposedTx `json:"transactions"`
+	Fees          float64      `json:"fees"`
+	CollectedFees float64      `json:"collected_fees"`
+	Subsidy       float64      `json:"subsidy"`
+	MinFeeRate    float64      `json:"min_fee_rate"`
+	Pending       int          `json:"pending"`
+	Excluded      int          `json:"excluded"`
+}
+
+// ProposedTx is one transaction of a BlockProposal. FeeRate is the fee per
+// 1000 bytes.
+type ProposedTx struct {
+	TxID    string  `json:"txid"`
+	Type    string  `json:"type"`
+	Fee     float64 `json:"fee"`
+	Size    int     `json:"size"`
+	FeeRate float64 `json:"fee_rate"`
+}
+
+// DeploymentInfo is returned by GetDeploymentInfo.
+type DeploymentInfo struct {
+	Height      uint64       `json:"height"` // of the next block
+	Window      uint64       `json:"window"`
+	Threshold   uint64       `json:"threshold"`
+	Deployments []Deployment `json:"deployments"`
+}
+
+// Deployment is one soft fork in a DeploymentInfo. State is defined,
+// started, locked_in, active or failed; Signals is set while started.
+type Deployment struct {
+	Name          string `json:"name"`
+	Bit           uint   `json:"bit"`
+	StartHeight   uint64 `json:"start_height"`
+	TimeoutHeight uint64 `json:"timeout_height"`
+	State         string `json:"state"`
+	Since         uint64 `json:"since"`
+	Signals       *struct {
+		WindowStart uint64 `json:"window_start"`
+		Elapsed     uint64 `json:"elapsed"`
+		Count       uint64 `json:"count"`
+		Threshold   uint64 `json:"threshold"`
+		Possible    bool   `json:"possible"`
+	} `json:"signals,omitempty"`
+}
+
+// MiningInfo is returned by GetMiningInfo.
+type MiningInfo struct {
+	Blocks      uint64  `json:"blocks"`
+	Difficulty  uint32  `json:"difficulty"`
+	NetworkHash float64 `json:"network_hash"`
+	MaxSupply   float64 `json:"max_supply"`
+	TotalMinted float64 `json:"total_minted"`
+	StakedTotal float64 `json:"staked_total"`
+	MempoolSize int     `json:"mempool_size"`
+	Peers       int     `json:"peers"`
+
+	// Progressive difficulty floor for the next block.
+	FloorEpoch      uint64 `json:"floor_epoch"`
+	FloorBits       uint32 `json:"floor_bits"`
+	NextFloorHeight uint64 `json:"next_floor_height"` // 0 once the floor stops tightening
+	NextFloorBits   uint32 `json:"next_floor_bits"`
+}
+
+// ChainInfo is returned by ChainInfo.
+type ChainInfo struct {
+	Name          string  `json:"name"`
+	Ticker        string  `json:"ticker"`
+	Blocks        uint64  `json:"blocks"`
+	BestHash      string  `json:"best_hash"`
+	Difficulty    uint32  `json:"difficulty"`
+	ChainWork     string  `json:"chainwork"` // cumulative work, 64 hex digits
+	NetworkHashPS float64 `json:"network_hashps"`
+	MaxSupply     float64 `json:"max_supply"`
+	TotalMinted   float64 `json:"total_minted"`
+	StakedTotal   float64 `json:"staked_total"`
+	MempoolSize   int     `json:"mempool_size"`
+	Peers         int     `json:"peers"`
+}
+
+// PeerInfo describes a connected peer.
+type PeerInfo struct {
+	Address        string   `json:"address"`
+	Version        uint32   `json:"version"`
+	Height         uint64   `json:"height"`
+	ChainWork      string   `json:"chain_work,omitempty"`
+	Services       []string `json:"services"`
+	NodeID         string   `json:"node_id,omitempty"`
+	ProtocolErrors int32    `json:"protocol_errors"`
+
+	UserAgent string    `json:"user_agent,omitempty"`
+	Direction string    `json:"direction"`
+	ConnTime  time.Time `json:"conn_time"`
+	LastSend  time.Time `json:"last_send"`
+	LastRecv  time.Time `json:"last_recv"`
+}
+
+// SyncStatus is the node's block download progress.
+type SyncStatus struct {
+	Syncing      bool      `json:"syncing"`
+	Peer         string    `json:"peer,omitempty"`
+	Height       uint64    `json:"height"`
+	Hash         string    `json:"hash"`
+	TargetHeight uint64    `json:"target_height"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+// Wallet is a wallet created on the node.
+type Wallet struct {
+	Address   string `json:"address"`
+	PublicKey string `json:"public_key,omitempty"`
+	Reference string `json:"reference,omitempty"`
+}
+
+// Balance is an address's confirmed balance.
+type Balance struct {
+	Address   string  `json:"address"`
+	Balance   float64 `json:"balance"`
+	Staked    float64 `json:"staked"`
+	Pending   float64 `json:"pending"`   // rewards not yet mature
+	Unbonding float64 `json:"unbonding"` // unstaked, not yet released
+	Available float64 `json:"available"`
+
+	// Under the stake lock: the first height the stake may be unstaked
+	// at and the blocks left until then.
+	StakeUnlockHeight  uint64 `json:"stake_unlock_height,omitempty"`
+	StakeLockRemaining uint64 `json:"stake_lock_remaining,omitempty"`
+}
+
+// Unbond is an unstake waiting for its coins to be released.
+type Unbond struct {
+	TxID          string  `json:"txid"`
+	Address       string  `json:"address"`
+	Amount        float64 `json:"amount"`
+	Height        uint64  `json:"height"`
+	ReleaseHeight uint64  `json:"release_height"`
+}
+
+// RestakeStatus is a wallet's auto-restake policy. Pending is what has
+// been released or rewarded since the last restake.
+type RestakeStatus struct {
+	Address   string  `json:"address"`
+	Enabled   bool    `json:"enabled"`
+	Threshold float64 `json:"threshold"`
+	Pending   float64 `json:"pending"`
+}
+
+// Reward is one staking reward paid to an address.
+type Reward struct {
+	Height    uint64  `json:"height"`
+	TxID      string  `json:"txid"`
+	Amount    float64 `json:"amount"`
+	Timestamp int64   `json:"timestamp"`
+}
+
+// RewardPage is a page of an address's staking rewards. Total sums the
+// page; a non-zero Next is the fromHeight of the following page.
+type RewardPage struct {
+	Address string   `json:"address"`
+	Rewards []Reward `json:"rewards"`
+	Total   float64  `json:"total"`
+	Next    uint64   `json:"next"`
+}
+
+// SendResult is returned by Send.
+type SendResult struct {
+	TxID   string    `json:"txid"`
+	From   string    `json:"from"`
+	To     string    `json:"to"`
+	Amount float64   `json:"amount"`
+	Fee    float64   `json:"fee"`
+	Inputs []TxInput `json:"inputs"`
+	Status string    `json:"status"`
+}
+
+// AddressInfo is returned by ValidateAddress.
+type AddressInfo struct {
+	Address     string `json:"address"`
+	IsValid     bool   `json:"isvalid"`
+	Error       string `json:"error,omitempty"`
+	Format      string `json:"format,omitempty"` // checksummed, legacy or burn
+	Checksummed string `json:"checksummed,omitempty"`
+	IsMine      bool   `json:"ismine"`
+}
+
+// NonceInfo is returned by GetNonce.
+type NonceInfo struct {
+	Address   string `json:"address"`
+	Nonce     uint64 `json:"nonce"`      // highest confirmed nonce
+	NextNonce uint64 `json:"next_nonce"` // for the next transaction, counting pending ones
+}
+
+// UpgradeResult is returned by UpgradeAddress.
+type UpgradeResult struct {
+	Legacy  string  `json:"legacy"`
+	Address string  `json:"address"`
+	TxID    string  `json:"txid,omitempty"`
+	Amount  float64 `json:"amount,omitempty"`
+	Fee     float64 `json:"fee,omitempty"`
+	Status  string  `json:"status"`
+}
+
+// BroadcastState records how a pending transaction has been announced.
+type BroadcastState struct {
+	TxID           string      `json:"txid"`
+	ReceivedFrom   string      `json:"received_from,omitempty"`
+	Peers          []string    `json:"peers"`
+	Announcements  int         `json:"announcements"`
+	FirstBroadcast time.Time   `json:"first_broadcast"`
+	LastBroadcast  time.Time   `json:"last_broadcast"`
+	Rejections     []Rejection `json:"rejections,omitempty"`
+}
+
+// Rejection is a peer's refusal of a relayed transaction.
+type Rejection struct {
+	Peer   string `json:"peer"`
+	Code   string `json:"code"`
+	Reason string `json:"reason,omitempty"`
+}
+
+// Supply is the coin supply summary.
+type Supply struct {
+	Height      uint64  `json:"height"`
+	MaxSupply   float64 `json:"max_supply"`
+	TotalMinted float64 `json:"total_minted"`
+	TotalBurned float64 `json:"total_burned"`
+	Circulating float64 `json:"circulating"`
+	Staked      float64 `json:"staked"`
+	Unbonding   float64 `json:"unbonding"`
+	BurnAddress string  `json:"burn_address"`
+}
+
+// StakingInfo holds stake and PoS reward figures as of the tip.
+type StakingInfo struct {
+	Height         uint64           `json:"height"`
+	TotalStaked    float64          `json:"total_staked"`
+	EligibleStake  float64          `json:"eligible_stake"`
+	EligibleCount  int              `json:"eligible_stakers"`
+	MinStake       float64          `json:"min_stake"`
+	RewardPerBlock float64          `json:"reward_per_block"`
+	BlocksPerYear  uint64           `json:"blocks_per_year"`
+	Projection     *StakeProjection `json:"projection,omitempty"`
+	Earnings       *StakingEarnings `json:"earnings,omitempty"`
+}
+
+// StakeProjection estimates the yield of a new stake.
+type StakeProjection struct {
+	Amount         float64 `json:"amount"`
+	Eligible       bool    `json:"eligible"`
+	RewardPerBlock float64 `json:"reward_per_block"`
+	YearlyReward   float64 `json:"yearly_reward"`
+	APY            float64 `json:"apy"`
+}
+
+// StakingEarnings is an address's PoS reward history.
+type StakingEarnings struct {
+	Address    string  `json:"address"`
+	Total      float64 `json:"total"`
+	Blocks     int     `json:"blocks"`
+	LastHeight uint64  `json:"last_height"`
+}
+
+// ValidatorStats is an address's block production and staking record.
+type ValidatorStats struct {
+	Address         string  `json:"address"`
+	Stake           float64 `json:"stake"`
+	Eligible        bool    `json:"eligible"`
+	StakeShare      float64 `json:"stake_share"`
+	Validator       string  `json:"validator,omitempty"`
+	Delegated       float64 `json:"delegated"`
+	Delegators      int     `json:"delegators"`
+	BlocksProduced  int     `json:"blocks_produced"`
+	LastProduced    uint64  `json:"last_produced"`
+	ProducerRewards float64 `json:"producer_rewards"`
+	RewardedBlocks  int     `json:"rewarded_blocks"`
+	LastRewarded    uint64  `json:"last_rewarded"`
+	StakingRewards  float64 `json:"staking_rewards"`
+	Window          uint64  `json:"window"`
+	WindowProduced  int     `json:"window_produced"`
+	WindowRewarded  int     `json:"window_rewarded"`
+	Participation   float64 `json:"participation"`
+}
+
+// Finality is the state of checkpoint finality at the tip.
+type Finality struct {
+	Interval        uint64      `json:"interval"`
+	FinalizedHeight uint64      `json:"finalized_height"`
+	FinalizedHash   string      `json:"finalized_hash,omitempty"`
+	Latest          *Checkpoint `json:"latest,omitempty"`
+}
+
+// Checkpoint is a block the stakers are asked to finalize, with the
+// attestations collected so far.
+type Checkpoint struct {
+	Height      uint64             `json:"height"`
+	Hash        string             `json:"hash"`
+	Weights     map[string]float64 `json:"weights"`
+	TotalStake  float64            `json:"total_stake"`
+	SignedStake float64            `json:"signed_stake"`
+	Signatures  map[string]string  `json:"signatures"`
+	Finalized   bool               `json:"finalized"`
+	FinalizedAt int64              `json:"finalized_at,omitempty"`
+}
+
+// Attestation is one staker's signature over a checkpoint.
+type Attestation struct {
+	Height    uint64 `json:"height"`
+	Hash      string `json:"hash"`
+	Address   string `json:"address"`
+	Signature string `json:"signature"`
+}
+
+// Evidence is two attestations by one address for different blocks at the
+// same checkpoint height.
+type Evidence struct {
+	First  Attestation `json:"first"`
+	Second Attestation `json:"second"`
+}
+
+// EvidenceResult is the outcome of SubmitEvidence. Added is false when the
+// node already had the evidence.
+type EvidenceResult struct {
+	Address string `json:"address"`
+	Height  uint64 `json:"height"`
+	Added   bool   `json:"added"`
+}
+
+// RetargetPoint is the difficulty of one retarget interval.
+type RetargetPoint struct {
+	Height       uint64  `json:"height"`
+	Bits         uint32  `json:"bits"`
+	Difficulty   float64 `json:"difficulty"`
+	AtFloor      bool    `json:"at_floor"`
+	AvgBlockTime float64 `json:"avg_block_time"`
+}
+
+// FloorEpoch is one step of the progressive difficulty floor.
+type FloorEpoch struct {
+	Epoch           uint64  `json:"epoch"`
+	StartHeight     uint64  `json:"start_height"`
+	FloorBits       uint32  `json:"floor_bits"`
+	FloorDifficulty float64 `json:"floor_difficulty"`
+	BlocksUntil     uint64  `json:"blocks_until"`
+	ETA             int64   `json:"eta"`
+}
+
+// DifficultyHistory reports past retargets and the upcoming floor schedule.
+type DifficultyHistory struct {
+	Height             uint64          `json:"height"`
+	Bits               uint32          `json:"bits"`
+	Difficulty         float64         `json:"difficulty"`
+	NextBits           uint32          `json:"next_bits"`
+	NextDifficulty     float64         `json:"next_difficulty"`
+	AdjustInterval     uint64          `json:"adjust_interval"`
+	NextRetargetHeight uint64          `json:"next_retarget_height"`
+	Retargets          []RetargetPoint `json:"retargets"`
+	FloorSchedule      []FloorEpoch    `json:"floor_schedule"`
+}
+
+// DayStats aggregates the blocks of one UTC day.
+type DayStats struct {
+	Date         string  `json:"date"`
+	Blocks       int     `json:"blocks"`
+	Transactions int     `json:"transactions"`
+	Volume       float64 `json:"volume"`
+	Fees         float64 `json:"fees"`
+	Minted       float64 `json:"minted"`
+	NewAddresses int     `json:"new_addresses"`
+}
+
+// AddressTx is one transaction in an address's history.
+type AddressTx struct {
+	Height uint64 `json:"height"`
+	Index  uint32 `json:"index"`
+	TxID   string `json:"txid"`
+}
+
+// BalancePoint is an address's balance after a block that changed it.
+type BalancePoint struct {
+	Height  uint64  `json:"height"`
+	Balance float64 `json:"balance"`
+}
+
+// HistoricalBalance is an address's balance after a past block.
+type HistoricalBalance struct {
+	Address   string  `json:"address"`
+	Height    uint64  `json:"height"`
+	Balance   float64 `json:"balance"`
+	ChangedAt uint64  `json:"changed_at"` // last block at or below Height that changed it
+}
+
+// RichEntry is one address in the rich list.
+type RichEntry struct {
+	Rank    int     `json:"rank"`
+	Address string  `json:"address"`
+	Balance float64 `json:"balance"`
+}
+
+// BlockFilter is a block's address filter.
+type BlockFilter struct {
+	Height uint64 `json:"height"`
+	Hash   string `json:"hash"`
+	Hashes int    `json:"hashes"`
+	Filter string `json:"filter"`
+}
+
+// IndexStatus reports how far an optional index has been built.
+type IndexStatus struct {
+	Synced      bool    `json:"synced"`
+	Height      int64   `json:"best_block_height"`
+	ChainHeight int64   `json:"chain_height"`
+	Progress    float64 `json:"progress"`
+	StartedAt   int64   `json:"started_at,omitempty"`
+	Error       string  `json:"error,omitempty"`
+}
+
+// JournalEntry is one event from the node's event journal.
+type JournalEntry struct {
+	Seq    uint64       `json:"seq"`
+	Time   int64        `json:"time"`
+	Type   string       `json:"type"` // block_connected, tx_added or tx_removed
+	Block  *Block       `json:"block,omitempty"`
+	Tx     *Transaction `json:"tx,omitempty"`
+	Reason string       `json:"reason,omitempty"` // for tx_removed: conflict or expired
+}
+
+// JournalPage is a run of journal entries.
+type JournalPage struct {
+	Entries []JournalEntry `json:"entries"`
+	Next    uint64         `json:"next"` // seq to request next
+	Last    uint64         `json:"last"` // seq of the newest entry
+}
+
+// Deposit is the data of a "deposit" notification.
+type Deposit struct {
+	TxID          string  `json:"txid"`
+	Address       string  `json:"address"`
+	Reference     string  `json:"reference"`
+	Amount        float64 `json:"amount"`
+	Status        string  `json:"status"` // pending or confirmed
+	Confirmations int64   `json:"confirmations"`
+	BlockHash     string  `json:"block_hash,omitempty"`
+	BlockHeight   uint64  `json:"block_height,omitempty"`
+}
+
+// TxActivity is the data of a "tx" notification, sent to subscriptions
+// watching an address the transaction touches.
+type TxActivity struct {
+	Status      string       `json:"status"` // pending or confirmed
+	Addresses   []string     `json:"addresses"`
+	BlockHash   string       `json:"block_hash,omitempty"`
+	BlockHeight uint64       `json:"block_height,omitempty"`
+	Tx          *Transaction `json:"tx"`
+}
+
+// BlockActivity is the data of a "block" notification, sent to every
+// subscription that watches addresses.
+type BlockActivity struct {
+	Status string `json:"status"` // always connected
+	Hash   string `json:"hash"`
+	Height uint64 `json:"height"`
+}
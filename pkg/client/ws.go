@@ -0,0 +1,238 @@
+package client
+
+import (
+	"bufio"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+// wsGUID is the RFC 6455 handshake constant.
+const wsGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// Event is a notification from the node's /api/ws stream, in the same
+// shape as a webhook delivery.
+type Event struct {
+	Event     string          `json:"event"`
+	Timestamp int64           `json:"timestamp"`
+	Data      json.RawMessage `json:"data"`
+}
+
+// Deposit decodes the data of a "deposit" event.
+func (e Event) Deposit() (Deposit, error) {
+	var d Deposit
+	if e.Event != "deposit" {
+		return d, fmt.Errorf("event is %q, not deposit", e.Event)
+	}
+	err := json.Unmarshal(e.Data, &d)
+	return d, err
+}
+
+// TxActivity decodes the data of a "tx" event.
+func (e Event) TxActivity() (TxActivity, error) {
+	var a TxActivity
+	if e.Event != "tx" {
+		return a, fmt.Errorf("event is %q, not tx", e.Event)
+	}
+	err := json.Unmarshal(e.Data, &a)
+	return a, err
+}
+
+// BlockActivity decodes the data of a "block" event.
+func (e Event) BlockActivity() (BlockActivity, error) {
+	var b BlockActivity
+	if e.Event != "block" {
+		return b, fmt.Errorf("event is %q, not block", e.Event)
+	}
+	err := json.Unmarshal(e.Data, &b)
+	return b, err
+}
+
+// Subscription is an open notification stream. Events is closed when the
+// connection ends; Err then reports why.
+type Subscription struct {
+	Events <-chan Event
+
+	conn net.Conn
+	mu   sync.Mutex
+	err  error
+	once sync.Once
+}
+
+// Subscribe opens the node's websocket notification stream.
+func (c *Client) Subscribe() (*Subscription, error) {
+	return c.subscribe("/api/ws")
+}
+
+// SubscribeAddresses opens a notification stream filtered on addresses: it
+// carries only tx and deposit events touching them, and block events.
+func (c *Client) SubscribeAddresses(addresses ...string) (*Subscription, error) {
+	return c.subscribe("/api/ws?addresses=" + url.QueryEscape(strings.Join(addresses, ",")))
+}
+
+func (c *Client) subscribe(path string) (*Subscription, error) {
+	u, err := url.Parse(c.BaseURL)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "http" {
+		return nil, fmt.Errorf("subscribe: unsupported scheme %q", u.Scheme)
+	}
+	conn, err := net.Dial("tcp", u.Host)
+	if err != nil {
+		return nil, err
+	}
+
+	var nonce [16]byte
+	rand.Read(nonce[:])
+	key := base64.StdEncoding.EncodeToString(nonce[:])
+	fmt.Fprintf(conn, "GET %s HTTP/1.1\r\nHost: %s\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n"+
+		"Sec-WebSocket-Key: %s\r\nSec-WebSocket-Version: 13\r\n\r\n", path, u.Host, key)
+
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, nil)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	sum := sha1.Sum([]byte(key + wsGUID))
+	if resp.StatusCode != http.StatusSwitchingProtocols ||
+		resp.Header.Get("Sec-WebSocket-Accept") != base64.StdEncoding.EncodeToString(sum[:]) {
+		conn.Close()
+		return nil, fmt.Errorf("subscribe: websocket upgrade refused (%s)", resp.Status)
+	}
+
+	events := make(chan Event, 64)
+	s := &Subscription{Events: events, conn: conn}
+	go s.read(br, events)
+	return s, nil
+}
+
+// Close ends the subscription.
+func (s *Subscription) Close() error {
+	var err error
+	s.once.Do(func() {
+		s.write(0x8, nil)
+		err = s.conn.Close()
+	})
+	return err
+}
+
+// Err returns the error that ended the stream, or nil after Close.
+func (s *Subscription) Err() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.err
+}
+
+func (s *Subscription) read(r *bufio.Reader, events chan<- Event) {
+	defer close(events)
+	var msg []byte
+	for {
+		fin, opcode, payload, err := readFrame(r)
+		if err != nil {
+			s.mu.Lock()
+			if s.err == nil {
+				s.err = err
+			}
+			s.mu.Unlock()
+			return
+		}
+		switch opcode {
+		case 0x8: // close
+			return
+		case 0x9: // ping
+			s.write(0xA, payload)
+			continue
+		case 0xA: // pong
+			continue
+		}
+		msg = append(msg, payload...)
+		if !fin {
+			continue
+		}
+		var ev Event
+		if json.Unmarshal(msg, &ev) == nil {
+			events <- ev
+		}
+		msg = nil
+	}
+}
+
+// write sends a masked client frame.
+func (s *Subscription) write(opcode byte, payload []byte) error {
+	hdr := []byte{0x80 | opcode}
+	switch n := len(payload); {
+	case n < 126:
+		hdr = append(hdr, 0x80|byte(n))
+	case n <= 0xFFFF:
+		hdr = append(hdr, 0x80|126, byte(n>>8), byte(n))
+	default:
+		hdr = append(hdr, 0x80|127)
+		hdr = binary.BigEndian.AppendUint64(hdr, uint64(n))
+	}
+	var mask [4]byte
+	rand.Read(mask[:])
+	hdr = append(hdr, mask[:]...)
+	for i, b := range payload {
+		hdr = append(hdr, b^mask[i%4])
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, err := s.conn.Write(hdr)
+	return err
+}
+
+// readFrame reads one server frame.
+func readFrame(r *bufio.Reader) (fin bool, opcode byte, payload []byte, err error) {
+	var hdr [2]byte
+	if _, err = io.ReadFull(r, hdr[:]); err != nil {
+		return
+	}
+	fin, opcode = hdr[0]&0x80 != 0, hdr[0]&0x0F
+	n := uint64(hdr[1] & 0x7F)
+	switch n {
+	case 126:
+		var b [2]byte
+		if _, err = io.ReadFull(r, b[:]); err != nil {
+			return
+		}
+		n = uint64(binary.BigEndian.Uint16(b[:]))
+	case 127:
+		var b [8]byte
+		if _, err = io.ReadFull(r, b[:]); err != nil {
+			return
+		}
+		n = binary.BigEndian.Uint64(b[:])
+	}
+	if n > 16<<20 {
+		err = fmt.Errorf("websocket frame too large: %d bytes", n)
+		return
+	}
+	var mask [4]byte
+	masked := hdr[1]&0x80 != 0
+	if masked {
+		if _, err = io.ReadFull(r, mask[:]); err != nil {
+			return
+		}
+	}
+	payload = make([]byte, n)
+	if _, err = io.ReadFull(r, payload); err != nil {
+		return
+	}
+	if masked {
+		for i := range payload {
+			payload[i] ^= mask[i%4]
+		}
+	}
+	return
+}
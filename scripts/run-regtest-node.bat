@@ -0,0 +1,7 @@
+@echo off
+REM DevInsiderCoin — Start Regtest Node (Windows)
+cd /d "%~dp0\.."
+
+go build -o dvcnode.exe ./cmd/dvcnode
+
+dvcnode.exe --network regtest --datadir .\data\regtest %*
@@ -0,0 +1,11 @@
+#!/bin/bash
+# DevInsiderCoin — Start Regtest Node
+SCRIPT_DIR="$(cd "$(dirname "$0")" && pwd)"
+cd "$SCRIPT_DIR/.."
+
+go build -o dvcnode ./cmd/dvcnode 2>/dev/null
+
+./dvcnode \
+  --network regtest \
+  --datadir ./data/regtest \
+  "$@"